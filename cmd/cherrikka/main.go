@@ -1,33 +1,83 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
+	_ "time/tzdata" // --time-zone works without a system zoneinfo
 
 	"cherrikka/internal/app"
+	"cherrikka/internal/util"
 	"cherrikka/internal/web"
 )
 
+// jsonOutput holds the global flags given before the command; they apply to every
+// JSON result printed by printJSON.
+var jsonOutput struct {
+	compact bool
+	path    string
+}
+
 func main() {
-	if len(os.Args) < 2 {
+	global := flag.NewFlagSet("cherrikka", flag.ExitOnError)
+	global.Usage = printUsage
+	global.BoolFunc("compact", "print JSON results on a single line", func(v string) error {
+		on, err := strconv.ParseBool(v)
+		jsonOutput.compact = on
+		return err
+	})
+	global.BoolFunc("pretty", "print indented JSON results (default)", func(v string) error {
+		on, err := strconv.ParseBool(v)
+		jsonOutput.compact = !on
+		return err
+	})
+	global.StringVar(&jsonOutput.path, "output", "", "write the JSON result to this file instead of stdout")
+	_ = global.Parse(os.Args[1:])
+	args := global.Args()
+	if len(args) < 1 {
 		printUsage()
 		os.Exit(2)
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "inspect":
-		runInspect(os.Args[2:])
+		runInspect(args[1:])
 	case "validate":
-		runValidate(os.Args[2:])
+		runValidate(args[1:])
 	case "convert":
-		runConvert(os.Args[2:])
+		runConvert(args[1:])
+	case "slice":
+		runSlice(args[1:])
 	case "serve":
-		runServe(os.Args[2:])
+		runServe(args[1:])
+	case "schema":
+		runSchema(args[1:])
+	case "check":
+		runCheck(args[1:])
+	case "manifest":
+		runManifest(args[1:])
+	case "verify-integrity":
+		runVerifyIntegrity(args[1:])
+	case "check-providers":
+		runCheckProviders(args[1:])
+	case "diff":
+		runDiff(args[1:])
+	case "stats":
+		runStats(args[1:])
+	case "extract-files":
+		runExtractFiles(args[1:])
+	case "explain":
+		runExplain(args[1:])
+	case "version":
+		printJSON(app.CurrentBuildInfo())
 	default:
-		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
 		printUsage()
 		os.Exit(2)
 	}
@@ -35,12 +85,14 @@ func main() {
 
 func runInspect(args []string) {
 	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
-	input := fs.String("input", "", "input backup zip")
+	input := fs.String("input", "", "input backup zip or unpacked backup directory")
+	from := fs.String("from", "auto", "assume source format when detection fails: auto|cherry|rikka")
+	files := fs.Bool("files", false, "list every attachment with its size, hash and orphan/missing state")
 	_ = fs.Parse(args)
 	if *input == "" {
 		die("--input is required")
 	}
-	res, err := app.Inspect(*input)
+	res, err := app.InspectWithOptions(*input, app.InspectOptions{AssumeFormat: *from, FileDetails: *files})
 	if err != nil {
 		die(err.Error())
 	}
@@ -49,12 +101,14 @@ func runInspect(args []string) {
 
 func runValidate(args []string) {
 	fs := flag.NewFlagSet("validate", flag.ExitOnError)
-	input := fs.String("input", "", "input backup zip")
+	input := fs.String("input", "", "input backup zip or unpacked backup directory")
+	verifyHashes := fs.Bool("verify-hashes", false, "recompute payload SHA256 and flag mismatches")
+	from := fs.String("from", "auto", "assume source format when detection fails: auto|cherry|rikka")
 	_ = fs.Parse(args)
 	if *input == "" {
 		die("--input is required")
 	}
-	res, err := app.Validate(*input)
+	res, err := app.ValidateWithOptions(*input, app.ValidateOptions{VerifyHashes: *verifyHashes, AssumeFormat: *from})
 	if err != nil {
 		die(err.Error())
 	}
@@ -64,30 +118,227 @@ func runValidate(args []string) {
 func runConvert(args []string) {
 	fs := flag.NewFlagSet("convert", flag.ExitOnError)
 	var inputs multiStringFlag
-	fs.Var(&inputs, "input", "input backup zip (repeatable)")
+	fs.Var(&inputs, "input", "input backup zip or unpacked backup directory (repeatable)")
+	var binds multiStringFlag
+	fs.Var(&binds, "bind", "rebind a conversation to an assistant by id or name, e.g. <conversationId>=<assistant> (repeatable)")
+	var includeConversations multiStringFlag
+	fs.Var(&includeConversations, "include-conversation", "convert only conversations with this id or a title containing it, case-insensitive (repeatable)")
+	var excludeConversations multiStringFlag
+	fs.Var(&excludeConversations, "exclude-conversation", "skip conversations with this id or a title containing it, case-insensitive (repeatable)")
+	var inputURLs multiStringFlag
+	fs.Var(&inputURLs, "input-url", "http(s) url of a backup zip to download and convert (repeatable)")
+	var sourceNames multiStringFlag
+	fs.Var(&sourceNames, "source-name", "merge tag for the matching --input/--input-url, used in renamed assistants (repeatable, default the file name)")
+	maxDownloadSize := fs.Int64("max-download-size", 1<<30, "refuse --input-url downloads larger than this many bytes")
+	maxExtractBytes := fs.Int64("max-extract-bytes", 0, "abort when an input zip decompresses to more than this many bytes (0 disables)")
+	downloadTimeout := fs.Duration("download-timeout", 5*time.Minute, "timeout for each --input-url download")
 	output := fs.String("output", "", "output backup zip")
 	from := fs.String("from", "auto", "source format: auto|cherry|rikka")
-	to := fs.String("to", "", "target format: cherry|rikka")
+	to := fs.String("to", "", "target format: cherry|rikka, or ir to write the parsed intermediate representation as JSON")
 	template := fs.String("template", "", "target template backup zip")
 	redact := fs.Bool("redact-secrets", false, "redact secret fields")
+	redactExtraKeys := fs.String("redact-extra-keys", "", "comma-separated extra key tokens to redact with --redact-secrets, e.g. bearer,x_auth")
+	externalizeSecrets := fs.Bool("externalize-secrets", false, "move secrets into a separate keyfile and leave placeholders in the backup")
+	secretsFile := fs.String("secrets-file", "", "keyfile written by --externalize-secrets (default <output>.secrets.json), or read to restore placeholders")
 	configPrecedence := fs.String("config-precedence", "latest", "config precedence for multi-input merge: latest|first|target|source")
 	configSourceIndex := fs.Int("config-source-index", 0, "1-based source index when --config-precedence=source")
+	var settingsFrom multiStringFlag
+	fs.Var(&settingsFrom, "settings-from", "pin a settings key or group to a 1-based source, e.g. sync.webdav=2 (repeatable)")
+	dedupeFiles := fs.Bool("dedupe-files", false, "when merging, keep one copy of attachments with the same SHA256 across sources")
+	contentAddressedFiles := fs.Bool("content-addressed-files", false, "cherry output: name attachment payloads by SHA256 so identical attachments are stored once")
+	var setKeys multiStringFlag
+	fs.Var(&setKeys, "set-key", "replace a provider's API key, matched by id or name, e.g. openai=sk-... (repeatable)")
+	defaultAssistantName := fs.String("default-assistant-name", "", "name of the assistant synthesized for backups without assistants")
+	defaultAssistantPrompt := fs.String("default-assistant-prompt", "", "system prompt of the assistant synthesized for backups without assistants")
+	defaultProvider := fs.String("default-provider", "", "provider type added for backups without provider models, e.g. openai|anthropic|gemini (default openai)")
+	defaultModel := fs.String("default-model", "", "chat model added with --default-provider for backups without provider models")
+	userName := fs.String("user-name", "", "override the target user profile name")
+	userID := fs.String("user-id", "", "override the target user profile id")
+	limitMediaSize := fs.Int64("limit-media-size", 0, "drop attachments larger than this many bytes (0 keeps all)")
+	noEmptyPlaceholder := fs.Bool("no-empty-placeholder", false, "omit files with missing payloads and note them in messages instead of writing empty placeholders")
+	excludeArchived := fs.Bool("exclude-archived", false, "leave archived/hidden conversations out of the output")
+	flattenBranches := fs.Bool("flatten-branches", false, "keep only the selected branch of each rikka message node and drop the alternates")
+	stripReasoning := fs.Bool("strip-reasoning", false, "drop reasoning (chain-of-thought) parts from messages")
+	titleFrom := fs.String("title-from", "keep", "conversation title strategy: keep|first-user|first-message|first-assistant")
+	pruneUnusedModels := fs.Bool("prune-unused-models", false, "drop provider models not used by any assistant or model selection")
+	dropEmptyMessages := fs.Bool("drop-empty-messages", true, "drop blank placeholder messages (use --drop-empty-messages=false to keep them)")
+	dryRun := fs.Bool("dry-run", false, "run the whole conversion but write nothing; print the manifest with counts of what would be written")
+	verifyOutput := fs.Bool("verify-output", true, "validate the built output before it replaces --output, failing if it does not pass (use --verify-output=false to skip)")
+	tempDir := fs.String("temp-dir", "", "directory for extraction and build temp files (default $CHERRIKKA_TMPDIR, then the OS temp)")
+	timeZone := fs.String("time-zone", "", "zone for human-facing times such as README.txt and {date}: IANA name or +hh:mm (data stays UTC)")
+	since := fs.String("since", "", "drop conversations last updated before this RFC3339 time, e.g. 2024-01-01T00:00:00Z")
+	until := fs.String("until", "", "drop conversations last updated after this RFC3339 time")
+	concurrency := fs.Int("concurrency", runtime.GOMAXPROCS(0), "number of inputs extracted and parsed at once when merging")
+	irCache := fs.String("ir-cache", "", "directory caching parsed sources by SHA256 so repeated conversions skip the parse")
+	summaryOnly := fs.Bool("summary-only", false, "leave the id map out of the manifest to keep it small")
+	noRawSidecar := fs.Bool("no-raw-sidecar", false, "skip the cherrikka/raw/ copies of the input zips (smaller output, no rehydration on the way back)")
+	reportUnmapped := fs.Bool("report-unmapped", false, "list every source config key not carried to the target in the manifest")
+	allowSameFormat := fs.Bool("allow-same-format", false, "allow converting a backup to its own format (lossy round trip)")
+	integrityManifest := fs.Bool("integrity-manifest", false, "write cherrikka/files.sha256 listing every output file's SHA256")
+	embedReadme := fs.Bool("embed-readme", false, "write a human-readable cherrikka/README.txt into the output")
+	inputDir := fs.String("input-dir", "", "convert every backup zip in this directory independently")
+	outputDir := fs.String("output-dir", "", "output directory for --input-dir or --no-merge conversion")
+	noMerge := fs.Bool("no-merge", false, "convert each --input independently into --output-dir instead of merging")
+	outputTemplate := fs.String("output-template", "", "batch output file name template: {name} {from} {to} {date} {index}")
 	_ = fs.Parse(args)
+	settingsOverrides, err := app.ParseSettingsOverrides(settingsFrom)
+	if err != nil {
+		die(err.Error())
+	}
+	keyOverrides, err := app.ParseProviderKeyOverrides(setKeys)
+	if err != nil {
+		die(err.Error())
+	}
+	conversationAssistant, err := app.ParseConversationAssistants(binds)
+	if err != nil {
+		die(err.Error())
+	}
+	sinceMillis, err := parseRFC3339Millis("--since", *since)
+	if err != nil {
+		die(err.Error())
+	}
+	untilMillis, err := parseRFC3339Millis("--until", *until)
+	if err != nil {
+		die(err.Error())
+	}
 
-	if len(inputs) == 0 || *output == "" || *to == "" {
-		die("--input, --output, --to are required")
+	convertOpts := app.ConvertOptions{
+		OutputPath:            *output,
+		From:                  *from,
+		To:                    *to,
+		TemplatePath:          *template,
+		RedactSecrets:         *redact,
+		RedactConfig:          util.RedactConfig{ExtraTokens: splitCommaList(*redactExtraKeys)},
+		ConfigPrecedence:      *configPrecedence,
+		ConfigSourceIndex:     *configSourceIndex,
+		SettingsOverrides:     settingsOverrides,
+		DedupeFiles:           *dedupeFiles,
+		ContentAddressedFiles: *contentAddressedFiles,
+		ProviderKeyOverrides:  keyOverrides,
+		ConversationAssistant: conversationAssistant,
+		UserName:              *userName,
+		UserID:                *userID,
+		DefaultAssistant:      app.DefaultAssistantOptions{Name: *defaultAssistantName, Prompt: *defaultAssistantPrompt},
+		DefaultModel:          app.DefaultModelOptions{Provider: *defaultProvider, Model: *defaultModel},
+		MaxFileBytes:          *limitMediaSize,
+		SkipMissingFiles:      *noEmptyPlaceholder,
+		EmbedReadme:           *embedReadme,
+		IntegrityManifest:     *integrityManifest,
+		ExcludeArchived:       *excludeArchived,
+		StripReasoning:        *stripReasoning,
+		FlattenBranches:       *flattenBranches,
+		KeepEmptyMessages:     !*dropEmptyMessages,
+		SkipVerifyOutput:      !*verifyOutput,
+		DryRun:                *dryRun,
+		PruneUnusedModels:     *pruneUnusedModels,
+		TitleStrategy:         *titleFrom,
+		AllowSameFormat:       *allowSameFormat,
+		ReportUnmapped:        *reportUnmapped,
+		OmitIDMap:             *summaryOnly,
+		OmitRawSources:        *noRawSidecar,
+		IRCacheDir:            *irCache,
+		Concurrency:           *concurrency,
+		TimeZone:              *timeZone,
+		IncludeConversations:  []string(includeConversations),
+		ExcludeConversations:  []string(excludeConversations),
+		SinceUnixMillis:       sinceMillis,
+		UntilUnixMillis:       untilMillis,
+		TempDir:               *tempDir,
+		MaxDownloadBytes:      *maxDownloadSize,
+		ExtractLimits:         app.ExtractLimits{MaxBytes: *maxExtractBytes},
+		DownloadTimeout:       *downloadTimeout,
+		ExternalizeSecrets:    *externalizeSecrets,
+		SecretsPath:           *secretsFile,
 	}
+	if *inputDir != "" || *noMerge {
+		if *noMerge && *inputDir == "" && len(inputs) == 0 {
+			die("--no-merge requires at least one --input")
+		}
+		if *outputDir == "" || *to == "" {
+			die("--output-dir, --to are required for --input-dir/--no-merge")
+		}
+		results, err := app.ConvertBatch(app.BatchConvertOptions{
+			InputDir:       *inputDir,
+			InputPaths:     []string(inputs),
+			OutputDir:      *outputDir,
+			OutputTemplate: *outputTemplate,
+			Convert:        convertOpts,
+		})
+		if err != nil {
+			die(err.Error())
+		}
+		ok := true
+		for _, res := range results {
+			if res.Error != "" {
+				ok = false
+			}
+		}
+		printJSON(map[string]any{
+			"ok":      ok,
+			"outputs": results,
+		})
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(inputs)+len(inputURLs) == 0 || (*output == "" && !*dryRun) || *to == "" {
+		die("--input (or --input-url), --output, --to are required")
+	}
+
+	convertOpts.InputPaths = []string(inputs)
+	convertOpts.InputURLs = []string(inputURLs)
+	convertOpts.SourceNames = []string(sourceNames)
+	manifest, err := app.Convert(convertOpts)
+	if err != nil {
+		die(err.Error())
+	}
+	if *dryRun {
+		printJSON(map[string]any{
+			"ok":       true,
+			"dryRun":   true,
+			"manifest": manifest,
+		})
+		return
+	}
+	printJSON(map[string]any{
+		"ok":       true,
+		"output":   *output,
+		"manifest": manifest,
+	})
+}
 
+func runSlice(args []string) {
+	fs := flag.NewFlagSet("slice", flag.ExitOnError)
+	input := fs.String("input", "", "input backup zip")
+	output := fs.String("output", "", "output backup zip")
+	from := fs.String("from", "auto", "source format: auto|cherry|rikka")
+	to := fs.String("to", "", "target format: cherry|rikka (may equal the source format)")
+	fromDate := fs.String("from-date", "", "keep conversations last active on or after this day (YYYY-MM-DD)")
+	toDate := fs.String("to-date", "", "keep conversations last active on or before this day (YYYY-MM-DD)")
+	timeZone := fs.String("time-zone", "", "zone the dates are read in: IANA name or +hh:mm (default UTC)")
+	redact := fs.Bool("redact-secrets", false, "redact secret fields")
+	tempDir := fs.String("temp-dir", "", "directory for extraction and build temp files (default $CHERRIKKA_TMPDIR, then the OS temp)")
+	_ = fs.Parse(args)
+	if *input == "" || *output == "" || *to == "" {
+		die("--input, --output, --to are required")
+	}
+	if *fromDate == "" && *toDate == "" {
+		die("--from-date or --to-date is required")
+	}
 	manifest, err := app.Convert(app.ConvertOptions{
-		InputPath:         inputs[0],
-		InputPaths:        []string(inputs),
-		OutputPath:        *output,
-		From:              *from,
-		To:                *to,
-		TemplatePath:      *template,
-		RedactSecrets:     *redact,
-		ConfigPrecedence:  *configPrecedence,
-		ConfigSourceIndex: *configSourceIndex,
+		InputPaths:    []string{*input},
+		OutputPath:    *output,
+		From:          *from,
+		To:            *to,
+		RedactSecrets: *redact,
+		FromDate:      *fromDate,
+		ToDate:        *toDate,
+		TimeZone:      *timeZone,
+		TempDir:       *tempDir,
+		// slicing a backup into smaller backups of the same app is the common case
+		AllowSameFormat: true,
 	})
 	if err != nil {
 		die(err.Error())
@@ -102,15 +353,170 @@ func runConvert(args []string) {
 func runServe(args []string) {
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
 	listen := fs.String("listen", "127.0.0.1:7788", "listen address")
+	var corsOrigins multiStringFlag
+	fs.Var(&corsOrigins, "cors-origin", "allowed CORS origin (repeatable, default *)")
+	maxExtractBytes := fs.Int64("max-extract-bytes", 4<<30, "reject uploads that decompress to more than this many bytes")
 	_ = fs.Parse(args)
-	if err := web.Serve(*listen); err != nil {
+	if err := web.Serve(web.ServeOptions{Listen: *listen, AllowedOrigins: corsOrigins, MaxExtractBytes: *maxExtractBytes}); err != nil {
 		die(err.Error())
 	}
 }
 
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	input := fs.String("input", "", "input backup zip")
+	to := fs.String("to", "", "target format: cherry|rikka")
+	_ = fs.Parse(args)
+	if *input == "" || *to == "" {
+		die("--input, --to are required")
+	}
+	res, err := app.CheckCompatibility(*input, *to)
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(res)
+}
+
+func runCheckProviders(args []string) {
+	fs := flag.NewFlagSet("check-providers", flag.ExitOnError)
+	input := fs.String("input", "", "input backup zip")
+	live := fs.Bool("live", false, "send a models-list request to each provider (makes network calls)")
+	timeout := fs.Duration("timeout", 15*time.Second, "per-provider request timeout")
+	_ = fs.Parse(args)
+	if *input == "" {
+		die("--input is required")
+	}
+	res, err := app.CheckProviders(context.Background(), *input, app.ProviderCheckOptions{Live: *live, Timeout: *timeout})
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(res)
+	if res.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	left := fs.String("left", "", "first backup zip, e.g. the original")
+	right := fs.String("right", "", "second backup zip, e.g. the round-tripped conversion")
+	_ = fs.Parse(args)
+	if *left == "" || *right == "" {
+		die("--left, --right are required")
+	}
+	res, err := app.DiffBackups(context.Background(), *left, *right)
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(res)
+}
+
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	input := fs.String("input", "", "backup zip")
+	_ = fs.Parse(args)
+	if *input == "" {
+		die("--input is required")
+	}
+	res, err := app.StatsBackup(context.Background(), *input)
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(res)
+}
+
+func runExtractFiles(args []string) {
+	fs := flag.NewFlagSet("extract-files", flag.ExitOnError)
+	input := fs.String("input", "", "input backup zip or unpacked backup directory")
+	output := fs.String("output", "", "directory the attachments are written to")
+	_ = fs.Parse(args)
+	if *input == "" || *output == "" {
+		die("--input and --output are required")
+	}
+	res, err := app.ExtractFiles(context.Background(), *input, *output)
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(res)
+}
+
+func runManifest(args []string) {
+	fs := flag.NewFlagSet("manifest", flag.ExitOnError)
+	input := fs.String("input", "", "converted backup zip")
+	_ = fs.Parse(args)
+	if *input == "" {
+		die("--input is required")
+	}
+	manifest, err := app.ReadManifest(*input)
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(manifest)
+}
+
+func runVerifyIntegrity(args []string) {
+	fs := flag.NewFlagSet("verify-integrity", flag.ExitOnError)
+	input := fs.String("input", "", "converted backup zip")
+	_ = fs.Parse(args)
+	if *input == "" {
+		die("--input is required")
+	}
+	res, err := app.VerifyIntegrity(*input)
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(res)
+	if !res.Valid {
+		os.Exit(1)
+	}
+}
+
+func runExplain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the explanation as JSON")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		die("usage: cherrikka explain <warning-code>")
+	}
+	exp, ok := app.ExplainWarning(fs.Arg(0))
+	if !ok {
+		die("unknown warning code: " + fs.Arg(0))
+	}
+	if *asJSON {
+		printJSON(exp)
+		return
+	}
+	fmt.Printf("%s\n\nWhat it means:\n  %s\n\nHow to fix:\n  %s\n", exp.Code, exp.Meaning, exp.Fix)
+}
+
+func runSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	kind := fs.String("type", "", "schema type: manifest|inspect|validate")
+	_ = fs.Parse(args)
+	if *kind == "" {
+		die("--type is required")
+	}
+	schema, err := app.Schema(*kind)
+	if err != nil {
+		die(err.Error())
+	}
+	printJSON(schema)
+}
+
 func printJSON(v any) {
-	b, _ := json.MarshalIndent(v, "", "  ")
-	fmt.Println(string(b))
+	var b []byte
+	if jsonOutput.compact {
+		b, _ = json.Marshal(v)
+	} else {
+		b, _ = json.MarshalIndent(v, "", "  ")
+	}
+	if jsonOutput.path == "" {
+		fmt.Println(string(b))
+		return
+	}
+	if err := os.WriteFile(jsonOutput.path, append(b, '\n'), 0o644); err != nil {
+		die(err.Error())
+	}
 }
 
 func die(msg string) {
@@ -119,12 +525,50 @@ func die(msg string) {
 }
 
 func printUsage() {
-	fmt.Println(`cherrikka commands:
+	fmt.Println(`cherrikka [--compact|--pretty] [--output <result.json>] <command> ...
+
+cherrikka commands:
 
-  cherrikka inspect --input <backup.zip>
-  cherrikka validate --input <backup.zip>
-  cherrikka convert --input <src.zip> [--input <src2.zip> ...] --output <dst.zip> --from auto|cherry|rikka --to cherry|rikka [--template <target-template.zip>] [--redact-secrets] [--config-precedence latest|first|target|source] [--config-source-index <n>]
-  cherrikka serve --listen 127.0.0.1:7788`)
+  cherrikka inspect --input <backup.zip> [--from auto|cherry|rikka] [--files]
+  cherrikka validate --input <backup.zip> [--verify-hashes] [--from auto|cherry|rikka]
+  cherrikka check --input <backup.zip> --to cherry|rikka
+  cherrikka check-providers --input <backup.zip> [--live] [--timeout 15s]
+  cherrikka diff --left <a.zip> --right <b.zip>
+  cherrikka stats --input <backup.zip>
+  cherrikka extract-files --input <backup.zip> --output <dir>
+  cherrikka manifest --input <converted.zip>
+  cherrikka verify-integrity --input <converted.zip>
+  cherrikka convert --input <src.zip> [--input <src2.zip> ...] --output <dst.zip> --from auto|cherry|rikka --to cherry|rikka|ir [--template <target-template.zip>] [--redact-secrets [--redact-extra-keys <a,b>] | --externalize-secrets] [--secrets-file <keyfile.json>] [--config-precedence latest|first|target|source] [--config-source-index <n>] [--settings-from <key>=<n> ...] [--dedupe-files] [--content-addressed-files] [--set-key <provider>=<api-key> ...] [--bind <conversationId>=<assistant> ...] [--include-conversation <id-or-title> ...] [--exclude-conversation <id-or-title> ...] [--source-name <name> ...] [--user-name <name>] [--user-id <id>] [--default-assistant-name <name>] [--default-assistant-prompt <text>] [--default-provider <type>] [--default-model <model>] [--limit-media-size <bytes>] [--no-empty-placeholder] [--embed-readme] [--integrity-manifest] [--exclude-archived] [--flatten-branches] [--drop-empty-messages=false] [--verify-output=false] [--dry-run] [--strip-reasoning] [--prune-unused-models] [--title-from keep|first-user|first-message|first-assistant] [--allow-same-format] [--report-unmapped] [--summary-only] [--no-raw-sidecar] [--temp-dir <dir>] [--ir-cache <dir>] [--concurrency <n>] [--time-zone <zone>] [--since <rfc3339>] [--until <rfc3339>]
+  cherrikka convert --input-url <https://.../backup.zip> --output <dst.zip> --to cherry|rikka [--max-download-size <bytes>] [--download-timeout 5m] [--max-extract-bytes <bytes>]
+  cherrikka convert --input-dir <dir> --output-dir <dir> --to cherry|rikka [--output-template {name}-{to}-{date}.zip]
+  cherrikka convert --no-merge --input <a.zip> --input <b.zip> --output-dir <dir> --to cherry|rikka
+  cherrikka slice --input <src.zip> --output <dst.zip> --to cherry|rikka [--from-date YYYY-MM-DD] [--to-date YYYY-MM-DD] [--time-zone <zone>] [--redact-secrets] [--temp-dir <dir>]
+  cherrikka serve --listen 127.0.0.1:7788 [--cors-origin <origin> ...] [--max-extract-bytes <bytes>]
+  cherrikka schema --type manifest|inspect|validate
+  cherrikka explain [--json] <warning-code>
+  cherrikka version`)
+}
+
+func splitCommaList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func parseRFC3339Millis(flagName, v string) (int64, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an RFC3339 time such as 2024-01-01T00:00:00Z: %s", flagName, v)
+	}
+	return t.UnixMilli(), nil
 }
 
 type multiStringFlag []string
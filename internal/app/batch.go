@@ -0,0 +1,143 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cherrikka/internal/ir"
+	"cherrikka/internal/util"
+)
+
+const defaultOutputTemplate = "{name}-{to}.zip"
+
+type BatchConvertOptions struct {
+	InputDir       string
+	InputPaths     []string
+	OutputDir      string
+	OutputTemplate string // placeholders: {name} {from} {to} {date} {index}
+	Convert        ConvertOptions
+}
+
+type BatchResult struct {
+	Input    string       `json:"input"`
+	Output   string       `json:"output"`
+	Manifest *ir.Manifest `json:"manifest,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// ConvertBatch converts every input independently, naming outputs from OutputTemplate.
+func ConvertBatch(opts BatchConvertOptions) ([]BatchResult, error) {
+	if strings.TrimSpace(opts.OutputDir) == "" {
+		return nil, fmt.Errorf("output dir is required")
+	}
+	inputs := normalizeInputPaths("", opts.InputPaths)
+	if dir := strings.TrimSpace(opts.InputDir); dir != "" {
+		found, err := listBackupZips(dir)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, found...)
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no input backups found")
+	}
+	if !opts.Convert.DryRun {
+		if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if strings.TrimSpace(opts.Convert.TimeZone) != "" {
+		loc, err := util.LoadTimeZone(opts.Convert.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("--time-zone: %w", err)
+		}
+		now = now.In(loc)
+	}
+	used := map[string]struct{}{}
+	results := make([]BatchResult, 0, len(inputs))
+	for i, input := range inputs {
+		name := expandOutputTemplate(opts.OutputTemplate, input, opts.Convert.From, opts.Convert.To, i+1, now)
+		output := uniqueOutputPath(filepath.Join(opts.OutputDir, name), used)
+		convOpts := opts.Convert
+		convOpts.InputPath = input
+		convOpts.InputPaths = nil
+		convOpts.OutputPath = output
+		res := BatchResult{Input: input, Output: output}
+		manifest, err := Convert(convOpts)
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Manifest = manifest
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func listBackupZips(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".zip") {
+			continue
+		}
+		out = append(out, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func expandOutputTemplate(tmpl, input, from, to string, index int, now time.Time) string {
+	tmpl = strings.TrimSpace(tmpl)
+	if tmpl == "" {
+		tmpl = defaultOutputTemplate
+	}
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	from = strings.ToLower(strings.TrimSpace(from))
+	if from == "" {
+		from = "auto"
+	}
+	out := strings.NewReplacer(
+		"{name}", base,
+		"{from}", from,
+		"{to}", strings.ToLower(strings.TrimSpace(to)),
+		"{date}", now.Format("20060102"),
+		"{index}", strconv.Itoa(index),
+	).Replace(tmpl)
+	// keep outputs inside the output dir
+	out = strings.NewReplacer("/", "_", "\\", "_").Replace(out)
+	ext := ".zip"
+	if strings.EqualFold(strings.TrimSpace(to), TargetIR) {
+		ext = ".json"
+		if strings.EqualFold(filepath.Ext(out), ".zip") {
+			out = strings.TrimSuffix(out, filepath.Ext(out))
+		}
+	}
+	if !strings.EqualFold(filepath.Ext(out), ext) {
+		out += ext
+	}
+	return out
+}
+
+func uniqueOutputPath(path string, used map[string]struct{}) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	candidate := path
+	for n := 2; ; n++ {
+		if _, exists := used[candidate]; !exists {
+			used[candidate] = struct{}{}
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", stem, n, ext)
+	}
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/ir"
+	"cherrikka/internal/rikka"
+)
+
+const (
+	CompatMapped           = "mapped"
+	CompatSidecarPreserved = "sidecar-preserved"
+	CompatDropped          = "dropped"
+)
+
+type CompatItem struct {
+	Feature string `json:"feature"`
+	Count   int    `json:"count"`
+	Status  string `json:"status"`
+	Note    string `json:"note,omitempty"`
+}
+
+type CompatReport struct {
+	Format           string       `json:"format"`
+	Target           string       `json:"target"`
+	Items            []CompatItem `json:"items"`
+	Mapped           int          `json:"mapped"`
+	SidecarPreserved int          `json:"sidecarPreserved"`
+	Dropped          int          `json:"dropped"`
+	Warnings         []string     `json:"warnings,omitempty"`
+}
+
+// CheckCompatibility reports which source constructs survive conversion to the given target.
+func CheckCompatibility(path, to string) (*CompatReport, error) {
+	to = strings.ToLower(strings.TrimSpace(to))
+	if to != "cherry" && to != "rikka" {
+		return nil, fmt.Errorf("unsupported target format: %s", to)
+	}
+	workDir, cleanup, err := resolveWorkDir(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	workDir, d, err := detectWorkDir(workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	if d.Format == backup.FormatUnknown {
+		return nil, fmt.Errorf("unknown backup format")
+	}
+	parsed, err := parseByFormat(d.Format, workDir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompatReport{Format: string(d.Format), Target: to, Items: []CompatItem{}}
+	sameFormat := string(d.Format) == to
+	crossStatus := func(preservable bool) string {
+		switch {
+		case sameFormat:
+			return CompatMapped
+		case preservable:
+			return CompatSidecarPreserved
+		default:
+			return CompatDropped
+		}
+	}
+	add := func(feature string, count int, status, note string) {
+		if count == 0 {
+			return
+		}
+		report.Items = append(report.Items, CompatItem{Feature: feature, Count: count, Status: status, Note: note})
+		switch status {
+		case CompatMapped:
+			report.Mapped++
+		case CompatSidecarPreserved:
+			report.SidecarPreserved++
+		case CompatDropped:
+			report.Dropped++
+		}
+	}
+
+	switch d.Format {
+	case backup.FormatRikka:
+		settings := asMap(parsed.Config["rikka.settings"])
+		memories := len(asSlice(settings["memories"])) + len(asSlice(settings["memoryEntities"]))
+		lorebooks := len(asSlice(settings["lorebooks"]))
+		for _, item := range asSlice(settings["assistants"]) {
+			assistant := asMap(item)
+			if enabled, _ := assistant["enableMemory"].(bool); enabled {
+				memories++
+			}
+			if len(asSlice(assistant["lorebookIds"])) > 0 {
+				lorebooks++
+			}
+		}
+		add("memory.settings", memories, crossStatus(true), "")
+		rows, err := rikka.CountTableRows(workDir, "MemoryEntity")
+		if err != nil {
+			report.Warnings = append(report.Warnings, "compat-count-failed:MemoryEntity:"+err.Error())
+		}
+		add("memory.entities", rows, CompatDropped, "MemoryEntity rows are not carried by the converter")
+		add("lorebooks", lorebooks, crossStatus(true), "")
+	case backup.FormatCherry:
+		unsupported := asMap(parsed.Opaque["interop.cherry.unsupported"])
+		add("memory.settings", len(asMap(unsupported["settings"]))+len(asMap(unsupported["persistSlices"])), crossStatus(true), "")
+		persist := asMap(parsed.Config["cherry.persistSlices"])
+		add("knowledgeBases", len(asSlice(asMap(persist["knowledge"])["bases"])), crossStatus(true), "")
+		add("indexedDB.extraTables", len(asMap(parsed.Opaque["cherry.indexedDB.extra"])), crossStatus(true), "")
+		statusNote := ""
+		if !sameFormat {
+			statusNote = "error/paused message states have no rikka equivalent; restored from the raw sidecar on the way back"
+		}
+		add("messageStatus", countCherryMessageStates(parsed), crossStatus(true), statusNote)
+	}
+
+	branchStatus, branchNote := CompatDropped, "only the selected branch of each message node is written"
+	if to == "rikka" {
+		branchStatus, branchNote = CompatMapped, ""
+	}
+	add("branches", countBranchNodes(parsed), branchStatus, branchNote)
+	multiModel := countMultiModelResponses(parsed)
+	note := ""
+	if to == "rikka" {
+		note = "responses are flattened into sequential assistant messages"
+	}
+	add("multiModelMessages", multiModel, CompatMapped, note)
+	return report, nil
+}
+
+func countBranchNodes(data *ir.BackupIR) int {
+	count := 0
+	for _, conv := range data.Conversations {
+		for _, msg := range conv.Messages {
+			if _, ok := msg.Opaque[rikka.OpaqueBranches]; ok {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// countCherryMessageStates counts messages whose cherry status is not success.
+func countCherryMessageStates(data *ir.BackupIR) int {
+	count := 0
+	for _, conv := range data.Conversations {
+		for _, msg := range conv.Messages {
+			if strings.TrimSpace(str(msg.Opaque["cherry.status"])) != "" {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// countMultiModelResponses counts user turns answered by more than one assistant message.
+func countMultiModelResponses(data *ir.BackupIR) int {
+	count := 0
+	for _, conv := range data.Conversations {
+		byAsk := map[string]int{}
+		for _, msg := range conv.Messages {
+			if msg.Role != "assistant" {
+				continue
+			}
+			if askID := strings.TrimSpace(str(msg.Opaque["cherry.askId"])); askID != "" {
+				byAsk[askID]++
+			}
+		}
+		for _, n := range byAsk {
+			if n > 1 {
+				count++
+			}
+		}
+	}
+	return count
+}
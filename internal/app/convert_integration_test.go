@@ -1,11 +1,22 @@
 package app
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"database/sql"
+	"encoding/gob"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -124,11 +135,12 @@ func TestConvertWithRedaction(t *testing.T) {
 	srcRikka := buildSampleRikkaBackup(t)
 	outRikka := filepath.Join(t.TempDir(), "redacted_rikka.zip")
 	_, err := Convert(ConvertOptions{
-		InputPath:     srcRikka,
-		OutputPath:    outRikka,
-		From:          "auto",
-		To:            "rikka",
-		RedactSecrets: true,
+		InputPath:       srcRikka,
+		OutputPath:      outRikka,
+		From:            "auto",
+		To:              "rikka",
+		RedactSecrets:   true,
+		AllowSameFormat: true,
 	})
 	if err != nil {
 		t.Fatalf("convert with redaction failed: %v", err)
@@ -295,7 +307,7 @@ func buildSampleCherryBackup(t *testing.T) string {
 	irData.Files[0].SourcePath = filePath
 	irData.Config["cherry.settings"] = map[string]any{"apiKey": "secret-key"}
 
-	if _, err := cherry.BuildFromIR(irData, dataDir, "", false, idMap); err != nil {
+	if _, err := cherry.BuildFromIR(context.Background(), irData, dataDir, "", false, idMap); err != nil {
 		t.Fatalf("build cherry from IR failed: %v", err)
 	}
 	zipPath := filepath.Join(t.TempDir(), "sample_cherry.zip")
@@ -315,7 +327,7 @@ func buildSampleRikkaBackup(t *testing.T) string {
 	}
 	irData.Files[0].SourcePath = filePath
 
-	if _, err := rikka.BuildFromIR(irData, dataDir, "", false, idMap); err != nil {
+	if _, err := rikka.BuildFromIR(context.Background(), irData, dataDir, "", false, idMap); err != nil {
 		t.Fatalf("build rikka from IR failed: %v", err)
 	}
 	zipPath := filepath.Join(t.TempDir(), "sample_rikka.zip")
@@ -323,6 +335,24 @@ func buildSampleRikkaBackup(t *testing.T) string {
 	return zipPath
 }
 
+// writeRikkaBackup builds irData as a rikka backup zip, with the sample payload for
+// its first file.
+func writeRikkaBackup(t *testing.T, irData *ir.BackupIR) string {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), "sample.txt")
+	if err := os.WriteFile(filePath, []byte("sample file content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	irData.Files[0].SourcePath = filePath
+	dataDir := t.TempDir()
+	if _, err := rikka.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build rikka from IR failed: %v", err)
+	}
+	zipPath := filepath.Join(t.TempDir(), "custom_rikka.zip")
+	zipDir(t, dataDir, zipPath)
+	return zipPath
+}
+
 func buildSampleCherryBackupWithoutTopicName(t *testing.T) string {
 	t.Helper()
 	dataDir := t.TempDir()
@@ -508,7 +538,7 @@ func zipDir(t *testing.T, dir, outZip string) {
 	for _, rel := range paths {
 		entries = append(entries, backup.ZipEntry{Path: rel, SourcePath: filepath.Join(dir, filepath.FromSlash(rel))})
 	}
-	if err := backup.WriteZip(outZip, entries); err != nil {
+	if err := backup.WriteZip(context.Background(), outZip, entries); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -516,7 +546,7 @@ func zipDir(t *testing.T, dir, outZip string) {
 func unzipTemp(t *testing.T, zipPath string) string {
 	t.Helper()
 	dir := t.TempDir()
-	if err := backup.ExtractZip(zipPath, dir); err != nil {
+	if err := backup.ExtractZip(context.Background(), zipPath, dir); err != nil {
 		t.Fatal(err)
 	}
 	return dir
@@ -618,3 +648,2601 @@ func indexOf(s, needle string) int {
 	}
 	return -1
 }
+
+func TestConvertRikkaToCherry_UserProfileOverride(t *testing.T) {
+	srcRikka := buildSampleRikkaBackup(t)
+	outCherry := filepath.Join(t.TempDir(), "profile_override.zip")
+	if _, err := Convert(ConvertOptions{
+		InputPath:  srcRikka,
+		OutputPath: outCherry,
+		From:       "auto",
+		To:         "cherry",
+		UserName:   "new-device",
+		UserID:     "user-123",
+	}); err != nil {
+		t.Fatalf("convert rikka->cherry failed: %v", err)
+	}
+
+	settings := readCherryPersistSlice(t, outCherry, "settings")
+	if settings["userName"] != "new-device" {
+		t.Fatalf("expected userName override, got=%v", settings["userName"])
+	}
+	if settings["userId"] != "user-123" {
+		t.Fatalf("expected userId override, got=%v", settings["userId"])
+	}
+}
+
+func readCherryPersistSlice(t *testing.T, zipPath, slice string) map[string]any {
+	t.Helper()
+	dir := unzipTemp(t, zipPath)
+	b, err := os.ReadFile(filepath.Join(dir, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root map[string]any
+	if err := json.Unmarshal(b, &root); err != nil {
+		t.Fatal(err)
+	}
+	localStorage, _ := root["localStorage"].(map[string]any)
+	persistStr, _ := localStorage["persist:cherry-studio"].(string)
+	var persist map[string]string
+	if err := json.Unmarshal([]byte(persistStr), &persist); err != nil {
+		t.Fatalf("parse persist slices failed: %v", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal([]byte(persist[slice]), &out); err != nil {
+		t.Fatalf("parse persist slice %s failed: %v", slice, err)
+	}
+	return out
+}
+
+func TestConvertMultiSourceMerge_PreservesAssistantTopicGrouping(t *testing.T) {
+	srcA := buildSampleCherryBackupWithAssistantTopicsSlice(t)
+	srcB := buildSampleCherryBackupWithAssistantTopicsSlice(t)
+	outRikka := filepath.Join(t.TempDir(), "merged_assistant_grouping.zip")
+	if _, err := Convert(ConvertOptions{
+		InputPaths: []string{srcA, srcB},
+		OutputPath: outRikka,
+		From:       "auto",
+		To:         "rikka",
+	}); err != nil {
+		t.Fatalf("convert multi-source failed: %v", err)
+	}
+
+	dir := unzipTemp(t, outRikka)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatalf("open output db failed: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT assistant_id, COUNT(*) FROM ConversationEntity GROUP BY assistant_id`)
+	if err != nil {
+		t.Fatalf("query assistant distribution failed: %v", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var assistantID string
+		var cnt int
+		if err := rows.Scan(&assistantID, &cnt); err != nil {
+			t.Fatalf("scan assistant distribution failed: %v", err)
+		}
+		counts[assistantID] = cnt
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows error: %v", err)
+	}
+	if len(counts) != 4 {
+		t.Fatalf("expected 4 assistant buckets (2 per source), got=%v", counts)
+	}
+	ones, twos := 0, 0
+	for _, cnt := range counts {
+		switch cnt {
+		case 1:
+			ones++
+		case 2:
+			twos++
+		}
+	}
+	if ones != 2 || twos != 2 {
+		t.Fatalf("expected assistant distribution [2,1,2,1], got=%v", counts)
+	}
+}
+
+func TestValidateVerifyHashesDetectsCorruptedPayload(t *testing.T) {
+	srcCherryZip := buildSampleCherryBackup(t)
+	outRikka := filepath.Join(t.TempDir(), "to_rikka_hashes.zip")
+	manifest, err := Convert(ConvertOptions{
+		InputPath:  srcCherryZip,
+		OutputPath: outRikka,
+		From:       "auto",
+		To:         "rikka",
+	})
+	if err != nil {
+		t.Fatalf("convert cherry->rikka failed: %v", err)
+	}
+	if len(manifest.FileHashes) == 0 {
+		t.Fatalf("expected manifest file hashes")
+	}
+
+	val, err := ValidateWithOptions(outRikka, ValidateOptions{VerifyHashes: true})
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if !val.Valid {
+		t.Fatalf("expected intact output valid, issues=%v", val.Issues)
+	}
+	if val.FileSummary == nil || val.FileSummary.HashVerified != len(manifest.FileHashes) {
+		t.Fatalf("expected %d verified hashes, got=%+v", len(manifest.FileHashes), val.FileSummary)
+	}
+
+	dir := unzipTemp(t, outRikka)
+	uploads, err := filepath.Glob(filepath.Join(dir, "upload", "*"))
+	if err != nil || len(uploads) == 0 {
+		t.Fatalf("expected upload payloads, err=%v", err)
+	}
+	if err := os.WriteFile(uploads[0], []byte("corrupted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corruptZip := filepath.Join(t.TempDir(), "corrupt.zip")
+	zipDir(t, dir, corruptZip)
+
+	val, err = ValidateWithOptions(corruptZip, ValidateOptions{VerifyHashes: true})
+	if err != nil {
+		t.Fatalf("validate corrupted failed: %v", err)
+	}
+	if val.Valid {
+		t.Fatalf("expected corrupted output invalid")
+	}
+	rel, _ := filepath.Rel(dir, uploads[0])
+	want := "file-hash-mismatch:" + filepath.ToSlash(rel)
+	found := false
+	for _, issue := range val.Errors {
+		if issue == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected %s in errors, got=%v", want, val.Errors)
+	}
+}
+
+func TestCheckCompatibilityRikkaToCherry(t *testing.T) {
+	irData := buildSampleIR()
+	settings := irData.Config["rikka.settings"].(map[string]any)
+	settings["lorebooks"] = []any{map[string]any{"id": "lb-1", "name": "World"}}
+	settings["memories"] = []any{map[string]any{"id": 1, "content": "likes tea"}}
+	dataDir := t.TempDir()
+	if _, err := rikka.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build rikka from IR failed: %v", err)
+	}
+	settingsPath := filepath.Join(dataDir, "settings.json")
+	b, err := os.ReadFile(settingsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written map[string]any
+	if err := json.Unmarshal(b, &written); err != nil {
+		t.Fatal(err)
+	}
+	written["lorebooks"] = settings["lorebooks"]
+	written["memories"] = settings["memories"]
+	if err := os.WriteFile(settingsPath, []byte(util.MustJSON(written)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	zipPath := filepath.Join(t.TempDir(), "rikka_compat.zip")
+	zipDir(t, dataDir, zipPath)
+
+	report, err := CheckCompatibility(zipPath, "cherry")
+	if err != nil {
+		t.Fatalf("check compatibility failed: %v", err)
+	}
+	if report.Format != "rikka" || report.Target != "cherry" {
+		t.Fatalf("unexpected report header: %+v", report)
+	}
+	statuses := map[string]string{}
+	for _, item := range report.Items {
+		statuses[item.Feature] = item.Status
+	}
+	if statuses["lorebooks"] != CompatSidecarPreserved || statuses["memory.settings"] != CompatSidecarPreserved {
+		t.Fatalf("expected lorebooks/memory sidecar-preserved, got=%v", statuses)
+	}
+
+	sameReport, err := CheckCompatibility(zipPath, "rikka")
+	if err != nil {
+		t.Fatalf("check compatibility same format failed: %v", err)
+	}
+	for _, item := range sameReport.Items {
+		if item.Feature == "lorebooks" && item.Status != CompatMapped {
+			t.Fatalf("expected lorebooks mapped for same-format target, got=%s", item.Status)
+		}
+	}
+}
+
+func TestConvertBatchOutputTemplate(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, name := range []string{"alpha.zip", "beta.zip"} {
+		b, err := os.ReadFile(buildSampleCherryBackup(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(inputDir, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outputDir := t.TempDir()
+	results, err := ConvertBatch(BatchConvertOptions{
+		InputDir:       inputDir,
+		OutputDir:      outputDir,
+		OutputTemplate: "{to}-{index}-{name}",
+		Convert:        ConvertOptions{From: "auto", To: "rikka"},
+	})
+	if err != nil {
+		t.Fatalf("batch convert failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 batch results, got %d", len(results))
+	}
+	for i, want := range []string{"rikka-1-alpha.zip", "rikka-2-beta.zip"} {
+		if results[i].Error != "" {
+			t.Fatalf("batch item %d failed: %s", i, results[i].Error)
+		}
+		if got := filepath.Base(results[i].Output); got != want {
+			t.Fatalf("expected output %s, got %s", want, got)
+		}
+		if _, err := os.Stat(results[i].Output); err != nil {
+			t.Fatalf("expected output written: %v", err)
+		}
+	}
+}
+
+func TestExpandOutputTemplateAvoidsCollisions(t *testing.T) {
+	now := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	if got := expandOutputTemplate("{name}-{to}-{date}.zip", "/in/backup.zip", "auto", "cherry", 1, now); got != "backup-cherry-20240506.zip" {
+		t.Fatalf("unexpected expansion: %s", got)
+	}
+	used := map[string]struct{}{}
+	first := uniqueOutputPath("/out/a.zip", used)
+	second := uniqueOutputPath("/out/a.zip", used)
+	if first != "/out/a.zip" || second != "/out/a-2.zip" {
+		t.Fatalf("expected collision suffix, got %s %s", first, second)
+	}
+}
+
+func TestConvertCherryWrappedInTopLevelDirectory(t *testing.T) {
+	flatDir := unzipTemp(t, buildSampleCherryBackup(t))
+	wrapped := t.TempDir()
+	if err := os.Rename(flatDir, filepath.Join(wrapped, "backup-2024")); err != nil {
+		t.Fatal(err)
+	}
+	wrappedZip := filepath.Join(t.TempDir(), "wrapped_cherry.zip")
+	zipDir(t, wrapped, wrappedZip)
+
+	ins, err := Inspect(wrappedZip)
+	if err != nil {
+		t.Fatalf("inspect wrapped failed: %v", err)
+	}
+	if ins.Format != "cherry" || ins.Conversations == 0 {
+		t.Fatalf("expected wrapped cherry backup detected, got=%+v", ins)
+	}
+	outRikka := filepath.Join(t.TempDir(), "wrapped_to_rikka.zip")
+	if _, err := Convert(ConvertOptions{
+		InputPath:  wrappedZip,
+		OutputPath: outRikka,
+		From:       "auto",
+		To:         "rikka",
+	}); err != nil {
+		t.Fatalf("convert wrapped cherry failed: %v", err)
+	}
+}
+
+func TestConvertLimitMediaSizeOmitsOversizedFiles(t *testing.T) {
+	srcCherryZip := buildSampleCherryBackup(t)
+	outRikka := filepath.Join(t.TempDir(), "limited.zip")
+	manifest, err := Convert(ConvertOptions{
+		InputPath:    srcCherryZip,
+		OutputPath:   outRikka,
+		From:         "auto",
+		To:           "rikka",
+		MaxFileBytes: 5,
+	})
+	if err != nil {
+		t.Fatalf("convert with media limit failed: %v", err)
+	}
+	found := false
+	for _, w := range manifest.Warnings {
+		if strings.HasPrefix(w, "file-omitted:sample.txt:") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected file-omitted warning, got=%v", manifest.Warnings)
+	}
+	ins, err := Inspect(outRikka)
+	if err != nil {
+		t.Fatalf("inspect limited output failed: %v", err)
+	}
+	if ins.Files != 0 {
+		t.Fatalf("expected oversized file dropped, got files=%d", ins.Files)
+	}
+	if ins.Conversations == 0 {
+		t.Fatalf("expected conversations kept")
+	}
+}
+
+func TestConvertNoEmptyPlaceholderSkipsMissingFiles(t *testing.T) {
+	irData := buildSampleIR()
+	dataDir := t.TempDir()
+	if _, err := cherry.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build cherry from IR failed: %v", err)
+	}
+	// drop the payloads so the source backup references a file it does not carry
+	payloads, _ := filepath.Glob(filepath.Join(dataDir, "Data", "Files", "*"))
+	for _, p := range payloads {
+		if err := os.Remove(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// zips carry no empty dirs; keep Data/ so the backup is still detected as cherry
+	if err := os.WriteFile(filepath.Join(dataDir, "Data", "Files", ".keep"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srcCherryZip := filepath.Join(t.TempDir(), "missing_payload.zip")
+	zipDir(t, dataDir, srcCherryZip)
+
+	outRikka := filepath.Join(t.TempDir(), "skipped.zip")
+	manifest, err := Convert(ConvertOptions{
+		InputPath:        srcCherryZip,
+		OutputPath:       outRikka,
+		From:             "auto",
+		To:               "rikka",
+		SkipMissingFiles: true,
+	})
+	if err != nil {
+		t.Fatalf("convert with --no-empty-placeholder failed: %v", err)
+	}
+	warnings := strings.Join(manifest.Warnings, "\n")
+	if !strings.Contains(warnings, "file-missing-skipped:sample.txt") {
+		t.Fatalf("expected file-missing-skipped warning, got=%v", manifest.Warnings)
+	}
+	if strings.Contains(warnings, "created empty placeholder") {
+		t.Fatalf("expected no empty placeholder, got=%v", manifest.Warnings)
+	}
+
+	dir := unzipTemp(t, outRikka)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatalf("open output db failed: %v", err)
+	}
+	defer db.Close()
+	var files int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM managed_files`).Scan(&files); err != nil {
+		t.Fatalf("count managed files failed: %v", err)
+	}
+	if files != 0 {
+		t.Fatalf("expected missing file row omitted, got=%d", files)
+	}
+	var notes int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM message_node WHERE messages LIKE '%[missing attachment: sample.txt]%'`).Scan(&notes); err != nil {
+		t.Fatalf("query message notes failed: %v", err)
+	}
+	if notes == 0 {
+		t.Fatalf("expected message part rewritten to a missing attachment note")
+	}
+
+	// a loader may flag a file missing while leaving its stale path behind
+	stale := buildSampleIR()
+	stale.Files[0].Missing = true
+	stale.Files[0].SourcePath = filepath.Join(t.TempDir(), "gone.txt")
+	if got := skipMissingFiles(stale); len(got) != 1 || len(stale.Files) != 0 {
+		t.Fatalf("expected the missing-flagged file skipped, warnings=%v files=%d", got, len(stale.Files))
+	}
+}
+
+func TestConvertFlattenBranchesDropsAlternates(t *testing.T) {
+	dir := unzipTemp(t, buildSampleRikkaBackup(t))
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodeID, messagesJSON string
+	if err := db.QueryRow(`SELECT id, messages FROM message_node ORDER BY node_index DESC LIMIT 1`).Scan(&nodeID, &messagesJSON); err != nil {
+		t.Fatal(err)
+	}
+	var messages []map[string]any
+	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+		t.Fatal(err)
+	}
+	alternate := map[string]any{"id": "alt-1", "role": "ASSISTANT", "parts": []any{map[string]any{"type": "text", "text": "alternate answer"}}}
+	if _, err := db.Exec(`UPDATE message_node SET messages = ?, select_index = 1 WHERE id = ?`, util.MustJSON(append([]map[string]any{alternate}, messages...)), nodeID); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	parsed, err := parseByFormat(backup.FormatRikka, dir, "")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if warnings := flattenBranches(parsed); strings.Join(warnings, ",") != "branches-flattened:1" {
+		t.Fatalf("expected one alternate dropped, got=%v", warnings)
+	}
+	last := parsed.Conversations[0].Messages[len(parsed.Conversations[0].Messages)-1]
+	if last.ID == "alt-1" || countBranchNodes(parsed) != 0 {
+		t.Fatalf("expected the selected branch kept and no branch nodes left, last=%s", last.ID)
+	}
+
+	src := filepath.Join(t.TempDir(), "branched.zip")
+	zipDir(t, dir, src)
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: filepath.Join(t.TempDir(), "flat.zip"), From: "auto", To: "cherry", FlattenBranches: true})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if !containsString(strings.Join(manifest.Warnings, ","), "branches-flattened:1") {
+		t.Fatalf("expected flatten warning, got=%v", manifest.Warnings)
+	}
+}
+
+func TestRikkaBranchesSurviveRikkaRoundTrip(t *testing.T) {
+	dir := unzipTemp(t, buildSampleRikkaBackup(t))
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodeID, messagesJSON string
+	if err := db.QueryRow(`SELECT id, messages FROM message_node ORDER BY node_index DESC LIMIT 1`).Scan(&nodeID, &messagesJSON); err != nil {
+		t.Fatal(err)
+	}
+	var messages []map[string]any
+	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+		t.Fatal(err)
+	}
+	alternate := map[string]any{"id": "alt-1", "role": "ASSISTANT", "parts": []any{map[string]any{"type": "text", "text": "alternate answer"}}}
+	if _, err := db.Exec(`UPDATE message_node SET messages = ?, select_index = 1 WHERE id = ?`, util.MustJSON(append([]map[string]any{alternate}, messages...)), nodeID); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	src := filepath.Join(t.TempDir(), "branched.zip")
+	zipDir(t, dir, src)
+
+	out := filepath.Join(t.TempDir(), "roundtrip.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", AllowSameFormat: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	outDB, err := sql.Open("sqlite", filepath.Join(unzipTemp(t, out), "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outDB.Close()
+	var selectIndex int
+	if err := outDB.QueryRow(`SELECT messages, select_index FROM message_node ORDER BY node_index DESC LIMIT 1`).Scan(&messagesJSON, &selectIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || selectIndex != 1 || !containsString(messagesJSON, "alternate answer") {
+		t.Fatalf("expected both branches and select_index 1 restored, got select_index=%d messages=%s", selectIndex, messagesJSON)
+	}
+}
+
+func TestRikkaConversationStateSurvivesRikkaRoundTrip(t *testing.T) {
+	dir := unzipTemp(t, buildSampleRikkaBackup(t))
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE ConversationEntity SET is_pinned = 1, suggestions = ?`, `["tell me more","summarize"]`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	src := filepath.Join(t.TempDir(), "pinned.zip")
+	zipDir(t, dir, src)
+
+	out := filepath.Join(t.TempDir(), "roundtrip.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", AllowSameFormat: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	outDB, err := sql.Open("sqlite", filepath.Join(unzipTemp(t, out), "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outDB.Close()
+	var suggestions string
+	var isPinned, truncateIndex int
+	if err := outDB.QueryRow(`SELECT suggestions, is_pinned, truncate_index FROM ConversationEntity LIMIT 1`).Scan(&suggestions, &isPinned, &truncateIndex); err != nil {
+		t.Fatal(err)
+	}
+	if isPinned != 1 || suggestions != `["tell me more","summarize"]` || truncateIndex != -1 {
+		t.Fatalf("expected pinned conversation with suggestions, got is_pinned=%d suggestions=%s truncate_index=%d", isPinned, suggestions, truncateIndex)
+	}
+}
+
+func TestValidateIRReportsBrokenReferences(t *testing.T) {
+	data := buildSampleIR()
+	if issues := ValidateIR(data); len(issues) != 0 {
+		t.Fatalf("expected sample IR to be consistent, got=%v", issues)
+	}
+	data.Files = nil
+	data.Conversations[0].AssistantID = "assistant-gone"
+	data.Conversations = append(data.Conversations, data.Conversations[0])
+	got := []string{}
+	for _, issue := range ValidateIR(data) {
+		got = append(got, issue.String())
+	}
+	joined := strings.Join(got, "\n")
+	for _, want := range []string{
+		"duplicate-id:conversations:conv-1",
+		"duplicate-id:messages:msg-2",
+		"missing-assistant:conversations/conv-1:assistant-gone",
+		"missing-file:conversations/conv-1/messages/msg-2:file-1",
+	} {
+		if !containsString(joined, want) {
+			t.Fatalf("expected issue %q, got=%v", want, got)
+		}
+	}
+
+	manifest, err := Convert(ConvertOptions{
+		InputPath:  buildSampleCherryBackup(t),
+		OutputPath: filepath.Join(t.TempDir(), "out.zip"),
+		From:       "auto",
+		To:         "rikka",
+	})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if containsString(strings.Join(manifest.Warnings, "\n"), "ir-invalid:") {
+		t.Fatalf("expected no ir-invalid warnings for a clean backup, got=%v", manifest.Warnings)
+	}
+}
+
+func TestConvertReusesIRCache(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	cacheDir := t.TempDir()
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: filepath.Join(t.TempDir(), "first.zip"), From: "auto", To: "rikka", IRCacheDir: cacheDir}); err != nil {
+		t.Fatalf("first convert failed: %v", err)
+	}
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*-cherry.gob"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one cached IR, got=%v err=%v", entries, err)
+	}
+
+	// rewrite the cached title: a second conversion only shows it if the cache is used
+	b, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry irCacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&entry); err != nil {
+		t.Fatalf("decode cache failed: %v", err)
+	}
+	entry.IR.Conversations[0].Title = "From Cache"
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(entries[0], buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "second.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", IRCacheDir: cacheDir}); err != nil {
+		t.Fatalf("cached convert failed: %v", err)
+	}
+	dir := unzipTemp(t, out)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatalf("open output db failed: %v", err)
+	}
+	defer db.Close()
+	var title string
+	if err := db.QueryRow(`SELECT title FROM ConversationEntity LIMIT 1`).Scan(&title); err != nil {
+		t.Fatalf("query title failed: %v", err)
+	}
+	if title != "From Cache" {
+		t.Fatalf("expected title from cached IR, got=%q", title)
+	}
+	var size int64
+	if err := db.QueryRow(`SELECT size_bytes FROM managed_files LIMIT 1`).Scan(&size); err != nil {
+		t.Fatalf("query managed file failed: %v", err)
+	}
+	if size != int64(len("sample file content")) {
+		t.Fatalf("expected payload copied from the fresh extraction, got size=%d", size)
+	}
+
+	manifest, err := Convert(ConvertOptions{InputPath: buildSampleRikkaBackup(t), OutputPath: filepath.Join(t.TempDir(), "rikka.zip"), From: "auto", To: "cherry", IRCacheDir: cacheDir})
+	if err != nil {
+		t.Fatalf("convert rikka source with cache failed: %v", err)
+	}
+	if containsString(strings.Join(manifest.Warnings, "\n"), "ir-cache:") {
+		t.Fatalf("expected rikka IR to be cacheable, got=%v", manifest.Warnings)
+	}
+}
+
+func TestConvertNormalizesMixedTimestamps(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "Data", "Files"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "Data", "Files", ".keep"), []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	persist := map[string]any{
+		"assistants": map[string]any{"assistants": []any{map[string]any{
+			"id":     "default",
+			"name":   "Default",
+			"topics": []any{map[string]any{"id": "topic-1", "assistantId": "default", "createdAt": "2024-03-01 10:00:00", "updatedAt": float64(1709290800000)}},
+		}}},
+	}
+	persistRaw := map[string]any{}
+	for k, v := range persist {
+		persistRaw[k] = util.MustJSON(v)
+	}
+	data := map[string]any{
+		"localStorage": map[string]any{"persist:cherry-studio": util.MustJSON(persistRaw)},
+		"indexedDB": map[string]any{
+			"topics": []any{map[string]any{"id": "topic-1", "messages": []any{
+				map[string]any{"id": "msg-1", "role": "user", "createdAt": float64(1709287260000), "blocks": []any{"block-1"}},
+				map[string]any{"id": "msg-2", "role": "assistant", "createdAt": "2024-03-01T18:02:00+08:00", "blocks": []any{"block-2"}},
+			}}},
+			"message_blocks": []any{
+				map[string]any{"id": "block-1", "messageId": "msg-1", "type": "main_text", "content": "hi"},
+				map[string]any{"id": "block-2", "messageId": "msg-2", "type": "main_text", "content": "hello"},
+			},
+		},
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "data.json"), []byte(util.MustJSON(data)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseByFormat(backup.FormatCherry, dataDir, "")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	conv := parsed.Conversations[0]
+	got := []string{conv.CreatedAt, conv.UpdatedAt, conv.Messages[0].CreatedAt, conv.Messages[1].CreatedAt}
+	want := []string{"2024-03-01T10:00:00.000Z", "2024-03-01T11:00:00.000Z", "2024-03-01T10:01:00.000Z", "2024-03-01T10:02:00.000Z"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected normalized UTC timestamps\n got=%v\nwant=%v", got, want)
+	}
+
+	src := filepath.Join(t.TempDir(), "mixed.zip")
+	zipDir(t, dataDir, src)
+	out := filepath.Join(t.TempDir(), "zoned.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", EmbedReadme: true, TimeZone: "+08:00"}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	readme, err := os.ReadFile(filepath.Join(unzipTemp(t, out), "cherrikka", "README.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsString(string(readme), " +08:00") {
+		t.Fatalf("expected README times in the requested zone, got:\n%s", readme)
+	}
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", TimeZone: "Nowhere/City"}); err == nil || !containsString(err.Error(), "--time-zone") {
+		t.Fatalf("expected unknown zone to be rejected, got=%v", err)
+	}
+}
+
+func TestConvertSelectsConversationsByIDOrTitle(t *testing.T) {
+	irData := buildSampleIR()
+	for _, title := range []string{"Trip Planning", "Work notes"} {
+		irData.Conversations = append(irData.Conversations, ir.IRConversation{
+			ID:          "conv-" + strings.ToLower(strings.Fields(title)[0]),
+			AssistantID: "assistant-1",
+			Title:       title,
+			Messages: []ir.IRMessage{{
+				ID: "msg-" + title, Role: "user",
+				Parts: []ir.IRPart{{Type: "text", Content: title}},
+			}},
+		})
+	}
+	src := writeRikkaBackup(t, irData)
+
+	out := filepath.Join(t.TempDir(), "selected.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", IncludeConversations: []string{"trip", "NOTES"}, ExcludeConversations: []string{"work"}})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	warnings := strings.Join(manifest.Warnings, ",")
+	if !containsString(warnings, "conversations-selected:1/3") || !containsString(warnings, "files-selected:1") {
+		t.Fatalf("expected only the trip conversation kept and the sample attachment dropped, warnings=%v", manifest.Warnings)
+	}
+	res, err := Inspect(out)
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	if res.Conversations != 1 || res.Files != 0 {
+		t.Fatalf("expected 1 conversation and no files, got %d conversations and %d files", res.Conversations, res.Files)
+	}
+
+	byID := buildSampleIR()
+	byID.Conversations = append(byID.Conversations, irData.Conversations[1])
+	selectConversations(byID, []string{"conv-trip"}, nil)
+	if len(byID.Conversations) != 1 || byID.Conversations[0].ID != "conv-trip" {
+		t.Fatalf("expected selection by exact id, got %+v", byID.Conversations)
+	}
+}
+
+func TestConvertFiltersConversationsBySinceUntil(t *testing.T) {
+	irData := buildSampleIR()
+	irData.Conversations[0].CreatedAt = "2024-03-01T08:00:00.000Z"
+	irData.Conversations[0].UpdatedAt = "2024-03-10T08:00:00.000Z"
+	irData.Conversations = append(irData.Conversations, ir.IRConversation{
+		ID:          "conv-2",
+		AssistantID: "assistant-1",
+		Title:       "Recent Conversation",
+		CreatedAt:   "2024-08-01T10:00:00.000Z",
+		Messages: []ir.IRMessage{{
+			ID: "msg-3", Role: "user", CreatedAt: "2024-08-01T10:00:00.000Z",
+			Parts: []ir.IRPart{{Type: "text", Content: "only created, never updated"}},
+		}},
+	})
+	src := writeRikkaBackup(t, irData)
+
+	since := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	out := filepath.Join(t.TempDir(), "recent.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", SinceUnixMillis: since})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	warnings := strings.Join(manifest.Warnings, ",")
+	if !containsString(warnings, "filtered-conversations:1") || !containsString(warnings, "filtered-files:1") {
+		t.Fatalf("expected the march conversation and its attachment filtered, warnings=%v", manifest.Warnings)
+	}
+	res, err := Inspect(out)
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	if res.Conversations != 1 || res.Files != 0 {
+		t.Fatalf("expected 1 conversation and no files, got %d conversations and %d files", res.Conversations, res.Files)
+	}
+
+	undated := buildSampleIR()
+	undated.Conversations[0].CreatedAt, undated.Conversations[0].UpdatedAt = "", ""
+	if dropped, _, kept := filterConversationsByTime(undated, since, 0); dropped != 0 || kept != 1 || len(undated.Conversations) != 1 {
+		t.Fatalf("expected the undated conversation kept and counted, dropped=%d undated=%d", dropped, kept)
+	}
+
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", SinceUnixMillis: since, UntilUnixMillis: since - 1}); err == nil {
+		t.Fatalf("expected --since after --until to fail")
+	}
+}
+
+func TestConvertSlicesConversationsByDateRange(t *testing.T) {
+	irData := buildSampleIR()
+	halfOne := "2024-03-10T08:00:00.000Z"
+	for i := range irData.Conversations[0].Messages {
+		irData.Conversations[0].Messages[i].CreatedAt = halfOne
+	}
+	irData.Conversations[0].CreatedAt, irData.Conversations[0].UpdatedAt = halfOne, halfOne
+	irData.Conversations = append(irData.Conversations, ir.IRConversation{
+		ID:          "conv-2",
+		AssistantID: "assistant-1",
+		Title:       "Later Conversation",
+		CreatedAt:   "2024-06-30T20:00:00.000Z",
+		UpdatedAt:   "2024-06-30T20:00:00.000Z",
+		Messages: []ir.IRMessage{{
+			ID: "msg-3", Role: "user", CreatedAt: "2024-06-30T20:00:00.000Z",
+			Parts: []ir.IRPart{{Type: "text", Content: "late in june, utc"}},
+		}},
+	})
+	src := writeRikkaBackup(t, irData)
+
+	out := filepath.Join(t.TempDir(), "h1.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", FromDate: "2024-01-01", ToDate: "2024-06-30"})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if !containsString(strings.Join(manifest.Warnings, ","), "conversations-sliced:2/2") {
+		t.Fatalf("expected both conversations inside the inclusive window, warnings=%v", manifest.Warnings)
+	}
+
+	// in +08:00 the june conversation happened on july 1st
+	manifest, err = Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", FromDate: "2024-07-01", TimeZone: "+08:00"})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	warnings := strings.Join(manifest.Warnings, ",")
+	if !containsString(warnings, "conversations-sliced:1/2") || !containsString(warnings, "files-sliced:1") {
+		t.Fatalf("expected only the later conversation and no attachment, warnings=%v", manifest.Warnings)
+	}
+	res, err := Inspect(out)
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	if res.Conversations != 1 || res.Files != 0 || res.Assistants == 0 {
+		t.Fatalf("expected a valid sliced backup with one conversation, got %+v", res)
+	}
+
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", FromDate: "2024-07-01", ToDate: "2024-06-30"}); err == nil {
+		t.Fatal("expected an inverted range to be rejected")
+	}
+}
+
+func TestConvertOmitIDMapControlsManifestSize(t *testing.T) {
+	src := buildSampleRikkaBackup(t)
+	full := filepath.Join(t.TempDir(), "full.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: full, From: "auto", To: "cherry"})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if len(manifest.IDMap) == 0 {
+		t.Fatal("expected id map in the manifest by default")
+	}
+	if embedded, err := ReadManifest(full); err != nil || len(embedded.IDMap) == 0 {
+		t.Fatalf("expected id map in the sidecar manifest by default, err=%v", err)
+	}
+
+	summary := filepath.Join(t.TempDir(), "summary.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: summary, From: "auto", To: "cherry", OmitIDMap: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	embedded, err := ReadManifest(summary)
+	if err != nil {
+		t.Fatalf("read manifest failed: %v", err)
+	}
+	if len(embedded.IDMap) != 0 || len(embedded.FileHashes) == 0 {
+		t.Fatalf("expected the sidecar manifest without id map but with hashes, got idMap=%d fileHashes=%d", len(embedded.IDMap), len(embedded.FileHashes))
+	}
+}
+
+func TestConvertProviderKeyOverridesReplaceKeys(t *testing.T) {
+	irData := buildSampleIR()
+	irData.Config["rikka.settings"].(map[string]any)["providers"] = []any{map[string]any{
+		"id": "openai", "name": "OpenAI", "type": "openai", "apiKey": "secret-key",
+		"models": []any{map[string]any{"id": "gpt-4o"}},
+	}}
+	src := writeRikkaBackup(t, irData)
+	overrides, err := ParseProviderKeyOverrides([]string{"openai=sk-fresh-key", "Missing=sk-unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "rekeyed.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", ProviderKeyOverrides: overrides})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(unzipTemp(t, out), "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsString(string(data), "sk-fresh-key") || containsString(string(data), "secret-key") {
+		t.Fatalf("expected the OpenAI key to be replaced in the output")
+	}
+	if !containsString(strings.Join(manifest.Warnings, ","), "provider-key-override-unmatched:Missing") {
+		t.Fatalf("expected unmatched override warning, got=%v", manifest.Warnings)
+	}
+
+	redacted := filepath.Join(t.TempDir(), "redacted.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: redacted, From: "auto", To: "cherry", ProviderKeyOverrides: overrides, RedactSecrets: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(unzipTemp(t, redacted), "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsString(string(data), "sk-fresh-key") {
+		t.Fatalf("expected redaction to run after the key override")
+	}
+	if _, err := ParseProviderKeyOverrides([]string{"sk-no-provider"}); err == nil || containsString(err.Error(), "sk-no-provider") {
+		t.Fatalf("expected malformed override rejected without echoing the key, got=%v", err)
+	}
+}
+
+func TestAmbiguousArchiveRequiresFrom(t *testing.T) {
+	mixedDir := unzipTemp(t, buildSampleCherryBackup(t))
+	rikkaDir := unzipTemp(t, buildSampleRikkaBackup(t))
+	for _, name := range []string{"settings.json", "rikka_hub.db"} {
+		b, err := os.ReadFile(filepath.Join(rikkaDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(mixedDir, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := filepath.Join(t.TempDir(), "mixed.zip")
+	zipDir(t, mixedDir, src)
+
+	if _, err := Inspect(src); err == nil || !containsString(err.Error(), "ambiguous backup") || !containsString(err.Error(), "--from") {
+		t.Fatalf("expected ambiguous backup error with a --from hint from inspect, got=%v", err)
+	}
+	if _, err := ExtractFiles(context.Background(), src, t.TempDir()); err == nil || !containsString(err.Error(), "ambiguous backup") || containsString(err.Error(), "--from") {
+		t.Fatalf("extract-files has no --from flag and should not suggest one, got=%v", err)
+	}
+	out := filepath.Join(t.TempDir(), "out.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry"}); err == nil || !containsString(err.Error(), "ambiguous backup") {
+		t.Fatalf("expected ambiguous backup error from convert, got=%v", err)
+	}
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "rikka", To: "cherry"})
+	if err != nil {
+		t.Fatalf("convert with --from rikka failed: %v", err)
+	}
+	if manifest.SourceFormat != "rikka" {
+		t.Fatalf("expected the rikka half to be parsed, got=%s", manifest.SourceFormat)
+	}
+}
+
+func TestConvertScaffoldDefaultsForConversationOnlyBackup(t *testing.T) {
+	irData := buildSampleIR()
+	irData.Assistants = nil
+	irData.Conversations[0].AssistantID = ""
+	irData.Config = map[string]any{"rikka.settings": map[string]any{}}
+	src := writeRikkaBackup(t, irData)
+
+	out := filepath.Join(t.TempDir(), "scaffolded.zip")
+	manifest, err := Convert(ConvertOptions{
+		InputPath:        src,
+		OutputPath:       out,
+		From:             "auto",
+		To:               "cherry",
+		DefaultAssistant: DefaultAssistantOptions{Name: "Researcher", Prompt: "Cite sources."},
+		DefaultModel:     DefaultModelOptions{Provider: "anthropic", Model: "claude-sonnet-4"},
+	})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	warnings := strings.Join(manifest.Warnings, ",")
+	if !containsString(warnings, "defaults-applied:assistant:Researcher") || !containsString(warnings, "defaults-applied:model:anthropic/claude-sonnet-4") {
+		t.Fatalf("expected scaffold warnings, got=%v", manifest.Warnings)
+	}
+	parsed, err := parseByFormat(backup.FormatCherry, unzipTemp(t, out), "")
+	if err != nil {
+		t.Fatalf("parse output failed: %v", err)
+	}
+	if len(parsed.Assistants) != 1 || parsed.Assistants[0].Name != "Researcher" || parsed.Assistants[0].Prompt != "Cite sources." {
+		t.Fatalf("expected the configured default assistant, got=%+v", parsed.Assistants)
+	}
+	if parsed.Conversations[0].AssistantID != parsed.Assistants[0].ID {
+		t.Fatalf("expected the conversation assigned to the default assistant")
+	}
+	llm := asMap(asMap(parsed.Config["cherry.persistSlices"])["llm"])
+	if got := str(asMap(llm["defaultModel"])["id"]); got != "claude-sonnet-4" {
+		t.Fatalf("expected configured default model, got=%q", got)
+	}
+
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", DefaultModel: DefaultModelOptions{Provider: "nope", Model: "m"}}); err == nil || !containsString(err.Error(), "--default-provider") {
+		t.Fatalf("expected unknown provider type rejected, got=%v", err)
+	}
+}
+
+func TestConvertToCherryIsReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	srcRikkaZip := buildSampleRikkaBackup(t)
+	readDataJSON := func() []byte {
+		out := filepath.Join(t.TempDir(), "to_cherry.zip")
+		if _, err := Convert(ConvertOptions{
+			InputPath:  srcRikkaZip,
+			OutputPath: out,
+			From:       "auto",
+			To:         "cherry",
+		}); err != nil {
+			t.Fatalf("convert rikka->cherry failed: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(unzipTemp(t, out), "data.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	first := readDataJSON()
+	second := readDataJSON()
+	if string(first) != string(second) {
+		t.Fatalf("expected identical data.json across conversions")
+	}
+}
+
+func TestReadManifestFromConvertedBackup(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	out := filepath.Join(t.TempDir(), "out.zip")
+	want, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka"})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	got, err := ReadManifest(out)
+	if err != nil {
+		t.Fatalf("read manifest failed: %v", err)
+	}
+	if got.SourceFormat != want.SourceFormat || got.TargetFormat != want.TargetFormat {
+		t.Fatalf("unexpected manifest: %+v", got)
+	}
+	if _, err := ReadManifest(src); err == nil || !strings.Contains(err.Error(), "no cherrikka manifest") {
+		t.Fatalf("expected missing-manifest error for source backup, got %v", err)
+	}
+}
+
+func TestConvertEmbedReadme(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	out := filepath.Join(t.TempDir(), "out.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", EmbedReadme: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	zr, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatalf("open output: %v", err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name != "cherrikka/README.txt" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open readme: %v", err)
+		}
+		defer rc.Close()
+		b, _ := io.ReadAll(rc)
+		if !strings.Contains(string(b), "Target:   rikkahub (rikka)") || !strings.Contains(string(b), "conversations:") {
+			t.Fatalf("unexpected readme content:\n%s", b)
+		}
+		return
+	}
+	t.Fatalf("expected cherrikka/README.txt in output")
+}
+
+func TestConvertCherryArchivedConversations(t *testing.T) {
+	irData := buildSampleIR()
+	archived := irData.Conversations[0]
+	archived.ID = "conv-archived"
+	archived.Title = "Archived Conversation"
+	archived.Opaque = map[string]any{"archived": true}
+	irData.Conversations = append(irData.Conversations, archived)
+	irData.Files[0].SourcePath = filepath.Join(t.TempDir(), "sample.txt")
+	if err := os.WriteFile(irData.Files[0].SourcePath, []byte("sample file content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dataDir := t.TempDir()
+	if _, err := cherry.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build cherry from IR failed: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "archived_cherry.zip")
+	zipDir(t, dataDir, src)
+
+	convertAndParse := func(exclude bool) *ir.BackupIR {
+		out := filepath.Join(t.TempDir(), "out.zip")
+		if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", ExcludeArchived: exclude, AllowSameFormat: true}); err != nil {
+			t.Fatalf("convert failed: %v", err)
+		}
+		parsed, err := cherry.ParseToIR(unzipTemp(t, out))
+		if err != nil {
+			t.Fatalf("parse output failed: %v", err)
+		}
+		return parsed
+	}
+
+	kept := convertAndParse(false)
+	if len(kept.Conversations) != 2 {
+		t.Fatalf("expected both conversations by default, got=%d", len(kept.Conversations))
+	}
+	archivedCount := 0
+	for _, conv := range kept.Conversations {
+		if b, _ := conv.Opaque["archived"].(bool); b {
+			archivedCount++
+		}
+	}
+	if archivedCount != 1 {
+		t.Fatalf("expected archived flag preserved on one conversation, got=%d", archivedCount)
+	}
+
+	filtered := convertAndParse(true)
+	if len(filtered.Conversations) != 1 || filtered.Conversations[0].Title == "Archived Conversation" {
+		t.Fatalf("expected archived conversation excluded, got=%+v", filtered.Conversations)
+	}
+}
+
+func TestConvertExternalizeSecretsAndRestore(t *testing.T) {
+	src := buildSampleRikkaBackup(t)
+	out := filepath.Join(t.TempDir(), "externalized.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", ExternalizeSecrets: true, AllowSameFormat: true}); err != nil {
+		t.Fatalf("convert with externalized secrets failed: %v", err)
+	}
+	settingsJSON, err := os.ReadFile(filepath.Join(unzipTemp(t, out), "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(settingsJSON), "secret-key") || !strings.Contains(string(settingsJSON), "${cherrikka-secret:") {
+		t.Fatalf("expected placeholders instead of secrets in settings.json")
+	}
+	keyfile, err := os.ReadFile(out + ".secrets.json")
+	if err != nil {
+		t.Fatalf("expected keyfile next to output: %v", err)
+	}
+	if !strings.Contains(string(keyfile), "secret-key") {
+		t.Fatalf("keyfile should hold the original secret")
+	}
+	if _, err := os.Stat(filepath.Join(unzipTemp(t, out), "cherrikka", "raw", "source.zip")); err == nil {
+		t.Fatalf("raw source copy would leak externalized secrets")
+	}
+
+	restored := filepath.Join(t.TempDir(), "restored.zip")
+	if _, err := Convert(ConvertOptions{InputPath: out, OutputPath: restored, From: "auto", To: "rikka", SecretsPath: out + ".secrets.json"}); err != nil {
+		t.Fatalf("convert with secrets file failed: %v", err)
+	}
+	restoredJSON, err := os.ReadFile(filepath.Join(unzipTemp(t, restored), "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(restoredJSON), "secret-key") || strings.Contains(string(restoredJSON), "${cherrikka-secret:") {
+		t.Fatalf("expected placeholders resolved from keyfile")
+	}
+
+	keyfilePath := filepath.Join(t.TempDir(), "k.json")
+	again := filepath.Join(t.TempDir(), "again.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: again, From: "auto", To: "rikka", ExternalizeSecrets: true, SecretsPath: keyfilePath}); err == nil || !containsString(err.Error(), "same-format") {
+		t.Fatalf("expected externalizing into a named keyfile to keep the same-format check, got=%v", err)
+	}
+}
+
+func TestExternalizeSecretsCoversOpaqueProviderKeys(t *testing.T) {
+	data := buildSampleIR()
+	data.Opaque = map[string]any{"interop.rikka.unsupported": map[string]any{
+		"providers": []any{map[string]any{"id": "p-opaque", "apiKey": "sk-opaque-only"}},
+	}}
+	if _, err := applySecretsFile(data, ConvertOptions{ExternalizeSecrets: true}); err != nil {
+		t.Fatal(err)
+	}
+	if containsString(util.MustJSON(data.Opaque), "sk-opaque-only") {
+		t.Fatalf("expected the opaque provider key externalized, opaque=%s", util.MustJSON(data.Opaque))
+	}
+	keyfile := filepath.Join(t.TempDir(), "keys.json")
+	if err := writeSecretsFile(keyfile, data.Secrets); err != nil {
+		t.Fatal(err)
+	}
+	warnings, err := applySecretsFile(data, ConvertOptions{SecretsPath: keyfile})
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("restore failed: err=%v warnings=%v", err, warnings)
+	}
+	if !containsString(util.MustJSON(data.Opaque), "sk-opaque-only") {
+		t.Fatalf("expected the opaque provider key restored, opaque=%s", util.MustJSON(data.Opaque))
+	}
+}
+
+func TestConvertContextCancelledLeavesNoOutput(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	out := filepath.Join(t.TempDir(), "cancelled.zip")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ConvertContext(ctx, ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka"}); err == nil {
+		t.Fatalf("expected cancelled conversion to fail")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected no output after cancellation, stat err=%v", err)
+	}
+}
+
+func TestConvertFailedVerificationKeepsExistingOutput(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "existing.zip")
+	if err := os.WriteFile(out, []byte("previous backup"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// cancelling as verification starts makes it fail after the archive is built
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress := func(stage string, done, total int) {
+		if stage == StageVerify {
+			cancel()
+		}
+	}
+	if _, err := ConvertContext(ctx, ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", Progress: progress}); err == nil {
+		t.Fatalf("expected the conversion to fail verification")
+	}
+	b, err := os.ReadFile(out)
+	if err != nil || string(b) != "previous backup" {
+		t.Fatalf("expected the existing output left untouched, got=%q err=%v", b, err)
+	}
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the partial archive removed, found %d entries", len(entries))
+	}
+}
+
+func TestFetchInputKeepsSignedQueryOutOfErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	unreachable := srv.URL + "/backup.zip?X-Amz-Signature=topsecrettoken"
+	srv.Close()
+	_, _, err := FetchInput(context.Background(), unreachable, FetchOptions{Timeout: 5 * time.Second})
+	if err == nil {
+		t.Fatalf("expected a download from a closed server to fail")
+	}
+	if strings.Contains(err.Error(), "topsecrettoken") || !strings.Contains(err.Error(), "/backup.zip") {
+		t.Fatalf("expected the signed query redacted from the error, got=%v", err)
+	}
+}
+
+func TestConvertBatchNoMergeKeepsSourcesSeparate(t *testing.T) {
+	inputs := []string{buildSampleCherryBackup(t), buildSampleRikkaBackup(t)}
+	results, err := ConvertBatch(BatchConvertOptions{
+		InputPaths: inputs,
+		OutputDir:  t.TempDir(),
+		Convert:    ConvertOptions{From: "auto", To: "rikka", AllowSameFormat: true},
+	})
+	if err != nil {
+		t.Fatalf("no-merge convert failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected one output per input, got %d", len(results))
+	}
+	for i, res := range results {
+		if res.Error != "" {
+			t.Fatalf("input %d failed: %s", i, res.Error)
+		}
+		if len(res.Manifest.Sources) != 1 || res.Manifest.Sources[0].Name != filepath.Base(inputs[i]) {
+			t.Fatalf("expected output %d to come from %s only, got %+v", i, inputs[i], res.Manifest.Sources)
+		}
+	}
+}
+
+func TestStripReasoningParts(t *testing.T) {
+	data := buildSampleIR()
+	data.Conversations[0].Messages = append(data.Conversations[0].Messages, ir.IRMessage{
+		ID:    "msg-3",
+		Role:  "assistant",
+		Parts: []ir.IRPart{{Type: "reasoning", Content: "only thoughts"}},
+	})
+	warnings := stripReasoningParts(data)
+	if len(warnings) != 1 || warnings[0] != "reasoning-stripped:2" {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	for _, msg := range data.Conversations[0].Messages {
+		if len(msg.Parts) == 0 {
+			t.Fatalf("message %s left without parts", msg.ID)
+		}
+		for _, p := range msg.Parts {
+			if p.Type == "reasoning" {
+				t.Fatalf("reasoning part survived in %s", msg.ID)
+			}
+		}
+	}
+	last := data.Conversations[0].Messages[2].Parts
+	if len(last) != 1 || last[0].Type != "text" || last[0].Content != "" {
+		t.Fatalf("expected empty text placeholder, got %+v", last)
+	}
+}
+
+func TestDropEmptyMessages(t *testing.T) {
+	data := buildSampleIR()
+	data.Conversations[0].Messages = append(data.Conversations[0].Messages,
+		ir.IRMessage{ID: "msg-blank", Role: "assistant", Parts: []ir.IRPart{{Type: "text", Content: ""}}},
+		ir.IRMessage{ID: "msg-spaces", Role: "assistant", Parts: []ir.IRPart{{Type: "text", Content: "  \n"}}},
+	)
+	only := data.Conversations[0]
+	only.ID = "conv-only-blank"
+	only.Messages = []ir.IRMessage{{ID: "msg-only", Role: "assistant", Parts: []ir.IRPart{{Type: "text"}}}}
+	data.Conversations = append(data.Conversations, only)
+
+	warnings := dropEmptyMessages(data)
+	if len(warnings) != 1 || warnings[0] != "messages-dropped:empty:2" {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if got := len(data.Conversations[0].Messages); got != 2 {
+		t.Fatalf("expected 2 messages left, got=%d", got)
+	}
+	if got := len(data.Conversations[1].Messages); got != 1 {
+		t.Fatalf("expected sole blank message to be kept, got=%d", got)
+	}
+}
+
+func TestExplainWarning(t *testing.T) {
+	exp, ok := ExplainWarning("provider-invalid-disabled:model-selection-fallback:chatModelId")
+	if !ok {
+		t.Fatalf("expected known warning code")
+	}
+	if exp.Pattern != "provider-invalid-disabled:model-selection-fallback:" {
+		t.Fatalf("expected the most specific pattern, got=%s", exp.Pattern)
+	}
+	if exp.Code != "provider-invalid-disabled:model-selection-fallback:chatModelId" || exp.Meaning == "" || exp.Fix == "" {
+		t.Fatalf("unexpected explanation: %+v", exp)
+	}
+	if exp, ok := ExplainWarning("selected model titleModelId not found in providers"); !ok || exp.Pattern != "selected model " {
+		t.Fatalf("expected sentence-style warning to match, got=%+v ok=%v", exp, ok)
+	}
+	if _, ok := ExplainWarning("sidecar-rehydrate:applied:extra"); !ok {
+		t.Fatalf("expected sidecar-rehydrate prefix to match")
+	}
+	if _, ok := ExplainWarning("no-such-warning"); ok {
+		t.Fatalf("expected unknown warning code")
+	}
+}
+
+func TestPruneUnusedModels(t *testing.T) {
+	data := &ir.BackupIR{
+		Settings: map[string]any{
+			"core.providers": []any{
+				map[string]any{"id": "openai", "raw": map[string]any{"id": "openai", "models": []any{
+					map[string]any{"id": "gpt-4o"},
+					map[string]any{"id": "gpt-4o-mini"},
+					map[string]any{"id": "gpt-3.5-turbo"},
+					map[string]any{"id": "o1"},
+				}}},
+			},
+			"core.models": map[string]any{
+				"defaultModel": map[string]any{"id": "gpt-4o", "provider": "openai"},
+				"chatModelId":  "gpt-4o",
+			},
+			"core.assistants": []any{
+				map[string]any{"id": "a1", "chatModelId": "GPT-4o-mini"},
+			},
+		},
+	}
+	warnings := pruneUnusedModels(data)
+	if len(warnings) != 1 || warnings[0] != "models-pruned:2" {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	models := asSlice(asMap(asMap(asSlice(data.Settings["core.providers"])[0])["raw"])["models"])
+	if len(models) != 2 {
+		t.Fatalf("expected 2 models kept, got=%v", models)
+	}
+
+	empty := &ir.BackupIR{Settings: map[string]any{"core.providers": data.Settings["core.providers"]}}
+	if warnings := pruneUnusedModels(empty); len(warnings) != 0 {
+		t.Fatalf("expected no pruning without references, got=%v", warnings)
+	}
+}
+
+func TestConvertIntegrityManifestAndVerify(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	out := filepath.Join(t.TempDir(), "out.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", IntegrityManifest: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	res, err := VerifyIntegrity(out)
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if !res.Valid || res.Verified == 0 {
+		t.Fatalf("expected intact output to verify, got=%+v", res)
+	}
+
+	// rewrite the zip with settings.json altered
+	zr, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := filepath.Join(t.TempDir(), "tampered.zip")
+	f, err := os.Create(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, entry := range zr.File {
+		rc, err := entry.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		if entry.Name == "settings.json" {
+			b = append(b, ' ')
+		}
+		w, err := zw.Create(entry.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(b)
+	}
+	zr.Close()
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	res, err = VerifyIntegrity(tampered)
+	if err != nil {
+		t.Fatalf("verify tampered failed: %v", err)
+	}
+	if res.Valid || len(res.Mismatched) != 1 || res.Mismatched[0] != "settings.json" {
+		t.Fatalf("expected settings.json mismatch, got=%+v", res)
+	}
+
+	if _, err := VerifyIntegrity(src); err == nil {
+		t.Fatalf("expected error for backup without integrity manifest")
+	}
+}
+
+func TestCheckProvidersProbesModelsEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer good-key" {
+			_, _ = io.WriteString(w, `{"data":[]}`)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	irData := buildSampleIR()
+	irData.Config["rikka.settings"] = map[string]any{
+		"providers": []any{
+			map[string]any{"id": "3f1a2b4c-0000-4000-8000-000000000001", "type": "openai", "name": "Good", "enabled": true, "apiKey": "good-key", "baseUrl": srv.URL, "models": []any{}},
+			map[string]any{"id": "3f1a2b4c-0000-4000-8000-000000000002", "type": "openai", "name": "Bad", "enabled": true, "apiKey": "bad-key", "baseUrl": srv.URL, "models": []any{}},
+			map[string]any{"id": "3f1a2b4c-0000-4000-8000-000000000003", "type": "openai", "name": "NoKey", "enabled": true, "baseUrl": srv.URL, "models": []any{}},
+		},
+	}
+	dataDir := t.TempDir()
+	irData.Files = nil
+	if _, err := rikka.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build rikka from IR failed: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "providers.zip")
+	zipDir(t, dataDir, src)
+
+	offline, err := CheckProviders(context.Background(), src, ProviderCheckOptions{})
+	if err != nil {
+		t.Fatalf("check providers failed: %v", err)
+	}
+	if offline.Live || offline.Failed != 0 || offline.OK != 0 {
+		t.Fatalf("offline check must not probe providers: %+v", offline)
+	}
+
+	live, err := CheckProviders(context.Background(), src, ProviderCheckOptions{Live: true, Client: srv.Client()})
+	if err != nil {
+		t.Fatalf("live check providers failed: %v", err)
+	}
+	statuses := map[string]string{}
+	for _, p := range live.Providers {
+		statuses[p.Name] = p.Status
+	}
+	if statuses["Good"] != ProviderStatusOK || statuses["Bad"] != ProviderStatusAuthFailed || statuses["NoKey"] != ProviderStatusSkipped {
+		t.Fatalf("unexpected probe statuses: %+v", live.Providers)
+	}
+	if live.OK != 1 || live.Failed != 1 {
+		t.Fatalf("unexpected counts: %+v", live)
+	}
+}
+
+func TestConvertMergeDedupeFilesSharesImagePayload(t *testing.T) {
+	sharedImage := func(title string) string {
+		irData := buildSampleIR()
+		irData.Conversations[0].Title = title
+		irData.Files[0].Name, irData.Files[0].MimeType, irData.Files[0].Ext = "photo.png", "image/png", ".png"
+		irData.Conversations[0].Messages[1].Parts[1] = ir.IRPart{Type: "image", FileID: irData.Files[0].ID, Name: "photo.png", MimeType: "image/png"}
+		return writeRikkaBackup(t, irData)
+	}
+	inputs := []string{sharedImage("First"), sharedImage("Second")}
+
+	out := filepath.Join(t.TempDir(), "merged.json")
+	manifest, err := Convert(ConvertOptions{InputPaths: inputs, OutputPath: out, From: "auto", To: TargetIR, DedupeFiles: true})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if !containsString(strings.Join(manifest.Warnings, ","), "merge-file-deduped:") {
+		t.Fatalf("expected a dedupe warning, warnings=%v", manifest.Warnings)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump IRDump
+	if err := json.Unmarshal(b, &dump); err != nil {
+		t.Fatal(err)
+	}
+	if len(dump.IR.Files) != 1 {
+		t.Fatalf("expected one surviving file, got %d", len(dump.IR.Files))
+	}
+	referencing := 0
+	for _, conv := range dump.IR.Conversations {
+		found := false
+		for _, m := range conv.Messages {
+			for _, p := range m.Parts {
+				if p.FileID != "" {
+					if p.FileID != dump.IR.Files[0].ID {
+						t.Fatalf("part references %s, expected survivor %s", p.FileID, dump.IR.Files[0].ID)
+					}
+					found = true
+				}
+			}
+		}
+		if found {
+			referencing++
+		}
+	}
+	if referencing != 2 {
+		t.Fatalf("expected both conversations to reference the survivor, got %d", referencing)
+	}
+
+	manifest, err = Convert(ConvertOptions{InputPaths: inputs, OutputPath: out, From: "auto", To: TargetIR})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if containsString(strings.Join(manifest.Warnings, ","), "merge-file-deduped:") {
+		t.Fatalf("expected no dedupe without DedupeFiles, warnings=%v", manifest.Warnings)
+	}
+}
+
+func TestConvertMergeTagsFollowSourceNames(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone := filepath.Join(t.TempDir(), "phone-backup.zip")
+	laptop := filepath.Join(t.TempDir(), "old.zip")
+	for _, p := range []string{phone, laptop} {
+		if err := os.WriteFile(p, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest, err := Convert(ConvertOptions{
+		InputPaths:  []string{phone, laptop},
+		SourceNames: []string{"", "Work Laptop"},
+		OutputPath:  filepath.Join(t.TempDir(), "merged.zip"),
+		From:        "auto",
+		To:          "rikka",
+	})
+	if err != nil {
+		t.Fatalf("merge convert failed: %v", err)
+	}
+	if !containsString(strings.Join(manifest.Warnings, "\n"), "merge-assistant-renamed:Sample Assistant:Sample Assistant (Work-Laptop)") {
+		t.Fatalf("expected rename tagged by source name, got=%v", manifest.Warnings)
+	}
+	if len(manifest.Sources) != 2 || manifest.Sources[0].Tag != "phone-backup" || manifest.Sources[1].Tag != "Work-Laptop" {
+		t.Fatalf("unexpected manifest source tags: %+v", manifest.Sources)
+	}
+
+	if got := mergeSourceTags([]string{"x/a.zip", "y/A.zip", "z/###.zip"}, nil); strings.Join(got, ",") != "a,S2,S3" {
+		t.Fatalf("expected duplicate and empty tags to fall back to S<n>, got=%v", got)
+	}
+}
+
+func TestMergeSettingsOverridesPinGroupsToSources(t *testing.T) {
+	source := func(i int, provider, webdav string) parsedSource {
+		data := buildSampleIR()
+		data.Settings = map[string]any{
+			"core.providers": []any{map[string]any{"id": provider, "name": provider}},
+			"sync.webdav":    map[string]any{"url": webdav},
+		}
+		return parsedSource{Index: i + 1, Tag: fmt.Sprintf("S%d", i+1), Format: "rikka", LatestUnix: int64(i), IR: data}
+	}
+	sources := []parsedSource{source(0, "known-good", "https://old.example"), source(1, "stale", "https://current.example")}
+
+	merged, report, err := mergeSources(sources, MergeOptions{
+		TargetFormat:      "rikka",
+		ConfigPrecedence:  "first",
+		SettingsOverrides: map[string]int{"sync": 2, "core.providers": 1},
+	})
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if got := str(asMap(merged.Settings["sync.webdav"])["url"]); got != "https://current.example" {
+		t.Fatalf("expected sync.webdav from source 2, got %q", got)
+	}
+	providers := asSlice(merged.Settings["core.providers"])
+	if len(providers) != 1 || str(asMap(providers[0])["id"]) != "known-good" {
+		t.Fatalf("expected providers pinned to source 1 only, got %v", providers)
+	}
+	if !containsString(strings.Join(report.Warnings, "\n"), "settings-override:sync:S2") {
+		t.Fatalf("expected override warning, got %v", report.Warnings)
+	}
+
+	if _, _, err := mergeSources(sources, MergeOptions{SettingsOverrides: map[string]int{"sync.webdav": 3}}); err == nil {
+		t.Fatalf("expected out-of-range source index to fail")
+	}
+	if _, err := ParseSettingsOverrides([]string{"sync.webdav"}); err == nil {
+		t.Fatalf("expected malformed --settings-from to fail")
+	}
+}
+
+func TestConvertSameFormatRequiresOptIn(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	out := filepath.Join(t.TempDir(), "same.zip")
+	_, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry"})
+	if err == nil || !strings.Contains(err.Error(), "--allow-same-format") {
+		t.Fatalf("expected same-format conversion to be refused, got %v", err)
+	}
+	if _, statErr := os.Stat(out); statErr == nil {
+		t.Fatalf("refused conversion must not write output")
+	}
+
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "cherry", AllowSameFormat: true})
+	if err != nil {
+		t.Fatalf("convert with --allow-same-format failed: %v", err)
+	}
+	if !containsString(strings.Join(manifest.Warnings, "\n"), "same-format-conversion-is-lossy") {
+		t.Fatalf("expected lossy warning in manifest, got %v", manifest.Warnings)
+	}
+}
+
+func TestConvertFromInputURL(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	payload, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "from_url.zip")
+	manifest, err := Convert(ConvertOptions{InputURLs: []string{srv.URL + "/exports/cherry.zip?sig=secret"}, OutputPath: out, From: "auto", To: "rikka"})
+	if err != nil {
+		t.Fatalf("convert from url failed: %v", err)
+	}
+	if len(manifest.Sources) != 1 || manifest.Sources[0].Name != "cherry.zip" {
+		t.Fatalf("expected source named after the url path, got %+v", manifest.Sources)
+	}
+
+	_, err = Convert(ConvertOptions{InputURLs: []string{srv.URL + "/cherry.zip"}, OutputPath: out, To: "rikka", MaxDownloadBytes: 16})
+	if err == nil || !strings.Contains(err.Error(), "--max-download-size") {
+		t.Fatalf("expected download size cap to be enforced, got %v", err)
+	}
+	_, err = Convert(ConvertOptions{InputURLs: []string{"file:///etc/passwd"}, OutputPath: out, To: "rikka"})
+	if err == nil || !strings.Contains(err.Error(), "http or https") {
+		t.Fatalf("expected non-http scheme to be rejected, got %v", err)
+	}
+}
+
+func TestConvertReportUnmappedKeys(t *testing.T) {
+	irData := buildSampleIR()
+	irData.SourceFormat = "cherry"
+	irData.Files = nil
+	irData.Config["cherry.settings"] = map[string]any{
+		"webdavHost":    "https://dav.example.com",
+		"mysteryOption": "kept-nowhere",
+	}
+	dataDir := t.TempDir()
+	if _, err := cherry.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build cherry from IR failed: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "unmapped_cherry.zip")
+	zipDir(t, dataDir, src)
+
+	out := filepath.Join(t.TempDir(), "unmapped_rikka.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", ReportUnmapped: true})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	keys := strings.Join(manifest.UnmappedKeys, "\n")
+	if !strings.Contains(keys, "raw.cherry.settings.mysteryOption") {
+		t.Fatalf("expected unknown cherry setting reported, got %v", manifest.UnmappedKeys)
+	}
+	if strings.Contains(keys, "webdavHost") {
+		t.Fatalf("mapped webdav host should not be reported, got %v", manifest.UnmappedKeys)
+	}
+	if !containsString(strings.Join(manifest.Warnings, "\n"), "config-keys-unmapped:") {
+		t.Fatalf("expected unmapped summary warning, got %v", manifest.Warnings)
+	}
+
+	plain, err := Convert(ConvertOptions{InputPath: src, OutputPath: filepath.Join(t.TempDir(), "plain.zip"), From: "auto", To: "rikka"})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if len(plain.UnmappedKeys) != 0 {
+		t.Fatalf("unmapped keys are only reported on request")
+	}
+}
+
+func TestConvertHonorsTempDir(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	tempDir := filepath.Join(t.TempDir(), "scratch", "nested")
+	out := filepath.Join(t.TempDir(), "tempdir.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", TempDir: tempDir}); err != nil {
+		t.Fatalf("convert with temp dir failed: %v", err)
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("expected temp dir to be created: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected temp dirs cleaned up, found %d entries", len(entries))
+	}
+
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CHERRIKKA_TMPDIR", notDir)
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka"}); err == nil || !strings.Contains(err.Error(), "temp dir") {
+		t.Fatalf("expected CHERRIKKA_TMPDIR to be used, got %v", err)
+	}
+}
+
+func TestBindConversationAssistants(t *testing.T) {
+	data := buildSampleIR()
+	data.Assistants = append(data.Assistants, ir.IRAssistant{ID: "assistant-2", Name: "Coder"})
+	binds, err := ParseConversationAssistants([]string{"conv-1=coder", "conv-missing=assistant-1", "conv-1x=Nobody"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := bindConversationAssistants(data, binds)
+	if data.Conversations[0].AssistantID != "assistant-2" {
+		t.Fatalf("expected conv-1 rebound by assistant name, got=%s", data.Conversations[0].AssistantID)
+	}
+	want := "conversation-bind-unmatched:assistant:Nobody,conversation-bind-unmatched:conversation:conv-missing,conversations-rebound:1"
+	if got := strings.Join(warnings, ","); got != want {
+		t.Fatalf("unexpected warnings: %s", got)
+	}
+	if _, err := ParseConversationAssistants([]string{"conv-1"}); err == nil {
+		t.Fatalf("expected malformed --bind value to be rejected")
+	}
+
+	// a/backup.zip and b/backup.zip share a tag, so only the index tells them apart
+	sources := make([]parsedSource, 0, 2)
+	for i := 0; i < 2; i++ {
+		src := buildSampleIR()
+		src.Assistants = append(src.Assistants, ir.IRAssistant{ID: "assistant-2", Name: fmt.Sprintf("Coder S%d", i+1)})
+		sources = append(sources, parsedSource{Index: i + 1, Tag: "backup.zip", Format: "rikka", IR: src})
+	}
+	merged, _, err := mergeSources(sources, MergeOptions{TargetFormat: "rikka"})
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	warnings = bindConversationAssistants(merged, map[string]string{"conv-1": "assistant-2"})
+	if got := strings.Join(warnings, ","); got != "conversations-rebound:2" {
+		t.Fatalf("expected source ids to resolve after the merge, warnings=%s", got)
+	}
+	for _, conv := range merged.Conversations {
+		var assistant ir.IRAssistant
+		for _, a := range merged.Assistants {
+			if a.ID == conv.AssistantID {
+				assistant = a
+			}
+		}
+		if source := conv.Opaque[mergeSourceSeedKey]; assistant.Name != "Coder "+str(source) {
+			t.Fatalf("expected conversation from %v bound to its own source's assistant, got=%q", source, assistant.Name)
+		}
+	}
+}
+
+func TestConvertVerifyOutput(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	out := filepath.Join(t.TempDir(), "verified.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka"}); err != nil {
+		t.Fatalf("convert with verification failed: %v", err)
+	}
+	if err := verifyOutput(context.Background(), "", out, "rikka"); err != nil {
+		t.Fatalf("expected converted output to verify, got=%v", err)
+	}
+	if err := verifyOutput(context.Background(), "", src, "rikka"); err == nil || !containsString(err.Error(), "verify output") {
+		t.Fatalf("expected a cherry backup to fail rikka verification, got=%v", err)
+	}
+}
+
+func TestConvertDryRunWritesNothing(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	tempDir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "never.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", TempDir: tempDir, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected no output zip, stat err=%v", err)
+	}
+	if manifest.DryRun == nil || manifest.DryRun.Conversations != 1 || manifest.DryRun.Assistants != 1 || manifest.DryRun.Messages == 0 {
+		t.Fatalf("unexpected dry run summary: %+v", manifest.DryRun)
+	}
+	if entries, err := os.ReadDir(tempDir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected temp dirs cleaned up, got=%v err=%v", entries, err)
+	}
+	if _, err := Convert(ConvertOptions{InputPath: src, From: "auto", To: "rikka", DryRun: true}); err != nil {
+		t.Fatalf("dry run without an output path failed: %v", err)
+	}
+}
+
+func TestConvertToIRWritesJSONDump(t *testing.T) {
+	src := buildSampleCherryBackup(t)
+	out := filepath.Join(t.TempDir(), "dump.json")
+	manifest, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "ir", RedactSecrets: true})
+	if err != nil {
+		t.Fatalf("convert to ir failed: %v", err)
+	}
+	if manifest.TargetFormat != TargetIR {
+		t.Fatalf("unexpected target format: %s", manifest.TargetFormat)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump IRDump
+	if err := json.Unmarshal(b, &dump); err != nil {
+		t.Fatalf("dump is not json: %v", err)
+	}
+	if dump.IR == nil || len(dump.IR.Conversations) != 1 || dump.Manifest == nil || dump.Manifest.SourceFormat != "cherry" {
+		t.Fatalf("unexpected dump: manifest=%+v ir=%v", dump.Manifest, dump.IR != nil)
+	}
+	if got := expandOutputTemplate("", "/in/backup.zip", "auto", "ir", 1, time.Now()); got != "backup-ir.json" {
+		t.Fatalf("unexpected batch output name for ir: %s", got)
+	}
+}
+
+func TestDiffBackupsReportsAddedRemovedChanged(t *testing.T) {
+	left := buildSampleRikkaBackup(t)
+	if res, err := DiffBackups(context.Background(), left, left); err != nil || !res.Identical {
+		t.Fatalf("expected a backup to be identical to itself, res=%+v err=%v", res, err)
+	}
+
+	irData := buildSampleIR()
+	irData.Conversations[0].Messages = irData.Conversations[0].Messages[:1]
+	irData.Conversations = append(irData.Conversations, ir.IRConversation{
+		ID: "conv-2", AssistantID: "assistant-1", Title: "Extra",
+		Messages: []ir.IRMessage{{ID: "msg-9", Role: "user", Parts: []ir.IRPart{{Type: "text", Content: "new"}}}},
+	})
+	irData.Assistants[0].Name = "Renamed Assistant"
+	right := writeRikkaBackup(t, irData)
+
+	res, err := DiffBackups(context.Background(), left, right)
+	if err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	if res.Identical || len(res.Added.Conversations) != 1 || res.Added.Conversations[0].Title != "Extra" {
+		t.Fatalf("expected the extra conversation as added, got=%+v", res.Added.Conversations)
+	}
+	if len(res.Changed.Conversations) != 1 {
+		t.Fatalf("expected the shortened conversation as changed, got=%+v", res.Changed.Conversations)
+	}
+	if strings.Join(res.Added.Assistants, ",") != "Renamed Assistant" || strings.Join(res.Removed.Assistants, ",") != "Sample Assistant" {
+		t.Fatalf("unexpected assistant diff: added=%v removed=%v", res.Added.Assistants, res.Removed.Assistants)
+	}
+}
+
+func TestStatsBackupCountsUsage(t *testing.T) {
+	irData := buildSampleIR()
+	msg := &irData.Conversations[0].Messages[1]
+	msg.Parts = append(msg.Parts, ir.IRPart{Type: "tool", Name: "search", ToolCallID: "call-1", Input: `{"q":"x"}`, Output: []ir.IRPart{{Type: "text", Content: "result"}}})
+	dir := unzipTemp(t, writeRikkaBackup(t, irData))
+	// the builders do not write message model ids, so set one the way the app does
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodeID, messagesJSON string
+	if err := db.QueryRow(`SELECT id, messages FROM message_node ORDER BY node_index DESC LIMIT 1`).Scan(&nodeID, &messagesJSON); err != nil {
+		t.Fatal(err)
+	}
+	var messages []map[string]any
+	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+		t.Fatal(err)
+	}
+	messages[0]["modelId"] = "model-uuid-1"
+	if _, err := db.Exec(`UPDATE message_node SET messages = ? WHERE id = ?`, util.MustJSON(messages), nodeID); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	src := filepath.Join(t.TempDir(), "stats.zip")
+	zipDir(t, dir, src)
+
+	stats, err := StatsBackup(context.Background(), src)
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+	if stats.Conversations != 1 || stats.Messages != 2 || stats.ReasoningParts != 1 || stats.ToolCallParts != 1 {
+		t.Fatalf("unexpected totals: %+v", stats)
+	}
+	if len(stats.Assistants) != 1 || stats.Assistants[0].Name != "Sample Assistant" || stats.Assistants[0].Conversations != 1 || stats.Assistants[0].Messages != 2 {
+		t.Fatalf("unexpected assistant usage: %+v", stats.Assistants)
+	}
+	if len(stats.Models) != 1 || stats.Models[0].ModelID != "model-uuid-1" || stats.Models[0].Messages != 1 {
+		t.Fatalf("unexpected model usage: %+v", stats.Models)
+	}
+	if stats.FileBytes != int64(len("sample file content")) || len(stats.FileTypes) != 1 || stats.FileTypes[0].Files != 1 {
+		t.Fatalf("unexpected file usage: bytes=%d types=%+v", stats.FileBytes, stats.FileTypes)
+	}
+}
+
+func TestInspectListsFileDetailsWhenRequested(t *testing.T) {
+	src := buildSampleRikkaBackup(t)
+	res, err := Inspect(src)
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	if res.FileDetails != nil {
+		t.Fatalf("file details should be omitted by default, got %+v", res.FileDetails)
+	}
+	res, err = InspectWithOptions(src, InspectOptions{FileDetails: true})
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	if len(res.FileDetails) != res.Files || len(res.FileDetails) == 0 {
+		t.Fatalf("expected one detail per file, got %d details for %d files", len(res.FileDetails), res.Files)
+	}
+	d := res.FileDetails[0]
+	if d.ID == "" || d.Name == "" || d.Size <= 0 || len(d.HashSHA256) != 64 || d.Missing {
+		t.Fatalf("unexpected file detail: %+v", d)
+	}
+}
+
+func TestConvertReportsProgressStages(t *testing.T) {
+	src := writeRikkaBackup(t, buildSampleIR())
+	out := filepath.Join(t.TempDir(), "progress.zip")
+	stages := []string{}
+	lastFiles := [2]int{}
+	_, err := Convert(ConvertOptions{
+		InputPath: src, OutputPath: out, From: "auto", To: "cherry",
+		Progress: func(stage string, done, total int) {
+			if len(stages) == 0 || stages[len(stages)-1] != stage {
+				stages = append(stages, stage)
+			}
+			if stage == StageFiles {
+				lastFiles = [2]int{done, total}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	want := []string{StageParse, StageMerge, StageFiles, StageWrite, StageVerify}
+	if strings.Join(stages, ",") != strings.Join(want, ",") {
+		t.Fatalf("stages = %v, want %v", stages, want)
+	}
+	if lastFiles[1] == 0 || lastFiles[0] != lastFiles[1] {
+		t.Fatalf("expected the files stage to finish at total, got %d/%d", lastFiles[0], lastFiles[1])
+	}
+}
+
+func TestInspectAndConvertUnpackedDirectory(t *testing.T) {
+	cherryZip := filepath.Join(t.TempDir(), "cherry.zip")
+	if _, err := Convert(ConvertOptions{InputPath: writeRikkaBackup(t, buildSampleIR()), OutputPath: cherryZip, From: "auto", To: "cherry"}); err != nil {
+		t.Fatalf("convert to cherry failed: %v", err)
+	}
+	unpacked := filepath.Join(t.TempDir(), "mybackup")
+	if err := backup.ExtractZip(context.Background(), cherryZip, unpacked); err != nil {
+		t.Fatal(err)
+	}
+	before, err := util.ListFiles(unpacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Inspect(unpacked + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("inspect directory failed: %v", err)
+	}
+	if res.Format != "cherry" || res.Conversations != 1 {
+		t.Fatalf("expected the unpacked cherry backup to inspect, got %+v", res)
+	}
+
+	out := filepath.Join(t.TempDir(), "from-dir.zip")
+	if _, err := Convert(ConvertOptions{InputPath: unpacked, OutputPath: out, From: "auto", To: "rikka"}); err != nil {
+		t.Fatalf("convert directory failed: %v", err)
+	}
+	assertZipHasEntries(t, out, "cherrikka/raw/source.zip")
+	after, err := util.ListFiles(unpacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("input directory must be left alone, files before=%d after=%d", len(before), len(after))
+	}
+}
+
+func TestConvertWritesStructuredReport(t *testing.T) {
+	first := writeRikkaBackup(t, buildSampleIR())
+	second := writeRikkaBackup(t, buildSampleIR())
+	out := filepath.Join(t.TempDir(), "report.zip")
+	if _, err := Convert(ConvertOptions{InputPaths: []string{first, second}, SourceNames: []string{"phone", "tablet"}, OutputPath: out, From: "auto", To: "cherry", ConfigPrecedence: "first", DedupeFiles: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(unzipTemp(t, out), "cherrikka", "report.json"))
+	if err != nil {
+		t.Fatalf("expected cherrikka/report.json: %v", err)
+	}
+	var report ir.ConversionReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	sample := buildSampleIR()
+	sampleMessages := 0
+	for _, conv := range sample.Conversations {
+		sampleMessages += len(conv.Messages)
+	}
+	if len(report.Sources) != 2 {
+		t.Fatalf("expected 2 report sources, got %+v", report.Sources)
+	}
+	for _, src := range report.Sources {
+		if src.Conversations != len(sample.Conversations) || src.Messages != sampleMessages || src.Files != len(sample.Files) {
+			t.Fatalf("source counts %+v do not match the input", src)
+		}
+	}
+	if report.Merge.PrimarySourceIndex != 1 || report.Merge.ConfigPrecedence != "first" || report.Merge.DedupedFiles != len(sample.Files) {
+		t.Fatalf("unexpected merge decisions %+v", report.Merge)
+	}
+	if len(report.RenamedAssistants) != len(sample.Assistants) || report.RenamedAssistants[0].SourceIndex != 2 {
+		t.Fatalf("expected the second source's assistants renamed, got %+v", report.RenamedAssistants)
+	}
+	if report.Output.Conversations != 2*len(sample.Conversations) || report.Output.Files != len(sample.Files) {
+		t.Fatalf("unexpected output counts %+v", report.Output)
+	}
+}
+
+func TestConvertWithoutRawSidecarStillRoundTrips(t *testing.T) {
+	outRikka := filepath.Join(t.TempDir(), "no-raw.zip")
+	if _, err := Convert(ConvertOptions{InputPath: buildSampleCherryBackup(t), OutputPath: outRikka, From: "auto", To: "rikka", OmitRawSources: true}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	zr, err := zip.OpenReader(outRikka)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	seenManifest := false
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "cherrikka/raw/") {
+			t.Fatalf("expected no raw sidecar entries, found %s", f.Name)
+		}
+		if f.Name == "cherrikka/manifest.json" {
+			seenManifest = true
+		}
+	}
+	if !seenManifest {
+		t.Fatalf("expected the manifest to be written without raw sources")
+	}
+
+	// without raw copies only the manifest is available for rehydration
+	back := filepath.Join(t.TempDir(), "back.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: outRikka, OutputPath: back, From: "auto", To: "cherry"})
+	if err != nil {
+		t.Fatalf("convert back failed: %v", err)
+	}
+	warnings := strings.Join(manifest.Warnings, ",")
+	if !containsString(warnings, "sidecar-rehydrate:raw-sources-absent") || containsString(warnings, "sidecar-rehydrate:applied") {
+		t.Fatalf("expected rehydration to be skipped with a note, warnings=%v", manifest.Warnings)
+	}
+}
+
+func TestConvertReadsTarGzCherryBackup(t *testing.T) {
+	unpacked := unzipTemp(t, buildSampleCherryBackup(t))
+	tgz := filepath.Join(t.TempDir(), "cherry-backup.bak")
+	f, err := os.Create(tgz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	paths, err := util.ListFiles(unpacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range paths {
+		b, err := os.ReadFile(filepath.Join(unpacked, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: "backup/" + rel, Mode: 0o644, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Inspect(tgz)
+	if err != nil {
+		t.Fatalf("inspect tar.gz failed: %v", err)
+	}
+	if res.Format != "cherry" || res.Conversations == 0 {
+		t.Fatalf("expected a cherry backup inside the tar.gz, got %+v", res)
+	}
+	out := filepath.Join(t.TempDir(), "from-tgz.zip")
+	if _, err := Convert(ConvertOptions{InputPath: tgz, OutputPath: out, From: "auto", To: "rikka"}); err != nil {
+		t.Fatalf("convert tar.gz failed: %v", err)
+	}
+	rawSource := filepath.Join(unzipTemp(t, out), "cherrikka", "raw", "source.zip")
+	if _, err := zip.OpenReader(rawSource); err != nil {
+		t.Fatalf("expected the raw sidecar copy to be a zip: %v", err)
+	}
+}
+
+func TestConvertParsesSourcesConcurrentlyInInputOrder(t *testing.T) {
+	inputs := []string{buildSampleCherryBackup(t), buildSampleRikkaBackup(t), buildSampleCherryBackup(t), buildSampleRikkaBackup(t)}
+	run := func(concurrency int) *ir.Manifest {
+		manifest, err := Convert(ConvertOptions{InputPaths: inputs, SourceNames: []string{"a", "b", "c", "d"}, From: "auto", To: "cherry", DryRun: true, Concurrency: concurrency})
+		if err != nil {
+			t.Fatalf("convert with concurrency %d failed: %v", concurrency, err)
+		}
+		return manifest
+	}
+	serial, parallel := run(1), run(4)
+	if len(parallel.Sources) != len(inputs) {
+		t.Fatalf("expected %d sources, got %+v", len(inputs), parallel.Sources)
+	}
+	for i, src := range parallel.Sources {
+		if src.Index != i+1 || src.Tag != serial.Sources[i].Tag || src.SourceSHA256 != serial.Sources[i].SourceSHA256 {
+			t.Fatalf("source %d out of order: parallel=%+v serial=%+v", i, src, serial.Sources[i])
+		}
+	}
+	if strings.Join(parallel.Warnings, "\n") != strings.Join(serial.Warnings, "\n") || *parallel.DryRun != *serial.DryRun {
+		t.Fatalf("parallel parse changed the result:\nparallel=%v %+v\nserial=%v %+v", parallel.Warnings, parallel.DryRun, serial.Warnings, serial.DryRun)
+	}
+
+	tempDir := t.TempDir()
+	broken := filepath.Join(t.TempDir(), "broken.zip")
+	if err := os.WriteFile(broken, []byte("not a zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Convert(ConvertOptions{InputPaths: append([]string{broken}, inputs...), From: "auto", To: "cherry", DryRun: true, Concurrency: 4, TempDir: tempDir}); err == nil {
+		t.Fatalf("expected a broken input to fail the merge")
+	}
+	if entries, err := os.ReadDir(tempDir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected every extraction cleaned up, got=%v err=%v", entries, err)
+	}
+}
+
+func TestCherryExtraIndexedDBTablesSurviveRikkaRoundTrip(t *testing.T) {
+	dir := unzipTemp(t, buildSampleCherryBackup(t))
+	b, err := os.ReadFile(filepath.Join(dir, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	data["indexedDB"].(map[string]any)["knowledge_bases"] = []any{map[string]any{"id": "kb-1", "name": "Docs"}}
+	if b, err = json.Marshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "with-kb.zip")
+	zipDir(t, dir, src)
+
+	rikkaZip := filepath.Join(t.TempDir(), "rikka.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: rikkaZip, From: "auto", To: "rikka"}); err != nil {
+		t.Fatalf("convert to rikka failed: %v", err)
+	}
+	backZip := filepath.Join(t.TempDir(), "back.zip")
+	if _, err := Convert(ConvertOptions{InputPath: rikkaZip, OutputPath: backZip, From: "auto", To: "cherry"}); err != nil {
+		t.Fatalf("convert back to cherry failed: %v", err)
+	}
+	b, err = os.ReadFile(filepath.Join(unzipTemp(t, backZip), "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root struct {
+		IndexedDB struct {
+			KnowledgeBases []map[string]any `json:"knowledge_bases"`
+		} `json:"indexedDB"`
+	}
+	if err := json.Unmarshal(b, &root); err != nil {
+		t.Fatal(err)
+	}
+	if len(root.IndexedDB.KnowledgeBases) != 1 || root.IndexedDB.KnowledgeBases[0]["id"] != "kb-1" {
+		t.Fatalf("expected knowledge_bases restored from the sidecar, got=%v", root.IndexedDB.KnowledgeBases)
+	}
+}
+
+func TestExtractFilesWritesPayloadsByName(t *testing.T) {
+	payloadDir := t.TempDir()
+	irData := buildSampleIR()
+	irData.Files = nil
+	for i, content := range []string{"one", "two", "three"} {
+		p := filepath.Join(payloadDir, fmt.Sprintf("p%d", i))
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		name := "photo.png"
+		if i == 2 {
+			name = "notes.pdf"
+		}
+		irData.Files = append(irData.Files, ir.IRFile{ID: fmt.Sprintf("file-%d", i), Name: name, Ext: filepath.Ext(name), SourcePath: p})
+	}
+	irData.Conversations[0].Messages[1].Parts[2].FileID = "file-0"
+	dir := t.TempDir()
+	if _, err := rikka.BuildFromIR(context.Background(), irData, dir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build rikka from IR failed: %v", err)
+	}
+	removed := false
+	_ = filepath.Walk(filepath.Join(dir, "upload"), func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !removed {
+			if b, _ := os.ReadFile(p); string(b) == "three" {
+				removed = os.Remove(p) == nil
+			}
+		}
+		return nil
+	})
+	if !removed {
+		t.Fatalf("expected to remove the notes.pdf payload")
+	}
+	src := filepath.Join(t.TempDir(), "files.zip")
+	zipDir(t, dir, src)
+
+	out := t.TempDir()
+	if err := os.WriteFile(filepath.Join(out, "photo.png"), []byte("existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ExtractFiles(context.Background(), src, out)
+	if err != nil {
+		t.Fatalf("extract-files failed: %v", err)
+	}
+	if res.Written != 2 || res.Skipped != 1 || len(res.Warnings) != 1 || !strings.HasPrefix(res.Warnings[0], "extract-file-missing:") {
+		t.Fatalf("unexpected summary %+v", res)
+	}
+	got := map[string]string{}
+	for _, name := range []string{"photo.png", "photo-2.png", "photo-3.png"} {
+		b, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+		got[name] = string(b)
+	}
+	if photos := got["photo-2.png"] + got["photo-3.png"]; got["photo.png"] != "existing" || (photos != "onetwo" && photos != "twoone") {
+		t.Fatalf("expected both photos written beside the existing file, got=%v", got)
+	}
+}
+
+func TestCurrentBuildInfoReportsInjectedVersion(t *testing.T) {
+	old := Version
+	Version = "v1.2.3"
+	defer func() { Version = old }()
+	info := CurrentBuildInfo()
+	if info.Version != "v1.2.3" || info.SchemaVersion != ManifestSchemaVersion {
+		t.Fatalf("unexpected build info %+v", info)
+	}
+	if strings.Join(info.SourceFormats, ",") != "cherry,rikka" || strings.Join(info.TargetFormats, ",") != "cherry,rikka,ir" {
+		t.Fatalf("unexpected formats %+v", info)
+	}
+	Version = ""
+	if v := CurrentBuildInfo().Version; v == "" {
+		t.Fatalf("expected a fallback version")
+	}
+}
+
+func TestRikkaAssistantLorebooksSurviveCherryRoundTrip(t *testing.T) {
+	const assistantID = "6f1c2b7e-3d4a-4c5b-9e8f-0a1b2c3d4e5f"
+	const lorebookID = "0d9e8f7a-6b5c-4d3e-8f1a-2b3c4d5e6f70"
+	irData := buildSampleIR()
+	irData.SourceFormat = "rikka"
+	irData.Assistants[0].ID = assistantID
+	irData.Conversations[0].AssistantID = assistantID
+	irData.Config["rikka.settings"] = map[string]any{
+		"assistantId": assistantID,
+		"assistants": []any{map[string]any{
+			"id":          assistantID,
+			"name":        "Sample Assistant",
+			"lorebookIds": []any{lorebookID},
+		}},
+		"lorebooks": []any{map[string]any{"id": lorebookID, "name": "World"}},
+	}
+	src := writeRikkaBackup(t, irData)
+
+	cherryOut := filepath.Join(t.TempDir(), "cherry.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: cherryOut, From: "rikka", To: "cherry"}); err != nil {
+		t.Fatalf("convert to cherry failed: %v", err)
+	}
+	rikkaOut := filepath.Join(t.TempDir(), "rikka.zip")
+	if _, err := Convert(ConvertOptions{InputPath: cherryOut, OutputPath: rikkaOut, From: "cherry", To: "rikka"}); err != nil {
+		t.Fatalf("convert back to rikka failed: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(unzipTemp(t, rikkaOut), "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var settings map[string]any
+	if err := json.Unmarshal(b, &settings); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, item := range asSlice(settings["assistants"]) {
+		a := asMap(item)
+		if str(a["id"]) != assistantID {
+			continue
+		}
+		found = true
+		if ids := asSlice(a["lorebookIds"]); len(ids) != 1 || str(ids[0]) != lorebookID {
+			t.Fatalf("expected assistant to keep its lorebookIds, got=%v", a["lorebookIds"])
+		}
+	}
+	if !found {
+		t.Fatalf("assistant %s missing after round trip: %v", assistantID, settings["assistants"])
+	}
+}
+
+func TestConvertRejectsOversizedSidecarRawSource(t *testing.T) {
+	cherryOut := filepath.Join(t.TempDir(), "cherry.zip")
+	if _, err := Convert(ConvertOptions{InputPath: buildSampleRikkaBackup(t), OutputPath: cherryOut, From: "rikka", To: "cherry"}); err != nil {
+		t.Fatalf("convert to cherry failed: %v", err)
+	}
+	dir := unzipTemp(t, cherryOut)
+	// a small raw copy that decompresses far past the limit
+	f, err := os.Create(filepath.Join(dir, "cherrikka", "raw", "source.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("rikka_hub.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 4<<20)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	crafted := filepath.Join(t.TempDir(), "crafted.zip")
+	zipDir(t, dir, crafted)
+
+	_, err = Convert(ConvertOptions{
+		InputPath:     crafted,
+		OutputPath:    filepath.Join(t.TempDir(), "out.zip"),
+		From:          "cherry",
+		To:            "rikka",
+		ExtractLimits: ExtractLimits{MaxBytes: 1 << 20},
+	})
+	if !errors.Is(err, backup.ErrExtractLimit) {
+		t.Fatalf("expected the nested raw source to hit the extract limit, got %v", err)
+	}
+}
+
+func TestConvertToIRExternalizeSecretsKeepsKeysOutOfDump(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "dump.json")
+	if _, err := Convert(ConvertOptions{InputPath: buildSampleRikkaBackup(t), OutputPath: out, From: "auto", To: "ir", ExternalizeSecrets: true}); err != nil {
+		t.Fatalf("convert to ir failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret-key") || !strings.Contains(string(b), "${cherrikka-secret:") {
+		t.Fatalf("expected placeholders and no key material in the ir dump: %s", b)
+	}
+	secrets, err := os.ReadFile(out + ".secrets.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(secrets), "secret-key") {
+		t.Fatalf("expected the key in the secrets file, got %s", secrets)
+	}
+}
+
+func TestCheckProvidersKeepsGoogleKeyOutOfProbeErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "" || r.Header.Get("x-goog-api-key") != "google-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, `{"models":[]}`)
+	}))
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	irData := buildSampleIR()
+	irData.Config["rikka.settings"] = map[string]any{
+		"providers": []any{
+			map[string]any{"id": "3f1a2b4c-0000-4000-8000-000000000011", "type": "google", "name": "Up", "enabled": true, "apiKey": "google-key", "baseUrl": srv.URL, "models": []any{}},
+			map[string]any{"id": "3f1a2b4c-0000-4000-8000-000000000012", "type": "google", "name": "Down", "enabled": true, "apiKey": "down-key", "baseUrl": downURL + "/v1beta?key=down-key", "models": []any{}},
+		},
+	}
+	dataDir := t.TempDir()
+	irData.Files = nil
+	if _, err := rikka.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build rikka from IR failed: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "providers.zip")
+	zipDir(t, dataDir, src)
+
+	live, err := CheckProviders(context.Background(), src, ProviderCheckOptions{Live: true, Client: srv.Client()})
+	if err != nil {
+		t.Fatalf("live check providers failed: %v", err)
+	}
+	statuses := map[string]string{}
+	for _, p := range live.Providers {
+		statuses[p.Name] = p.Status
+		if strings.Contains(p.Error, "down-key") || strings.Contains(p.Error, "google-key") {
+			t.Fatalf("api key leaked into the probe error: %q", p.Error)
+		}
+	}
+	if statuses["Up"] != ProviderStatusOK || statuses["Down"] != ProviderStatusUnreachable {
+		t.Fatalf("unexpected probe statuses: %+v", live.Providers)
+	}
+}
+
+func TestConvertStripReasoningThenDropsReasoningOnlyMessages(t *testing.T) {
+	irData := buildSampleIR()
+	irData.Conversations[0].Messages = append(irData.Conversations[0].Messages, ir.IRMessage{
+		ID:    "msg-3",
+		Role:  "assistant",
+		Parts: []ir.IRPart{{Type: "reasoning", Content: "only thoughts"}},
+	})
+	out := filepath.Join(t.TempDir(), "dump.json")
+	manifest, err := Convert(ConvertOptions{InputPath: writeRikkaBackup(t, irData), OutputPath: out, From: "auto", To: "ir", StripReasoning: true})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump IRDump
+	if err := json.Unmarshal(b, &dump); err != nil {
+		t.Fatal(err)
+	}
+	messages := dump.IR.Conversations[0].Messages
+	if len(messages) != 2 {
+		t.Fatalf("expected the reasoning-only message dropped, got %d messages (warnings=%v)", len(messages), manifest.Warnings)
+	}
+	for _, msg := range messages {
+		if isEmptyMessage(msg) {
+			t.Fatalf("empty message %s left in the output", msg.ID)
+		}
+	}
+}
+
+func TestMergeIDsIgnoreSourceTags(t *testing.T) {
+	merge := func(tags ...string) *ir.BackupIR {
+		sources := make([]parsedSource, 0, len(tags))
+		for i, tag := range tags {
+			sources = append(sources, parsedSource{Index: i + 1, Tag: tag, Format: "rikka", IR: buildSampleIR()})
+		}
+		merged, _, err := mergeSources(sources, MergeOptions{TargetFormat: "rikka"})
+		if err != nil {
+			t.Fatalf("merge failed: %v", err)
+		}
+		return merged
+	}
+	a, b := merge("phone", "laptop"), merge("renamed-phone", "Work-Laptop")
+	for i := range a.Conversations {
+		if a.Conversations[i].ID != b.Conversations[i].ID || a.Conversations[i].Messages[0].ID != b.Conversations[i].Messages[0].ID {
+			t.Fatalf("conversation ids changed with the source tags: %s vs %s", a.Conversations[i].ID, b.Conversations[i].ID)
+		}
+	}
+	for i := range a.Assistants {
+		if a.Assistants[i].ID != b.Assistants[i].ID {
+			t.Fatalf("assistant ids changed with the source tags: %s vs %s", a.Assistants[i].ID, b.Assistants[i].ID)
+		}
+	}
+	for i := range a.Files {
+		if a.Files[i].ID != b.Files[i].ID {
+			t.Fatalf("file ids changed with the source tags: %s vs %s", a.Files[i].ID, b.Files[i].ID)
+		}
+	}
+}
+
+func TestConvertSelectsMergedConversationsBySourceID(t *testing.T) {
+	first := buildSampleIR()
+	second := buildSampleIR()
+	second.Conversations[0].ID = "5b8e2f0c-1d3a-4c6e-9f21-7a4b8c9d0e12"
+	second.Conversations[0].Title = "Second Source Conversation"
+	srcA, srcB := writeRikkaBackup(t, first), writeRikkaBackup(t, second)
+
+	out := filepath.Join(t.TempDir(), "merged.zip")
+	manifest, err := Convert(ConvertOptions{InputPaths: []string{srcA, srcB}, OutputPath: out, From: "auto", To: "rikka", IncludeConversations: []string{"5b8e2f0c-1d3a-4c6e-9f21-7a4b8c9d0e12"}})
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if !containsString(strings.Join(manifest.Warnings, ","), "conversations-selected:1/2") {
+		t.Fatalf("expected the source conversation id to select one merged conversation, warnings=%v", manifest.Warnings)
+	}
+	res, err := Inspect(out)
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	if res.Conversations != 1 {
+		t.Fatalf("expected 1 conversation, got %d", res.Conversations)
+	}
+}
+
+func TestRikkaBranchAlternatesGetStripAndFileDrops(t *testing.T) {
+	dir := unzipTemp(t, buildSampleRikkaBackup(t))
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodeID, messagesJSON string
+	if err := db.QueryRow(`SELECT id, messages FROM message_node ORDER BY node_index DESC LIMIT 1`).Scan(&nodeID, &messagesJSON); err != nil {
+		t.Fatal(err)
+	}
+	var messages []map[string]any
+	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+		t.Fatal(err)
+	}
+	// the alternate carries the same reasoning and document parts as the selected answer
+	alternate := map[string]any{}
+	for k, v := range messages[0] {
+		alternate[k] = v
+	}
+	alternate["id"] = "alt-1"
+	alternate["parts"] = append(asSlice(messages[0]["parts"]), map[string]any{"type": "me.rerere.ai.ui.UIMessagePart.Text", "text": "alternate answer"})
+	if !containsString(util.MustJSON(alternate), `"reasoning"`) || !containsString(util.MustJSON(alternate), "file://") {
+		t.Fatalf("fixture alternate lacks reasoning or a file part: %s", util.MustJSON(alternate))
+	}
+	if _, err := db.Exec(`UPDATE message_node SET messages = ?, select_index = 1 WHERE id = ?`, util.MustJSON(append([]map[string]any{alternate}, messages...)), nodeID); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	src := filepath.Join(t.TempDir(), "branched.zip")
+	zipDir(t, dir, src)
+
+	out := filepath.Join(t.TempDir(), "filtered.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: out, From: "auto", To: "rikka", AllowSameFormat: true, StripReasoning: true, MaxFileBytes: 5}); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	outDB, err := sql.Open("sqlite", filepath.Join(unzipTemp(t, out), "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outDB.Close()
+	var selectIndex int
+	if err := outDB.QueryRow(`SELECT messages, select_index FROM message_node ORDER BY node_index DESC LIMIT 1`).Scan(&messagesJSON, &selectIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || selectIndex != 1 {
+		t.Fatalf("expected both branches kept, got select_index=%d messages=%s", selectIndex, messagesJSON)
+	}
+	alt := util.MustJSON(messages[0])
+	if containsString(alt, `"reasoning"`) || containsString(alt, "file://") || !containsString(alt, "alternate answer") {
+		t.Fatalf("expected the alternate without reasoning or dropped file parts, got %s", alt)
+	}
+}
+
+func TestCherryMessageStatusSurvivesRikkaRoundTrip(t *testing.T) {
+	irData := buildSampleIR()
+	irData.Conversations[0].Messages[1].Opaque = map[string]any{"cherry.status": "paused"}
+	filePath := filepath.Join(t.TempDir(), "sample.txt")
+	if err := os.WriteFile(filePath, []byte("sample file content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	irData.Files[0].SourcePath = filePath
+	dataDir := t.TempDir()
+	if _, err := cherry.BuildFromIR(context.Background(), irData, dataDir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build cherry from IR failed: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "paused_cherry.zip")
+	zipDir(t, dataDir, src)
+
+	rikkaOut := filepath.Join(t.TempDir(), "rikka.zip")
+	if _, err := Convert(ConvertOptions{InputPath: src, OutputPath: rikkaOut, From: "cherry", To: "rikka"}); err != nil {
+		t.Fatalf("convert to rikka failed: %v", err)
+	}
+	cherryOut := filepath.Join(t.TempDir(), "cherry.zip")
+	manifest, err := Convert(ConvertOptions{InputPath: rikkaOut, OutputPath: cherryOut, From: "rikka", To: "cherry"})
+	if err != nil {
+		t.Fatalf("convert back to cherry failed: %v", err)
+	}
+	parsed, err := parseByFormat(backup.FormatCherry, unzipTemp(t, cherryOut), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []string{}
+	for _, msg := range parsed.Conversations[0].Messages {
+		states = append(states, str(msg.Opaque["cherry.status"]))
+	}
+	if len(states) != 2 || states[0] != "" || states[1] != "paused" {
+		t.Fatalf("expected the paused status restored on the assistant message, got=%v warnings=%v", states, manifest.Warnings)
+	}
+}
+
+func TestSidecarWithoutRawSourcesOmitsRawDir(t *testing.T) {
+	dir := t.TempDir()
+	sources := []parsedSource{{Index: 1, SourceBytes: []byte("zip")}}
+	manifest := &ir.Manifest{SourceFormat: "cherry", TargetFormat: "rikka"}
+	if err := writeSidecar(dir, sources, 0, manifest, &ir.ConversionReport{}, false); err != nil {
+		t.Fatalf("write sidecar: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cherrikka", "raw")); !os.IsNotExist(err) {
+		t.Fatalf("expected no raw/ directory without raw sources, stat err=%v", err)
+	}
+	if readme := buildSidecarReadme(manifest, buildSampleIR(), time.UTC, false); strings.Contains(readme, "raw/") {
+		t.Fatalf("readme mentions raw/ although nothing was embedded:\n%s", readme)
+	}
+	if readme := buildSidecarReadme(manifest, buildSampleIR(), time.UTC, true); !strings.Contains(readme, "raw/") {
+		t.Fatalf("readme should point at raw/ when sources are embedded:\n%s", readme)
+	}
+}
@@ -0,0 +1,334 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/ir"
+	"cherrikka/internal/mapping"
+	"cherrikka/internal/util"
+)
+
+type DiffSide struct {
+	Path          string `json:"path"`
+	Format        string `json:"format"`
+	Conversations int    `json:"conversations"`
+	Assistants    int    `json:"assistants"`
+	Providers     int    `json:"providers"`
+	Files         int    `json:"files"`
+}
+
+type DiffConversation struct {
+	Title        string `json:"title"`
+	FirstMessage string `json:"firstMessage,omitempty"`
+	Messages     int    `json:"messages"`
+}
+
+type DiffFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// DiffSection lists what exists on only one side of the diff.
+type DiffSection struct {
+	Conversations []DiffConversation `json:"conversations"`
+	Assistants    []string           `json:"assistants"`
+	Providers     []string           `json:"providers"`
+	Files         []DiffFile         `json:"files"`
+	Settings      []string           `json:"settings"`
+}
+
+type DiffCount struct {
+	Key   string `json:"key"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// DiffChanges lists what exists on both sides with different content.
+type DiffChanges struct {
+	Conversations []DiffCount `json:"conversations"`
+	Files         []DiffCount `json:"files"`
+	Settings      []string    `json:"settings"`
+}
+
+type BackupDiff struct {
+	Left      DiffSide    `json:"left"`
+	Right     DiffSide    `json:"right"`
+	Identical bool        `json:"identical"`
+	Added     DiffSection `json:"added"`
+	Removed   DiffSection `json:"removed"`
+	Changed   DiffChanges `json:"changed"`
+}
+
+// DiffBackups parses two backups and compares them structurally: conversations by
+// title and first message, assistants and providers by name, files by SHA256, and
+// normalized settings by leaf path. "Added" is what only the right backup has.
+func DiffBackups(ctx context.Context, leftPath, rightPath string) (*BackupDiff, error) {
+	left, err := loadDiffSide(ctx, leftPath)
+	if err != nil {
+		return nil, err
+	}
+	right, err := loadDiffSide(ctx, rightPath)
+	if err != nil {
+		return nil, err
+	}
+	out := &BackupDiff{
+		Left:    left.side,
+		Right:   right.side,
+		Added:   newDiffSection(),
+		Removed: newDiffSection(),
+		Changed: DiffChanges{Conversations: []DiffCount{}, Files: []DiffCount{}, Settings: []string{}},
+	}
+	diffConversations(left.data, right.data, out)
+	out.Added.Assistants, out.Removed.Assistants = diffNames(assistantNames(left.data), assistantNames(right.data))
+	out.Added.Providers, out.Removed.Providers = diffNames(providerNames(left.data), providerNames(right.data))
+	diffFiles(left.files, right.files, out)
+	diffSettings(left.data.Settings, right.data.Settings, out)
+	out.Identical = len(out.Added.Conversations)+len(out.Added.Assistants)+len(out.Added.Providers)+len(out.Added.Files)+len(out.Added.Settings) == 0 &&
+		len(out.Removed.Conversations)+len(out.Removed.Assistants)+len(out.Removed.Providers)+len(out.Removed.Files)+len(out.Removed.Settings) == 0 &&
+		len(out.Changed.Conversations)+len(out.Changed.Files)+len(out.Changed.Settings) == 0
+	return out, nil
+}
+
+type diffInput struct {
+	side  DiffSide
+	data  *ir.BackupIR
+	files []DiffFile
+}
+
+// loadDiffSide parses a backup and hashes its payloads before the extraction is removed.
+func loadDiffSide(ctx context.Context, path string) (*diffInput, error) {
+	workDir, cleanup, err := extractToTemp(ctx, "", path, ExtractLimits{})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	workDir, d, err := detectWorkDir(workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	if d.Format == backup.FormatUnknown {
+		return nil, fmt.Errorf("unknown backup format: %s", path)
+	}
+	parsed, err := parseByFormat(d.Format, workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	mapping.EnsureNormalizedSettings(parsed)
+	files := make([]DiffFile, 0, len(parsed.Files))
+	for _, f := range parsed.Files {
+		hash := f.HashSHA256
+		if hash == "" && f.SourcePath != "" && !f.Missing {
+			if hash, err = util.SHA256File(f.SourcePath); err != nil {
+				return nil, fmt.Errorf("hash %s: %w", f.Name, err)
+			}
+		}
+		files = append(files, DiffFile{Name: f.Name, SHA256: hash})
+	}
+	return &diffInput{
+		side: DiffSide{
+			Path:          path,
+			Format:        string(d.Format),
+			Conversations: len(parsed.Conversations),
+			Assistants:    len(parsed.Assistants),
+			Providers:     len(asSlice(parsed.Settings["core.providers"])),
+			Files:         len(parsed.Files),
+		},
+		data:  parsed,
+		files: files,
+	}, nil
+}
+
+func newDiffSection() DiffSection {
+	return DiffSection{Conversations: []DiffConversation{}, Assistants: []string{}, Providers: []string{}, Files: []DiffFile{}, Settings: []string{}}
+}
+
+func diffConversations(left, right *ir.BackupIR, out *BackupDiff) {
+	index := func(data *ir.BackupIR) (map[string][]DiffConversation, []string) {
+		byKey := map[string][]DiffConversation{}
+		order := []string{}
+		for _, conv := range data.Conversations {
+			c := DiffConversation{Title: strings.TrimSpace(conv.Title), FirstMessage: firstMessageText(conv), Messages: len(conv.Messages)}
+			key := c.Title + "\x00" + c.FirstMessage
+			if len(byKey[key]) == 0 {
+				order = append(order, key)
+			}
+			byKey[key] = append(byKey[key], c)
+		}
+		return byKey, order
+	}
+	leftByKey, leftOrder := index(left)
+	rightByKey, rightOrder := index(right)
+	for _, key := range leftOrder {
+		l, r := leftByKey[key], rightByKey[key]
+		for i, c := range l {
+			if i >= len(r) {
+				out.Removed.Conversations = append(out.Removed.Conversations, c)
+				continue
+			}
+			if c.Messages != r[i].Messages {
+				out.Changed.Conversations = append(out.Changed.Conversations, DiffCount{Key: c.Title, Left: fmt.Sprintf("%d messages", c.Messages), Right: fmt.Sprintf("%d messages", r[i].Messages)})
+			}
+		}
+	}
+	for _, key := range rightOrder {
+		l, r := leftByKey[key], rightByKey[key]
+		if len(r) > len(l) {
+			out.Added.Conversations = append(out.Added.Conversations, r[len(l):]...)
+		}
+	}
+}
+
+// firstMessageText is the first text part of the first message, trimmed to keep the
+// diff readable.
+func firstMessageText(conv ir.IRConversation) string {
+	if len(conv.Messages) == 0 {
+		return ""
+	}
+	for _, p := range conv.Messages[0].Parts {
+		if text := strings.TrimSpace(p.Content); p.Type == "text" && text != "" {
+			if r := []rune(text); len(r) > 80 {
+				return string(r[:80])
+			}
+			return text
+		}
+	}
+	return ""
+}
+
+func assistantNames(data *ir.BackupIR) []string {
+	out := make([]string, 0, len(data.Assistants))
+	for _, a := range data.Assistants {
+		out = append(out, strings.TrimSpace(a.Name))
+	}
+	return out
+}
+
+func providerNames(data *ir.BackupIR) []string {
+	out := []string{}
+	for _, item := range asSlice(data.Settings["core.providers"]) {
+		out = append(out, strings.TrimSpace(str(asMap(item)["name"])))
+	}
+	return out
+}
+
+// diffNames compares two name multisets and returns (only right, only left).
+func diffNames(left, right []string) ([]string, []string) {
+	counts := map[string]int{}
+	for _, n := range left {
+		counts[n]++
+	}
+	added := []string{}
+	for _, n := range right {
+		if counts[n] > 0 {
+			counts[n]--
+			continue
+		}
+		added = append(added, n)
+	}
+	removed := []string{}
+	for _, n := range left {
+		if counts[n] > 0 {
+			counts[n]--
+			removed = append(removed, n)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// diffFiles matches files by hash; unmatched files with the same name on both sides
+// are reported as changed.
+func diffFiles(left, right []DiffFile, out *BackupDiff) {
+	hashes := map[string]int{}
+	for _, f := range left {
+		hashes[f.SHA256]++
+	}
+	onlyRight := []DiffFile{}
+	for _, f := range right {
+		if hashes[f.SHA256] > 0 {
+			hashes[f.SHA256]--
+			continue
+		}
+		onlyRight = append(onlyRight, f)
+	}
+	onlyLeft := []DiffFile{}
+	for _, f := range left {
+		if hashes[f.SHA256] > 0 {
+			hashes[f.SHA256]--
+			onlyLeft = append(onlyLeft, f)
+		}
+	}
+	rightByName := map[string][]int{}
+	for i, f := range onlyRight {
+		rightByName[f.Name] = append(rightByName[f.Name], i)
+	}
+	paired := map[int]bool{}
+	for _, f := range onlyLeft {
+		if candidates := rightByName[f.Name]; len(candidates) > 0 {
+			out.Changed.Files = append(out.Changed.Files, DiffCount{Key: f.Name, Left: f.SHA256, Right: onlyRight[candidates[0]].SHA256})
+			paired[candidates[0]] = true
+			rightByName[f.Name] = candidates[1:]
+			continue
+		}
+		out.Removed.Files = append(out.Removed.Files, f)
+	}
+	for i, f := range onlyRight {
+		if !paired[i] {
+			out.Added.Files = append(out.Added.Files, f)
+		}
+	}
+}
+
+// diffSettings compares normalized settings leaves. The raw per-app copies and ids,
+// which are regenerated by every conversion, are left out.
+func diffSettings(left, right map[string]any, out *BackupDiff) {
+	leftLeaves, rightLeaves := settingsLeaves(left), settingsLeaves(right)
+	for p, lv := range leftLeaves {
+		rv, ok := rightLeaves[p]
+		if !ok {
+			out.Removed.Settings = append(out.Removed.Settings, p)
+			continue
+		}
+		if lv != rv {
+			out.Changed.Settings = append(out.Changed.Settings, p)
+		}
+	}
+	for p := range rightLeaves {
+		if _, ok := leftLeaves[p]; !ok {
+			out.Added.Settings = append(out.Added.Settings, p)
+		}
+	}
+	sort.Strings(out.Added.Settings)
+	sort.Strings(out.Removed.Settings)
+	sort.Strings(out.Changed.Settings)
+}
+
+func settingsLeaves(settings map[string]any) map[string]string {
+	trimmed := map[string]any{}
+	for key, v := range settings {
+		if strings.HasPrefix(key, "raw.") || strings.HasPrefix(key, "normalizer.") {
+			continue
+		}
+		trimmed[key] = v
+	}
+	leaves := map[string][]any{}
+	collectConfigLeaves("", trimmed, leaves)
+	out := map[string]string{}
+	for p, values := range leaves {
+		if leafName(p) == "id" || strings.Contains(p, ".raw.") || strings.HasSuffix(p, ".raw") {
+			continue
+		}
+		encoded := make([]string, 0, len(values))
+		for _, v := range values {
+			encoded = append(encoded, util.MustJSON(v))
+		}
+		sort.Strings(encoded)
+		out[p] = strings.Join(encoded, ",")
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package app
+
+import "strings"
+
+type WarningExplanation struct {
+	Code    string `json:"code"`
+	Pattern string `json:"pattern"`
+	Meaning string `json:"meaning"`
+	Fix     string `json:"fix"`
+}
+
+// Patterns ending in ":" match any code with that prefix; the longest match wins.
+var knownWarnings = []WarningExplanation{
+	{Pattern: "provider-invalid-disabled:model-selection-fallback:", Meaning: "The selected model for this slot could not be found among the converted providers, so the first available model was used instead.", Fix: "Pick the intended model again in the target app, or make sure the source provider that owns it is enabled and has models."},
+	{Pattern: "provider-invalid-disabled:", Meaning: "A provider had no usable models and was disabled in the output.", Fix: "Add models to the provider in the target app, or remove it if it is no longer needed."},
+	{Pattern: "provider-azure-fields-dropped:", Meaning: "An Azure OpenAI provider was converted to a RikkaHub OpenAI-compatible provider pointing at the Azure /openai/v1 endpoint; RikkaHub has no field for the listed Azure settings, so they are kept only in the sidecar.", Fix: "Use the deployment name as the model id in RikkaHub; nothing else to fix unless your resource requires a specific API version."},
+	{Pattern: "skip unsupported canonical provider mapping to rikka", Meaning: "A provider type has no RikkaHub equivalent and was left out of the output.", Fix: "Recreate the provider manually in RikkaHub using an OpenAI-compatible endpoint if the service offers one."},
+	{Pattern: "unsupported cherry provider type: ", Meaning: "A Cherry Studio provider type is not known to cherrikka; it is kept as-is but may not map cleanly.", Fix: "Check the provider after import and adjust its type and endpoint if needed."},
+	{Pattern: "normalized unsupported model type to CHAT: ", Meaning: "A model type not supported by RikkaHub was converted to a chat model.", Fix: "Change the model type in RikkaHub if it is actually an embedding or image model."},
+	{Pattern: "selected model ", Meaning: "A model selection pointed at a model that does not exist in the output, so it was reset to the first available model.", Fix: "Pick the intended model again in the target app settings."},
+	{Pattern: "selected assistant not found, fallback to first assistant", Meaning: "The selected assistant was missing from the output, so the first assistant was selected.", Fix: "Select the intended assistant again in the target app."},
+	{Pattern: "settings.json is not a JSON object, treating it as empty settings", Meaning: "The RikkaHub settings.json was not a JSON object, so settings were skipped and only the database content was converted.", Fix: "Re-export the backup from RikkaHub, or reconfigure providers and assistants in the target app."},
+	{Pattern: "rikka-wal-present:may-contain-uncommitted-data", Meaning: "The RikkaHub backup has a non-empty rikka_hub-wal with changes not yet written into rikka_hub.db; they were applied to a temporary copy of the database before reading it.", Fix: "Nothing to fix; if data still looks stale, close RikkaHub fully before backing up."},
+	{Pattern: "data.json root is an array, using its only element", Meaning: "The Cherry Studio data.json was wrapped in an array; its single element was used as the backup.", Fix: "Nothing to fix; this is informational."},
+	{Pattern: "data.json root is not a JSON object, treating it as an empty backup", Meaning: "The Cherry Studio data.json did not contain a backup object, so no settings or conversations could be read from it.", Fix: "Re-export the backup from Cherry Studio."},
+	{Pattern: "sidecar-rehydrate:applied", Meaning: "The input was produced by cherrikka and the original backup in its sidecar was used to restore settings the intermediate format could not hold.", Fix: "Nothing to fix; this is informational."},
+	{Pattern: "sidecar-rehydrate:model-selection", Meaning: "Model selection slots recorded in the sidecar manifest were restored.", Fix: "Nothing to fix; this is informational."},
+	{Pattern: "sidecar-rehydrate:source-format-mismatch", Meaning: "The sidecar holds a backup in a different format than the target, so it could not be used for restoring settings.", Fix: "Nothing to fix; convert from the original backup if settings are missing."},
+	{Pattern: "sidecar-rehydrate:multiple-source-candidates", Meaning: "The sidecar holds several backups in the target format; the first one was used to restore settings.", Fix: "Convert from the specific original backup if a different one should win."},
+	{Pattern: "sidecar-rehydrate:raw-sources-absent", Meaning: "The input was produced by cherrikka without the raw source copies (--no-raw-sidecar), so settings the intermediate format cannot hold could not be restored.", Fix: "Convert from the original backup if settings are missing."},
+	{Pattern: "sidecar-rehydrate:", Meaning: "The sidecar could not be fully used to restore original settings.", Fix: "Convert from the original backup instead of a previously converted one if settings are missing."},
+	{Pattern: "sidecar-raw-omitted:secrets-externalized", Meaning: "The raw source copies were left out of the sidecar because they contain the secrets that were externalized.", Fix: "Keep the original backup if you need a lossless round trip later."},
+	{Pattern: "same-format-conversion-is-lossy", Meaning: "The backup was converted to its own format; it went through the intermediate format, so anything cherrikka does not model was normalized away or dropped.", Fix: "Keep the original backup; use validate or check instead if you only wanted to inspect it."},
+	{Pattern: "config-keys-unmapped:", Meaning: "Some source config keys were not found anywhere in the converted settings; the full list is in the manifest's unmappedKeys.", Fix: "Review unmappedKeys and set anything you need manually in the target app."},
+	{Pattern: "unsupported-isolated:", Meaning: "Settings the target app cannot represent were set aside and kept in the sidecar.", Fix: "Nothing to fix; convert back with cherrikka to restore them."},
+	{Pattern: "file-missing-skipped:", Meaning: "An attachment had no payload in the source backup, so it was left out and its message references were replaced by a text note.", Fix: "Re-export the source backup including its files, or drop --no-empty-placeholder to keep an empty placeholder."},
+	{Pattern: "extract-file-missing:", Meaning: "An attachment has no payload in the backup, so extract-files had nothing to write for it.", Fix: "Re-export the source backup including its files."},
+	{Pattern: "file-omitted:", Meaning: "An attachment was larger than --limit-media-size and was replaced by a text note.", Fix: "Raise or remove --limit-media-size to keep the attachment."},
+	{Pattern: "file-hash-mismatch:", Meaning: "A file's SHA256 differs from the one recorded in the manifest; the backup may be corrupted or was edited.", Fix: "Re-export the backup from the source app or use the original file."},
+	{Pattern: "file-hash-unrecorded:", Meaning: "A file is present in the backup but not recorded in the manifest.", Fix: "Nothing to fix unless you did not add the file yourself."},
+	{Pattern: "file-hash-unreadable:", Meaning: "A file recorded in the manifest could not be read for hashing.", Fix: "Check that the backup extracted completely and re-run validation."},
+	{Pattern: "file-hash-manifest-invalid:", Meaning: "The sidecar manifest could not be parsed, so hashes were not verified.", Fix: "Re-run the conversion to regenerate the manifest."},
+	{Pattern: "conversations-excluded:archived:", Meaning: "Archived or hidden conversations were left out because --exclude-archived was set.", Fix: "Run without --exclude-archived to keep them."},
+	{Pattern: "conversations-selected:", Meaning: "Only conversations matching --include-conversation and not --exclude-conversation were converted (kept/total).", Fix: "Adjust the include/exclude patterns; they match the conversation id or a case-insensitive title substring."},
+	{Pattern: "files-selected:", Meaning: "Attachments referenced only by conversations skipped by --include-conversation/--exclude-conversation were left out.", Fix: "Include the conversations that use them."},
+	{Pattern: "filtered-conversations:undated-kept:", Meaning: "Conversations without a parseable creation or update time cannot be placed in --since/--until and were kept.", Fix: "Fix their timestamps in the source app, or use --exclude-conversation to leave them out."},
+	{Pattern: "filtered-conversations:", Meaning: "Conversations last updated outside --since/--until were dropped from each source before merging.", Fix: "Widen or remove --since/--until to keep them."},
+	{Pattern: "filtered-files:", Meaning: "Attachments referenced only by conversations dropped by --since/--until were left out.", Fix: "Widen or remove --since/--until to keep them."},
+	{Pattern: "conversations-sliced:undated:", Meaning: "Conversations with no parseable creation, update or message time were left out of the date-range slice.", Fix: "Run without --from-date/--to-date to keep them, or fix their timestamps in the source app."},
+	{Pattern: "conversations-sliced:", Meaning: "Only conversations whose last activity falls inside --from-date/--to-date were kept (kept/total).", Fix: "Widen the date range, or slice the remaining ranges into further backups."},
+	{Pattern: "files-sliced:", Meaning: "Files referenced only by conversations outside the date range were left out.", Fix: "Widen the date range to keep the conversations that use them."},
+	{Pattern: "titles-rederived:", Meaning: "Conversation titles were replaced using the --title-from strategy.", Fix: "Run with --title-from keep to leave the original titles alone."},
+	{Pattern: "models-pruned:", Meaning: "Provider models not used by any assistant or model selection were left out because --prune-unused-models was set.", Fix: "Run without --prune-unused-models to keep every model, or re-add the models you need in the target app."},
+	{Pattern: "messages-dropped:empty:", Meaning: "Blank placeholder messages were removed from conversations.", Fix: "Run with --drop-empty-messages=false to keep them."},
+	{Pattern: "branches-flattened:", Meaning: "Alternate (regenerated) rikka responses were dropped because --flatten-branches was set; only the selected branch of each message remains.", Fix: "Run without --flatten-branches to keep the alternates where the target can hold them."},
+	{Pattern: "reasoning-stripped:", Meaning: "Reasoning (chain-of-thought) parts were removed because --strip-reasoning was set.", Fix: "Run without --strip-reasoning to keep them."},
+	{Pattern: "defaults-applied:assistant:", Meaning: "The backup had no assistants, so the assistant from --default-assistant-name/--default-assistant-prompt was created and every conversation assigned to it.", Fix: "Nothing to fix; rename or edit the assistant in the target app if needed."},
+	{Pattern: "defaults-applied:model:", Meaning: "The backup had no provider models, so the provider and model from --default-provider/--default-model were added and selected; it has no API key yet.", Fix: "Add the provider's API key in the target app, or pass --set-key."},
+	{Pattern: "provider-key-override-unmatched:", Meaning: "A --set-key override named a provider that is not in the backup, so no key was replaced.", Fix: "Use the provider's id or name as shown by check-providers."},
+	{Pattern: "conversation-bind-unmatched:assistant:", Meaning: "A --bind override named an assistant that is not in the converted backup, so its conversation kept its assistant.", Fix: "Use the assistant's id or name; after a merge, renamed assistants carry a source suffix."},
+	{Pattern: "conversation-bind-unmatched:conversation:", Meaning: "A --bind override named a conversation id that is not in the converted backup.", Fix: "Use the conversation id as written to the output; merged conversations get new ids, listed in the manifest idMap."},
+	{Pattern: "conversations-rebound:", Meaning: "Conversations were reassigned to the assistants named with --bind.", Fix: "Nothing to fix; this is informational."},
+	{Pattern: "secrets-externalized:", Meaning: "Secrets were moved into a separate keyfile and replaced with placeholders in the backup.", Fix: "Keep the keyfile safe; pass it with --secrets-file to restore the secrets later."},
+	{Pattern: "secret-unresolved:", Meaning: "A secret placeholder had no value in the supplied keyfile and was left in place.", Fix: "Pass the keyfile written by the original --externalize-secrets run."},
+	{Pattern: "multi-source-merge:", Meaning: "Several backups were merged into one output.", Fix: "Nothing to fix; this is informational."},
+	{Pattern: "settings-override:", Meaning: "A settings group was taken from the source pinned with --settings-from instead of the primary source.", Fix: "Nothing to fix; this is informational."},
+	{Pattern: "merge-file-deduped:", Meaning: "Attachments with this SHA256 in several sources were written once; every message now references the surviving copy.", Fix: "Nothing to fix; run without --dedupe-files to keep one copy per source."},
+	{Pattern: "merge-assistant-renamed:", Meaning: "Two merged assistants had the same name, so one was renamed.", Fix: "Rename the assistant in the target app if the suffix is unwanted."},
+	{Pattern: "merge-conversation-rebound:", Meaning: "A merged conversation pointed at an assistant that was not available and was reassigned.", Fix: "Move the conversation to the intended assistant in the target app."},
+	{Pattern: "merge-file-path-collision:", Meaning: "Two merged backups had files at the same path; one was stored under a new name.", Fix: "Nothing to fix; message references were updated."},
+	{Pattern: "ir-invalid:missing-file:", Meaning: "Before building, a message part referenced a file id that is not among the backup's files; the target may show a broken attachment.", Fix: "Re-export the source backup including its files, or use --no-empty-placeholder."},
+	{Pattern: "ir-invalid:missing-assistant:", Meaning: "Before building, a conversation referenced an assistant that is not in the backup; the target binds it to a fallback assistant.", Fix: "Reassign the conversation to the intended assistant in the target app."},
+	{Pattern: "ir-invalid:duplicate-id:", Meaning: "Before building, the same id was used by two entities of one kind, so one may overwrite the other in the target.", Fix: "Convert the inputs separately to find the source that carries the duplicate."},
+	{Pattern: "ir-cache:write-failed:", Meaning: "The parsed source could not be written to --ir-cache; the conversion itself is unaffected.", Fix: "Check that the cache directory is writable."},
+	{Pattern: "merge-file-reference-missing:", Meaning: "A message referenced a file that was not present in any merged backup.", Fix: "Re-export the source backup including its files."},
+	{Pattern: "compat-count-failed:", Meaning: "The compatibility check could not count some records.", Fix: "Run validate on the backup to look for database problems."},
+}
+
+// ExplainWarning looks up a warning code in the table of known codes.
+func ExplainWarning(code string) (WarningExplanation, bool) {
+	code = strings.TrimSpace(code)
+	best := -1
+	for i, known := range knownWarnings {
+		if code != known.Pattern && !(isPrefixPattern(known.Pattern) && strings.HasPrefix(code, known.Pattern)) {
+			continue
+		}
+		if best < 0 || len(known.Pattern) > len(knownWarnings[best].Pattern) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return WarningExplanation{}, false
+	}
+	out := knownWarnings[best]
+	out.Code = code
+	return out, true
+}
+
+func isPrefixPattern(pattern string) bool {
+	return strings.HasSuffix(pattern, ":") || strings.HasSuffix(pattern, " ")
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/util"
+)
+
+type ExtractFilesResult struct {
+	Format   string   `json:"format"`
+	Output   string   `json:"output"`
+	Written  int      `json:"written"`
+	Skipped  int      `json:"skipped"`
+	Bytes    int64    `json:"bytes"`
+	Warnings []string `json:"warnings"`
+}
+
+// ExtractFiles copies every attachment payload of a backup into outputDir under its
+// original name, adding -2, -3... on collisions with each other or with files already
+// there. Files without a payload are skipped with a warning.
+func ExtractFiles(ctx context.Context, path, outputDir string) (*ExtractFilesResult, error) {
+	workDir, cleanup, err := extractToTemp(ctx, "", path, ExtractLimits{})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	workDir, d, err := detectWorkDir(workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	if d.Format == backup.FormatUnknown {
+		return nil, fmt.Errorf("unknown backup format: %s", path)
+	}
+	parsed, err := parseByFormat(d.Format, workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := util.EnsureDir(outputDir); err != nil {
+		return nil, err
+	}
+	used := map[string]struct{}{}
+	if entries, err := os.ReadDir(outputDir); err == nil {
+		for _, e := range entries {
+			used[filepath.Join(outputDir, e.Name())] = struct{}{}
+		}
+	}
+
+	out := &ExtractFilesResult{Format: string(d.Format), Output: outputDir, Warnings: []string{}}
+	for _, f := range parsed.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if f.Missing || f.SourcePath == "" {
+			out.Skipped++
+			out.Warnings = append(out.Warnings, "extract-file-missing:"+f.ID)
+			continue
+		}
+		fallback := util.SanitizeFileName(f.ID, "file") + util.SanitizeFileExt(f.Ext)
+		dst := uniqueOutputPath(filepath.Join(outputDir, util.SanitizeFileName(f.Name, fallback)), used)
+		if err := util.CopyFile(f.SourcePath, dst); err != nil {
+			return nil, fmt.Errorf("extract %s: %w", f.Name, err)
+		}
+		if st, err := os.Stat(dst); err == nil {
+			out.Bytes += st.Size()
+		}
+		out.Written++
+	}
+	return out, nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cherrikka/internal/util"
+)
+
+const (
+	defaultMaxDownloadBytes = int64(1 << 30)
+	defaultDownloadTimeout  = 5 * time.Minute
+)
+
+type FetchOptions struct {
+	MaxBytes int64         // refuse downloads larger than this; 0 uses 1 GiB
+	Timeout  time.Duration // whole-request timeout; 0 uses 5 minutes
+	TempDir  string        // parent for the download dir; see ConvertOptions.TempDir
+	Client   *http.Client
+}
+
+// FetchInput downloads an http(s) backup into a temp directory and returns the local
+// path. The file keeps the URL's base name so manifests still show a useful source.
+func FetchInput(ctx context.Context, rawURL string, opts FetchOptions) (string, func(), error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", nil, fmt.Errorf("--input-url must be an http or https url: %s", redactURL(rawURL))
+	}
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxDownloadBytes
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultDownloadTimeout
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// transport errors quote the request url, which may carry a signed query
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		return "", nil, fmt.Errorf("download %s: %w", redactURL(rawURL), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, fmt.Errorf("download %s: %s", redactURL(rawURL), resp.Status)
+	}
+	if resp.ContentLength > maxBytes {
+		return "", nil, fmt.Errorf("download %s: %d bytes exceeds --max-download-size %d", redactURL(rawURL), resp.ContentLength, maxBytes)
+	}
+
+	tmp, err := makeTempDir(opts.TempDir, "cherrikka-fetch-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tmp) }
+	target := filepath.Join(tmp, util.SanitizeFileName(path.Base(u.Path), "download.zip"))
+	f, err := os.Create(target)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, maxBytes+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("download %s: %w", redactURL(rawURL), err)
+	}
+	if n > maxBytes {
+		cleanup()
+		return "", nil, fmt.Errorf("download %s: exceeds --max-download-size %d", redactURL(rawURL), maxBytes)
+	}
+	return target, cleanup, nil
+}
+
+// redactURL drops the query and credentials, which often carry signed tokens.
+func redactURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "<invalid url>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cherrikka/internal/ir"
+	"cherrikka/internal/util"
+)
+
+type hashVerification struct {
+	Verified   int
+	Mismatches []string
+	Warnings   []string
+	checked    map[string]struct{}
+}
+
+// hashBuildPayloads records the SHA256 of every output entry outside the sidecar directory.
+func hashBuildPayloads(buildDir string) (map[string]string, error) {
+	paths, err := util.ListFiles(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, rel := range paths {
+		if strings.HasPrefix(rel, "cherrikka/") {
+			continue
+		}
+		hash, err := util.SHA256File(filepath.Join(buildDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", rel, err)
+		}
+		out[rel] = hash
+	}
+	return out, nil
+}
+
+func verifyRecordedHashes(workDir string) *hashVerification {
+	res := &hashVerification{checked: map[string]struct{}{}}
+	b, ok, err := util.ReadFileIfExists(filepath.Join(workDir, "cherrikka", "manifest.json"))
+	if err != nil || !ok {
+		return res
+	}
+	var manifest ir.Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		res.Warnings = append(res.Warnings, "file-hash-manifest-invalid:"+err.Error())
+		return res
+	}
+	paths := make([]string, 0, len(manifest.FileHashes))
+	for rel := range manifest.FileHashes {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	for _, rel := range paths {
+		res.checked[rel] = struct{}{}
+		actual, err := util.SHA256File(filepath.Join(workDir, filepath.FromSlash(rel)))
+		if err != nil {
+			res.Warnings = append(res.Warnings, "file-hash-unreadable:"+rel)
+			continue
+		}
+		res.compare(rel, manifest.FileHashes[rel], actual)
+	}
+	return res
+}
+
+func (v *hashVerification) verifyIRFiles(workDir string, data *ir.BackupIR) {
+	if data == nil {
+		return
+	}
+	unrecorded := 0
+	for i := range data.Files {
+		file := &data.Files[i]
+		if file.Missing || file.SourcePath == "" {
+			continue
+		}
+		rel, err := filepath.Rel(workDir, file.SourcePath)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if _, ok := v.checked[rel]; ok {
+			continue
+		}
+		v.checked[rel] = struct{}{}
+		actual, err := util.SHA256File(file.SourcePath)
+		if err != nil {
+			v.Warnings = append(v.Warnings, "file-hash-unreadable:"+rel)
+			continue
+		}
+		if file.HashSHA256 == "" {
+			file.HashSHA256 = actual
+		}
+		expected := ""
+		for _, key := range []string{"hashSha256", "sha256"} {
+			if s := strings.TrimSpace(str(file.Metadata[key])); s != "" {
+				expected = s
+				break
+			}
+		}
+		if expected == "" {
+			unrecorded++
+			continue
+		}
+		v.compare(rel, expected, actual)
+	}
+	if unrecorded > 0 {
+		v.Warnings = append(v.Warnings, fmt.Sprintf("file-hash-unrecorded:%d", unrecorded))
+	}
+}
+
+func (v *hashVerification) compare(rel, expected, actual string) {
+	if strings.EqualFold(strings.TrimSpace(expected), actual) {
+		v.Verified++
+		return
+	}
+	v.Mismatches = append(v.Mismatches, "file-hash-mismatch:"+rel)
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/util"
+)
+
+const integrityManifestPath = "cherrikka/files.sha256"
+
+type IntegrityResult struct {
+	Valid      bool     `json:"valid"`
+	Verified   int      `json:"verified"`
+	Mismatched []string `json:"mismatched,omitempty"`
+	Missing    []string `json:"missing,omitempty"`
+	Unlisted   []string `json:"unlisted,omitempty"`
+}
+
+// writeIntegrityManifest writes a sha256sum-style listing of entries plus the
+// already-hashed streamed entries to cherrikka/files.sha256 and returns entries
+// with the listing appended.
+func writeIntegrityManifest(buildDir string, entries []backup.ZipEntry, streamed map[string]string) ([]backup.ZipEntry, error) {
+	hashes := make(map[string]string, len(entries)+len(streamed))
+	for rel, hash := range streamed {
+		hashes[rel] = hash
+	}
+	for _, e := range entries {
+		if e.Path == integrityManifestPath {
+			continue
+		}
+		hash, err := util.SHA256File(e.SourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", e.Path, err)
+		}
+		hashes[e.Path] = hash
+	}
+	paths := make([]string, 0, len(hashes))
+	for rel := range hashes {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	for _, rel := range paths {
+		fmt.Fprintf(&b, "%s  %s\n", hashes[rel], rel)
+	}
+	target := filepath.Join(buildDir, filepath.FromSlash(integrityManifestPath))
+	if err := util.EnsureDir(filepath.Dir(target)); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(target, []byte(b.String()), 0o644); err != nil {
+		return nil, err
+	}
+	return append(entries, backup.ZipEntry{Path: integrityManifestPath, SourcePath: target}), nil
+}
+
+// VerifyIntegrity recomputes the hash of every file listed in cherrikka/files.sha256
+// and reports mismatched, missing and unlisted entries.
+func VerifyIntegrity(zipPath string) (*IntegrityResult, error) {
+	zr, err := backup.OpenZip(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var listing *zip.File
+	prefix := ""
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(path.Clean(strings.ReplaceAll(f.Name, "\\", "/")), "/")
+		if name == integrityManifestPath {
+			listing, prefix = f, ""
+			break
+		}
+		// tolerate backups re-zipped inside a single top-level directory
+		if listing == nil && strings.HasSuffix(name, "/"+integrityManifestPath) && strings.Count(name, "/") == 2 {
+			listing, prefix = f, strings.TrimSuffix(name, integrityManifestPath)
+		}
+	}
+	if listing == nil {
+		return nil, fmt.Errorf("no integrity manifest found in %s (convert with --integrity-manifest)", zipPath)
+	}
+
+	expected, err := readIntegrityListing(listing)
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]*zip.File{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean(strings.ReplaceAll(f.Name, "\\", "/")), "/")
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		entries[strings.TrimPrefix(name, prefix)] = f
+	}
+
+	res := &IntegrityResult{}
+	paths := make([]string, 0, len(expected))
+	for rel := range expected {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	for _, rel := range paths {
+		f, ok := entries[rel]
+		if !ok {
+			res.Missing = append(res.Missing, rel)
+			continue
+		}
+		actual, err := hashZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", rel, err)
+		}
+		if !strings.EqualFold(actual, expected[rel]) {
+			res.Mismatched = append(res.Mismatched, rel)
+			continue
+		}
+		res.Verified++
+	}
+	for rel := range entries {
+		if _, ok := expected[rel]; !ok && rel != integrityManifestPath {
+			res.Unlisted = append(res.Unlisted, rel)
+		}
+	}
+	sort.Strings(res.Unlisted)
+	res.Valid = len(res.Mismatched) == 0 && len(res.Missing) == 0 && len(res.Unlisted) == 0
+	return res, nil
+}
+
+func readIntegrityListing(f *zip.File) (map[string]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	out := map[string]string{}
+	scanner := bufio.NewScanner(rc)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hash, rel, ok := strings.Cut(text, "  ")
+		if !ok || len(hash) != sha256.Size*2 || strings.TrimSpace(rel) == "" {
+			return nil, fmt.Errorf("invalid integrity manifest line %d", line)
+		}
+		out[strings.TrimSpace(rel)] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func hashZipFile(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
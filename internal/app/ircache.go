@@ -0,0 +1,116 @@
+package app
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/ir"
+	"cherrikka/internal/util"
+)
+
+// irCacheVersion is bumped whenever the parsers change what they put into the IR,
+// so stale caches are ignored instead of replayed.
+const irCacheVersion = 2
+
+// irCacheEntry is the structural parse of one source. File payloads are not cached:
+// SourcePaths holds each file's path relative to the extracted work dir, in Files order,
+// and is rebased onto the current extraction on load.
+type irCacheEntry struct {
+	Version     int
+	Format      string
+	IR          *ir.BackupIR
+	SourcePaths []string
+}
+
+func init() {
+	// dynamic types found in the IR's map[string]any fields
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+	gob.Register([]string{})
+	gob.Register(map[string]string{})
+	gob.Register([]map[string]any{})
+	gob.Register(time.Time{})
+}
+
+// parseWithCache parses workDir, reusing a cached IR for the same source SHA256 and
+// format when cacheDir is set. Cache problems never fail the conversion; they are
+// reported as warnings and the source is parsed normally.
+func parseWithCache(cacheDir, tempDir, sourceSHA string, format backup.Format, workDir string) (*ir.BackupIR, []string, error) {
+	if cacheDir == "" {
+		parsed, err := parseByFormat(format, workDir, tempDir)
+		return parsed, nil, err
+	}
+	cachePath := filepath.Join(cacheDir, fmt.Sprintf("%s-%s.gob", sourceSHA, format))
+	if parsed, ok := loadIRCache(cachePath, format, workDir); ok {
+		return parsed, nil, nil
+	}
+	parsed, err := parseByFormat(format, workDir, tempDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := storeIRCache(cachePath, format, workDir, parsed); err != nil {
+		return parsed, []string{"ir-cache:write-failed:" + err.Error()}, nil
+	}
+	return parsed, nil, nil
+}
+
+func loadIRCache(cachePath string, format backup.Format, workDir string) (*ir.BackupIR, bool) {
+	b, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, false
+	}
+	var entry irCacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&entry); err != nil {
+		return nil, false
+	}
+	if entry.Version != irCacheVersion || entry.Format != string(format) || entry.IR == nil || len(entry.SourcePaths) != len(entry.IR.Files) {
+		return nil, false
+	}
+	for i := range entry.IR.Files {
+		if rel := entry.SourcePaths[i]; rel != "" {
+			entry.IR.Files[i].SourcePath = filepath.Join(workDir, filepath.FromSlash(rel))
+		}
+	}
+	return entry.IR, true
+}
+
+func storeIRCache(cachePath string, format backup.Format, workDir string, parsed *ir.BackupIR) error {
+	entry := irCacheEntry{Version: irCacheVersion, Format: string(format), IR: parsed, SourcePaths: make([]string, len(parsed.Files))}
+	for i, f := range parsed.Files {
+		if f.SourcePath == "" {
+			continue
+		}
+		rel, err := filepath.Rel(workDir, f.SourcePath)
+		if err != nil {
+			return err
+		}
+		entry.SourcePaths[i] = filepath.ToSlash(rel)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&entry); err != nil {
+		return err
+	}
+	if err := util.EnsureDir(filepath.Dir(cachePath)); err != nil {
+		return err
+	}
+	// write-then-rename so a concurrent conversion never reads a partial cache
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".ir-cache-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), cachePath)
+}
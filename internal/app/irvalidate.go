@@ -0,0 +1,106 @@
+package app
+
+import (
+	"strings"
+
+	"cherrikka/internal/ir"
+)
+
+const (
+	IssueMissingFile      = "missing-file"
+	IssueMissingAssistant = "missing-assistant"
+	IssueDuplicateID      = "duplicate-id"
+)
+
+// Issue is one broken cross-reference inside an IR. Path locates the referencing
+// (or duplicated) entity, Ref is the id that does not resolve.
+type Issue struct {
+	Code string `json:"code"`
+	Path string `json:"path"`
+	Ref  string `json:"ref"`
+}
+
+func (i Issue) String() string {
+	return i.Code + ":" + i.Path + ":" + i.Ref
+}
+
+// ValidateIR checks the references the builders rely on: every part FileID names a
+// file, every conversation AssistantID names an assistant, and no id is used twice
+// within assistants, conversations, messages or files.
+func ValidateIR(data *ir.BackupIR) []Issue {
+	issues := []Issue{}
+	if data == nil {
+		return issues
+	}
+	assistants, conversations, messages, files := []string{}, []string{}, []string{}, []string{}
+	for _, a := range data.Assistants {
+		assistants = append(assistants, a.ID)
+	}
+	for _, c := range data.Conversations {
+		conversations = append(conversations, c.ID)
+		for _, m := range c.Messages {
+			messages = append(messages, m.ID)
+		}
+	}
+	for _, f := range data.Files {
+		files = append(files, f.ID)
+	}
+	issues = append(issues, duplicateIRIDs("assistants", assistants)...)
+	issues = append(issues, duplicateIRIDs("conversations", conversations)...)
+	issues = append(issues, duplicateIRIDs("messages", messages)...)
+	issues = append(issues, duplicateIRIDs("files", files)...)
+
+	fileIDs := map[string]struct{}{}
+	for _, f := range data.Files {
+		fileIDs[strings.TrimSpace(f.ID)] = struct{}{}
+	}
+	assistantIDs := map[string]struct{}{}
+	for _, a := range data.Assistants {
+		assistantIDs[strings.TrimSpace(a.ID)] = struct{}{}
+	}
+	for _, c := range data.Conversations {
+		if id := strings.TrimSpace(c.AssistantID); id != "" {
+			if _, ok := assistantIDs[id]; !ok {
+				issues = append(issues, Issue{Code: IssueMissingAssistant, Path: "conversations/" + c.ID, Ref: id})
+			}
+		}
+		for _, m := range c.Messages {
+			issues = appendMissingFileIssues(issues, "conversations/"+c.ID+"/messages/"+m.ID, m.Parts, fileIDs)
+		}
+	}
+	return issues
+}
+
+func appendMissingFileIssues(issues []Issue, path string, parts []ir.IRPart, fileIDs map[string]struct{}) []Issue {
+	for _, p := range parts {
+		if id := strings.TrimSpace(p.FileID); id != "" {
+			if _, ok := fileIDs[id]; !ok {
+				issues = append(issues, Issue{Code: IssueMissingFile, Path: path, Ref: id})
+			}
+		}
+		issues = appendMissingFileIssues(issues, path, p.Output, fileIDs)
+	}
+	return issues
+}
+
+// duplicateIRIDs reports every id seen more than once, in first-seen order.
+func duplicateIRIDs(scope string, ids []string) []Issue {
+	counts := map[string]int{}
+	order := []string{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		if counts[id] == 0 {
+			order = append(order, id)
+		}
+		counts[id]++
+	}
+	issues := []Issue{}
+	for _, id := range order {
+		if counts[id] > 1 {
+			issues = append(issues, Issue{Code: IssueDuplicateID, Path: scope, Ref: id})
+		}
+	}
+	return issues
+}
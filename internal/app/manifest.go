@@ -0,0 +1,54 @@
+package app
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/ir"
+)
+
+const sidecarManifestPath = "cherrikka/manifest.json"
+
+// ReadManifest returns the sidecar manifest embedded in a converted backup without extracting it.
+func ReadManifest(zipPath string) (*ir.Manifest, error) {
+	zr, err := backup.OpenZip(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var found *zip.File
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(path.Clean(strings.ReplaceAll(f.Name, "\\", "/")), "/")
+		if name == sidecarManifestPath {
+			found = f
+			break
+		}
+		// tolerate backups re-zipped inside a single top-level directory
+		if found == nil && strings.HasSuffix(name, "/"+sidecarManifestPath) && strings.Count(name, "/") == 2 {
+			found = f
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no cherrikka manifest found in %s (not produced by cherrikka?)", zipPath)
+	}
+	rc, err := found.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ir.Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid cherrikka manifest: %w", err)
+	}
+	return &manifest, nil
+}
@@ -9,14 +9,17 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	guuid "github.com/google/uuid"
 
 	"cherrikka/internal/ir"
+	"cherrikka/internal/util"
 )
 
 type MergedSourceMeta struct {
 	Index      int
+	Tag        string
 	Name       string
 	SourceApp  string
 	Format     string
@@ -29,11 +32,19 @@ type MergeOptions struct {
 	TargetFormat      string
 	ConfigPrecedence  string
 	ConfigSourceIndex int
+	// SettingsOverrides pins normalized settings keys (or key groups such as "sync")
+	// to a 1-based source index, applied over the primary source's settings.
+	SettingsOverrides map[string]int
+	// DedupeFiles collapses files with the same SHA256 from different sources into the
+	// first one seen and points every part at it.
+	DedupeFiles bool
 }
 
 type MergeReport struct {
 	PrimarySourceIndex int
 	Sources            []MergedSourceMeta
+	RenamedAssistants  []ir.ReportRename
+	DedupedFiles       int
 	Warnings           []string
 }
 
@@ -46,6 +57,7 @@ type parsedSource struct {
 	Hints       []string
 	SHA256      string
 	LatestUnix  int64
+	Counts      ir.ReportCounts // as parsed, before filters and merge
 	SourceBytes []byte
 	IR          *ir.BackupIR
 }
@@ -58,6 +70,11 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 	if err != nil {
 		return nil, nil, err
 	}
+	for key, idx := range opts.SettingsOverrides {
+		if idx <= 0 || idx > len(sources) {
+			return nil, nil, fmt.Errorf("--settings-from %s=%d must be within 1..%d", key, idx, len(sources))
+		}
+	}
 
 	report := &MergeReport{
 		PrimarySourceIndex: primary + 1,
@@ -66,6 +83,7 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 	for _, src := range sources {
 		report.Sources = append(report.Sources, MergedSourceMeta{
 			Index:      src.Index,
+			Tag:        src.Tag,
 			Name:       src.Name,
 			SourceApp:  src.IR.SourceApp,
 			Format:     src.Format,
@@ -96,6 +114,7 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 	}
 
 	mergeWarnings := []string{fmt.Sprintf("multi-source-merge:count=%d", len(sources))}
+	mergeWarnings = append(mergeWarnings, applySettingsOverrides(merged.Settings, sources, opts.SettingsOverrides)...)
 	opaqueSources := map[string]any{}
 
 	assistantBySource := map[int]map[string]string{}
@@ -109,11 +128,11 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 			cloned := cloneAssistant(assistant)
 			oldID := strings.TrimSpace(cloned.ID)
 			if oldID == "" {
-				oldID = deterministicUUID("", fmt.Sprintf("merge:%s:assistant:missing:%s", src.Tag, cloned.Name))
+				oldID = deterministicUUID("", fmt.Sprintf("merge:%s:assistant:missing:%s", src.idSeed(), cloned.Name))
 			}
-			newID := deterministicUUID("", fmt.Sprintf("merge:%s:assistant:%s:%s", src.Tag, oldID, cloned.Name))
+			newID := deterministicUUID("", fmt.Sprintf("merge:%s:assistant:%s:%s", src.idSeed(), oldID, cloned.Name))
 			if _, exists := usedAssistantIDs[newID]; exists {
-				newID = deterministicUUID("", fmt.Sprintf("merge:%s:assistant:%s:%s:dup", src.Tag, oldID, cloned.Name))
+				newID = deterministicUUID("", fmt.Sprintf("merge:%s:assistant:%s:%s:dup", src.idSeed(), oldID, cloned.Name))
 			}
 			usedAssistantIDs[newID] = struct{}{}
 			assistantBySource[src.Index][oldID] = newID
@@ -121,6 +140,10 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 				assistantBySource[src.Index][strings.TrimSpace(cloned.ID)] = newID
 			}
 			cloned.ID = newID
+			cloned.Opaque[mergeSourceSeedKey] = src.idSeed()
+			if sourceID := strings.TrimSpace(assistant.ID); sourceID != "" {
+				cloned.Opaque[mergeSourceIDKey] = sourceID
+			}
 
 			originalName := strings.TrimSpace(cloned.Name)
 			if originalName == "" {
@@ -131,6 +154,7 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 			if _, exists := usedAssistantNames[nameKey]; exists {
 				cloned.Name = uniqueAssistantName(cloned.Name, src.Tag, usedAssistantNames)
 				mergeWarnings = append(mergeWarnings, fmt.Sprintf("merge-assistant-renamed:%s:%s", originalName, cloned.Name))
+				report.RenamedAssistants = append(report.RenamedAssistants, ir.ReportRename{SourceIndex: src.Index, From: originalName, To: cloned.Name})
 			} else {
 				usedAssistantNames[nameKey] = struct{}{}
 			}
@@ -152,16 +176,31 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 	fileBySource := map[int]map[string]string{}
 	usedRelPath := map[string]struct{}{}
 	usedCherryStem := map[string]struct{}{}
+	type dedupeSurvivor struct {
+		id     string
+		source int
+	}
+	survivorByHash := map[string]dedupeSurvivor{}
 	for _, src := range sources {
 		fileBySource[src.Index] = map[string]string{}
 		for _, file := range src.IR.Files {
 			cloned := cloneFile(file)
 			oldID := strings.TrimSpace(cloned.ID)
 			if oldID == "" {
-				oldID = deterministicUUID("", fmt.Sprintf("merge:%s:file:missing:%s", src.Tag, cloned.Name))
+				oldID = deterministicUUID("", fmt.Sprintf("merge:%s:file:missing:%s", src.idSeed(), cloned.Name))
+			}
+			hash := strings.ToLower(strings.TrimSpace(cloned.HashSHA256))
+			if survivor, ok := survivorByHash[hash]; ok && opts.DedupeFiles && survivor.source != src.Index {
+				fileBySource[src.Index][oldID] = survivor.id
+				mergeWarnings = append(mergeWarnings, "merge-file-deduped:"+hash)
+				report.DedupedFiles++
+				continue
 			}
-			newID := deterministicUUID("", fmt.Sprintf("merge:%s:file:%s:%s:%s", src.Tag, oldID, cloned.Name, cloned.HashSHA256))
+			newID := deterministicUUID("", fmt.Sprintf("merge:%s:file:%s:%s:%s", src.idSeed(), oldID, cloned.Name, cloned.HashSHA256))
 			fileBySource[src.Index][oldID] = newID
+			if _, seen := survivorByHash[hash]; hash != "" && !cloned.Missing && !seen {
+				survivorByHash[hash] = dedupeSurvivor{id: newID, source: src.Index}
+			}
 			cloned.ID = newID
 			if cloned.Metadata == nil {
 				cloned.Metadata = map[string]any{}
@@ -206,14 +245,18 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 			clonedConv := cloneConversation(conv)
 			oldID := strings.TrimSpace(clonedConv.ID)
 			if oldID == "" {
-				oldID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:missing:%s", src.Tag, clonedConv.Title))
+				oldID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:missing:%s", src.idSeed(), clonedConv.Title))
 			}
-			newConvID := deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:%s", src.Tag, oldID, clonedConv.Title))
+			newConvID := deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:%s", src.idSeed(), oldID, clonedConv.Title))
 			if _, exists := usedConversationIDs[newConvID]; exists {
-				newConvID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:%s:dup", src.Tag, oldID, clonedConv.Title))
+				newConvID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:%s:dup", src.idSeed(), oldID, clonedConv.Title))
 			}
 			usedConversationIDs[newConvID] = struct{}{}
 			clonedConv.ID = newConvID
+			clonedConv.Opaque[mergeSourceSeedKey] = src.idSeed()
+			if sourceID := strings.TrimSpace(conv.ID); sourceID != "" {
+				clonedConv.Opaque[mergeSourceIDKey] = sourceID
+			}
 
 			if remapped, ok := sourceAssistantMap[strings.TrimSpace(conv.AssistantID)]; ok && remapped != "" {
 				clonedConv.AssistantID = remapped
@@ -228,9 +271,9 @@ func mergeSources(sources []parsedSource, opts MergeOptions) (*ir.BackupIR, *Mer
 			for mi, msg := range clonedConv.Messages {
 				oldMsgID := strings.TrimSpace(msg.ID)
 				if oldMsgID == "" {
-					oldMsgID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:message:%d", src.Tag, oldID, mi))
+					oldMsgID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:message:%d", src.idSeed(), oldID, mi))
 				}
-				msg.ID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:message:%s:%d", src.Tag, oldID, oldMsgID, mi))
+				msg.ID = deterministicUUID("", fmt.Sprintf("merge:%s:conversation:%s:message:%s:%d", src.idSeed(), oldID, oldMsgID, mi))
 				msg.Parts = remapMessageParts(msg.Parts, sourceFileMap, &mergeWarnings)
 				clonedConv.Messages[mi] = msg
 			}
@@ -297,11 +340,7 @@ func choosePrimarySourceIndex(sources []parsedSource, opts MergeOptions) (int, e
 func inferLatestUnixMillis(sourcePath string, data *ir.BackupIR) int64 {
 	best := int64(0)
 	parse := func(raw string) {
-		raw = strings.TrimSpace(raw)
-		if raw == "" {
-			return
-		}
-		if t, err := time.Parse(time.RFC3339, raw); err == nil && t.UnixMilli() > best {
+		if t, ok := util.ParseTimestamp(raw); ok && t.UnixMilli() > best {
 			best = t.UnixMilli()
 		}
 	}
@@ -331,6 +370,7 @@ func mergeSettingsFromSources(sources []parsedSource, primary int) map[string]an
 	}
 	appendListBySignature(out, "core.providers", asSlice(out["core.providers"]))
 	appendListBySignature(out, "core.assistants", asSlice(out["core.assistants"]))
+	appendListBySignature(out, "core.assistantTags", asSlice(out["core.assistantTags"]))
 
 	for i, src := range sources {
 		if i == primary {
@@ -339,6 +379,7 @@ func mergeSettingsFromSources(sources []parsedSource, primary int) map[string]an
 		other := src.IR.Settings
 		appendListBySignature(out, "core.providers", asSlice(other["core.providers"]))
 		appendListBySignature(out, "core.assistants", asSlice(other["core.assistants"]))
+		appendListBySignature(out, "core.assistantTags", asSlice(other["core.assistantTags"]))
 		appendListBySignature(out, "raw.unsupported", asSlice(other["raw.unsupported"]))
 		mergeMapMissing(out, "raw.cherry", asMap(other["raw.cherry"]))
 		mergeMapMissing(out, "raw.rikka", asMap(other["raw.rikka"]))
@@ -346,6 +387,60 @@ func mergeSettingsFromSources(sources []parsedSource, primary int) map[string]an
 	return out
 }
 
+// applySettingsOverrides replaces every settings key matching an override (the key
+// itself or anything below it) with the pinned source's value, dropping keys the
+// pinned source does not have.
+func applySettingsOverrides(out map[string]any, sources []parsedSource, overrides map[string]int) []string {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	// shorter groups first so "sync.webdav=2" can refine "sync=1"
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	warnings := []string{}
+	for _, group := range keys {
+		src := sources[overrides[group]-1].IR.Settings
+		matches := func(key string) bool {
+			return key == group || strings.HasPrefix(key, group+".")
+		}
+		for key := range out {
+			if matches(key) {
+				delete(out, key)
+			}
+		}
+		for key, value := range src {
+			if matches(key) {
+				out[key] = cloneAny(value)
+			}
+		}
+		warnings = append(warnings, fmt.Sprintf("settings-override:%s:S%d", group, overrides[group]))
+	}
+	return warnings
+}
+
+// ParseSettingsOverrides parses repeated key=index pairs such as "sync.webdav=2".
+func ParseSettingsOverrides(values []string) (map[string]int, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]int, len(values))
+	for _, raw := range values {
+		key, idx, ok := strings.Cut(raw, "=")
+		key = strings.TrimSpace(key)
+		n, err := strconv.Atoi(strings.TrimSpace(idx))
+		if !ok || key == "" || err != nil {
+			return nil, fmt.Errorf("--settings-from must be <settings-key>=<source-index>, got %q", raw)
+		}
+		out[key] = n
+	}
+	return out, nil
+}
+
 func appendListBySignature(dst map[string]any, key string, incoming []any) {
 	current := asSlice(dst[key])
 	seen := map[string]struct{}{}
@@ -505,6 +600,80 @@ func normalizeCherryStem(file ir.IRFile) string {
 	return candidate
 }
 
+// mergeSourceSeedKey tags merged entities with their source's idSeed (tags can repeat
+// across inputs with the same base name), and mergeSourceIDKey keeps their pre-merge
+// ID, so IDs the user copied from a source backup still resolve after mergeSources
+// rewrote them.
+const (
+	mergeSourceSeedKey = "merge.sourceSeed"
+	mergeSourceIDKey   = "merge.sourceId"
+)
+
+func hasConversationID(conv ir.IRConversation, id string) bool {
+	if id == "" {
+		return false
+	}
+	if conv.ID == id {
+		return true
+	}
+	sourceID, _ := conv.Opaque[mergeSourceIDKey].(string)
+	return sourceID == id
+}
+
+// idSeed is the source's part of merged ID seeds. It is the input position, not Tag, so
+// renaming an input file or passing --source-name keeps merged IDs stable.
+func (s parsedSource) idSeed() string {
+	return fmt.Sprintf("S%d", s.Index)
+}
+
+// mergeSourceTags names every source for merge renames ("Default (phone-backup)"):
+// the user-supplied name or else the file name without its extension, sanitized.
+// Empty or duplicate tags fall back to S<n>, so the result only depends on the inputs.
+func mergeSourceTags(paths, names []string) []string {
+	tags := make([]string, len(paths))
+	used := map[string]struct{}{}
+	for i, p := range paths {
+		candidate := ""
+		if i < len(names) {
+			candidate = names[i]
+		}
+		if strings.TrimSpace(candidate) == "" {
+			base := filepath.Base(p)
+			candidate = strings.TrimSuffix(base, filepath.Ext(base))
+		}
+		tag := sanitizeSourceTag(candidate)
+		if _, dup := used[strings.ToLower(tag)]; tag == "" || dup {
+			tag = fmt.Sprintf("S%d", i+1)
+		}
+		used[strings.ToLower(tag)] = struct{}{}
+		tags[i] = tag
+	}
+	return tags
+}
+
+// sanitizeSourceTag keeps letters, digits, "-", "_" and "."; other runs become "-".
+// Tags end up in assistant names and colon-separated warnings.
+func sanitizeSourceTag(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	tag := strings.Trim(b.String(), "-_.")
+	if runes := []rune(tag); len(runes) > 40 {
+		tag = strings.Trim(string(runes[:40]), "-_.")
+	}
+	return tag
+}
+
 func uniqueAssistantName(base, tag string, used map[string]struct{}) string {
 	trimmed := strings.TrimSpace(base)
 	if trimmed == "" {
@@ -0,0 +1,40 @@
+package app
+
+import "cherrikka/internal/backup"
+
+// Stages reported through ConvertOptions.Progress, in the order they run.
+const (
+	StageParse  = "parse"  // done/total count sources extracted and parsed
+	StageMerge  = "merge"  // 0/1 before merging sources, 1/1 after
+	StageFiles  = "files"  // done/total count attachment payloads copied by the builder
+	StageWrite  = "write"  // 0/1 before the output zip is finished, 1/1 after
+	StageVerify = "verify" // 0/1 before the written zip is re-validated
+)
+
+type hashingSink interface {
+	backup.FileSink
+	Hashes() map[string]string
+}
+
+// progressSink reports every payload the builder copies. Files without a payload
+// are never copied, so the count may stop short of total until the stage is closed.
+type progressSink struct {
+	hashingSink
+	done, total int
+	report      func(stage string, done, total int)
+}
+
+func (p *progressSink) CopyFile(rel, srcPath string) (int64, error) {
+	n, err := p.hashingSink.CopyFile(rel, srcPath)
+	if err == nil && p.done < p.total {
+		p.done++
+		p.report(StageFiles, p.done, p.total)
+	}
+	return n, err
+}
+
+func reportProgress(progress func(stage string, done, total int), stage string, done, total int) {
+	if progress != nil {
+		progress(stage, done, total)
+	}
+}
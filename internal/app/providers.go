@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/mapping"
+)
+
+const (
+	ProviderStatusOK          = "ok"
+	ProviderStatusAuthFailed  = "auth-failed"
+	ProviderStatusUnreachable = "unreachable"
+	ProviderStatusFailed      = "failed"
+	ProviderStatusSkipped     = "skipped"
+	ProviderStatusNotChecked  = "not-checked"
+)
+
+type ProviderCheckOptions struct {
+	Live    bool          // issue the network probes; without it providers are only listed
+	Timeout time.Duration // per-request timeout, default 15s
+	Client  *http.Client
+}
+
+type ProviderProbe struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	BaseURL    string `json:"baseUrl,omitempty"`
+	Status     string `json:"status"`
+	HTTPStatus int    `json:"httpStatus,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type ProviderCheckReport struct {
+	Format    string          `json:"format"`
+	Live      bool            `json:"live"`
+	Providers []ProviderProbe `json:"providers"`
+	OK        int             `json:"ok"`
+	Failed    int             `json:"failed"`
+	Skipped   int             `json:"skipped"`
+}
+
+// CheckProviders lists the providers of a backup as they would be mapped and, when
+// opts.Live is set, probes each one's models endpoint with its API key.
+func CheckProviders(ctx context.Context, path string, opts ProviderCheckOptions) (*ProviderCheckReport, error) {
+	workDir, cleanup, err := extractToTemp(ctx, "", path, ExtractLimits{})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	workDir, d, err := detectWorkDir(workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	if d.Format == backup.FormatUnknown {
+		return nil, fmt.Errorf("unknown backup format")
+	}
+	parsed, err := parseByFormat(d.Format, workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	mapping.EnsureNormalizedSettings(parsed)
+	// the rikka mapping yields canonical provider types with normalized base URLs
+	settings, _ := mapping.BuildRikkaSettingsFromIR(parsed, nil)
+
+	client := opts.Client
+	if client == nil {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = 15 * time.Second
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	report := &ProviderCheckReport{Format: string(d.Format), Live: opts.Live, Providers: []ProviderProbe{}}
+	for _, item := range asSlice(settings["providers"]) {
+		pm := asMap(item)
+		probe := ProviderProbe{Name: str(pm["name"]), Type: str(pm["type"]), BaseURL: str(pm["baseUrl"])}
+		switch {
+		case strings.TrimSpace(str(pm["apiKey"])) == "":
+			probe.Status, probe.Error = ProviderStatusSkipped, "no api key"
+		case !opts.Live:
+			probe.Status = ProviderStatusNotChecked
+		default:
+			probeProvider(ctx, client, pm, &probe)
+		}
+		switch probe.Status {
+		case ProviderStatusOK:
+			report.OK++
+		case ProviderStatusSkipped, ProviderStatusNotChecked:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+		report.Providers = append(report.Providers, probe)
+	}
+	return report, nil
+}
+
+func probeProvider(ctx context.Context, client *http.Client, provider map[string]any, probe *ProviderProbe) {
+	req, err := providerProbeRequest(ctx, provider)
+	if err != nil {
+		probe.Status, probe.Error = ProviderStatusSkipped, err.Error()
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// transport errors quote the request url, which may carry credentials
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		probe.Status, probe.Error = ProviderStatusUnreachable, err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	probe.HTTPStatus = resp.StatusCode
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		probe.Status = ProviderStatusOK
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		probe.Status = ProviderStatusAuthFailed
+	default:
+		probe.Status, probe.Error = ProviderStatusFailed, resp.Status
+	}
+}
+
+// providerProbeRequest builds the cheapest authenticated request for each canonical type:
+// a models list. Multi-key providers ("k1,k2") are probed with their first key.
+func providerProbeRequest(ctx context.Context, provider map[string]any) (*http.Request, error) {
+	key := strings.TrimSpace(strings.Split(str(provider["apiKey"]), ",")[0])
+	base := strings.TrimRight(strings.TrimSpace(str(provider["baseUrl"])), "/")
+	if base == "" {
+		return nil, fmt.Errorf("no base url")
+	}
+	switch str(provider["type"]) {
+	case "openai":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/models", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		return req, nil
+	case "claude":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/models", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-api-key", key)
+		req.Header.Set("anthropic-version", "2023-06-01")
+		return req, nil
+	case "google":
+		if vertex, _ := provider["vertexAI"].(bool); vertex {
+			return nil, fmt.Errorf("vertex ai service accounts are not probed")
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/models", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-goog-api-key", key)
+		return req, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider type: %s", str(provider["type"]))
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"strings"
+
+	"cherrikka/internal/ir"
+)
+
+func countIR(data *ir.BackupIR) ir.ReportCounts {
+	c := ir.ReportCounts{Assistants: len(data.Assistants), Conversations: len(data.Conversations), Files: len(data.Files)}
+	for _, conv := range data.Conversations {
+		c.Messages += len(conv.Messages)
+	}
+	return c
+}
+
+// dropTracker turns the count changes of the IR passes into report entries. Each
+// note compares against the previous one, so it must follow every pass that removes
+// conversations, messages or files.
+type dropTracker struct {
+	last    ir.ReportCounts
+	dropped []ir.ReportDrop
+}
+
+func newDropTracker(data *ir.BackupIR) *dropTracker {
+	return &dropTracker{last: countIR(data), dropped: []ir.ReportDrop{}}
+}
+
+func (t *dropTracker) add(stage string, before, after ir.ReportCounts) {
+	d := ir.ReportDrop{
+		Stage:         stage,
+		Conversations: max(before.Conversations-after.Conversations, 0),
+		Messages:      max(before.Messages-after.Messages, 0),
+		Files:         max(before.Files-after.Files, 0),
+	}
+	if d.Conversations+d.Messages+d.Files > 0 {
+		t.dropped = append(t.dropped, d)
+	}
+}
+
+func (t *dropTracker) note(stage string, data *ir.BackupIR) {
+	now := countIR(data)
+	t.add(stage, t.last, now)
+	t.last = now
+}
+
+func buildConversionReport(opts ConvertOptions, mergedIR *ir.BackupIR, mergeReport *MergeReport, parsedSources []parsedSource, dropped []ir.ReportDrop) *ir.ConversionReport {
+	precedence := strings.ToLower(strings.TrimSpace(opts.ConfigPrecedence))
+	if precedence == "" {
+		precedence = "latest"
+	}
+	report := &ir.ConversionReport{
+		SchemaVersion:     1,
+		Sources:           make([]ir.ReportSource, 0, len(parsedSources)),
+		Merge:             ir.ReportMerge{ConfigPrecedence: precedence},
+		RenamedAssistants: []ir.ReportRename{},
+		Dropped:           dropped,
+		Output:            countIR(mergedIR),
+	}
+	for _, src := range parsedSources {
+		report.Sources = append(report.Sources, ir.ReportSource{Index: src.Index, Tag: src.Tag, Name: src.Name, Format: src.Format, ReportCounts: src.Counts})
+	}
+	if mergeReport != nil {
+		report.Merge.PrimarySourceIndex = mergeReport.PrimarySourceIndex
+		report.Merge.DedupedFiles = mergeReport.DedupedFiles
+		report.RenamedAssistants = append(report.RenamedAssistants, mergeReport.RenamedAssistants...)
+	}
+	return report
+}
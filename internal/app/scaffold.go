@@ -0,0 +1,88 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"cherrikka/internal/ir"
+	"cherrikka/internal/mapping"
+)
+
+// DefaultAssistantOptions replaces the "Default" assistant the builders invent for
+// backups that carry no assistants.
+type DefaultAssistantOptions struct {
+	Name   string
+	Prompt string
+}
+
+func (o DefaultAssistantOptions) set() bool {
+	return strings.TrimSpace(o.Name) != "" || strings.TrimSpace(o.Prompt) != ""
+}
+
+// DefaultModelOptions adds a provider with one chat model for backups that carry
+// no provider models, instead of the placeholder model the cherry builder invents.
+type DefaultModelOptions struct {
+	Provider string // provider type, e.g. openai, anthropic, gemini; empty means openai
+	Model    string
+}
+
+func (o DefaultModelOptions) set() bool {
+	return strings.TrimSpace(o.Model) != ""
+}
+
+func validateScaffoldDefaults(opts ConvertOptions) error {
+	if strings.TrimSpace(opts.DefaultModel.Provider) != "" && !opts.DefaultModel.set() {
+		return fmt.Errorf("--default-model is required with --default-provider")
+	}
+	if opts.DefaultModel.set() && !mapping.IsKnownProviderType(fallbackProviderType(opts.DefaultModel.Provider)) {
+		return fmt.Errorf("--default-provider must be a cherry or rikka provider type such as openai, anthropic or gemini, got %q", opts.DefaultModel.Provider)
+	}
+	return nil
+}
+
+// applyScaffoldDefaults fills the entities a backup lacks with the configured
+// defaults; backups that have assistants or provider models are left alone.
+func applyScaffoldDefaults(data *ir.BackupIR, opts ConvertOptions) []string {
+	if data.Settings == nil {
+		data.Settings = map[string]any{}
+	}
+	warnings := []string{}
+	modelID := ""
+	if opts.DefaultModel.set() {
+		providerType := fallbackProviderType(opts.DefaultModel.Provider)
+		model := strings.TrimSpace(opts.DefaultModel.Model)
+		if mapping.AddDefaultProvider(data.Settings, providerType, model) {
+			modelID = model
+			warnings = append(warnings, "defaults-applied:model:"+providerType+"/"+model)
+		}
+	}
+	if opts.DefaultAssistant.set() && len(data.Assistants) == 0 && len(asSlice(data.Settings["core.assistants"])) == 0 {
+		assistant := ir.IRAssistant{
+			ID:       "default",
+			Name:     strings.TrimSpace(opts.DefaultAssistant.Name),
+			Prompt:   opts.DefaultAssistant.Prompt,
+			Settings: map[string]any{},
+		}
+		if assistant.Name == "" {
+			assistant.Name = "Default"
+		}
+		if modelID != "" {
+			assistant.Model = map[string]any{"id": modelID}
+		}
+		data.Assistants = []ir.IRAssistant{assistant}
+		data.Settings["core.assistants"] = buildCoreAssistants(data.Assistants)
+		// with no assistants every conversation pointed nowhere; give them the new one
+		for ci := range data.Conversations {
+			data.Conversations[ci].AssistantID = assistant.ID
+		}
+		warnings = append(warnings, "defaults-applied:assistant:"+assistant.Name)
+	}
+	return warnings
+}
+
+func fallbackProviderType(providerType string) string {
+	if providerType = strings.ToLower(strings.TrimSpace(providerType)); providerType != "" {
+		return providerType
+	}
+	return "openai"
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"cherrikka/internal/ir"
+	"cherrikka/internal/util"
+)
+
+func Schema(kind string) (map[string]any, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "manifest":
+		return util.JSONSchemaFor(ir.Manifest{}, "cherrikka manifest"), nil
+	case "inspect":
+		return util.JSONSchemaFor(InspectResult{}, "cherrikka inspect result"), nil
+	case "validate":
+		return util.JSONSchemaFor(ValidateResult{}, "cherrikka validate result"), nil
+	default:
+		return nil, fmt.Errorf("unsupported schema type: %s", kind)
+	}
+}
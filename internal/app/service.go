@@ -1,12 +1,15 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"cherrikka/internal/backup"
@@ -27,10 +30,11 @@ type ConfigSummary struct {
 }
 
 type FileSummary struct {
-	Total      int `json:"total"`
-	Referenced int `json:"referenced"`
-	Orphan     int `json:"orphan"`
-	Missing    int `json:"missing"`
+	Total        int `json:"total"`
+	Referenced   int `json:"referenced"`
+	Orphan       int `json:"orphan"`
+	Missing      int `json:"missing"`
+	HashVerified int `json:"hashVerified,omitempty"`
 }
 
 type InspectResult struct {
@@ -42,6 +46,18 @@ type InspectResult struct {
 	SourceApp     string         `json:"sourceApp"`
 	ConfigSummary *ConfigSummary `json:"configSummary,omitempty"`
 	FileSummary   *FileSummary   `json:"fileSummary,omitempty"`
+	FileDetails   []FileDetail   `json:"fileDetails,omitempty"` // only with InspectOptions.FileDetails
+}
+
+type FileDetail struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Ext         string `json:"ext,omitempty"`
+	LogicalType string `json:"logicalType,omitempty"`
+	Size        int64  `json:"size"`
+	HashSHA256  string `json:"hashSha256,omitempty"`
+	Orphan      bool   `json:"orphan"`
+	Missing     bool   `json:"missing"`
 }
 
 type ValidateResult struct {
@@ -54,35 +70,137 @@ type ValidateResult struct {
 	FileSummary   *FileSummary   `json:"fileSummary,omitempty"`
 }
 
+type InspectOptions struct {
+	AssumeFormat  string // cherry|rikka: parse as this format when detection fails
+	FileDetails   bool   // list every file in InspectResult.FileDetails, sorted by name
+	ExtractLimits ExtractLimits
+}
+
+type ValidateOptions struct {
+	VerifyHashes  bool   // recompute payload SHA256 and compare against recorded hashes
+	AssumeFormat  string // cherry|rikka: parse as this format when detection fails
+	ExtractLimits ExtractLimits
+}
+
+// ExtractLimits caps what extracting one input zip may write to disk, guarding against
+// zip bombs; a zero field disables that limit.
+type ExtractLimits struct {
+	MaxBytes int64 // total decompressed bytes
+	MaxFiles int64 // file entries
+}
+
+// TargetIR is the --to value that writes the merged intermediate representation as
+// JSON instead of building a backup.
+const TargetIR = "ir"
+
+// IRDump is the file written by --to ir. File payloads are not included.
+type IRDump struct {
+	Manifest *ir.Manifest `json:"manifest"`
+	Warnings []string     `json:"warnings"`
+	IR       *ir.BackupIR `json:"ir"`
+}
+
 type ConvertOptions struct {
 	InputPath         string
 	InputPaths        []string
+	InputURLs         []string      // http(s) backups downloaded to temp before conversion
+	SourceNames       []string      // merge tag per input (inputs first, then urls); empty entries use the file name
+	MaxDownloadBytes  int64         // cap for each --input-url download; 0 uses 1 GiB
+	ExtractLimits     ExtractLimits // applied to every input and the template
+	DownloadTimeout   time.Duration // per-download timeout; 0 uses 5 minutes
 	OutputPath        string
 	From              string // auto|cherry|rikka
 	To                string // cherry|rikka
 	TemplatePath      string
 	RedactSecrets     bool
-	ConfigPrecedence  string // latest|first|target|source
-	ConfigSourceIndex int    // 1-based, used when ConfigPrecedence=source
+	RedactConfig      util.RedactConfig // extra redacted key tokens and never-redacted keys, used with RedactSecrets
+	ConfigPrecedence  string            // latest|first|target|source
+	ConfigSourceIndex int               // 1-based, used when ConfigPrecedence=source
+	SettingsOverrides map[string]int    // normalized settings key or group -> 1-based source index
+	DedupeFiles       bool              // collapse files with the same SHA256 across merged sources into one payload
+	// ContentAddressedFiles uses the SHA256 as cherry file id and Data/Files name so
+	// identical attachments share one entry; the id map points every file at it.
+	ContentAddressedFiles bool
+	UserName              string                  // overrides ui.profile.userName when set
+	UserID                string                  // overrides ui.profile.userId when set
+	DefaultAssistant      DefaultAssistantOptions // replaces the synthesized assistant of backups without assistants
+	DefaultModel          DefaultModelOptions     // provider and chat model for backups without provider models
+	// ProviderKeyOverrides replaces the API key of providers matched by id or name
+	// (case-insensitive) before any secret redaction or externalization.
+	ProviderKeyOverrides map[string]string
+	// ConversationAssistant rebinds conversations (by id as written to the output, i.e.
+	// after merge) to the assistant with the given id or name.
+	ConversationAssistant map[string]string
+	MaxFileBytes          int64  // drop attachments larger than this; 0 disables
+	SkipMissingFiles      bool   // drop files without a payload and note them in messages instead of writing empty placeholders
+	EmbedReadme           bool   // write a human-readable cherrikka/README.txt next to the manifest
+	IntegrityManifest     bool   // write cherrikka/files.sha256 listing the SHA256 of every output file
+	ExcludeArchived       bool   // drop archived/hidden conversations; inverted from "IncludeArchived" so the zero value keeps them
+	FlattenBranches       bool   // keep only the selected message of each rikka branch node and drop the alternates
+	StripReasoning        bool   // remove reasoning (chain-of-thought) parts from every message
+	KeepEmptyMessages     bool   // keep messages made only of empty text parts, which are dropped by default
+	PruneUnusedModels     bool   // drop provider models not referenced by any assistant or selection slot
+	TitleStrategy         string // keep|first-user|first-message|first-assistant; empty keeps existing titles
+	AllowSameFormat       bool   // permit a single cherry->cherry or rikka->rikka conversion, which is lossy; implied when SecretsPath restores secrets
+	TempDir               string // parent for temp dirs; empty falls back to CHERRIKKA_TMPDIR, then the OS temp
+	ReportUnmapped        bool   // list source config leaf keys not found in the built target (Manifest.UnmappedKeys)
+	OmitIDMap             bool   // leave Manifest.IDMap (every topic/message/file id) out to keep the manifest small
+	OmitRawSources        bool   // skip the cherrikka/raw/ copies of the input zips; later conversions then cannot rehydrate from them
+	SkipVerifyOutput      bool   // don't re-open the finished zip with the target validator before it replaces OutputPath
+	DryRun                bool   // build into the temp dir only; no output zip or keyfile is written and Manifest.DryRun is set
+	IRCacheDir            string // reuse parsed IRs keyed by source SHA256; payloads are still extracted
+	TimeZone              string // IANA name or +hh:mm for human-facing times (README.txt, batch {date}); data stays UTC
+	FromDate              string // YYYY-MM-DD in TimeZone; keep only conversations last active on or after it
+	ToDate                string // YYYY-MM-DD in TimeZone, inclusive; keep only conversations last active on or before it
+	// IncludeConversations keeps only conversations whose id equals an entry or whose
+	// title contains it (case-insensitive); ExcludeConversations then drops matches.
+	IncludeConversations []string
+	ExcludeConversations []string
+	SinceUnixMillis      int64 // drop conversations updated before this, per source before merging; 0 disables
+	UntilUnixMillis      int64 // drop conversations updated after this, per source before merging; 0 disables
+	// ExternalizeSecrets replaces secrets with util.SecretPlaceholder values and writes them
+	// to SecretsPath (default <output>.secrets.json). Without it, a SecretsPath keyfile is
+	// used to resolve placeholders left by an earlier externalized conversion.
+	ExternalizeSecrets bool
+	SecretsPath        string
+	// Concurrency bounds how many inputs are extracted and parsed at once; 0 or 1
+	// parses them one after another. The CLI defaults it to GOMAXPROCS.
+	Concurrency int
+	// Progress, when set, is called as each stage advances, one call at a time even
+	// while sources are parsed concurrently; see the Stage* constants. done/total
+	// count sources or files.
+	Progress func(stage string, done, total int)
 }
 
 func Inspect(path string) (*InspectResult, error) {
-	workDir, cleanup, err := extractToTemp(path)
+	return InspectWithOptions(path, InspectOptions{})
+}
+
+func InspectWithOptions(path string, opts InspectOptions) (*InspectResult, error) {
+	return InspectContext(context.Background(), path, opts)
+}
+
+// InspectContext is InspectWithOptions with cancellation; the temp extraction is removed on abort.
+func InspectContext(ctx context.Context, path string, opts InspectOptions) (*InspectResult, error) {
+	workDir, cleanup, err := extractToTemp(ctx, "", path, opts.ExtractLimits)
 	if err != nil {
 		return nil, err
 	}
 	defer cleanup()
 
-	d := backup.DetectExtractedDir(workDir)
+	workDir, d, err := detectWorkDir(workDir, opts.AssumeFormat)
+	if err != nil {
+		return nil, withFromHint(err)
+	}
 	if d.Format == backup.FormatUnknown {
 		return &InspectResult{Format: "unknown", Hints: d.Hints}, nil
 	}
 
-	parsed, err := parseByFormat(d.Format, workDir)
+	parsed, err := parseByFormat(d.Format, workDir, "")
 	if err != nil {
 		return nil, err
 	}
-	return &InspectResult{
+	res := &InspectResult{
 		Format:        string(d.Format),
 		Hints:         d.Hints,
 		Conversations: len(parsed.Conversations),
@@ -91,17 +209,34 @@ func Inspect(path string) (*InspectResult, error) {
 		SourceApp:     parsed.SourceApp,
 		ConfigSummary: summarizeConfig(parsed),
 		FileSummary:   summarizeFiles(parsed),
-	}, nil
+	}
+	if opts.FileDetails {
+		res.FileDetails = fileDetails(parsed)
+	}
+	return res, nil
 }
 
 func Validate(path string) (*ValidateResult, error) {
-	workDir, cleanup, err := extractToTemp(path)
+	return ValidateWithOptions(path, ValidateOptions{})
+}
+
+func ValidateWithOptions(path string, opts ValidateOptions) (*ValidateResult, error) {
+	return ValidateContext(context.Background(), path, opts)
+}
+
+// ValidateContext is ValidateWithOptions with cancellation.
+func ValidateContext(ctx context.Context, path string, opts ValidateOptions) (*ValidateResult, error) {
+	workDir, cleanup, err := extractToTemp(ctx, "", path, opts.ExtractLimits)
 	if err != nil {
 		return nil, err
 	}
 	defer cleanup()
 
-	d := backup.DetectExtractedDir(workDir)
+	workDir, d, err := detectWorkDir(workDir, opts.AssumeFormat)
+	if err != nil {
+		err = withFromHint(err)
+		return &ValidateResult{Valid: false, Format: "unknown", Issues: []string{err.Error()}, Errors: []string{err.Error()}}, nil
+	}
 	if d.Format == backup.FormatUnknown {
 		return &ValidateResult{Valid: false, Format: "unknown", Issues: []string{"unknown backup format"}}, nil
 	}
@@ -119,10 +254,21 @@ func Validate(path string) (*ValidateResult, error) {
 		}
 	}
 
-	irData, err := parseByFormat(d.Format, workDir)
+	// Recorded hashes are checked before parsing: opening the rikka DB may checkpoint its WAL.
+	var hashes *hashVerification
+	if opts.VerifyHashes {
+		hashes = verifyRecordedHashes(workDir)
+	}
+
+	irData, err := parseByFormat(d.Format, workDir, "")
 	if err != nil {
 		errorsList = append(errorsList, err.Error())
 	}
+	if hashes != nil {
+		hashes.verifyIRFiles(workDir, irData)
+		errorsList = append(errorsList, hashes.Mismatches...)
+		warnings = append(warnings, hashes.Warnings...)
+	}
 	var cfgSummary *ConfigSummary
 	var fileSummary *FileSummary
 	if irData != nil {
@@ -135,6 +281,9 @@ func Validate(path string) (*ValidateResult, error) {
 		if fileSummary != nil && fileSummary.Missing > 0 {
 			warnings = append(warnings, fmt.Sprintf("found %d missing file payload(s)", fileSummary.Missing))
 		}
+		if fileSummary != nil && hashes != nil {
+			fileSummary.HashVerified = hashes.Verified
+		}
 	}
 	errorsList = dedupeStrings(errorsList)
 	warnings = dedupeStrings(warnings)
@@ -153,174 +302,1199 @@ func Validate(path string) (*ValidateResult, error) {
 }
 
 func Convert(opts ConvertOptions) (*ir.Manifest, error) {
+	return ConvertContext(context.Background(), opts)
+}
+
+// ConvertContext is Convert with cancellation, checked between extracted entries, copied
+// files and inserted rows. A cancelled conversion leaves no partial output behind.
+func ConvertContext(ctx context.Context, opts ConvertOptions) (*ir.Manifest, error) {
 	inputPaths := normalizeInputPaths(opts.InputPath, opts.InputPaths)
-	if len(inputPaths) == 0 || strings.TrimSpace(opts.OutputPath) == "" {
+	inputCount := len(inputPaths) + len(opts.InputURLs)
+	if inputCount == 0 || (strings.TrimSpace(opts.OutputPath) == "" && !opts.DryRun) {
 		return nil, fmt.Errorf("input and output are required")
 	}
 	to := strings.ToLower(strings.TrimSpace(opts.To))
-	if to != "cherry" && to != "rikka" {
-		return nil, fmt.Errorf("--to must be cherry or rikka")
+	if to != "cherry" && to != "rikka" && to != TargetIR {
+		return nil, fmt.Errorf("--to must be cherry, rikka or ir")
 	}
 	from := strings.ToLower(strings.TrimSpace(opts.From))
 	if from == "" {
 		from = "auto"
 	}
-	if len(inputPaths) > 1 && from != "auto" {
-		return nil, fmt.Errorf("multi-input convert only supports --from auto")
+	if inputCount > 1 && from != "auto" {
+		return nil, fmt.Errorf("multi-input convert only supports --from auto")
+	}
+	titleStrategy := strings.ToLower(strings.TrimSpace(opts.TitleStrategy))
+	switch titleStrategy {
+	case "", rikka.TitleStrategyKeep, rikka.TitleStrategyFirstUser, rikka.TitleStrategyFirstMessage, rikka.TitleStrategyFirstAssistant:
+	default:
+		return nil, fmt.Errorf("--title-from must be keep, first-user, first-message or first-assistant")
+	}
+	displayZone, err := util.LoadTimeZone(opts.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("--time-zone: %w", err)
+	}
+	window, err := parseDateWindow(opts.FromDate, opts.ToDate, displayZone)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SinceUnixMillis > 0 && opts.UntilUnixMillis > 0 && opts.SinceUnixMillis > opts.UntilUnixMillis {
+		return nil, fmt.Errorf("--since must not be after --until")
+	}
+	if err := validateScaffoldDefaults(opts); err != nil {
+		return nil, err
+	}
+	for _, rawURL := range opts.InputURLs {
+		localPath, cleanupFetch, err := FetchInput(ctx, rawURL, FetchOptions{MaxBytes: opts.MaxDownloadBytes, Timeout: opts.DownloadTimeout, TempDir: opts.TempDir})
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupFetch()
+		inputPaths = append(inputPaths, localPath)
+	}
+
+	if len(opts.SourceNames) > len(inputPaths) {
+		return nil, fmt.Errorf("--source-name given %d times for %d inputs", len(opts.SourceNames), len(inputPaths))
+	}
+	sourceTags := mergeSourceTags(inputPaths, opts.SourceNames)
+
+	var cleanupMu sync.Mutex
+	cleanupInputs := make([]func(), 0, len(inputPaths))
+	defer func() {
+		for _, cleanup := range cleanupInputs {
+			cleanup()
+		}
+	}()
+	registerCleanup := func(cleanup func()) {
+		cleanupMu.Lock()
+		defer cleanupMu.Unlock()
+		cleanupInputs = append(cleanupInputs, cleanup)
+	}
+	loaded := make([]loadedSource, len(inputPaths))
+	var progressMu sync.Mutex
+	parsedCount := 0
+	reportProgress(opts.Progress, StageParse, 0, len(inputPaths))
+	err = forEachBounded(ctx, len(inputPaths), opts.Concurrency, func(ctx context.Context, i int) error {
+		src, err := loadSource(ctx, opts, from, to, i, inputPaths, sourceTags[i], registerCleanup)
+		if err != nil {
+			return err
+		}
+		loaded[i] = src
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		if parsedCount++; parsedCount < len(inputPaths) {
+			reportProgress(opts.Progress, StageParse, parsedCount, len(inputPaths))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	parsedSources := make([]parsedSource, 0, len(inputPaths))
+	filteredConversations, filteredFiles, undatedConversations := 0, 0, 0
+	timeFiltered := &dropTracker{}
+	for _, src := range loaded {
+		if opts.SinceUnixMillis > 0 || opts.UntilUnixMillis > 0 {
+			filteredConversations += src.filteredConversations
+			filteredFiles += src.filteredFiles
+			undatedConversations += src.undatedConversations
+			timeFiltered.add("time-filter:"+src.Tag, src.Counts, src.keptCounts)
+		}
+		parsedSources = append(parsedSources, src.parsedSource)
+	}
+
+	reportProgress(opts.Progress, StageParse, len(inputPaths), len(inputPaths))
+	reportProgress(opts.Progress, StageMerge, 0, 1)
+	mergedIR, mergeReport, err := mergeSources(parsedSources, MergeOptions{
+		TargetFormat:      to,
+		ConfigPrecedence:  opts.ConfigPrecedence,
+		ConfigSourceIndex: opts.ConfigSourceIndex,
+		SettingsOverrides: opts.SettingsOverrides,
+		DedupeFiles:       opts.DedupeFiles,
+	})
+	if err != nil {
+		return nil, err
+	}
+	reportProgress(opts.Progress, StageMerge, 1, 1)
+
+	if opts.SinceUnixMillis > 0 || opts.UntilUnixMillis > 0 {
+		mergedIR.Warnings = append(mergedIR.Warnings, fmt.Sprintf("filtered-conversations:%d", filteredConversations))
+		if filteredFiles > 0 {
+			mergedIR.Warnings = append(mergedIR.Warnings, fmt.Sprintf("filtered-files:%d", filteredFiles))
+		}
+		if undatedConversations > 0 {
+			mergedIR.Warnings = append(mergedIR.Warnings, fmt.Sprintf("filtered-conversations:undated-kept:%d", undatedConversations))
+		}
+	}
+	drops := newDropTracker(mergedIR)
+	drops.dropped = append(drops.dropped, timeFiltered.dropped...)
+	applyProfileOverrides(mergedIR, opts)
+	mergedIR.Warnings = append(mergedIR.Warnings, applyScaffoldDefaults(mergedIR, opts)...)
+	mergedIR.Warnings = append(mergedIR.Warnings, applyProviderKeyOverrides(mergedIR, opts.ProviderKeyOverrides)...)
+	mergedIR.Warnings = append(mergedIR.Warnings, bindConversationAssistants(mergedIR, opts.ConversationAssistant)...)
+	mergedIR.Warnings = append(mergedIR.Warnings, omitOversizedFiles(mergedIR, opts.MaxFileBytes)...)
+	drops.note("oversized-files", mergedIR)
+	if opts.SkipMissingFiles {
+		mergedIR.Warnings = append(mergedIR.Warnings, skipMissingFiles(mergedIR)...)
+		drops.note("missing-files", mergedIR)
+	}
+	if opts.ExcludeArchived {
+		mergedIR.Warnings = append(mergedIR.Warnings, excludeArchivedConversations(mergedIR)...)
+		drops.note("archived", mergedIR)
+	}
+	if len(opts.IncludeConversations) > 0 || len(opts.ExcludeConversations) > 0 {
+		mergedIR.Warnings = append(mergedIR.Warnings, selectConversations(mergedIR, opts.IncludeConversations, opts.ExcludeConversations)...)
+		drops.note("selection", mergedIR)
+	}
+	if opts.FlattenBranches {
+		mergedIR.Warnings = append(mergedIR.Warnings, flattenBranches(mergedIR)...)
+		drops.note("branches", mergedIR)
+	}
+	if window.active() {
+		mergedIR.Warnings = append(mergedIR.Warnings, sliceConversations(mergedIR, window)...)
+		drops.note("slice", mergedIR)
+	}
+	if titleStrategy != "" && titleStrategy != rikka.TitleStrategyKeep {
+		mergedIR.Warnings = append(mergedIR.Warnings, applyTitleStrategy(mergedIR, titleStrategy)...)
+	}
+	if opts.PruneUnusedModels {
+		mergedIR.Warnings = append(mergedIR.Warnings, pruneUnusedModels(mergedIR)...)
+	}
+	// strip first so messages that only held reasoning count as empty
+	if opts.StripReasoning {
+		mergedIR.Warnings = append(mergedIR.Warnings, stripReasoningParts(mergedIR)...)
+	}
+	if !opts.KeepEmptyMessages {
+		mergedIR.Warnings = append(mergedIR.Warnings, dropEmptyMessages(mergedIR)...)
+		drops.note("empty-messages", mergedIR)
+	}
+	// non-fatal: surface references broken by merge, rehydrate or the passes above
+	for _, issue := range ValidateIR(mergedIR) {
+		mergedIR.Warnings = append(mergedIR.Warnings, "ir-invalid:"+issue.String())
+	}
+
+	if opts.ExternalizeSecrets && opts.RedactSecrets {
+		return nil, fmt.Errorf("redact secrets and externalize secrets are mutually exclusive")
+	}
+	if len(opts.RedactConfig.ExtraTokens) > 0 && !opts.RedactSecrets {
+		return nil, fmt.Errorf("--redact-extra-keys requires --redact-secrets")
+	}
+	secretWarnings, err := applySecretsFile(mergedIR, opts)
+	if err != nil {
+		return nil, err
+	}
+	mergedIR.Warnings = append(mergedIR.Warnings, secretWarnings...)
+	if opts.RedactSecrets {
+		mergedIR.Config = util.RedactAnyWithConfig(mergedIR.Config, opts.RedactConfig).(map[string]any)
+		if len(mergedIR.Settings) > 0 {
+			if redacted, ok := util.RedactAnyWithConfig(mergedIR.Settings, opts.RedactConfig).(map[string]any); ok {
+				mergedIR.Settings = redacted
+			}
+		}
+	}
+
+	if to == TargetIR {
+		return writeIRDump(opts, mergedIR, mergeReport, parsedSources)
+	}
+
+	templateDir := ""
+	cleanupTemplate := func() {}
+	if opts.TemplatePath != "" {
+		templateDir, cleanupTemplate, err = extractToTemp(ctx, opts.TempDir, opts.TemplatePath, opts.ExtractLimits)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupTemplate()
+	}
+
+	buildDir, err := makeTempDir(opts.TempDir, "cherrikka-build-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(buildDir)
+
+	// Attachment payloads are streamed straight into the output zip; only the small
+	// generated files and the sidecar are staged in buildDir.
+	var out *backup.StreamZip
+	var sink hashingSink
+	if opts.DryRun {
+		sink = backup.NewHashSink()
+	} else {
+		out, err = backup.CreateStreamZip(opts.OutputPath)
+		if err != nil {
+			return nil, err
+		}
+		defer out.Abort()
+		sink = out
+	}
+	if opts.Progress != nil {
+		sink = &progressSink{hashingSink: sink, total: len(mergedIR.Files), report: opts.Progress}
+		opts.Progress(StageFiles, 0, len(mergedIR.Files))
+	}
+
+	idMap := map[string]string{}
+	var redact *util.RedactConfig
+	if opts.RedactSecrets {
+		redact = &opts.RedactConfig
+	}
+	buildWarnings := []string{}
+	if to == "cherry" {
+		buildWarnings, err = cherry.BuildFromIRTo(ctx, mergedIR, buildDir, sink, templateDir, redact, idMap, cherry.BuildOptions{ContentAddressedFiles: opts.ContentAddressedFiles})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		buildWarnings, err = rikka.BuildFromIRTo(ctx, mergedIR, buildDir, sink, templateDir, redact, idMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ps, ok := sink.(*progressSink); ok && ps.done < ps.total {
+		opts.Progress(StageFiles, ps.total, ps.total)
+	}
+
+	var unmappedKeys []string
+	if opts.ReportUnmapped {
+		unmappedKeys, err = reportUnmappedKeys(mergedIR.Settings, to, buildDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(unmappedKeys) > 0 {
+			buildWarnings = append(buildWarnings, fmt.Sprintf("config-keys-unmapped:%d", len(unmappedKeys)))
+		}
+	}
+
+	manifest, primaryIdx := buildConvertManifest(opts, to, mergedIR, mergeReport, parsedSources, buildWarnings, unmappedKeys, idMap)
+
+	streamedHashes := sink.Hashes()
+	fileHashes, err := hashBuildPayloads(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	for rel, hash := range streamedHashes {
+		fileHashes[rel] = hash
+	}
+	manifest.FileHashes = fileHashes
+	if opts.DryRun {
+		manifest.DryRun = summarizeDryRun(mergedIR)
+		return manifest, nil
+	}
+
+	report := buildConversionReport(opts, mergedIR, mergeReport, parsedSources, drops.dropped)
+	includeRaw := !opts.OmitRawSources && !opts.ExternalizeSecrets
+	if err := writeSidecar(buildDir, parsedSources, primaryIdx, manifest, report, includeRaw); err != nil {
+		return nil, err
+	}
+	if opts.EmbedReadme {
+		readme := buildSidecarReadme(manifest, mergedIR, displayZone, includeRaw)
+		if err := os.WriteFile(filepath.Join(buildDir, "cherrikka", "README.txt"), []byte(readme), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	reportProgress(opts.Progress, StageWrite, 0, 1)
+	entries, err := collectZipEntries(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.IntegrityManifest {
+		if entries, err = writeIntegrityManifest(buildDir, entries, streamedHashes); err != nil {
+			return nil, err
+		}
+	}
+	if err := out.Finish(ctx, entries); err != nil {
+		return nil, err
+	}
+	reportProgress(opts.Progress, StageWrite, 1, 1)
+	if !opts.SkipVerifyOutput {
+		reportProgress(opts.Progress, StageVerify, 0, 1)
+		if err := verifyOutput(ctx, opts.TempDir, out.PartialPath(), to); err != nil {
+			return nil, err
+		}
+	}
+	if err := out.Commit(); err != nil {
+		return nil, err
+	}
+	if opts.ExternalizeSecrets {
+		if err := writeSecretsFile(secretsFilePath(opts), mergedIR.Secrets); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// loadedSource is one parsed input plus what the time filter removed from it.
+type loadedSource struct {
+	parsedSource
+	keptCounts            ir.ReportCounts
+	filteredConversations int
+	filteredFiles         int
+	undatedConversations  int
+}
+
+// loadSource extracts, parses and rehydrates inputPaths[i]. It may run concurrently
+// with other sources, so its temp dir is handed to register rather than deferred.
+func loadSource(ctx context.Context, opts ConvertOptions, from, to string, i int, inputPaths []string, tag string, register func(func())) (loadedSource, error) {
+	inputPath := inputPaths[i]
+	extractedDir, cleanupIn, err := extractToTemp(ctx, opts.TempDir, inputPath, opts.ExtractLimits)
+	if err != nil {
+		return loadedSource{}, err
+	}
+	register(cleanupIn)
+
+	inDir, d, err := detectWorkDir(extractedDir, from)
+	if err != nil {
+		return loadedSource{}, fmt.Errorf("%w (%s)", withFromHint(err), filepath.Base(inputPath))
+	}
+	if d.Format == backup.FormatUnknown {
+		return loadedSource{}, fmt.Errorf("cannot detect backup format: %s", filepath.Base(inputPath))
+	}
+	if from != "auto" && from != string(d.Format) {
+		return loadedSource{}, fmt.Errorf("source format mismatch: detected=%s flag=%s (%s)", d.Format, from, filepath.Base(inputPath))
+	}
+	// restoring an externalized backup's secrets is the one expected same-format run;
+	// with --externalize-secrets, SecretsPath is where the keyfile is written instead
+	restoringSecrets := strings.TrimSpace(opts.SecretsPath) != "" && !opts.ExternalizeSecrets
+	if len(inputPaths) == 1 && string(d.Format) == to && !opts.AllowSameFormat && !restoringSecrets {
+		return loadedSource{}, fmt.Errorf("%s is already a %s backup; same-format conversion is lossy (pass --allow-same-format to convert anyway)", filepath.Base(inputPath), to)
+	}
+
+	sourceBytes, err := sourceArchiveBytes(ctx, opts.TempDir, inputPath, extractedDir)
+	if err != nil {
+		return loadedSource{}, err
+	}
+	sourceSHA := util.SHA256Hex(sourceBytes)
+	sourceIR, cacheWarnings, err := parseWithCache(opts.IRCacheDir, opts.TempDir, sourceSHA, d.Format, inDir)
+	if err != nil {
+		return loadedSource{}, err
+	}
+	sourceIR.Warnings = append(sourceIR.Warnings, cacheWarnings...)
+	rehydrateWarnings, err := tryRehydrateFromSidecar(ctx, opts.TempDir, inDir, to, sourceIR, opts.ExtractLimits)
+	if err != nil {
+		return loadedSource{}, err
+	}
+	sourceIR.Warnings = append(sourceIR.Warnings, rehydrateWarnings...)
+	sourceIR.Warnings = append(sourceIR.Warnings, mapping.EnsureNormalizedSettings(sourceIR)...)
+	out := loadedSource{}
+	sourceCounts := countIR(sourceIR)
+	out.keptCounts = sourceCounts
+	if opts.SinceUnixMillis > 0 || opts.UntilUnixMillis > 0 {
+		out.filteredConversations, out.filteredFiles, out.undatedConversations = filterConversationsByTime(sourceIR, opts.SinceUnixMillis, opts.UntilUnixMillis)
+		out.keptCounts = countIR(sourceIR)
+	}
+	sourceIR.TargetFormat = to
+	sourceIR.DetectedHints = d.Hints
+	if len(inputPaths) == 1 && string(d.Format) == to {
+		sourceIR.Warnings = append(sourceIR.Warnings, "same-format-conversion-is-lossy")
+	}
+
+	out.parsedSource = parsedSource{
+		Index:       i + 1,
+		Tag:         tag,
+		Path:        inputPath,
+		Name:        filepath.Base(inputPath),
+		Format:      string(d.Format),
+		Hints:       d.Hints,
+		SHA256:      sourceSHA,
+		LatestUnix:  inferLatestUnixMillis(inputPath, sourceIR),
+		Counts:      sourceCounts,
+		SourceBytes: sourceBytes,
+		IR:          sourceIR,
+	}
+	return out, nil
+}
+
+func summarizeDryRun(data *ir.BackupIR) *ir.DryRunSummary {
+	summary := &ir.DryRunSummary{Conversations: len(data.Conversations), Assistants: len(data.Assistants), Files: len(data.Files)}
+	for _, conv := range data.Conversations {
+		summary.Messages += len(conv.Messages)
+	}
+	return summary
+}
+
+// buildConvertManifest assembles the manifest for a finished build and returns the
+// index of the primary source, whose raw copy heads the sidecar.
+func buildConvertManifest(opts ConvertOptions, to string, mergedIR *ir.BackupIR, mergeReport *MergeReport, parsedSources []parsedSource, buildWarnings, unmappedKeys []string, idMap map[string]string) (*ir.Manifest, int) {
+	primaryIdx := 0
+	if mergeReport != nil && mergeReport.PrimarySourceIndex > 0 {
+		primaryIdx = mergeReport.PrimarySourceIndex - 1
+	}
+	if primaryIdx < 0 || primaryIdx >= len(parsedSources) {
+		primaryIdx = 0
+	}
+	primarySource := parsedSources[primaryIdx]
+
+	manifestSources := make([]ir.ManifestSource, 0, len(parsedSources))
+	for _, src := range parsedSources {
+		manifestSources = append(manifestSources, ir.ManifestSource{
+			Index:        src.Index,
+			Tag:          src.Tag,
+			Name:         src.Name,
+			SourceApp:    src.IR.SourceApp,
+			SourceFormat: src.Format,
+			SourceSHA256: src.SHA256,
+			Hints:        cloneStringSlice(src.Hints),
+		})
+	}
+	allWarnings := append([]string{}, mergedIR.Warnings...)
+	if mergeReport != nil {
+		allWarnings = append(allWarnings, mergeReport.Warnings...)
+	}
+	allWarnings = append(allWarnings, buildWarnings...)
+	if opts.ExternalizeSecrets && to != TargetIR {
+		// the raw source copies still hold the plaintext secrets
+		allWarnings = append(allWarnings, "sidecar-raw-omitted:secrets-externalized")
+	}
+	manifest := &ir.Manifest{
+		SchemaVersion: ManifestSchemaVersion,
+		SourceApp:     primarySource.IR.SourceApp,
+		SourceFormat:  primarySource.Format,
+		SourceSHA256:  primarySource.SHA256,
+		TargetApp:     targetAppName(to),
+		TargetFormat:  to,
+		Redaction:     opts.RedactSecrets,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+		Sources:       manifestSources,
+		UnmappedKeys:  unmappedKeys,
+		Warnings:      dedupeStrings(allWarnings),
+	}
+	if !opts.OmitIDMap {
+		manifest.IDMap = idMap
+	}
+	if to == "cherry" {
+		if selection := mapping.CherryUnmappedModelSelection(mergedIR.Settings); len(selection) > 0 {
+			manifest.ModelSelection = selection
+		}
+	}
+	return manifest, primaryIdx
+}
+
+// writeIRDump writes the IR after every convert pass, with its manifest, as pretty JSON.
+func writeIRDump(opts ConvertOptions, data *ir.BackupIR, mergeReport *MergeReport, parsedSources []parsedSource) (*ir.Manifest, error) {
+	manifest, _ := buildConvertManifest(opts, TargetIR, data, mergeReport, parsedSources, nil, nil, map[string]string{})
+	if opts.DryRun {
+		manifest.DryRun = summarizeDryRun(data)
+		return manifest, nil
+	}
+	// the externalized keys go to the secrets file only, never into the dump
+	dumped := *data
+	dumped.Secrets = nil
+	dump := IRDump{Manifest: manifest, Warnings: manifest.Warnings, IR: &dumped}
+	b, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := util.EnsureDir(filepath.Dir(opts.OutputPath)); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(opts.OutputPath, b, 0o644); err != nil {
+		return nil, err
+	}
+	if opts.ExternalizeSecrets {
+		if err := writeSecretsFile(secretsFilePath(opts), data.Secrets); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// verifyOutput extracts the finished output and runs the target format's structural
+// validator and parser on it. Unlike Validate, an output without conversations is fine.
+func verifyOutput(ctx context.Context, tempDir, outputPath, format string) error {
+	workDir, cleanup, err := extractToTemp(ctx, tempDir, outputPath, ExtractLimits{})
+	if err != nil {
+		return fmt.Errorf("verify output: %w", err)
+	}
+	defer cleanup()
+	workDir, d, err := detectWorkDir(workDir, format)
+	if err != nil {
+		return fmt.Errorf("verify output: %w", err)
+	}
+	if string(d.Format) != format {
+		return fmt.Errorf("verify output: detected %s backup, expected %s", d.Format, format)
+	}
+	switch d.Format {
+	case backup.FormatCherry:
+		err = cherry.ValidateExtracted(workDir)
+	case backup.FormatRikka:
+		err = rikka.ValidateExtracted(workDir)
+	}
+	if err != nil {
+		return fmt.Errorf("verify output: %w", err)
+	}
+	if _, err := parseByFormat(d.Format, workDir, tempDir); err != nil {
+		return fmt.Errorf("verify output: %w", err)
+	}
+	return nil
+}
+
+// applySecretsFile either externalizes secrets into data.Secrets or, when a keyfile is
+// given, resolves placeholders from it.
+func applySecretsFile(data *ir.BackupIR, opts ConvertOptions) ([]string, error) {
+	if opts.ExternalizeSecrets {
+		secrets := map[string]string{}
+		data.Config = util.ExternalizeSecrets(data.Config, "config", secrets).(map[string]any)
+		if len(data.Settings) > 0 {
+			data.Settings = util.ExternalizeSecrets(data.Settings, "settings", secrets).(map[string]any)
+		}
+		// opaque payloads such as interop.*.unsupported are written back out too
+		if len(data.Opaque) > 0 {
+			data.Opaque = util.ExternalizeSecrets(data.Opaque, "opaque", secrets).(map[string]any)
+		}
+		data.Secrets = secrets
+		return []string{fmt.Sprintf("secrets-externalized:%d", len(secrets))}, nil
+	}
+	if strings.TrimSpace(opts.SecretsPath) == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(opts.SecretsPath)
+	if err != nil {
+		return nil, err
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(b, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid secrets file: %w", err)
+	}
+	config, missing := util.ResolveSecretPlaceholders(data.Config, secrets)
+	data.Config = config.(map[string]any)
+	if len(data.Settings) > 0 {
+		settings, missingSettings := util.ResolveSecretPlaceholders(data.Settings, secrets)
+		data.Settings = settings.(map[string]any)
+		missing = append(missing, missingSettings...)
+	}
+	if len(data.Opaque) > 0 {
+		opaque, missingOpaque := util.ResolveSecretPlaceholders(data.Opaque, secrets)
+		data.Opaque = opaque.(map[string]any)
+		missing = append(missing, missingOpaque...)
+	}
+	warnings := []string{}
+	for _, key := range dedupeStrings(missing) {
+		warnings = append(warnings, "secret-unresolved:"+key)
+	}
+	return warnings, nil
+}
+
+func secretsFilePath(opts ConvertOptions) string {
+	if p := strings.TrimSpace(opts.SecretsPath); p != "" {
+		return p
+	}
+	return opts.OutputPath + ".secrets.json"
+}
+
+func writeSecretsFile(path string, secrets map[string]string) error {
+	if secrets == nil {
+		secrets = map[string]string{}
+	}
+	b, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+func applyProfileOverrides(data *ir.BackupIR, opts ConvertOptions) {
+	userName := strings.TrimSpace(opts.UserName)
+	userID := strings.TrimSpace(opts.UserID)
+	if userName == "" && userID == "" {
+		return
+	}
+	if data.Settings == nil {
+		data.Settings = map[string]any{}
+	}
+	profile := cloneMapAny(asMap(data.Settings["ui.profile"]))
+	if userName != "" {
+		profile["userName"] = userName
+	}
+	if userID != "" {
+		profile["userId"] = userID
+	}
+	data.Settings["ui.profile"] = profile
+}
+
+// applyProviderKeyOverrides sets the apiKey of every provider whose id or name matches
+// an override; overrides that match no provider are reported.
+func applyProviderKeyOverrides(data *ir.BackupIR, overrides map[string]string) []string {
+	if len(overrides) == 0 {
+		return nil
+	}
+	matched := map[string]bool{}
+	for _, item := range asSlice(data.Settings["core.providers"]) {
+		pm := asMap(item)
+		raw := asMap(pm["raw"])
+		if len(raw) == 0 {
+			continue
+		}
+		for name, key := range overrides {
+			for _, candidate := range []any{pm["id"], pm["name"], raw["id"], raw["name"]} {
+				if c := strings.TrimSpace(str(candidate)); c != "" && strings.EqualFold(c, strings.TrimSpace(name)) {
+					raw["apiKey"] = key
+					matched[name] = true
+					break
+				}
+			}
+		}
+	}
+	warnings := []string{}
+	for name := range overrides {
+		if !matched[name] {
+			warnings = append(warnings, "provider-key-override-unmatched:"+name)
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// ParseProviderKeyOverrides parses repeated provider=key pairs such as "openai=sk-...".
+func ParseProviderKeyOverrides(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(values))
+	for _, raw := range values {
+		name, key, ok := strings.Cut(raw, "=")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("--set-key must be <provider>=<api-key>, got %q", redactKeyOverride(raw))
+		}
+		out[name] = key
+	}
+	return out, nil
+}
+
+// redactKeyOverride keeps the provider part of a malformed --set-key value so the
+// error never echoes a key.
+func redactKeyOverride(raw string) string {
+	if name, _, ok := strings.Cut(raw, "="); ok {
+		return name + "=***"
+	}
+	return "***"
+}
+
+// bindConversationAssistants points each listed conversation at the named assistant.
+// The assistant is matched by exact id first, then by its pre-merge id (preferring the
+// conversation's own source), then by name (case-insensitive).
+func bindConversationAssistants(data *ir.BackupIR, binds map[string]string) []string {
+	if len(binds) == 0 {
+		return nil
+	}
+	warnings := []string{}
+	bound := 0
+	for convID, want := range binds {
+		if resolveAssistantRef(data.Assistants, want, "") == "" {
+			warnings = append(warnings, "conversation-bind-unmatched:assistant:"+want)
+			continue
+		}
+		seen := false
+		for i := range data.Conversations {
+			conv := &data.Conversations[i]
+			if !hasConversationID(*conv, strings.TrimSpace(convID)) {
+				continue
+			}
+			seen = true
+			// after a merge, prefer the assistant that came from the conversation's source
+			source, _ := conv.Opaque[mergeSourceSeedKey].(string)
+			if assistantID := resolveAssistantRef(data.Assistants, want, source); conv.AssistantID != assistantID {
+				conv.AssistantID = assistantID
+				bound++
+			}
+		}
+		if !seen {
+			warnings = append(warnings, "conversation-bind-unmatched:conversation:"+convID)
+		}
+	}
+	sort.Strings(warnings)
+	if bound > 0 {
+		warnings = append(warnings, fmt.Sprintf("conversations-rebound:%d", bound))
+	}
+	return warnings
+}
+
+func resolveAssistantRef(assistants []ir.IRAssistant, ref, source string) string {
+	ref = strings.TrimSpace(ref)
+	for _, a := range assistants {
+		if strings.TrimSpace(a.ID) == ref {
+			return a.ID
+		}
+	}
+	fromSource := ""
+	for _, a := range assistants {
+		if sourceID, _ := a.Opaque[mergeSourceIDKey].(string); sourceID != ref {
+			continue
+		}
+		if seed, _ := a.Opaque[mergeSourceSeedKey].(string); seed == source {
+			return a.ID
+		}
+		if fromSource == "" {
+			fromSource = a.ID
+		}
+	}
+	if fromSource != "" {
+		return fromSource
+	}
+	for _, a := range assistants {
+		if strings.EqualFold(strings.TrimSpace(a.Name), ref) {
+			return a.ID
+		}
+	}
+	return ""
+}
+
+// ParseConversationAssistants parses repeated conversation=assistant pairs for --bind.
+func ParseConversationAssistants(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(values))
+	for _, raw := range values {
+		convID, assistant, ok := strings.Cut(raw, "=")
+		convID, assistant = strings.TrimSpace(convID), strings.TrimSpace(assistant)
+		if !ok || convID == "" || assistant == "" {
+			return nil, fmt.Errorf("--bind must be <conversation-id>=<assistant>, got %q", raw)
+		}
+		out[convID] = assistant
+	}
+	return out, nil
+}
+
+// selectConversations applies --include-conversation/--exclude-conversation and drops
+// the files only the skipped conversations referenced.
+func selectConversations(data *ir.BackupIR, include, exclude []string) []string {
+	matches := func(conv ir.IRConversation, patterns []string) bool {
+		title := strings.ToLower(conv.Title)
+		for _, p := range patterns {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			if hasConversationID(conv, p) || strings.Contains(title, strings.ToLower(p)) {
+				return true
+			}
+		}
+		return false
+	}
+	referencedBefore := referencedFileIDs(data)
+	total := len(data.Conversations)
+	kept := make([]ir.IRConversation, 0, len(data.Conversations))
+	for _, conv := range data.Conversations {
+		if len(include) > 0 && !matches(conv, include) {
+			continue
+		}
+		if matches(conv, exclude) {
+			continue
+		}
+		kept = append(kept, conv)
+	}
+	data.Conversations = kept
+	warnings := []string{fmt.Sprintf("conversations-selected:%d/%d", len(kept), total)}
+	if dropped := dropOrphanedFiles(data, referencedBefore); dropped > 0 {
+		warnings = append(warnings, fmt.Sprintf("files-selected:%d", dropped))
+	}
+	return warnings
+}
+
+func excludeArchivedConversations(data *ir.BackupIR) []string {
+	kept := make([]ir.IRConversation, 0, len(data.Conversations))
+	for _, conv := range data.Conversations {
+		if archived, _ := conv.Opaque["archived"].(bool); archived {
+			continue
+		}
+		kept = append(kept, conv)
+	}
+	excluded := len(data.Conversations) - len(kept)
+	if excluded == 0 {
+		return nil
+	}
+	data.Conversations = kept
+	return []string{fmt.Sprintf("conversations-excluded:archived:%d", excluded)}
+}
+
+// flattenBranches drops the alternate messages rikka keeps per message node, leaving
+// the selected branch, which the parser already placed in Messages, as the history.
+func flattenBranches(data *ir.BackupIR) []string {
+	dropped := 0
+	for ci := range data.Conversations {
+		for mi := range data.Conversations[ci].Messages {
+			msg := &data.Conversations[ci].Messages[mi]
+			switch branches := msg.Opaque[rikka.OpaqueBranches].(type) {
+			case []map[string]any:
+				dropped += len(branches) - 1
+			case []any:
+				dropped += len(branches) - 1
+			default:
+				continue
+			}
+			delete(msg.Opaque, rikka.OpaqueBranches)
+			delete(msg.Opaque, rikka.OpaqueSelectIndex)
+		}
+	}
+	if dropped == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("branches-flattened:%d", dropped)}
+}
+
+// rawBranches returns the raw rikka alternates kept for msg and the selected index.
+func rawBranches(msg ir.IRMessage) ([]map[string]any, int, bool) {
+	var branches []map[string]any
+	switch raw := msg.Opaque[rikka.OpaqueBranches].(type) {
+	case []map[string]any:
+		branches = raw
+	case []any:
+		for _, item := range raw {
+			branches = append(branches, asMap(item))
+		}
+	default:
+		return nil, 0, false
+	}
+	selected := -1
+	switch n := msg.Opaque[rikka.OpaqueSelectIndex].(type) {
+	case int:
+		selected = n
+	case int64:
+		selected = int(n)
+	case float64:
+		selected = int(n)
+	}
+	if len(branches) < 2 || selected < 0 || selected >= len(branches) {
+		return nil, 0, false
+	}
+	return branches, selected, true
+}
+
+// filterBranchParts drops the parts matched by drop from the alternates of msg. The
+// selected alternate is skipped: the rikka writer rebuilds it from Parts. An alternate
+// left without parts gets an empty text part, as stripReasoningParts does.
+func filterBranchParts(msg *ir.IRMessage, drop func(part map[string]any) bool) int {
+	branches, selected, ok := rawBranches(*msg)
+	if !ok {
+		return 0
+	}
+	dropped := 0
+	out := make([]map[string]any, len(branches))
+	for i, branch := range branches {
+		out[i] = branch
+		if i == selected {
+			continue
+		}
+		parts := asSlice(branch["parts"])
+		kept := make([]any, 0, len(parts))
+		for _, part := range parts {
+			if drop(asMap(part)) {
+				continue
+			}
+			kept = append(kept, part)
+		}
+		if len(kept) == len(parts) {
+			continue
+		}
+		dropped += len(parts) - len(kept)
+		if len(kept) == 0 {
+			kept = append(kept, map[string]any{"type": "me.rerere.ai.ui.UIMessagePart.Text", "text": ""})
+		}
+		// copied, so the parsed source (and its IR cache entry) keeps the original
+		cloned := cloneMapAny(branch)
+		cloned["parts"] = kept
+		out[i] = cloned
+	}
+	if dropped > 0 {
+		msg.Opaque[rikka.OpaqueBranches] = out
 	}
+	return dropped
+}
 
-	parsedSources := make([]parsedSource, 0, len(inputPaths))
-	cleanupInputs := make([]func(), 0, len(inputPaths))
-	defer func() {
-		for _, cleanup := range cleanupInputs {
-			cleanup()
+// dropEmptyBranches removes the unselected alternates of msg whose parts are all
+// blank text, and the branch data once only the selected message is left.
+func dropEmptyBranches(msg *ir.IRMessage) int {
+	branches, selected, ok := rawBranches(*msg)
+	if !ok {
+		return 0
+	}
+	kept := make([]map[string]any, 0, len(branches))
+	newSelected := 0
+	for i, branch := range branches {
+		if i == selected {
+			newSelected = len(kept)
+			kept = append(kept, branch)
+			continue
 		}
-	}()
-	for i, inputPath := range inputPaths {
-		inDir, cleanupIn, err := extractToTemp(inputPath)
-		if err != nil {
-			return nil, err
+		empty := true
+		for _, part := range asSlice(branch["parts"]) {
+			pm := asMap(part)
+			if _, isText := pm["text"]; !isText || strings.TrimSpace(str(pm["text"])) != "" {
+				empty = false
+				break
+			}
 		}
-		cleanupInputs = append(cleanupInputs, cleanupIn)
-
-		d := backup.DetectExtractedDir(inDir)
-		if d.Format == backup.FormatUnknown {
-			return nil, fmt.Errorf("cannot detect backup format: %s", filepath.Base(inputPath))
+		if !empty {
+			kept = append(kept, branch)
 		}
-		if from != "auto" && from != string(d.Format) {
-			return nil, fmt.Errorf("source format mismatch: detected=%s flag=%s (%s)", d.Format, from, filepath.Base(inputPath))
+	}
+	dropped := len(branches) - len(kept)
+	switch {
+	case dropped == 0:
+	case len(kept) < 2:
+		delete(msg.Opaque, rikka.OpaqueBranches)
+		delete(msg.Opaque, rikka.OpaqueSelectIndex)
+	default:
+		msg.Opaque[rikka.OpaqueBranches] = kept
+		msg.Opaque[rikka.OpaqueSelectIndex] = newSelected
+	}
+	return dropped
+}
+
+// applyTitleStrategy re-derives conversation titles so both target writers see
+// the chosen title; conversations without any usable text keep their title.
+func applyTitleStrategy(data *ir.BackupIR, strategy string) []string {
+	changed := 0
+	for ci := range data.Conversations {
+		conv := &data.Conversations[ci]
+		title := rikka.DeriveConversationTitle(*conv, strategy)
+		if title == "" || title == conv.Title {
+			continue
 		}
+		conv.Title = title
+		changed++
+	}
+	if changed == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("titles-rederived:%s:%d", strategy, changed)}
+}
 
-		sourceIR, parseErr := parseByFormat(d.Format, inDir)
-		if parseErr != nil {
-			return nil, parseErr
+// pruneUnusedModels keeps only the provider models that an assistant or a model
+// selection slot points at. Nothing is pruned when no reference can be found.
+func pruneUnusedModels(data *ir.BackupIR) []string {
+	refs := map[string]struct{}{}
+	addRef := func(v any) {
+		values := []any{v}
+		if m := asMap(v); len(m) > 0 {
+			values = []any{m["id"], m["modelId"], m["chatModelId"], m["rikkaId"], m["name"]}
 		}
-		rehydrateWarnings, rehydrateErr := tryRehydrateFromSidecar(inDir, to, sourceIR)
-		if rehydrateErr != nil {
-			return nil, rehydrateErr
+		for _, value := range values {
+			if s := strings.ToLower(strings.TrimSpace(str(value))); s != "" {
+				refs[s] = struct{}{}
+			}
 		}
-		sourceIR.Warnings = append(sourceIR.Warnings, rehydrateWarnings...)
-		sourceIR.Warnings = append(sourceIR.Warnings, mapping.EnsureNormalizedSettings(sourceIR)...)
-		sourceIR.TargetFormat = to
-		sourceIR.DetectedHints = d.Hints
-
-		sourceBytes, readErr := os.ReadFile(inputPath)
-		if readErr != nil {
-			return nil, readErr
+	}
+	for _, v := range asMap(data.Settings["core.models"]) {
+		addRef(v)
+	}
+	for _, item := range asSlice(data.Settings["core.assistants"]) {
+		am := asMap(item)
+		addRef(am["chatModelId"])
+		raw := asMap(am["raw"])
+		for _, key := range []string{"model", "defaultModel", "chatModelId"} {
+			addRef(raw[key])
 		}
-		parsedSources = append(parsedSources, parsedSource{
-			Index:       i + 1,
-			Tag:         fmt.Sprintf("S%d", i+1),
-			Path:        inputPath,
-			Name:        filepath.Base(inputPath),
-			Format:      string(d.Format),
-			Hints:       d.Hints,
-			SHA256:      util.SHA256Hex(sourceBytes),
-			LatestUnix:  inferLatestUnixMillis(inputPath, sourceIR),
-			SourceBytes: sourceBytes,
-			IR:          sourceIR,
-		})
 	}
-
-	mergedIR, mergeReport, err := mergeSources(parsedSources, MergeOptions{
-		TargetFormat:      to,
-		ConfigPrecedence:  opts.ConfigPrecedence,
-		ConfigSourceIndex: opts.ConfigSourceIndex,
-	})
-	if err != nil {
-		return nil, err
+	for _, assistant := range data.Assistants {
+		addRef(assistant.Model)
+	}
+	if len(refs) == 0 {
+		return nil
 	}
 
-	if opts.RedactSecrets {
-		mergedIR.Config = util.RedactAny(mergedIR.Config).(map[string]any)
-		if len(mergedIR.Settings) > 0 {
-			if redacted, ok := util.RedactAny(mergedIR.Settings).(map[string]any); ok {
-				mergedIR.Settings = redacted
+	pruned := 0
+	for _, item := range asSlice(data.Settings["core.providers"]) {
+		raw := asMap(asMap(item)["raw"])
+		models := asSlice(raw["models"])
+		if len(models) == 0 {
+			continue
+		}
+		kept := make([]any, 0, len(models))
+		for _, mv := range models {
+			mm := asMap(mv)
+			used := false
+			for _, key := range []string{"id", "modelId", "rikkaId", "name", "displayName"} {
+				if _, ok := refs[strings.ToLower(strings.TrimSpace(str(mm[key])))]; ok && str(mm[key]) != "" {
+					used = true
+					break
+				}
+			}
+			if used {
+				kept = append(kept, mv)
 			}
 		}
+		pruned += len(models) - len(kept)
+		raw["models"] = kept
+	}
+	if pruned == 0 {
+		return nil
 	}
+	return []string{fmt.Sprintf("models-pruned:%d", pruned)}
+}
 
-	templateDir := ""
-	cleanupTemplate := func() {}
-	if opts.TemplatePath != "" {
-		templateDir, cleanupTemplate, err = extractToTemp(opts.TemplatePath)
-		if err != nil {
-			return nil, err
+// dropEmptyMessages removes messages whose parts are all blank text, keeping a
+// conversation's only message so no conversation ends up empty. Blank rikka
+// alternates are removed too.
+func dropEmptyMessages(data *ir.BackupIR) []string {
+	dropped := 0
+	for ci := range data.Conversations {
+		conv := &data.Conversations[ci]
+		if len(conv.Messages) <= 1 {
+			for mi := range conv.Messages {
+				dropped += dropEmptyBranches(&conv.Messages[mi])
+			}
+			continue
 		}
-		defer cleanupTemplate()
+		kept := make([]ir.IRMessage, 0, len(conv.Messages))
+		for _, msg := range conv.Messages {
+			if isEmptyMessage(msg) {
+				dropped++
+				continue
+			}
+			dropped += dropEmptyBranches(&msg)
+			kept = append(kept, msg)
+		}
+		if len(kept) == 0 {
+			kept = append(kept, conv.Messages[0])
+			dropped--
+		}
+		conv.Messages = kept
 	}
-
-	buildDir, err := os.MkdirTemp("", "cherrikka-build-*")
-	if err != nil {
-		return nil, err
+	if dropped == 0 {
+		return nil
 	}
-	defer os.RemoveAll(buildDir)
+	return []string{fmt.Sprintf("messages-dropped:empty:%d", dropped)}
+}
 
-	idMap := map[string]string{}
-	buildWarnings := []string{}
-	if to == "cherry" {
-		buildWarnings, err = cherry.BuildFromIR(mergedIR, buildDir, templateDir, opts.RedactSecrets, idMap)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		buildWarnings, err = rikka.BuildFromIR(mergedIR, buildDir, templateDir, opts.RedactSecrets, idMap)
-		if err != nil {
-			return nil, err
+func isEmptyMessage(msg ir.IRMessage) bool {
+	for _, p := range msg.Parts {
+		if p.Type != "text" || strings.TrimSpace(p.Content) != "" {
+			return false
 		}
 	}
+	return true
+}
 
-	primaryIdx := 0
-	if mergeReport != nil && mergeReport.PrimarySourceIndex > 0 {
-		primaryIdx = mergeReport.PrimarySourceIndex - 1
+// stripReasoningParts removes reasoning parts, leaving an empty text part in messages
+// that held nothing else so every message stays valid for the target app.
+func stripReasoningParts(data *ir.BackupIR) []string {
+	stripped := 0
+	for ci := range data.Conversations {
+		for mi := range data.Conversations[ci].Messages {
+			msg := &data.Conversations[ci].Messages[mi]
+			stripped += filterBranchParts(msg, func(part map[string]any) bool {
+				_, ok := part["reasoning"]
+				return ok
+			})
+			kept := make([]ir.IRPart, 0, len(msg.Parts))
+			for _, p := range msg.Parts {
+				if p.Type == "reasoning" {
+					stripped++
+					continue
+				}
+				kept = append(kept, p)
+			}
+			if len(kept) == len(msg.Parts) {
+				continue
+			}
+			if len(kept) == 0 {
+				kept = append(kept, ir.IRPart{Type: "text", Content: ""})
+			}
+			msg.Parts = kept
+		}
 	}
-	if primaryIdx < 0 || primaryIdx >= len(parsedSources) {
-		primaryIdx = 0
+	if stripped == 0 {
+		return nil
 	}
-	primarySource := parsedSources[primaryIdx]
+	return []string{fmt.Sprintf("reasoning-stripped:%d", stripped)}
+}
 
-	manifestSources := make([]ir.ManifestSource, 0, len(parsedSources))
-	for _, src := range parsedSources {
-		manifestSources = append(manifestSources, ir.ManifestSource{
-			Index:        src.Index,
-			Name:         src.Name,
-			SourceApp:    src.IR.SourceApp,
-			SourceFormat: src.Format,
-			SourceSHA256: src.SHA256,
-			Hints:        cloneStringSlice(src.Hints),
-		})
+// omitOversizedFiles drops files above maxBytes and replaces their message
+// references with a text note, returning one warning per omitted file.
+func omitOversizedFiles(data *ir.BackupIR, maxBytes int64) []string {
+	if maxBytes <= 0 || len(data.Files) == 0 {
+		return nil
 	}
-	allWarnings := append([]string{}, mergedIR.Warnings...)
-	if mergeReport != nil {
-		allWarnings = append(allWarnings, mergeReport.Warnings...)
+	warnings := []string{}
+	omitted := map[string]ir.IRFile{}
+	kept := make([]ir.IRFile, 0, len(data.Files))
+	for _, f := range data.Files {
+		size := f.Size
+		if size == 0 && f.SourcePath != "" {
+			if st, err := os.Stat(f.SourcePath); err == nil {
+				size = st.Size()
+			}
+		}
+		if size > maxBytes {
+			f.Size = size
+			omitted[f.ID] = f
+			warnings = append(warnings, fmt.Sprintf("file-omitted:%s:%d", f.Name, size))
+			continue
+		}
+		kept = append(kept, f)
 	}
-	allWarnings = append(allWarnings, buildWarnings...)
-	manifest := &ir.Manifest{
-		SchemaVersion: 1,
-		SourceApp:     primarySource.IR.SourceApp,
-		SourceFormat:  primarySource.Format,
-		SourceSHA256:  primarySource.SHA256,
-		TargetApp:     targetAppName(to),
-		TargetFormat:  to,
-		IDMap:         idMap,
-		Redaction:     opts.RedactSecrets,
-		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
-		Sources:       manifestSources,
-		Warnings:      dedupeStrings(allWarnings),
+	if len(omitted) == 0 {
+		return nil
+	}
+	data.Files = kept
+	replaceOmittedFileRefs(data, omitted, func(f ir.IRFile) string {
+		return fmt.Sprintf("[file omitted: %s (%d bytes)]", fallbackFileName(f), f.Size)
+	})
+	return warnings
+}
+
+// skipMissingFiles drops files whose payload is absent, so the builders do not write
+// empty placeholders for them, and turns their message references into text notes.
+func skipMissingFiles(data *ir.BackupIR) []string {
+	warnings := []string{}
+	missing := map[string]ir.IRFile{}
+	kept := make([]ir.IRFile, 0, len(data.Files))
+	for _, f := range data.Files {
+		if f.Missing || strings.TrimSpace(f.SourcePath) == "" {
+			missing[f.ID] = f
+			warnings = append(warnings, "file-missing-skipped:"+fallbackFileName(f))
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if len(missing) == 0 {
+		return nil
 	}
+	data.Files = kept
+	replaceOmittedFileRefs(data, missing, func(f ir.IRFile) string {
+		return fmt.Sprintf("[missing attachment: %s]", fallbackFileName(f))
+	})
+	return warnings
+}
 
-	if err := writeSidecar(buildDir, parsedSources, primaryIdx, manifest); err != nil {
-		return nil, err
+func replaceOmittedFileRefs(data *ir.BackupIR, omitted map[string]ir.IRFile, note func(ir.IRFile) string) {
+	for ci := range data.Conversations {
+		for mi := range data.Conversations[ci].Messages {
+			msg := &data.Conversations[ci].Messages[mi]
+			msg.Parts = replaceOmittedFileParts(msg.Parts, omitted, note)
+		}
 	}
+}
 
-	entries, err := collectZipEntries(buildDir)
-	if err != nil {
-		return nil, err
+func replaceOmittedFileParts(parts []ir.IRPart, omitted map[string]ir.IRFile, note func(ir.IRFile) string) []ir.IRPart {
+	for i, p := range parts {
+		if len(p.Output) > 0 {
+			parts[i].Output = replaceOmittedFileParts(p.Output, omitted, note)
+		}
+		f, ok := omitted[p.FileID]
+		if p.FileID == "" || !ok {
+			continue
+		}
+		parts[i] = ir.IRPart{
+			Type:    "text",
+			Content: note(f),
+			Metadata: map[string]any{
+				"omittedFileId":   f.ID,
+				"omittedFileSize": f.Size,
+				"omittedPartType": p.Type,
+			},
+		}
 	}
-	if err := backup.WriteZip(opts.OutputPath, entries); err != nil {
-		return nil, err
+	return parts
+}
+
+func fallbackFileName(f ir.IRFile) string {
+	if strings.TrimSpace(f.Name) != "" {
+		return f.Name
 	}
-	return manifest, nil
+	return f.ID
 }
 
 func normalizeInputPaths(single string, multi []string) []string {
@@ -343,7 +1517,7 @@ func normalizeInputPaths(single string, multi []string) []string {
 	return out
 }
 
-func tryRehydrateFromSidecar(inputDir, targetFormat string, sourceIR *ir.BackupIR) ([]string, error) {
+func tryRehydrateFromSidecar(ctx context.Context, tempDir, inputDir, targetFormat string, sourceIR *ir.BackupIR, limits ExtractLimits) ([]string, error) {
 	manifestPath := filepath.Join(inputDir, "cherrikka", "manifest.json")
 	if _, err := os.Stat(manifestPath); err != nil {
 		return nil, nil
@@ -358,6 +1532,15 @@ func tryRehydrateFromSidecar(inputDir, targetFormat string, sourceIR *ir.BackupI
 		return []string{"sidecar-rehydrate:invalid-manifest"}, nil
 	}
 	targetFormat = strings.ToLower(strings.TrimSpace(targetFormat))
+	selectionWarnings := []string{}
+	if targetFormat == "rikka" && len(manifest.ModelSelection) > 0 {
+		selection := map[string]any{}
+		for key, id := range manifest.ModelSelection {
+			selection[key] = id
+		}
+		sourceIR.Config["rehydrate.rikka.modelSelection"] = selection
+		selectionWarnings = append(selectionWarnings, "sidecar-rehydrate:model-selection")
+	}
 	type candidate struct {
 		path   string
 		format string
@@ -389,16 +1572,24 @@ func tryRehydrateFromSidecar(inputDir, targetFormat string, sourceIR *ir.BackupI
 		})
 	}
 	if len(candidates) == 0 {
-		return nil, nil
+		if strings.EqualFold(strings.TrimSpace(manifest.SourceFormat), targetFormat) {
+			// written with --no-raw-sidecar: convert from the manifest alone
+			selectionWarnings = append(selectionWarnings, "sidecar-rehydrate:raw-sources-absent")
+		}
+		return selectionWarnings, nil
 	}
 	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].index < candidates[j].index })
 	chosen := candidates[0]
-	outWarnings := []string{}
+	outWarnings := selectionWarnings
 	if len(candidates) > 1 {
 		outWarnings = append(outWarnings, "sidecar-rehydrate:multiple-source-candidates")
 	}
 
-	sidecarDir, cleanup, err := extractToTemp(chosen.path)
+	// the raw copy comes from the uploaded archive too, so it gets the same caps
+	sidecarDir, cleanup, err := extractToTemp(ctx, tempDir, chosen.path, limits)
+	if errors.Is(err, backup.ErrExtractLimit) {
+		return nil, fmt.Errorf("sidecar raw source: %w", err)
+	}
 	if err != nil {
 		return append(outWarnings, "sidecar-rehydrate:extract-source-failed"), nil
 	}
@@ -408,11 +1599,12 @@ func tryRehydrateFromSidecar(inputDir, targetFormat string, sourceIR *ir.BackupI
 	if d.Format == backup.FormatUnknown {
 		return append(outWarnings, "sidecar-rehydrate:source-format-unknown"), nil
 	}
+	sidecarDir = d.Root
 	if !strings.EqualFold(string(d.Format), targetFormat) {
 		return append(outWarnings, "sidecar-rehydrate:source-format-mismatch"), nil
 	}
 
-	rawIR, err := parseByFormat(d.Format, sidecarDir)
+	rawIR, err := parseByFormat(d.Format, sidecarDir, tempDir)
 	if err != nil {
 		return append(outWarnings, "sidecar-rehydrate:parse-source-failed"), nil
 	}
@@ -431,6 +1623,12 @@ func tryRehydrateFromSidecar(inputDir, targetFormat string, sourceIR *ir.BackupI
 		if raw := mapAny(rawIR.Opaque["interop.cherry.unsupported"]); len(raw) > 0 {
 			sourceIR.Opaque["interop.cherry.unsupported"] = raw
 		}
+		if raw := mapAny(rawIR.Opaque["cherry.indexedDB.extra"]); len(raw) > 0 {
+			sourceIR.Opaque["cherry.indexedDB.extra"] = raw
+		}
+		if n := restoreCherryMessageStates(rawIR, sourceIR, manifest.IDMap); n > 0 {
+			outWarnings = append(outWarnings, fmt.Sprintf("sidecar-rehydrate:message-status:%d", n))
+		}
 	case "rikka":
 		if raw := mapAny(rawIR.Config["rikka.settings"]); len(raw) > 0 {
 			sourceIR.Config["rehydrate.rikka.settings"] = raw
@@ -453,6 +1651,45 @@ func tryRehydrateFromSidecar(inputDir, targetFormat string, sourceIR *ir.BackupI
 	return outWarnings, nil
 }
 
+// restoreCherryMessageStates copies error/paused message states from the raw cherry
+// source onto the messages parsed from the rikka backup, which has no such field.
+// Messages are matched through the manifest id map, or by id when it was omitted.
+func restoreCherryMessageStates(rawIR, sourceIR *ir.BackupIR, idMap map[string]string) int {
+	states := map[string]string{}
+	for _, conv := range rawIR.Conversations {
+		for _, msg := range conv.Messages {
+			status := strings.TrimSpace(str(msg.Opaque["cherry.status"]))
+			if status == "" {
+				continue
+			}
+			id := msg.ID
+			if mapped := idMap["message:"+msg.ID]; mapped != "" {
+				id = mapped
+			}
+			states[id] = status
+		}
+	}
+	if len(states) == 0 {
+		return 0
+	}
+	restored := 0
+	for ci := range sourceIR.Conversations {
+		for mi := range sourceIR.Conversations[ci].Messages {
+			msg := &sourceIR.Conversations[ci].Messages[mi]
+			status, ok := states[msg.ID]
+			if !ok || str(msg.Opaque["cherry.status"]) != "" {
+				continue
+			}
+			if msg.Opaque == nil {
+				msg.Opaque = map[string]any{}
+			}
+			msg.Opaque["cherry.status"] = status
+			restored++
+		}
+	}
+	return restored
+}
+
 func mapAny(v any) map[string]any {
 	m, _ := v.(map[string]any)
 	if m == nil {
@@ -461,31 +1698,181 @@ func mapAny(v any) map[string]any {
 	return m
 }
 
-func parseByFormat(format backup.Format, dir string) (*ir.BackupIR, error) {
+// parseByFormat parses an extracted backup; tempDir is the --temp-dir root for any
+// scratch copies the parser needs ("" for the system temp dir).
+func parseByFormat(format backup.Format, dir, tempDir string) (*ir.BackupIR, error) {
+	var parsed *ir.BackupIR
+	var err error
 	switch format {
 	case backup.FormatCherry:
-		return cherry.ParseToIR(dir)
+		parsed, err = cherry.ParseToIR(dir)
 	case backup.FormatRikka:
-		return rikka.ParseToIR(dir)
+		root, rootErr := tempRoot(tempDir)
+		if rootErr != nil {
+			return nil, rootErr
+		}
+		parsed, err = rikka.ParseToIRWith(dir, rikka.ParseOptions{TempDir: root})
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", format)
 	}
+	if err != nil {
+		return nil, err
+	}
+	normalizeTimestamps(parsed)
+	return parsed, nil
+}
+
+// normalizeTimestamps rewrites every conversation, message and file timestamp in
+// util.TimestampLayout. Cherry mixes ISO strings and epoch millis and rikka stores
+// zoneless date-times, so comparing raw values would misorder them.
+func normalizeTimestamps(data *ir.BackupIR) {
+	for ci := range data.Conversations {
+		conv := &data.Conversations[ci]
+		conv.CreatedAt = util.NormalizeTimestamp(conv.CreatedAt)
+		conv.UpdatedAt = util.NormalizeTimestamp(conv.UpdatedAt)
+		for mi := range conv.Messages {
+			conv.Messages[mi].CreatedAt = util.NormalizeTimestamp(conv.Messages[mi].CreatedAt)
+		}
+	}
+	for fi := range data.Files {
+		data.Files[fi].CreatedAt = util.NormalizeTimestamp(data.Files[fi].CreatedAt)
+		data.Files[fi].UpdatedAt = util.NormalizeTimestamp(data.Files[fi].UpdatedAt)
+	}
+}
+
+// assumeSearchDepth bounds the nested-directory search used when a format is forced.
+const assumeSearchDepth = 3
+
+// errAmbiguousBackup carries no --from hint: only callers that accept --from add one,
+// via withFromHint.
+var errAmbiguousBackup = errors.New("ambiguous backup: contains both cherry and rikka markers")
+
+func withFromHint(err error) error {
+	if errors.Is(err, errAmbiguousBackup) {
+		return fmt.Errorf("%w; pass --from cherry or --from rikka", err)
+	}
+	return err
+}
+
+// detectWorkDir detects the backup format of dir. When assume names a concrete format and
+// detection fails, nested directories are searched for that format's required files instead.
+func detectWorkDir(dir, assume string) (string, backup.DetectResult, error) {
+	d := backup.DetectExtractedDir(dir)
+	assume = strings.ToLower(strings.TrimSpace(assume))
+	if d.Ambiguous() {
+		for _, candidate := range d.Candidates {
+			if string(candidate) == assume {
+				d.Format = candidate
+				d.Hints = append(d.Hints, "assumed-format:"+assume)
+				return d.Root, d, nil
+			}
+		}
+		return "", d, errAmbiguousBackup
+	}
+	if d.Format != backup.FormatUnknown || assume == "" || assume == "auto" {
+		return d.Root, d, nil
+	}
+	root, err := backup.LocateFormatRoot(dir, backup.Format(assume), assumeSearchDepth)
+	if err != nil {
+		return "", d, err
+	}
+	hints := append(backup.DetectExtractedDir(root).Hints, "assumed-format:"+assume)
+	if rel, err := filepath.Rel(dir, root); err == nil && rel != "." {
+		hints = append(hints, "root:"+filepath.ToSlash(rel))
+	}
+	return root, backup.DetectResult{Format: backup.Format(assume), Hints: hints, Root: root}, nil
+}
+
+// tempRoot picks the parent for temp dirs: the explicit option, then CHERRIKKA_TMPDIR,
+// then the OS temp ("").
+func tempRoot(dir string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = strings.TrimSpace(os.Getenv("CHERRIKKA_TMPDIR"))
+	}
+	if dir == "" {
+		return "", nil
+	}
+	if err := util.EnsureDir(dir); err != nil {
+		return "", fmt.Errorf("temp dir %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+func makeTempDir(tempDir, pattern string) (string, error) {
+	root, err := tempRoot(tempDir)
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(root, pattern)
 }
 
-func extractToTemp(zipPath string) (string, func(), error) {
-	tmp, err := os.MkdirTemp("", "cherrikka-zip-*")
+// extractToTemp unpacks zipPath, a zip or (by magic bytes) a .tar.gz, into a fresh
+// temp dir. An already unpacked backup directory is used in place and its cleanup
+// does nothing.
+func extractToTemp(ctx context.Context, tempDir, zipPath string, limits ExtractLimits) (string, func(), error) {
+	if st, err := os.Stat(zipPath); err == nil && st.IsDir() {
+		return zipPath, func() {}, nil
+	}
+	isTarGz, err := backup.IsGzip(zipPath)
+	if err != nil {
+		return "", nil, err
+	}
+	tmp, err := makeTempDir(tempDir, "cherrikka-zip-*")
 	if err != nil {
 		return "", nil, err
 	}
 	cleanup := func() { _ = os.RemoveAll(tmp) }
-	if err := backup.ExtractZip(zipPath, tmp); err != nil {
+	extract := backup.ExtractZipLimited
+	if isTarGz {
+		extract = backup.ExtractTarGzLimited
+	}
+	if err := extract(ctx, zipPath, tmp, limits.MaxBytes, limits.MaxFiles); err != nil {
 		cleanup()
 		return "", nil, err
 	}
 	return tmp, cleanup, nil
 }
 
-func writeSidecar(buildDir string, sources []parsedSource, primaryIdx int, manifest *ir.Manifest) error {
+// resolveWorkDir is extractToTemp without cancellation or limits, for callers that
+// accept either a backup zip or an unpacked backup directory.
+func resolveWorkDir(path string) (string, func(), error) {
+	return extractToTemp(context.Background(), "", path, ExtractLimits{})
+}
+
+// sourceArchiveBytes reads the input zip for the sidecar's raw source copy. Directory
+// and .tar.gz inputs are zipped from their extracted dir, so the copy is always a zip.
+func sourceArchiveBytes(ctx context.Context, tempDir, inputPath, extractedDir string) ([]byte, error) {
+	st, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		isTarGz, err := backup.IsGzip(inputPath)
+		if err != nil {
+			return nil, err
+		}
+		if !isTarGz {
+			return os.ReadFile(inputPath)
+		}
+	}
+	entries, err := collectZipEntries(extractedDir)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := makeTempDir(tempDir, "cherrikka-dirzip-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	zipPath := filepath.Join(tmp, "source.zip")
+	if err := backup.WriteZip(ctx, zipPath, entries); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(zipPath)
+}
+
+func writeSidecar(buildDir string, sources []parsedSource, primaryIdx int, manifest *ir.Manifest, report *ir.ConversionReport, includeRaw bool) error {
 	if len(sources) == 0 {
 		return fmt.Errorf("write sidecar: empty source list")
 	}
@@ -493,7 +1880,7 @@ func writeSidecar(buildDir string, sources []parsedSource, primaryIdx int, manif
 		primaryIdx = 0
 	}
 	sidecarDir := filepath.Join(buildDir, "cherrikka")
-	if err := util.EnsureDir(filepath.Join(sidecarDir, "raw")); err != nil {
+	if err := util.EnsureDir(sidecarDir); err != nil {
 		return err
 	}
 	mb, err := json.MarshalIndent(manifest, "", "  ")
@@ -503,6 +1890,19 @@ func writeSidecar(buildDir string, sources []parsedSource, primaryIdx int, manif
 	if err := os.WriteFile(filepath.Join(sidecarDir, "manifest.json"), mb, 0o644); err != nil {
 		return err
 	}
+	rb, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(sidecarDir, "report.json"), rb, 0o644); err != nil {
+		return err
+	}
+	if !includeRaw {
+		return nil
+	}
+	if err := util.EnsureDir(filepath.Join(sidecarDir, "raw")); err != nil {
+		return err
+	}
 	if err := os.WriteFile(filepath.Join(sidecarDir, "raw", "source.zip"), sources[primaryIdx].SourceBytes, 0o644); err != nil {
 		return err
 	}
@@ -515,6 +1915,44 @@ func writeSidecar(buildDir string, sources []parsedSource, primaryIdx int, manif
 	return nil
 }
 
+func buildSidecarReadme(manifest *ir.Manifest, data *ir.BackupIR, zone *time.Location, includeRaw bool) string {
+	messages := 0
+	for _, conv := range data.Conversations {
+		messages += len(conv.Messages)
+	}
+	var b strings.Builder
+	b.WriteString("This backup was produced by cherrikka.\n\n")
+	created := manifest.CreatedAt
+	if t, ok := util.ParseTimestamp(created); ok && zone != time.UTC {
+		created = t.In(zone).Format("2006-01-02 15:04:05 -07:00")
+	}
+	fmt.Fprintf(&b, "Created:  %s\n", created)
+	fmt.Fprintf(&b, "Source:   %s (%s)\n", manifest.SourceApp, manifest.SourceFormat)
+	fmt.Fprintf(&b, "Target:   %s (%s)\n", manifest.TargetApp, manifest.TargetFormat)
+	fmt.Fprintf(&b, "Redacted: %t\n\n", manifest.Redaction)
+	if len(manifest.Sources) > 1 {
+		b.WriteString("Merged sources:\n")
+		for _, src := range manifest.Sources {
+			fmt.Fprintf(&b, "  %d. %s (%s, sha256 %s)\n", src.Index, src.Name, src.SourceFormat, src.SourceSHA256)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Contents:\n")
+	fmt.Fprintf(&b, "  assistants:    %d\n", len(data.Assistants))
+	fmt.Fprintf(&b, "  conversations: %d\n", len(data.Conversations))
+	fmt.Fprintf(&b, "  messages:      %d\n", messages)
+	fmt.Fprintf(&b, "  files:         %d\n", len(data.Files))
+	if len(manifest.Warnings) > 0 {
+		fmt.Fprintf(&b, "  warnings:      %d (see manifest.json)\n", len(manifest.Warnings))
+	}
+	if includeRaw {
+		b.WriteString("\nmanifest.json holds the machine-readable conversion record and raw/ the original source backup(s).\n")
+	} else {
+		b.WriteString("\nmanifest.json holds the machine-readable conversion record. The original source backups were not embedded.\n")
+	}
+	return b.String()
+}
+
 func collectZipEntries(root string) ([]backup.ZipEntry, error) {
 	paths, err := util.ListFiles(root)
 	if err != nil {
@@ -532,6 +1970,9 @@ func targetAppName(to string) string {
 	if to == "cherry" {
 		return "cherry-studio"
 	}
+	if to == TargetIR {
+		return "cherrikka"
+	}
 	return "rikkahub"
 }
 
@@ -576,6 +2017,38 @@ func summarizeFiles(parsed *ir.BackupIR) *FileSummary {
 	return out
 }
 
+// fileDetails lists the parsed files; missing matches summarizeFiles, and the size
+// falls back to the extracted payload when the backup does not record one.
+func fileDetails(parsed *ir.BackupIR) []FileDetail {
+	out := make([]FileDetail, 0, len(parsed.Files))
+	for _, f := range parsed.Files {
+		missing := f.Missing || strings.TrimSpace(f.SourcePath) == ""
+		size := f.Size
+		if size <= 0 && !missing {
+			if st, err := os.Stat(f.SourcePath); err == nil {
+				size = st.Size()
+			}
+		}
+		out = append(out, FileDetail{
+			ID:          f.ID,
+			Name:        f.Name,
+			Ext:         f.Ext,
+			LogicalType: f.LogicalType,
+			Size:        size,
+			HashSHA256:  f.HashSHA256,
+			Orphan:      f.Orphan,
+			Missing:     missing,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
 func referencedFileIDs(parsed *ir.BackupIR) map[string]struct{} {
 	out := map[string]struct{}{}
 	for _, conv := range parsed.Conversations {
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cherrikka/internal/ir"
+	"cherrikka/internal/util"
+)
+
+const sliceDateLayout = "2006-01-02"
+
+// dateWindow is a half-open [from, until) activity window; a zero bound is unbounded.
+type dateWindow struct {
+	from  time.Time
+	until time.Time
+}
+
+func (w dateWindow) active() bool {
+	return !w.from.IsZero() || !w.until.IsZero()
+}
+
+func (w dateWindow) contains(t time.Time) bool {
+	if !w.from.IsZero() && t.Before(w.from) {
+		return false
+	}
+	return w.until.IsZero() || t.Before(w.until)
+}
+
+// parseDateWindow reads --from-date/--to-date as calendar days in zone; the to day is
+// inclusive, so 2024-01-01..2024-06-30 covers the whole first half of 2024.
+func parseDateWindow(fromDate, toDate string, zone *time.Location) (dateWindow, error) {
+	var w dateWindow
+	if s := strings.TrimSpace(fromDate); s != "" {
+		t, err := time.ParseInLocation(sliceDateLayout, s, zone)
+		if err != nil {
+			return w, fmt.Errorf("--from-date must be YYYY-MM-DD: %s", s)
+		}
+		w.from = t
+	}
+	if s := strings.TrimSpace(toDate); s != "" {
+		t, err := time.ParseInLocation(sliceDateLayout, s, zone)
+		if err != nil {
+			return w, fmt.Errorf("--to-date must be YYYY-MM-DD: %s", s)
+		}
+		w.until = t.AddDate(0, 0, 1)
+	}
+	if !w.from.IsZero() && !w.until.IsZero() && !w.from.Before(w.until) {
+		return w, fmt.Errorf("--from-date must not be after --to-date")
+	}
+	return w, nil
+}
+
+// sliceConversations keeps the conversations whose last activity falls inside the
+// window, so consecutive windows split a history without duplicating a conversation.
+// Files referenced only by dropped conversations go with them; assistants are
+// configuration and are all kept. Conversations without any parseable time are dropped.
+func sliceConversations(data *ir.BackupIR, window dateWindow) []string {
+	referencedBefore := referencedFileIDs(data)
+	total := len(data.Conversations)
+	undated := 0
+	kept := make([]ir.IRConversation, 0, len(data.Conversations))
+	for _, conv := range data.Conversations {
+		last, ok := conversationLastActivity(conv)
+		if !ok {
+			undated++
+			continue
+		}
+		if window.contains(last) {
+			kept = append(kept, conv)
+		}
+	}
+	data.Conversations = kept
+	droppedFiles := dropOrphanedFiles(data, referencedBefore)
+
+	warnings := []string{fmt.Sprintf("conversations-sliced:%d/%d", len(kept), total)}
+	if undated > 0 {
+		warnings = append(warnings, fmt.Sprintf("conversations-sliced:undated:%d", undated))
+	}
+	if droppedFiles > 0 {
+		warnings = append(warnings, fmt.Sprintf("files-sliced:%d", droppedFiles))
+	}
+	return warnings
+}
+
+// dropOrphanedFiles removes files that were referenced before conversations were
+// dropped and no longer are; files that were never referenced are kept.
+func dropOrphanedFiles(data *ir.BackupIR, referencedBefore map[string]struct{}) int {
+	referencedAfter := referencedFileIDs(data)
+	files := make([]ir.IRFile, 0, len(data.Files))
+	for _, f := range data.Files {
+		_, wasUsed := referencedBefore[f.ID]
+		_, stillUsed := referencedAfter[f.ID]
+		if wasUsed && !stillUsed {
+			continue
+		}
+		files = append(files, f)
+	}
+	dropped := len(data.Files) - len(files)
+	data.Files = files
+	return dropped
+}
+
+// filterConversationsByTime drops conversations whose UpdatedAt (CreatedAt when
+// UpdatedAt is missing) falls outside [since, until] in unix millis; a zero bound is
+// unbounded. Conversations without a parseable time cannot be placed and are kept. It
+// returns the number of conversations and files removed and of undated ones kept.
+func filterConversationsByTime(data *ir.BackupIR, since, until int64) (int, int, int) {
+	referencedBefore := referencedFileIDs(data)
+	kept := make([]ir.IRConversation, 0, len(data.Conversations))
+	undated := 0
+	for _, conv := range data.Conversations {
+		t, ok := util.ParseTimestamp(conv.UpdatedAt)
+		if !ok {
+			t, ok = util.ParseTimestamp(conv.CreatedAt)
+		}
+		if !ok {
+			undated++
+			kept = append(kept, conv)
+			continue
+		}
+		ms := t.UnixMilli()
+		if (since > 0 && ms < since) || (until > 0 && ms > until) {
+			continue
+		}
+		kept = append(kept, conv)
+	}
+	dropped := len(data.Conversations) - len(kept)
+	data.Conversations = kept
+	return dropped, dropOrphanedFiles(data, referencedBefore), undated
+}
+
+func conversationLastActivity(conv ir.IRConversation) (time.Time, bool) {
+	var last time.Time
+	consider := func(raw string) {
+		if t, ok := util.ParseTimestamp(raw); ok && t.After(last) {
+			last = t
+		}
+	}
+	consider(conv.CreatedAt)
+	consider(conv.UpdatedAt)
+	for _, msg := range conv.Messages {
+		consider(msg.CreatedAt)
+	}
+	return last, !last.IsZero()
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"cherrikka/internal/backup"
+	"cherrikka/internal/ir"
+)
+
+type AssistantUsage struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Conversations int    `json:"conversations"`
+	Messages      int    `json:"messages"`
+}
+
+type ModelUsage struct {
+	ModelID  string `json:"modelId"`
+	Messages int    `json:"messages"`
+}
+
+type FileTypeUsage struct {
+	LogicalType string `json:"logicalType"`
+	Files       int    `json:"files"`
+	Bytes       int64  `json:"bytes"`
+}
+
+type BackupStats struct {
+	Format         string           `json:"format"`
+	Conversations  int              `json:"conversations"`
+	Messages       int              `json:"messages"`
+	ToolCallParts  int              `json:"toolCallParts"`
+	ReasoningParts int              `json:"reasoningParts"`
+	FileBytes      int64            `json:"fileBytes"`
+	Assistants     []AssistantUsage `json:"assistants"`
+	Models         []ModelUsage     `json:"models"`
+	FileTypes      []FileTypeUsage  `json:"fileTypes"`
+}
+
+// StatsBackup parses a backup and summarizes its history: conversations and messages
+// per assistant, messages per model (messages without a model id are not counted
+// there), and attachment bytes per logical type. Nothing is converted.
+func StatsBackup(ctx context.Context, path string) (*BackupStats, error) {
+	workDir, cleanup, err := extractToTemp(ctx, "", path, ExtractLimits{})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	workDir, d, err := detectWorkDir(workDir, "")
+	if err != nil {
+		return nil, err
+	}
+	if d.Format == backup.FormatUnknown {
+		return nil, fmt.Errorf("unknown backup format: %s", path)
+	}
+	parsed, err := parseByFormat(d.Format, workDir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := &BackupStats{
+		Format:        string(d.Format),
+		Conversations: len(parsed.Conversations),
+		Assistants:    []AssistantUsage{},
+		Models:        []ModelUsage{},
+		FileTypes:     []FileTypeUsage{},
+	}
+	assistantIdx := map[string]int{}
+	for _, a := range parsed.Assistants {
+		assistantIdx[a.ID] = len(out.Assistants)
+		out.Assistants = append(out.Assistants, AssistantUsage{ID: a.ID, Name: strings.TrimSpace(a.Name)})
+	}
+	models := map[string]int{}
+	for _, conv := range parsed.Conversations {
+		idx, ok := assistantIdx[conv.AssistantID]
+		if !ok {
+			// conversations bound to an assistant the backup does not define
+			idx = len(out.Assistants)
+			assistantIdx[conv.AssistantID] = idx
+			out.Assistants = append(out.Assistants, AssistantUsage{ID: conv.AssistantID})
+		}
+		out.Assistants[idx].Conversations++
+		out.Assistants[idx].Messages += len(conv.Messages)
+		out.Messages += len(conv.Messages)
+		for _, msg := range conv.Messages {
+			if id := strings.TrimSpace(msg.ModelID); id != "" {
+				models[id]++
+			}
+			countRichParts(msg.Parts, out)
+		}
+	}
+	for id, n := range models {
+		out.Models = append(out.Models, ModelUsage{ModelID: id, Messages: n})
+	}
+
+	types := map[string]*FileTypeUsage{}
+	for _, f := range parsed.Files {
+		kind := strings.TrimSpace(f.LogicalType)
+		if kind == "" {
+			kind = "other"
+		}
+		if types[kind] == nil {
+			types[kind] = &FileTypeUsage{LogicalType: kind}
+		}
+		size := f.Size
+		if size <= 0 && f.SourcePath != "" && !f.Missing {
+			if st, err := os.Stat(f.SourcePath); err == nil {
+				size = st.Size()
+			}
+		}
+		types[kind].Files++
+		types[kind].Bytes += size
+		out.FileBytes += size
+	}
+	for _, t := range types {
+		out.FileTypes = append(out.FileTypes, *t)
+	}
+
+	sort.SliceStable(out.Assistants, func(i, j int) bool { return out.Assistants[i].Messages > out.Assistants[j].Messages })
+	sort.Slice(out.Models, func(i, j int) bool {
+		if out.Models[i].Messages != out.Models[j].Messages {
+			return out.Models[i].Messages > out.Models[j].Messages
+		}
+		return out.Models[i].ModelID < out.Models[j].ModelID
+	})
+	sort.Slice(out.FileTypes, func(i, j int) bool {
+		if out.FileTypes[i].Bytes != out.FileTypes[j].Bytes {
+			return out.FileTypes[i].Bytes > out.FileTypes[j].Bytes
+		}
+		return out.FileTypes[i].LogicalType < out.FileTypes[j].LogicalType
+	})
+	return out, nil
+}
+
+func countRichParts(parts []ir.IRPart, out *BackupStats) {
+	for _, p := range parts {
+		switch p.Type {
+		case "tool":
+			out.ToolCallParts++
+		case "reasoning":
+			out.ReasoningParts++
+		}
+	}
+}
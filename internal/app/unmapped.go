@@ -0,0 +1,156 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cherrikka/internal/util"
+)
+
+// reportUnmappedKeys lists the leaf paths of the source raw.cherry / raw.rikka config
+// whose value cannot be found anywhere in the built target config. Formats name
+// things differently, so a leaf counts as carried when the target has the same path,
+// the same non-trivial string value anywhere, or a same-named key with an equal (or
+// redacted) value. Zero values are skipped since dropping them loses nothing.
+func reportUnmappedKeys(settings map[string]any, to, buildDir string) ([]string, error) {
+	target, err := readBuiltTargetConfig(to, buildDir)
+	if err != nil {
+		return nil, err
+	}
+	targetLeaves := map[string][]any{}
+	collectConfigLeaves("", target, targetLeaves)
+	strValues := map[string]struct{}{}
+	byName := map[string][]any{}
+	for p, values := range targetLeaves {
+		name := strings.ToLower(leafName(p))
+		for _, v := range values {
+			if s, ok := v.(string); ok && len(s) > 3 {
+				strValues[s] = struct{}{}
+			}
+			byName[name] = append(byName[name], v)
+		}
+	}
+
+	unmapped := []string{}
+	for _, root := range []string{"raw.cherry", "raw.rikka"} {
+		sourceLeaves := map[string][]any{}
+		collectConfigLeaves("", asMap(settings[root]), sourceLeaves)
+		for p, values := range sourceLeaves {
+			if _, ok := targetLeaves[p]; ok {
+				continue
+			}
+			for _, v := range values {
+				if !isZeroLeaf(v) && !leafCarried(v, byName[strings.ToLower(leafName(p))], strValues) {
+					unmapped = append(unmapped, root+"."+p)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(unmapped)
+	return unmapped, nil
+}
+
+func readBuiltTargetConfig(to, buildDir string) (map[string]any, error) {
+	if to == "rikka" {
+		b, err := os.ReadFile(filepath.Join(buildDir, "settings.json"))
+		if err != nil {
+			return nil, err
+		}
+		var settings map[string]any
+		if err := json.Unmarshal(b, &settings); err != nil {
+			return nil, fmt.Errorf("parse built settings.json: %w", err)
+		}
+		return settings, nil
+	}
+	b, err := os.ReadFile(filepath.Join(buildDir, "data.json"))
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]any
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, fmt.Errorf("parse built data.json: %w", err)
+	}
+	// persist:cherry-studio is a JSON string of JSON-string slices
+	out := map[string]any{}
+	var persist map[string]any
+	if err := json.Unmarshal([]byte(str(asMap(root["localStorage"])["persist:cherry-studio"])), &persist); err != nil {
+		return out, nil
+	}
+	for k, v := range persist {
+		var decoded any
+		if s, ok := v.(string); ok && json.Unmarshal([]byte(s), &decoded) == nil {
+			out[k] = decoded
+			continue
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// collectConfigLeaves flattens v into dotted paths; array elements share a "[]" segment.
+func collectConfigLeaves(prefix string, v any, out map[string][]any) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			p := k
+			if prefix != "" {
+				p = prefix + "." + k
+			}
+			collectConfigLeaves(p, child, out)
+		}
+	case []any:
+		for _, child := range t {
+			collectConfigLeaves(prefix+"[]", child, out)
+		}
+	default:
+		if prefix != "" {
+			out[prefix] = append(out[prefix], v)
+		}
+	}
+}
+
+func leafName(p string) string {
+	p = strings.TrimRight(p, "[]")
+	if i := strings.LastIndex(p, "."); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
+
+func isZeroLeaf(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(t) == ""
+	case bool:
+		return !t
+	case float64:
+		return t == 0
+	}
+	return false
+}
+
+func leafCarried(v any, sameName []any, strValues map[string]struct{}) bool {
+	if s, ok := v.(string); ok {
+		if _, found := strValues[s]; found {
+			return true
+		}
+	}
+	for _, candidate := range sameName {
+		if candidate == v {
+			return true
+		}
+		if s, ok := candidate.(string); ok {
+			if _, placeholder := util.ParseSecretPlaceholder(s); placeholder || s == "***REDACTED***" {
+				return true
+			}
+		}
+	}
+	return false
+}
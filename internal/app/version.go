@@ -0,0 +1,41 @@
+package app
+
+import (
+	"runtime/debug"
+
+	"cherrikka/internal/backup"
+)
+
+// Version is the build version, injected at link time with
+// -ldflags "-X cherrikka/internal/app.Version=v1.2.3".
+var Version = ""
+
+// ManifestSchemaVersion is written to every cherrikka/manifest.json.
+const ManifestSchemaVersion = 1
+
+type BuildInfo struct {
+	Version       string   `json:"version"`
+	SchemaVersion int      `json:"schemaVersion"`
+	SourceFormats []string `json:"sourceFormats"`
+	TargetFormats []string `json:"targetFormats"`
+}
+
+// CurrentBuildInfo reports the build version and what this build can convert. Without
+// an injected Version it falls back to the module version recorded by go install,
+// then "dev".
+func CurrentBuildInfo() BuildInfo {
+	version := Version
+	if version == "" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		} else {
+			version = "dev"
+		}
+	}
+	return BuildInfo{
+		Version:       version,
+		SchemaVersion: ManifestSchemaVersion,
+		SourceFormats: []string{string(backup.FormatCherry), string(backup.FormatRikka)},
+		TargetFormats: []string{string(backup.FormatCherry), string(backup.FormatRikka), TargetIR},
+	}
+}
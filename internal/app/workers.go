@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"sync"
+)
+
+// forEachBounded runs fn for every index in [0, n) with at most limit calls in
+// flight (limit <= 1 runs them in order on the caller's goroutine). The first error
+// cancels the context passed to the remaining calls and is returned once all have
+// stopped.
+func forEachBounded(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) error {
+	if limit <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(ctx, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, limit)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -1,8 +1,11 @@
 package backup
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 type Format string
@@ -16,9 +19,58 @@ const (
 type DetectResult struct {
 	Format Format
 	Hints  []string
+	Root   string // directory holding the backup; differs from the input when nested one level
+	// Candidates lists every format whose markers were found when more than one was;
+	// Format is then only the first of them and callers should ask for --from.
+	Candidates []Format
 }
 
+// Ambiguous reports whether the directory carries markers of several formats.
+func (d DetectResult) Ambiguous() bool {
+	return len(d.Candidates) > 1
+}
+
+// DetectExtractedDir detects the backup format at dir, descending into a single
+// top-level subdirectory when the root itself is not recognized.
 func DetectExtractedDir(dir string) DetectResult {
+	res := detectDir(dir)
+	if res.Format != FormatUnknown {
+		return res
+	}
+	sub, ok := singleSubdir(dir)
+	if !ok {
+		return res
+	}
+	nested := detectDir(sub)
+	if nested.Format == FormatUnknown {
+		return res
+	}
+	nested.Hints = append(nested.Hints, "nested:"+filepath.Base(sub)+"/")
+	return nested
+}
+
+func singleSubdir(dir string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	found := ""
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "__MACOSX" {
+			continue
+		}
+		if found != "" {
+			return "", false
+		}
+		found = entry.Name()
+	}
+	if found == "" {
+		return "", false
+	}
+	return filepath.Join(dir, found), true
+}
+
+func detectDir(dir string) DetectResult {
 	hints := make([]string, 0, 8)
 	hasDataJSON := fileExists(filepath.Join(dir, "data.json"))
 	hasDataDir := dirExists(filepath.Join(dir, "Data"))
@@ -42,13 +94,21 @@ func DetectExtractedDir(dir string) DetectResult {
 		hints = append(hints, "upload/")
 	}
 
-	if hasDataJSON && hasDataDir {
-		return DetectResult{Format: FormatCherry, Hints: hints}
+	isCherry := hasDataJSON && hasDataDir
+	isRikka := hasSettingsJSON && (hasRikkaDB || hasUploadDir)
+	// data.json and rikka_hub.db are each unmistakable, so either one next to the
+	// other format's full signature makes the archive ambiguous
+	if (isCherry && (isRikka || hasRikkaDB)) || (isRikka && hasDataJSON) {
+		hints = append(hints, "ambiguous:cherry+rikka")
+		return DetectResult{Format: FormatCherry, Hints: hints, Root: dir, Candidates: []Format{FormatCherry, FormatRikka}}
+	}
+	if isCherry {
+		return DetectResult{Format: FormatCherry, Hints: hints, Root: dir}
 	}
-	if hasSettingsJSON && (hasRikkaDB || hasUploadDir) {
-		return DetectResult{Format: FormatRikka, Hints: hints}
+	if isRikka {
+		return DetectResult{Format: FormatRikka, Hints: hints, Root: dir}
 	}
-	return DetectResult{Format: FormatUnknown, Hints: hints}
+	return DetectResult{Format: FormatUnknown, Hints: hints, Root: dir}
 }
 
 func fileExists(path string) bool {
@@ -66,3 +126,53 @@ func dirExists(path string) bool {
 	}
 	return st.IsDir()
 }
+
+// LocateFormatRoot searches dir and up to maxDepth nested levels for the files
+// the given format requires, returning the first matching directory.
+func LocateFormatRoot(dir string, format Format, maxDepth int) (string, error) {
+	required := requiredFiles(format)
+	if len(required) == 0 {
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+	level := []string{dir}
+	for depth := 0; depth <= maxDepth && len(level) > 0; depth++ {
+		next := []string{}
+		for _, candidate := range level {
+			if hasAllFiles(candidate, required) {
+				return candidate, nil
+			}
+			entries, err := os.ReadDir(candidate)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() && entry.Name() != "__MACOSX" {
+					next = append(next, filepath.Join(candidate, entry.Name()))
+				}
+			}
+		}
+		sort.Strings(next)
+		level = next
+	}
+	return "", fmt.Errorf("cannot find %s backup files (%s) within %d directory level(s)", format, strings.Join(required, ", "), maxDepth)
+}
+
+func requiredFiles(format Format) []string {
+	switch format {
+	case FormatCherry:
+		return []string{"data.json"}
+	case FormatRikka:
+		return []string{"settings.json", "rikka_hub.db"}
+	default:
+		return nil
+	}
+}
+
+func hasAllFiles(dir string, names []string) bool {
+	for _, name := range names {
+		if !fileExists(filepath.Join(dir, name)) {
+			return false
+		}
+	}
+	return true
+}
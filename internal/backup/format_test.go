@@ -35,6 +35,22 @@ func TestDetectExtractedDir(t *testing.T) {
 		}
 	})
 
+	t.Run("ambiguous", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"data.json", "settings.json", "rikka_hub.db"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(`{}`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "Data"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		res := DetectExtractedDir(dir)
+		if !res.Ambiguous() || len(res.Candidates) != 2 {
+			t.Fatalf("want ambiguous cherry+rikka, got %+v", res)
+		}
+	})
+
 	t.Run("unknown", func(t *testing.T) {
 		dir := t.TempDir()
 		res := DetectExtractedDir(dir)
@@ -43,3 +59,51 @@ func TestDetectExtractedDir(t *testing.T) {
 		}
 	})
 }
+
+func TestLocateFormatRoot(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "outer", "inner")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "settings.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "rikka_hub.db"), []byte("db"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if DetectExtractedDir(dir).Format != FormatUnknown {
+		t.Fatalf("expected root detection to miss nested backup")
+	}
+	root, err := LocateFormatRoot(dir, FormatRikka, 3)
+	if err != nil {
+		t.Fatalf("locate rikka root failed: %v", err)
+	}
+	if root != nested {
+		t.Fatalf("want %s, got %s", nested, root)
+	}
+	if _, err := LocateFormatRoot(dir, FormatCherry, 3); err == nil {
+		t.Fatalf("expected error when cherry files are absent")
+	}
+}
+
+func TestDetectExtractedDir_SingleNestedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "backup-2024")
+	if err := os.MkdirAll(filepath.Join(nested, "Data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "__MACOSX"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "data.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res := DetectExtractedDir(dir)
+	if res.Format != FormatCherry {
+		t.Fatalf("want cherry, got %s", res.Format)
+	}
+	if res.Root != nested {
+		t.Fatalf("want root %s, got %s", nested, res.Root)
+	}
+}
@@ -0,0 +1,238 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cherrikka/internal/util"
+)
+
+// FileSink receives the payload files a builder emits. DirSink lays them out under
+// a build directory; StreamZip writes them straight into the output archive so
+// large attachments are copied once instead of staged and re-read.
+type FileSink interface {
+	CopyFile(rel, srcPath string) (int64, error)
+	WriteFile(rel string, data []byte) error
+}
+
+type DirSink struct {
+	Root string
+}
+
+func (d DirSink) CopyFile(rel, srcPath string) (int64, error) {
+	dst := filepath.Join(d.Root, filepath.FromSlash(rel))
+	if err := util.CopyFile(srcPath, dst); err != nil {
+		return 0, err
+	}
+	st, err := os.Stat(dst)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+func (d DirSink) WriteFile(rel string, data []byte) error {
+	dst := filepath.Join(d.Root, filepath.FromSlash(rel))
+	if err := util.EnsureDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o644)
+}
+
+// HashSink is a FileSink that only hashes what it receives; dry runs build through
+// it so nothing reaches disk.
+type HashSink struct {
+	hashes map[string]string
+}
+
+func NewHashSink() *HashSink {
+	return &HashSink{hashes: map[string]string{}}
+}
+
+func (h *HashSink) CopyFile(rel, srcPath string) (int64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return h.write(rel, src)
+}
+
+func (h *HashSink) WriteFile(rel string, data []byte) error {
+	_, err := h.write(rel, bytes.NewReader(data))
+	return err
+}
+
+func (h *HashSink) write(rel string, r io.Reader) (int64, error) {
+	name := strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	if _, exists := h.hashes[name]; exists {
+		return 0, fmt.Errorf("duplicate zip entry: %s", name)
+	}
+	sum := sha256.New()
+	n, err := io.Copy(sum, r)
+	if err != nil {
+		return n, err
+	}
+	h.hashes[name] = hex.EncodeToString(sum.Sum(nil))
+	return n, nil
+}
+
+// Hashes returns the SHA256 of every entry written through the sink, keyed by path.
+func (h *HashSink) Hashes() map[string]string {
+	out := make(map[string]string, len(h.hashes))
+	for k, v := range h.hashes {
+		out[k] = v
+	}
+	return out
+}
+
+// StreamZip is a FileSink backed by the output zip. Entries written through the
+// sink are hashed on the way in; Finish appends the remaining on-disk entries.
+// The archive is built in a temp file next to the output and only replaces it on
+// Commit, so an existing output survives a failed or rejected conversion.
+type StreamZip struct {
+	output    string
+	f         *os.File
+	zw        *zip.Writer
+	hashes    map[string]string
+	closed    bool
+	committed bool
+}
+
+func CreateStreamZip(output string) (*StreamZip, error) {
+	if err := util.EnsureDir(filepath.Dir(output)); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.partial")
+	if err != nil {
+		return nil, err
+	}
+	return &StreamZip{output: output, f: f, zw: zip.NewWriter(f), hashes: map[string]string{}}, nil
+}
+
+// PartialPath is where the archive is built until Commit; after Finish it holds the
+// complete zip and can be verified before it replaces the output.
+func (z *StreamZip) PartialPath() string {
+	return z.f.Name()
+}
+
+func (z *StreamZip) CopyFile(rel, srcPath string) (int64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return z.write(rel, src)
+}
+
+func (z *StreamZip) WriteFile(rel string, data []byte) error {
+	_, err := z.write(rel, bytes.NewReader(data))
+	return err
+}
+
+func (z *StreamZip) write(rel string, r io.Reader) (int64, error) {
+	name := strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	if _, exists := z.hashes[name]; exists {
+		return 0, fmt.Errorf("duplicate zip entry: %s", name)
+	}
+	w, err := createZipEntry(z.zw, name)
+	if err != nil {
+		return 0, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), r)
+	if err != nil {
+		return n, err
+	}
+	z.hashes[name] = hex.EncodeToString(h.Sum(nil))
+	return n, nil
+}
+
+// Hashes returns the SHA256 of every entry written through the sink, keyed by path.
+func (z *StreamZip) Hashes() map[string]string {
+	out := make(map[string]string, len(z.hashes))
+	for k, v := range z.hashes {
+		out[k] = v
+	}
+	return out
+}
+
+// Finish writes entries after the streamed ones and closes the archive. On error
+// the partial archive is removed.
+func (z *StreamZip) Finish(ctx context.Context, entries []ZipEntry) (err error) {
+	defer func() {
+		if err != nil {
+			z.Abort()
+		}
+	}()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.TrimPrefix(filepath.ToSlash(e.Path), "/") == "" {
+			continue
+		}
+		if e.SourcePath != "" {
+			if _, err := z.CopyFile(e.Path, e.SourcePath); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := z.WriteFile(e.Path, e.Data); err != nil {
+			return err
+		}
+	}
+	if err := z.zw.Close(); err != nil {
+		return err
+	}
+	if err := z.f.Sync(); err != nil {
+		return err
+	}
+	z.closed = true
+	return z.f.Close()
+}
+
+// Commit moves the finished archive over the output path.
+func (z *StreamZip) Commit() error {
+	if !z.closed {
+		return fmt.Errorf("commit before finish: %s", z.output)
+	}
+	if err := os.Rename(z.f.Name(), z.output); err != nil {
+		return err
+	}
+	z.committed = true
+	return nil
+}
+
+// Abort closes and removes the partial archive; it is a no-op after Commit.
+func (z *StreamZip) Abort() {
+	if z.committed {
+		return
+	}
+	if !z.closed {
+		z.closed = true
+		z.f.Close()
+	}
+	_ = os.Remove(z.f.Name())
+}
+
+func createZipEntry(zw *zip.Writer, name string) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+}
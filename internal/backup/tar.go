@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// IsGzip reports whether path starts with the gzip magic bytes, so renamed .tar.gz
+// backups are recognized regardless of extension.
+func IsGzip(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(magic, []byte{0x1f, 0x8b}), nil
+}
+
+func ExtractTarGz(ctx context.Context, srcTarGz, dstDir string) error {
+	return ExtractTarGzLimited(ctx, srcTarGz, dstDir, 0, 0)
+}
+
+// ExtractTarGzLimited mirrors ExtractZipLimited for gzip-compressed tar archives.
+// Only regular files and directories are extracted; links and devices are skipped.
+func ExtractTarGzLimited(ctx context.Context, srcTarGz, dstDir string, maxTotalBytes, maxFiles int64) error {
+	f, err := os.Open(srcTarGz)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	name := filepath.Base(srcTarGz)
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrCorruptArchive, name, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var written, files int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCorruptArchive, name, err)
+		}
+		cleanTarget, ok := extractTarget(dstDir, hdr.Name)
+		if !ok {
+			return fmt.Errorf("tar entry path traversal: %s", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(cleanTarget, 0o755); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			continue
+		}
+		files++
+		if maxFiles > 0 && files > maxFiles {
+			return fmt.Errorf("%w: %s has more than %d files", ErrExtractLimit, name, maxFiles)
+		}
+		if maxTotalBytes > 0 && hdr.Size > maxTotalBytes-written {
+			return fmt.Errorf("%w: %s decompresses to more than %d bytes", ErrExtractLimit, name, maxTotalBytes)
+		}
+		if err := os.MkdirAll(filepath.Dir(cleanTarget), 0o755); err != nil {
+			return err
+		}
+		out, err := os.Create(cleanTarget)
+		if err != nil {
+			return err
+		}
+		n, cpErr := io.Copy(out, tr)
+		closeErr := out.Close()
+		written += n
+		if cpErr != nil {
+			return fmt.Errorf("%w: entry %s: %v", ErrCorruptArchive, hdr.Name, cpErr)
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	if files == 0 {
+		return fmt.Errorf("%w: %s has no entries", ErrCorruptArchive, name)
+	}
+	return nil
+}
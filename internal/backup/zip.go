@@ -3,13 +3,15 @@ package backup
 import (
 	"archive/zip"
 	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-	"time"
 
 	"cherrikka/internal/util"
 )
@@ -20,18 +22,66 @@ type ZipEntry struct {
 	SourcePath string
 }
 
-func ExtractZip(srcZip, dstDir string) error {
-	r, err := zip.OpenReader(srcZip)
+// ErrCorruptArchive marks inputs that are not readable zips: empty files, incomplete
+// uploads or downloads, and files that are not zips at all.
+var ErrCorruptArchive = errors.New("file is not a valid zip or is truncated")
+
+// OpenZip opens a zip for reading, reporting empty, truncated and entry-less archives
+// as ErrCorruptArchive instead of the archive/zip error.
+func OpenZip(path string) (*zip.ReadCloser, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	if st.Size() == 0 {
+		return nil, fmt.Errorf("%w: %s is empty", ErrCorruptArchive, name)
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		if isCorruptZipError(err) {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorruptArchive, name, err)
+		}
+		return nil, err
+	}
+	if len(r.File) == 0 {
+		r.Close()
+		return nil, fmt.Errorf("%w: %s has no entries", ErrCorruptArchive, name)
+	}
+	return r, nil
+}
+
+func isCorruptZipError(err error) bool {
+	var flateErr flate.CorruptInputError
+	return errors.Is(err, zip.ErrFormat) || errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrAlgorithm) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.As(err, &flateErr)
+}
+
+// ErrExtractLimit marks archives whose entry count or decompressed size exceeds the
+// limits passed to ExtractZipLimited.
+var ErrExtractLimit = errors.New("zip exceeds extraction limit")
+
+func ExtractZip(ctx context.Context, srcZip, dstDir string) error {
+	return ExtractZipLimited(ctx, srcZip, dstDir, 0, 0)
+}
+
+// ExtractZipLimited is ExtractZip that stops with ErrExtractLimit once more than
+// maxFiles file entries or maxTotalBytes decompressed bytes would be written; a limit
+// of 0 disables it. Sizes are counted while copying, not taken from the zip headers.
+func ExtractZipLimited(ctx context.Context, srcZip, dstDir string, maxTotalBytes, maxFiles int64) error {
+	r, err := OpenZip(srcZip)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
 
+	var written, files int64
 	for _, f := range r.File {
-		target := filepath.Join(dstDir, filepath.FromSlash(f.Name))
-		cleanTarget := filepath.Clean(target)
-		cleanRoot := filepath.Clean(dstDir)
-		if !strings.HasPrefix(cleanTarget, cleanRoot+string(os.PathSeparator)) && cleanTarget != cleanRoot {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cleanTarget, ok := extractTarget(dstDir, f.Name)
+		if !ok {
 			return fmt.Errorf("zip entry path traversal: %s", f.Name)
 		}
 
@@ -41,11 +91,21 @@ func ExtractZip(srcZip, dstDir string) error {
 			}
 			continue
 		}
+		files++
+		if maxFiles > 0 && files > maxFiles {
+			return fmt.Errorf("%w: %s has more than %d files", ErrExtractLimit, filepath.Base(srcZip), maxFiles)
+		}
+		if maxTotalBytes > 0 && f.UncompressedSize64 > uint64(maxTotalBytes-written) {
+			return fmt.Errorf("%w: %s decompresses to more than %d bytes", ErrExtractLimit, filepath.Base(srcZip), maxTotalBytes)
+		}
 		if err := os.MkdirAll(filepath.Dir(cleanTarget), 0o755); err != nil {
 			return err
 		}
 		rc, err := f.Open()
 		if err != nil {
+			if isCorruptZipError(err) {
+				return fmt.Errorf("%w: entry %s: %v", ErrCorruptArchive, f.Name, err)
+			}
 			return err
 		}
 		out, err := os.Create(cleanTarget)
@@ -53,10 +113,22 @@ func ExtractZip(srcZip, dstDir string) error {
 			rc.Close()
 			return err
 		}
-		_, cpErr := io.Copy(out, rc)
+		var src io.Reader = rc
+		if maxTotalBytes > 0 {
+			// headers can lie; never write past the remaining budget
+			src = io.LimitReader(rc, maxTotalBytes-written+1)
+		}
+		n, cpErr := io.Copy(out, src)
 		closeErr := out.Close()
 		rcErr := rc.Close()
+		written += n
+		if cpErr == nil && maxTotalBytes > 0 && written > maxTotalBytes {
+			return fmt.Errorf("%w: %s decompresses to more than %d bytes", ErrExtractLimit, filepath.Base(srcZip), maxTotalBytes)
+		}
 		if cpErr != nil {
+			if isCorruptZipError(cpErr) {
+				return fmt.Errorf("%w: entry %s: %v", ErrCorruptArchive, f.Name, cpErr)
+			}
 			return cpErr
 		}
 		if closeErr != nil {
@@ -69,7 +141,19 @@ func ExtractZip(srcZip, dstDir string) error {
 	return nil
 }
 
-func WriteZip(output string, entries []ZipEntry) error {
+// extractTarget resolves an archive entry name under dstDir, rejecting names that
+// would land outside it.
+func extractTarget(dstDir, name string) (string, bool) {
+	cleanTarget := filepath.Clean(filepath.Join(dstDir, filepath.FromSlash(name)))
+	cleanRoot := filepath.Clean(dstDir)
+	if !strings.HasPrefix(cleanTarget, cleanRoot+string(os.PathSeparator)) && cleanTarget != cleanRoot {
+		return "", false
+	}
+	return cleanTarget, true
+}
+
+// WriteZip writes entries to output, removing the partial file when writing fails or ctx is cancelled.
+func WriteZip(ctx context.Context, output string, entries []ZipEntry) (err error) {
 	if err := util.EnsureDir(filepath.Dir(output)); err != nil {
 		return err
 	}
@@ -78,6 +162,12 @@ func WriteZip(output string, entries []ZipEntry) error {
 		return err
 	}
 	defer f.Close()
+	defer func() {
+		if err != nil {
+			f.Close()
+			_ = os.Remove(output)
+		}
+	}()
 
 	zw := zip.NewWriter(f)
 	defer zw.Close()
@@ -87,16 +177,14 @@ func WriteZip(output string, entries []ZipEntry) error {
 	})
 
 	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		name := strings.TrimPrefix(filepath.ToSlash(e.Path), "/")
 		if name == "" {
 			continue
 		}
-		h := &zip.FileHeader{
-			Name:     name,
-			Method:   zip.Deflate,
-			Modified: time.Now(),
-		}
-		w, err := zw.CreateHeader(h)
+		w, err := createZipEntry(zw, name)
 		if err != nil {
 			return err
 		}
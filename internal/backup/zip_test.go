@@ -0,0 +1,172 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractZipReportsCorruptArchives(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.zip")
+	f, err := os.Create(valid)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("data.json")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+	if _, err := w.Write([]byte(`{"time":1,"version":5,"indexedDB":{}}`)); err != nil {
+		t.Fatalf("write entry: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close file: %v", err)
+	}
+	full, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+
+	cases := map[string][]byte{
+		"empty":     {},
+		"truncated": full[:len(full)/2],
+		"not-a-zip": []byte("<html>502 Bad Gateway</html>"),
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".zip")
+			if err := os.WriteFile(path, content, 0o644); err != nil {
+				t.Fatalf("write input: %v", err)
+			}
+			err := ExtractZip(context.Background(), path, filepath.Join(dir, name+"-out"))
+			if !errors.Is(err, ErrCorruptArchive) {
+				t.Fatalf("expected ErrCorruptArchive, got %v", err)
+			}
+		})
+	}
+
+	if err := ExtractZip(context.Background(), valid, filepath.Join(dir, "valid-out")); err != nil {
+		t.Fatalf("extract valid zip: %v", err)
+	}
+	if _, err := OpenZip(filepath.Join(dir, "missing.zip")); err == nil || errors.Is(err, ErrCorruptArchive) {
+		t.Fatalf("missing file should not be reported as corrupt, got %v", err)
+	}
+}
+
+func TestExtractZipLimitedStopsAtLimits(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "bomb.zip")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	if _, err := zw.Create("Data/"); err != nil {
+		t.Fatalf("create dir entry: %v", err)
+	}
+	for _, name := range []string{"Data/a.bin", "Data/b.bin", "Data/c.bin"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		// highly compressible, like a zip bomb
+		if _, err := w.Write(make([]byte, 1<<20)); err != nil {
+			t.Fatalf("write entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close file: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		maxBytes int64
+		maxFiles int64
+		wantErr  bool
+	}{
+		{name: "bytes", maxBytes: 2<<20 + 1, wantErr: true},
+		{name: "files", maxFiles: 2, wantErr: true},
+		{name: "within", maxBytes: 3 << 20, maxFiles: 3},
+		{name: "unlimited"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ExtractZipLimited(context.Background(), src, filepath.Join(dir, tc.name), tc.maxBytes, tc.maxFiles)
+			if tc.wantErr != errors.Is(err, ErrExtractLimit) {
+				t.Fatalf("wantErr=%v, got %v", tc.wantErr, err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("extract failed: %v", err)
+			}
+		})
+	}
+}
+
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractTarGzDetectsByMagicAndGuardsPaths(t *testing.T) {
+	dir := t.TempDir()
+	renamed := filepath.Join(dir, "backup.zip")
+	writeTarGz(t, renamed, map[string]string{"data.json": "{}", "Data/Files/a.txt": "abc"})
+	if ok, err := IsGzip(renamed); err != nil || !ok {
+		t.Fatalf("expected gzip magic to be detected despite the .zip name, ok=%v err=%v", ok, err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := ExtractTarGz(context.Background(), renamed, out); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(out, "Data", "Files", "a.txt")); err != nil || string(b) != "abc" {
+		t.Fatalf("expected extracted payload, got %q err=%v", b, err)
+	}
+
+	evil := filepath.Join(dir, "evil.tar.gz")
+	writeTarGz(t, evil, map[string]string{"../escape.txt": "x"})
+	if err := ExtractTarGz(context.Background(), evil, filepath.Join(dir, "evil")); err == nil {
+		t.Fatalf("expected path traversal to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); err == nil {
+		t.Fatalf("traversal entry must not be written")
+	}
+
+	big := filepath.Join(dir, "big.tar.gz")
+	writeTarGz(t, big, map[string]string{"data.json": "0123456789"})
+	if err := ExtractTarGzLimited(context.Background(), big, filepath.Join(dir, "big"), 5, 0); !errors.Is(err, ErrExtractLimit) {
+		t.Fatalf("expected ErrExtractLimit, got %v", err)
+	}
+}
@@ -1,10 +1,12 @@
 package cherry
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -12,11 +14,32 @@ import (
 
 	guuid "github.com/google/uuid"
 
+	"cherrikka/internal/backup"
 	"cherrikka/internal/ir"
 	"cherrikka/internal/mapping"
 	"cherrikka/internal/util"
 )
 
+// decodeDataRoot tolerates a data.json whose root is not an object: an array
+// wrapping a single backup object is unwrapped, anything else is treated as empty.
+func decodeDataRoot(b []byte) (map[string]json.RawMessage, string, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, "", fmt.Errorf("parse data.json: %w", err)
+	}
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &root); err == nil && root != nil {
+		return root, "", nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err == nil && len(items) == 1 {
+		if err := json.Unmarshal(items[0], &root); err == nil && root != nil {
+			return root, "data.json root is an array, using its only element", nil
+		}
+	}
+	return map[string]json.RawMessage{}, "data.json root is not a JSON object, treating it as an empty backup", nil
+}
+
 func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 	dataPath := filepath.Join(extractedDir, "data.json")
 	b, err := os.ReadFile(dataPath)
@@ -24,9 +47,9 @@ func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 		return nil, err
 	}
 
-	var root map[string]json.RawMessage
-	if err := json.Unmarshal(b, &root); err != nil {
-		return nil, fmt.Errorf("parse data.json: %w", err)
+	root, rootWarning, err := decodeDataRoot(b)
+	if err != nil {
+		return nil, err
 	}
 
 	res := &ir.BackupIR{
@@ -38,6 +61,9 @@ func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 		Opaque:       map[string]any{},
 		Secrets:      map[string]string{},
 	}
+	if rootWarning != "" {
+		res.Warnings = append(res.Warnings, rootWarning)
+	}
 	if sidecarExists(extractedDir) {
 		res.Opaque["interop.sidecar.available"] = true
 	}
@@ -87,7 +113,7 @@ func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 				if ext == "" && strings.Contains(name, ".") {
 					ext = filepath.Ext(name)
 				}
-				sourcePath := resolveCherryFilePath(extractedDir, id, ext)
+				sourcePath := resolveCherryFilePath(extractedDir, id, ext, str(rec["name"]))
 				st, statErr := os.Stat(sourcePath)
 				if statErr != nil {
 					sourcePath = ""
@@ -134,14 +160,19 @@ func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 		}
 		for _, topic := range topics {
 			conv := ir.IRConversation{
-				ID:       str(topic["id"]),
-				Title:    str(topic["name"]),
-				Opaque:   map[string]any{},
-				Messages: []ir.IRMessage{},
+				ID:        str(topic["id"]),
+				Title:     str(topic["name"]),
+				CreatedAt: anyString(topic["createdAt"]),
+				UpdatedAt: anyString(topic["updatedAt"]),
+				Opaque:    map[string]any{},
+				Messages:  []ir.IRMessage{},
 			}
 			if conv.ID == "" {
 				conv.ID = util.NewUUID()
 			}
+			if isArchivedTopic(topic) {
+				conv.Opaque["archived"] = true
+			}
 			msgItems, _ := topic["messages"].([]any)
 			for _, item := range msgItems {
 				msgMap, ok := item.(map[string]any)
@@ -172,6 +203,8 @@ func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 	}
 	applyConversationAssistantFallbacks(res, explicitTopicAssistant, messageAssistantByTopic)
 	applyConversationTitleFallbacks(res)
+	applyConversationArchivedFlags(res)
+	applyConversationTimeFallbacks(res)
 	if isolated := mapping.ExtractCherryUnsupportedSettings(res.Config); len(isolated) > 0 {
 		res.Opaque["interop.cherry.unsupported"] = isolated
 		res.Warnings = append(res.Warnings, "unsupported-isolated:cherry.settings")
@@ -246,8 +279,13 @@ func parsePersistSlices(res *ir.BackupIR, localStorage map[string]any) error {
 			Settings:    asMap(m["settings"]),
 			Opaque:      map[string]any{},
 		}
+		if phrases, ok := m["regularPhrases"].([]any); ok && len(phrases) > 0 {
+			assistant.Opaque["cherry.regularPhrases"] = phrases
+		}
 		if assistant.ID == "" {
 			assistant.ID = util.NewUUID()
+			// write back so persisted topic ownership still resolves to this assistant
+			m["id"] = assistant.ID
 		}
 		res.Assistants = append(res.Assistants, assistant)
 	}
@@ -263,9 +301,19 @@ func parsePersistSlices(res *ir.BackupIR, localStorage map[string]any) error {
 
 func applyConversationAssistantFallbacks(res *ir.BackupIR, explicitTopicAssistant map[string]bool, messageAssistantByTopic map[string]string) {
 	assistantsByTopic := cherryAssistantTopicsFromPersist(res)
+	knownAssistants := map[string]bool{}
+	for _, a := range res.Assistants {
+		knownAssistants[a.ID] = true
+	}
 	for i := range res.Conversations {
 		conv := &res.Conversations[i]
 		if explicitTopicAssistant[conv.ID] {
+			owner := strings.TrimSpace(assistantsByTopic[conv.ID])
+			if knownAssistants[conv.AssistantID] || owner == "" {
+				continue
+			}
+			res.Warnings = append(res.Warnings, fmt.Sprintf("topic %s assistantId (%s) not found, using persisted owner (%s)", conv.ID, conv.AssistantID, owner))
+			conv.AssistantID = owner
 			continue
 		}
 		if aid := strings.TrimSpace(assistantsByTopic[conv.ID]); aid != "" {
@@ -291,6 +339,68 @@ func applyConversationTitleFallbacks(res *ir.BackupIR) {
 	}
 }
 
+// applyConversationArchivedFlags marks conversations whose persisted topic entry is archived or hidden.
+func applyConversationArchivedFlags(res *ir.BackupIR) {
+	archived := map[string]bool{}
+	persist, _ := res.Config["cherry.persistSlices"].(map[string]any)
+	assistantsSlice, _ := persist["assistants"].(map[string]any)
+	assistants, _ := assistantsSlice["assistants"].([]any)
+	for _, item := range assistants {
+		for _, topicItem := range toSlice(asMap(item)["topics"]) {
+			topic := asMap(topicItem)
+			if topicID := strings.TrimSpace(str(topic["id"])); topicID != "" && isArchivedTopic(topic) {
+				archived[topicID] = true
+			}
+		}
+	}
+	for i := range res.Conversations {
+		conv := &res.Conversations[i]
+		if !archived[conv.ID] {
+			continue
+		}
+		if conv.Opaque == nil {
+			conv.Opaque = map[string]any{}
+		}
+		conv.Opaque["archived"] = true
+	}
+}
+
+// applyConversationTimeFallbacks fills conversation times from the persisted topic
+// entries, which carry them when the indexedDB topic rows do not.
+func applyConversationTimeFallbacks(res *ir.BackupIR) {
+	times := map[string][2]string{}
+	persist, _ := res.Config["cherry.persistSlices"].(map[string]any)
+	assistantsSlice, _ := persist["assistants"].(map[string]any)
+	assistants, _ := assistantsSlice["assistants"].([]any)
+	for _, item := range assistants {
+		for _, topicItem := range toSlice(asMap(item)["topics"]) {
+			topic := asMap(topicItem)
+			if topicID := strings.TrimSpace(str(topic["id"])); topicID != "" {
+				times[topicID] = [2]string{anyString(topic["createdAt"]), anyString(topic["updatedAt"])}
+			}
+		}
+	}
+	for i := range res.Conversations {
+		conv := &res.Conversations[i]
+		t := times[conv.ID]
+		if conv.CreatedAt == "" {
+			conv.CreatedAt = t[0]
+		}
+		if conv.UpdatedAt == "" {
+			conv.UpdatedAt = t[1]
+		}
+	}
+}
+
+func isArchivedTopic(topic map[string]any) bool {
+	for _, key := range []string{"archived", "isArchived", "hidden"} {
+		if b, _ := topic[key].(bool); b {
+			return true
+		}
+	}
+	return false
+}
+
 func cherryAssistantTopicsFromPersist(res *ir.BackupIR) map[string]string {
 	out := map[string]string{}
 	persist, _ := res.Config["cherry.persistSlices"].(map[string]any)
@@ -384,6 +494,12 @@ func mergeDataFiles(extractedDir string, filesByID map[string]ir.IRFile) {
 	if err != nil {
 		return
 	}
+	referenced := map[string]struct{}{}
+	for _, f := range filesByID {
+		if f.SourcePath != "" {
+			referenced[f.SourcePath] = struct{}{}
+		}
+	}
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
@@ -398,6 +514,9 @@ func mergeDataFiles(extractedDir string, filesByID map[string]ir.IRFile) {
 			continue
 		}
 		fullPath := filepath.Join(filesDir, name)
+		if _, shared := referenced[fullPath]; shared {
+			continue
+		}
 		st, err := os.Stat(fullPath)
 		if err != nil {
 			continue
@@ -410,8 +529,8 @@ func mergeDataFiles(extractedDir string, filesByID map[string]ir.IRFile) {
 			SourcePath:  fullPath,
 			RelativeSrc: toRel(extractedDir, fullPath),
 			Size:        st.Size(),
-			CreatedAt:   st.ModTime().UTC().Format(time.RFC3339),
-			UpdatedAt:   st.ModTime().UTC().Format(time.RFC3339),
+			CreatedAt:   st.ModTime().UTC().Format(util.TimestampLayout),
+			UpdatedAt:   st.ModTime().UTC().Format(util.TimestampLayout),
 			HashSHA256:  hash,
 			LogicalType: normalizeLogicalType("", ext),
 			Orphan:      true,
@@ -437,11 +556,18 @@ func sortFiles(m map[string]ir.IRFile) []ir.IRFile {
 	return res
 }
 
+// cherryMessageKnownFields are message keys mapped onto IRMessage or rebuilt on
+// export; anything else is carried in Opaque["cherry.messageExtra"].
+var cherryMessageKnownFields = map[string]struct{}{
+	"id": {}, "role": {}, "createdAt": {}, "modelId": {}, "status": {}, "askId": {},
+	"blocks": {}, "content": {}, "assistantId": {}, "topicId": {},
+}
+
 func toIRMessage(msg map[string]any, blocksByID map[string]map[string]any, filesByID map[string]ir.IRFile) ir.IRMessage {
 	m := ir.IRMessage{
 		ID:        str(msg["id"]),
 		Role:      str(msg["role"]),
-		CreatedAt: str(msg["createdAt"]),
+		CreatedAt: anyString(msg["createdAt"]),
 		ModelID:   str(msg["modelId"]),
 		Parts:     []ir.IRPart{},
 		Opaque:    map[string]any{},
@@ -449,6 +575,22 @@ func toIRMessage(msg map[string]any, blocksByID map[string]map[string]any, files
 	if m.Role == "" {
 		m.Role = "user"
 	}
+	// error, paused, ...: only success is implied on export
+	if status := str(msg["status"]); status != "" && status != "success" {
+		m.Opaque["cherry.status"] = status
+	}
+	if askID := str(msg["askId"]); askID != "" {
+		m.Opaque["cherry.askId"] = askID
+	}
+	extra := map[string]any{}
+	for k, v := range msg {
+		if _, known := cherryMessageKnownFields[k]; !known {
+			extra[k] = v
+		}
+	}
+	if len(extra) > 0 {
+		m.Opaque["cherry.messageExtra"] = extra
+	}
 
 	blockIDs := toStringSlice(msg["blocks"])
 	for _, blockID := range blockIDs {
@@ -459,6 +601,14 @@ func toIRMessage(msg map[string]any, blocksByID map[string]map[string]any, files
 		m.Parts = append(m.Parts, mapBlockToPart(block, filesByID))
 	}
 
+	if m.CreatedAt == "" {
+		for _, p := range m.Parts {
+			if createdAt := str(p.Metadata["cherryCreatedAt"]); createdAt != "" {
+				m.CreatedAt = createdAt
+				break
+			}
+		}
+	}
 	if len(m.Parts) == 0 {
 		if c := str(msg["content"]); c != "" {
 			m.Parts = append(m.Parts, ir.IRPart{Type: "text", Content: c})
@@ -473,6 +623,9 @@ func toIRMessage(msg map[string]any, blocksByID map[string]map[string]any, files
 func mapBlockToPart(block map[string]any, filesByID map[string]ir.IRFile) ir.IRPart {
 	t := str(block["type"])
 	p := ir.IRPart{Type: "text", Metadata: map[string]any{"cherryBlockType": t}}
+	if createdAt := str(block["createdAt"]); createdAt != "" {
+		p.Metadata["cherryCreatedAt"] = createdAt
+	}
 
 	switch t {
 	case "main_text", "code", "translation", "compact":
@@ -505,6 +658,13 @@ func mapBlockToPart(block map[string]any, filesByID map[string]ir.IRFile) ir.IRP
 		if p.Name == "" {
 			p.Name = str(block["name"])
 		}
+	case "error":
+		p.Type = "text"
+		p.Content = cherryErrorText(block)
+		p.Metadata["error"] = true
+		if errInfo := asMap(block["error"]); len(errInfo) > 0 {
+			p.Metadata["cherryError"] = errInfo
+		}
 	default:
 		p.Type = "text"
 		if c := str(block["content"]); c != "" {
@@ -520,6 +680,27 @@ func mapBlockToPart(block map[string]any, filesByID map[string]ir.IRFile) ir.IRP
 	return p
 }
 
+func cherryErrorText(block map[string]any) string {
+	if c := str(block["content"]); c != "" {
+		return c
+	}
+	errInfo := asMap(block["error"])
+	for _, key := range []string{"message", "name"} {
+		if v := strings.TrimSpace(str(errInfo[key])); v != "" {
+			return v
+		}
+	}
+	return "[cherry error block]"
+}
+
+func isErrorPart(p ir.IRPart) bool {
+	if p.Type != "text" || p.Metadata == nil {
+		return false
+	}
+	flag, _ := p.Metadata["error"].(bool)
+	return flag || str(p.Metadata["cherryBlockType"]) == "error"
+}
+
 func fillPartFileInfo(p *ir.IRPart, block map[string]any, filesByID map[string]ir.IRFile) {
 	fm := asMap(block["file"])
 	if len(fm) == 0 {
@@ -542,7 +723,28 @@ func fillPartFileInfo(p *ir.IRPart, block map[string]any, filesByID map[string]i
 	}
 }
 
-func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets bool, idMap map[string]string) ([]string, error) {
+// BuildFromIR writes a cherry backup. Output arrays follow IR order: indexedDB.files
+// matches in.Files, topics match in.Conversations, and message_blocks are emitted per
+// message then per part. Generated ids and timestamps are derived deterministically
+// (see cherryBuildID and util.BuildTime) so identical input gives identical data.json.
+func BuildFromIR(ctx context.Context, in *ir.BackupIR, outputDir, templateDir string, redactSecrets bool, idMap map[string]string) ([]string, error) {
+	var redact *util.RedactConfig
+	if redactSecrets {
+		redact = &util.RedactConfig{}
+	}
+	return BuildFromIRTo(ctx, in, outputDir, backup.DirSink{Root: outputDir}, templateDir, redact, idMap, BuildOptions{})
+}
+
+// BuildOptions are cherry-only build switches.
+type BuildOptions struct {
+	// ContentAddressedFiles uses each payload's SHA256 as its cherry file id and
+	// Data/Files name, so files with the same content share one file-table entry.
+	ContentAddressedFiles bool
+}
+
+// BuildFromIRTo is BuildFromIR with Data/Files payloads sent to sink instead of
+// outputDir; data.json is still written to outputDir. A nil redact keeps secrets.
+func BuildFromIRTo(ctx context.Context, in *ir.BackupIR, outputDir string, sink backup.FileSink, templateDir string, redact *util.RedactConfig, idMap map[string]string, opts BuildOptions) ([]string, error) {
 	warnings := []string{}
 	var baseData map[string]any
 	if templateDir != "" {
@@ -581,54 +783,76 @@ func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets b
 		convByAssistant[conv.AssistantID] = append(convByAssistant[conv.AssistantID], conv)
 	}
 
-	fileTable, fileWarnings, err := materializeCherryFiles(outputDir, in.Files, idMap)
+	fileTable, fileWarnings, err := materializeCherryFiles(ctx, sink, in.Files, idMap, opts.ContentAddressedFiles)
 	if err != nil {
 		return nil, err
 	}
 	warnings = append(warnings, fileWarnings...)
 	indexedDB["files"] = fileTable
+	fileNames := make(map[string]string, len(fileTable))
+	for _, rec := range fileTable {
+		fileNames[str(rec["id"])] = str(rec["name"])
+	}
 
 	messageBlocks := make([]map[string]any, 0, 1024)
 	topics := make([]map[string]any, 0, len(in.Conversations))
-	for _, conv := range in.Conversations {
+	for ci, conv := range in.Conversations {
 		topicID := conv.ID
 		if topicID == "" {
-			topicID = util.NewUUID()
+			topicID = cherryBuildID(fmt.Sprintf("topic:%d:%s", ci, conv.Title))
 		}
 		if _, exists := idMap["topic:"+conv.ID]; !exists {
 			idMap["topic:"+conv.ID] = topicID
 		}
 		messages := make([]map[string]any, 0, len(conv.Messages))
-		for _, m := range conv.Messages {
+		for mi, m := range conv.Messages {
 			msgID := m.ID
 			if msgID == "" {
-				msgID = util.NewUUID()
+				msgID = cherryBuildID(fmt.Sprintf("message:%s:%d", topicID, mi))
 			}
 			idMap["message:"+m.ID] = msgID
 			blockIDs := make([]string, 0, len(m.Parts))
-			for _, p := range m.Parts {
-				blockID := util.NewUUID()
+			status := fallbackString(str(m.Opaque["cherry.status"]), "success")
+			createdAt := fallbackTime(fallbackString(m.CreatedAt, fallbackString(conv.CreatedAt, conv.UpdatedAt)))
+			for pi, p := range m.Parts {
+				blockID := cherryBuildID(fmt.Sprintf("block:%s:%d", msgID, pi))
 				blockIDs = append(blockIDs, blockID)
-				messageBlocks = append(messageBlocks, partToCherryBlock(blockID, msgID, p, in.Files, idMap))
+				messageBlocks = append(messageBlocks, partToCherryBlock(blockID, msgID, createdAt, p, in.Files, idMap, fileNames))
+				if isErrorPart(p) {
+					status = "error"
+				}
 			}
-			messages = append(messages, map[string]any{
+			message := map[string]any{
 				"id":          msgID,
 				"role":        normalizeRole(m.Role),
 				"assistantId": conv.AssistantID,
 				"topicId":     topicID,
-				"createdAt":   fallbackTime(m.CreatedAt),
-				"status":      "success",
+				"createdAt":   createdAt,
+				"status":      status,
 				"blocks":      blockIDs,
-			})
+			}
+			if askID := str(m.Opaque["cherry.askId"]); askID != "" {
+				message["askId"] = askID
+			}
+			for k, v := range asMap(m.Opaque["cherry.messageExtra"]) {
+				if _, exists := message[k]; !exists {
+					message[k] = v
+				}
+			}
+			messages = append(messages, message)
 		}
-		topics = append(topics, map[string]any{
+		topic := map[string]any{
 			"id":          topicID,
 			"name":        fallbackString(conv.Title, "Imported Conversation"),
 			"assistantId": conv.AssistantID,
 			"createdAt":   fallbackTime(conv.CreatedAt),
 			"updatedAt":   fallbackTime(conv.UpdatedAt),
 			"messages":    messages,
-		})
+		}
+		if archived, _ := conv.Opaque["archived"].(bool); archived {
+			topic["archived"] = true
+		}
+		topics = append(topics, topic)
 	}
 	indexedDB["topics"] = topics
 	indexedDB["message_blocks"] = messageBlocks
@@ -648,14 +872,14 @@ func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets b
 		}
 	}
 	if len(persistSlices) == 0 {
-		persistSlices = defaultPersistSlices()
+		persistSlices = defaultPersistSlices(userIDSeed(in))
 	}
-	assistantsSlice := buildAssistantsSlice(in.Assistants, convByAssistant)
+	assistantsSlice := buildAssistantsSlice(in.Assistants, convByAssistant, sourceAssistantsSlice(in))
 	persistSlices, mapWarnings := mapping.BuildCherryPersistSlicesFromIR(in, persistSlices, assistantsSlice)
 	warnings = append(warnings, mapWarnings...)
 
-	if redactSecrets {
-		persistSlices = util.RedactAny(persistSlices).(map[string]any)
+	if redact != nil {
+		persistSlices = util.RedactAnyWithConfig(persistSlices, *redact).(map[string]any)
 	}
 
 	persistRaw := map[string]any{}
@@ -664,7 +888,7 @@ func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets b
 	}
 	localStorage["persist:cherry-studio"] = util.MustJSON(persistRaw)
 
-	baseData["time"] = time.Now().UnixMilli()
+	baseData["time"] = util.BuildTime().UnixMilli()
 	baseData["version"] = 5
 	baseData["localStorage"] = localStorage
 	baseData["indexedDB"] = indexedDB
@@ -679,41 +903,54 @@ func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets b
 	return dedupeWarnings(warnings), nil
 }
 
-func materializeCherryFiles(outputDir string, files []ir.IRFile, idMap map[string]string) ([]map[string]any, []string, error) {
+func materializeCherryFiles(ctx context.Context, sink backup.FileSink, files []ir.IRFile, idMap map[string]string, contentAddressed bool) ([]map[string]any, []string, error) {
 	table := make([]map[string]any, 0, len(files))
 	warnings := []string{}
 	usedIDs := map[string]struct{}{}
-	destDir := filepath.Join(outputDir, "Data", "Files")
-	if err := util.EnsureDir(destDir); err != nil {
-		return nil, nil, err
-	}
+	// content-addressed entries are keyed by hash+ext; cherry opens Data/Files/<id><ext>,
+	// so files sharing content share one entry whose id is the hash and count the rest
+	sharedEntry := map[string]int{}
 	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		ext := cherryFileExt(f)
+		hashID := ""
+		if contentAddressed && f.SourcePath != "" && f.HashSHA256 != "" {
+			hashID = strings.ToLower(f.HashSHA256)
+			if i, ok := sharedEntry[hashID+ext]; ok {
+				table[i]["count"] = table[i]["count"].(int) + 1
+				idMap["file:"+f.ID] = table[i]["id"].(string)
+				continue
+			}
+		}
 		fid := chooseCherryFileID(f)
+		if _, taken := usedIDs[hashID]; hashID != "" && !taken {
+			fid = hashID
+		}
 		if _, exists := usedIDs[fid]; exists {
-			fid = util.NewUUID()
+			fid = cherryBuildID("file-dup:" + fid + ":" + f.ID)
 		}
 		usedIDs[fid] = struct{}{}
 		idMap["file:"+f.ID] = fid
-		ext := f.Ext
-		if ext == "" {
-			ext = filepath.Ext(f.Name)
-		}
 		name := fid + ext
-		if f.SourcePath != "" {
-			if err := util.CopyFile(f.SourcePath, filepath.Join(destDir, name)); err != nil {
-				return nil, nil, err
-			}
-		} else {
-			if err := os.WriteFile(filepath.Join(destDir, name), nil, 0o644); err != nil {
+		rel := path.Join("Data", "Files", name)
+		if f.SourcePath == "" {
+			if err := sink.WriteFile(rel, nil); err != nil {
 				return nil, nil, err
 			}
 			warnings = append(warnings, fmt.Sprintf("file %s missing source payload; created empty placeholder", f.ID))
+		} else if _, err := sink.CopyFile(rel, f.SourcePath); err != nil {
+			return nil, nil, err
+		}
+		if fid == hashID {
+			sharedEntry[hashID+ext] = len(table)
 		}
 		table = append(table, map[string]any{
 			"id":          fid,
 			"name":        name,
-			"origin_name": fallbackName(f.Name, name),
-			"path":        filepath.ToSlash(filepath.Join("Data", "Files", name)),
+			"origin_name": util.SanitizeFileName(f.Name, name),
+			"path":        rel,
 			"size":        f.Size,
 			"ext":         ext,
 			"type":        fallbackString(f.LogicalType, fallbackString(f.MimeType, "other")),
@@ -722,19 +959,25 @@ func materializeCherryFiles(outputDir string, files []ir.IRFile, idMap map[strin
 		})
 	}
 	if len(table) == 0 {
-		keepPath := filepath.Join(destDir, ".keep")
-		if err := os.WriteFile(keepPath, nil, 0o644); err != nil {
+		if err := sink.WriteFile("Data/Files/.keep", nil); err != nil {
 			return nil, nil, err
 		}
 	}
 	return table, dedupeWarnings(warnings), nil
 }
 
-func partToCherryBlock(blockID, messageID string, p ir.IRPart, files []ir.IRFile, idMap map[string]string) map[string]any {
+func cherryFileExt(f ir.IRFile) string {
+	if ext := util.SanitizeFileExt(f.Ext); ext != "" {
+		return ext
+	}
+	return util.SanitizeFileExt(filepath.Ext(util.SanitizeFileName(f.Name, "")))
+}
+
+func partToCherryBlock(blockID, messageID, createdAt string, p ir.IRPart, files []ir.IRFile, idMap, fileNames map[string]string) map[string]any {
 	meta := map[string]any{
 		"id":        blockID,
 		"messageId": messageID,
-		"createdAt": time.Now().UTC().Format(time.RFC3339),
+		"createdAt": fallbackString(str(p.Metadata["cherryCreatedAt"]), createdAt),
 		"status":    "success",
 	}
 	if p.Metadata != nil {
@@ -748,14 +991,12 @@ func partToCherryBlock(blockID, messageID string, p ir.IRPart, files []ir.IRFile
 				if id == "" {
 					id = f.ID
 				}
-				ext := f.Ext
-				if ext == "" {
-					ext = filepath.Ext(f.Name)
-				}
+				ext := cherryFileExt(f)
+				name := fallbackString(fileNames[id], id+ext)
 				return map[string]any{
 					"id":          id,
-					"name":        id + ext,
-					"origin_name": f.Name,
+					"name":        name,
+					"origin_name": util.SanitizeFileName(f.Name, name),
 					"ext":         ext,
 					"size":        f.Size,
 					"type":        fallbackString(f.MimeType, "other"),
@@ -771,7 +1012,7 @@ func partToCherryBlock(blockID, messageID string, p ir.IRPart, files []ir.IRFile
 		meta["content"] = p.Content
 	case "tool":
 		meta["type"] = "tool"
-		meta["toolId"] = fallbackString(p.ToolCallID, util.NewUUID())
+		meta["toolId"] = fallbackString(p.ToolCallID, cherryBuildID("tool:"+blockID))
 		meta["toolName"] = p.Name
 		if p.Input != "" {
 			var in any
@@ -811,13 +1052,35 @@ func partToCherryBlock(blockID, messageID string, p ir.IRPart, files []ir.IRFile
 			meta["content"] = p.Content
 		}
 	default:
+		if isErrorPart(p) {
+			meta["type"] = "error"
+			meta["status"] = "error"
+			errInfo := asMap(p.Metadata["cherryError"])
+			if len(errInfo) == 0 {
+				errInfo = map[string]any{"message": p.Content}
+			}
+			meta["error"] = errInfo
+			break
+		}
 		meta["type"] = "main_text"
 		meta["content"] = p.Content
 	}
 	return meta
 }
 
-func buildAssistantsSlice(assistants []ir.IRAssistant, convByAssistant map[string][]ir.IRConversation) map[string]any {
+// sourceAssistantsSlice returns the cherry assistants slice captured from the source
+// persist slices (or the sidecar rehydration copy), if any.
+func sourceAssistantsSlice(in *ir.BackupIR) map[string]any {
+	for _, key := range []string{"cherry.persistSlices", "rehydrate.cherry.persistSlices"} {
+		persist := asMap(in.Config[key])
+		if slice := asMap(persist["assistants"]); len(slice) > 0 {
+			return slice
+		}
+	}
+	return nil
+}
+
+func buildAssistantsSlice(assistants []ir.IRAssistant, convByAssistant map[string][]ir.IRConversation, source map[string]any) map[string]any {
 	if len(assistants) == 0 {
 		assistants = []ir.IRAssistant{{
 			ID:   "default",
@@ -828,11 +1091,11 @@ func buildAssistantsSlice(assistants []ir.IRAssistant, convByAssistant map[strin
 	arr := make([]any, 0, len(assistants))
 	for i, a := range assistants {
 		if a.ID == "" {
-			a.ID = util.NewUUID()
+			a.ID = cherryBuildID(fmt.Sprintf("assistant:%d:%s", i, a.Name))
 		}
 		topics := make([]any, 0)
 		for _, c := range convByAssistant[a.ID] {
-			topics = append(topics, map[string]any{
+			topic := map[string]any{
 				"id":                   c.ID,
 				"assistantId":          a.ID,
 				"name":                 fallbackString(c.Title, "Imported Conversation"),
@@ -840,7 +1103,18 @@ func buildAssistantsSlice(assistants []ir.IRAssistant, convByAssistant map[strin
 				"updatedAt":            fallbackTime(c.UpdatedAt),
 				"messages":             []any{},
 				"isNameManuallyEdited": true,
-			})
+			}
+			if archived, _ := c.Opaque["archived"].(bool); archived {
+				topic["archived"] = true
+			}
+			topics = append(topics, topic)
+		}
+		// Only keys the source actually set are written: Cherry Studio falls back to its
+		// own defaults for missing ones (temperature 1.0, contextCount 5, streamOutput
+		// on), so an empty map keeps "unset" instead of baking in a value.
+		settings := make(map[string]any, len(a.Settings))
+		for k, v := range a.Settings {
+			settings[k] = v
 		}
 		arr = append(arr, map[string]any{
 			"id":             a.ID,
@@ -849,32 +1123,68 @@ func buildAssistantsSlice(assistants []ir.IRAssistant, convByAssistant map[strin
 			"topics":         topics,
 			"type":           "assistant",
 			"emoji":          "😀",
-			"settings":       fallbackMap(a.Settings, map[string]any{"contextCount": 32, "temperature": 0.7, "streamOutput": true}),
-			"regularPhrases": []any{},
+			"settings":       settings,
+			"regularPhrases": fallbackSlice(a.Opaque["cherry.regularPhrases"]),
 		})
 	}
-	def := arr[0].(map[string]any)
 	defaultAssistant := map[string]any{}
-	for k, v := range def {
-		defaultAssistant[k] = v
+	if sourceDefault := asMap(source["defaultAssistant"]); len(sourceDefault) > 0 {
+		for k, v := range sourceDefault {
+			defaultAssistant[k] = v
+		}
+		if str(defaultAssistant["id"]) == "" {
+			defaultAssistant["id"] = "default"
+		}
+		if str(defaultAssistant["name"]) == "" {
+			defaultAssistant["name"] = "Default"
+		}
+	} else {
+		def := arr[0].(map[string]any)
+		for k, v := range def {
+			defaultAssistant[k] = v
+		}
+		defaultAssistant["id"] = "default"
+		defaultAssistant["name"] = "Default"
 	}
-	defaultAssistant["id"] = "default"
-	defaultAssistant["name"] = "Default"
 
 	return map[string]any{
 		"defaultAssistant": defaultAssistant,
 		"assistants":       arr,
-		"tagsOrder":        []any{},
-		"collapsedTags":    map[string]any{},
-		"presets":          []any{},
+		"tagsOrder":        fallbackSlice(source["tagsOrder"]),
+		"collapsedTags":    fallbackMap(asMap(source["collapsedTags"]), map[string]any{}),
+		"presets":          fallbackSlice(source["presets"]),
 		"unifiedListOrder": []any{},
 	}
 }
 
-func defaultPersistSlices() map[string]any {
+func fallbackSlice(v any) []any {
+	if items, ok := v.([]any); ok && items != nil {
+		return items
+	}
+	return []any{}
+}
+
+// userIDSeed identifies a backup by its conversation and assistant ids so a
+// synthesized cherry userId stays stable across repeated conversions.
+func userIDSeed(in *ir.BackupIR) string {
+	parts := make([]string, 0, len(in.Conversations)+len(in.Assistants))
+	for _, a := range in.Assistants {
+		parts = append(parts, a.ID)
+	}
+	for _, c := range in.Conversations {
+		parts = append(parts, c.ID)
+	}
+	return strings.Join(parts, "|")
+}
+
+func defaultPersistSlices(seed string) map[string]any {
+	userID := util.NewUUID()
+	if strings.Trim(seed, "|") != "" {
+		userID = cherryBuildID("user:" + seed)
+	}
 	return map[string]any{
 		"settings": map[string]any{
-			"userId":         util.NewUUID(),
+			"userId":         userID,
 			"userName":       "",
 			"skipBackupFile": false,
 		},
@@ -908,7 +1218,7 @@ func normalizeRole(role string) string {
 
 func fallbackTime(v string) string {
 	if v == "" {
-		return time.Now().UTC().Format(time.RFC3339)
+		return util.BuildTime().Format(util.TimestampLayout)
 	}
 	return v
 }
@@ -977,12 +1287,20 @@ func fallbackMap(v map[string]any, d map[string]any) map[string]any {
 	return v
 }
 
-func resolveCherryFilePath(extractedDir, id, ext string) string {
+// resolveCherryFilePath finds the payload of a file-table entry: <id><ext> first, then
+// the entry's own name (content-addressed payloads are shared under their SHA256).
+func resolveCherryFilePath(extractedDir, id, ext, name string) string {
 	basePath := filepath.Join(extractedDir, "Data", "Files", id+ext)
 	if _, err := os.Stat(basePath); err == nil {
 		return basePath
 	}
 	filesDir := filepath.Join(extractedDir, "Data", "Files")
+	if name != "" && name == filepath.Base(name) && name != "." && name != ".." {
+		p := filepath.Join(filesDir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
 	entries, err := os.ReadDir(filesDir)
 	if err != nil {
 		return basePath
@@ -1043,6 +1361,12 @@ func chooseCherryFileID(f ir.IRFile) string {
 	return deterministicCherryFileID(f)
 }
 
+// cherryBuildID derives stable ids for output records that have no source id,
+// so converting the same input twice yields identical data.json bytes.
+func cherryBuildID(seed string) string {
+	return guuid.NewSHA1(guuid.NameSpaceURL, []byte("cherrikka:cherry:"+seed)).String()
+}
+
 func deterministicCherryFileID(f ir.IRFile) string {
 	seedParts := []string{
 		strings.TrimSpace(f.ID),
@@ -1134,7 +1458,7 @@ func ValidateExtracted(dir string) error {
 				}
 				fileIDs[id] = struct{}{}
 				ext := str(rec["ext"])
-				path := resolveCherryFilePath(dir, id, ext)
+				path := resolveCherryFilePath(dir, id, ext, str(rec["name"]))
 				if _, err := os.Stat(path); err != nil {
 					issues = append(issues, "indexedDB.files entry missing payload: "+id)
 				}
@@ -1142,10 +1466,25 @@ func ValidateExtracted(dir string) error {
 		}
 	}
 
+	if raw, ok := indexed["topics"]; ok {
+		var topics []map[string]any
+		if err := json.Unmarshal(raw, &topics); err == nil {
+			topicIDs := make([]string, 0, len(topics))
+			for _, topic := range topics {
+				topicIDs = append(topicIDs, str(topic["id"]))
+			}
+			issues = append(issues, util.DuplicateIDIssues("indexedDB.topics", topicIDs)...)
+		}
+	}
+
 	if raw, ok := indexed["message_blocks"]; ok {
 		var blocks []map[string]any
 		if err := json.Unmarshal(raw, &blocks); err == nil {
 			for _, block := range blocks {
+				// arguments are normally an object; only a string form can be malformed
+				if args, ok := block["arguments"].(string); ok && str(block["type"]) == "tool" && strings.TrimSpace(args) != "" && !json.Valid([]byte(args)) {
+					issues = append(issues, "tool input not valid json: "+str(block["toolName"]))
+				}
 				fileMap := asMap(block["file"])
 				fileID := str(fileMap["id"])
 				if fileID == "" {
@@ -1184,6 +1523,9 @@ func ValidateExtracted(dir string) error {
 			llm := asMap(decoded["llm"])
 			modelIDs := map[string]struct{}{}
 			providerIDs := map[string]struct{}{}
+			providerIDList := []string{}
+			// cherry references models by (provider, id), so the same id under two providers is fine
+			providerModelKeys := []string{}
 			for _, pItem := range toSlice(llm["providers"]) {
 				pm := asMap(pItem)
 				providerID := strings.TrimSpace(str(pm["id"]))
@@ -1192,6 +1534,7 @@ func ValidateExtracted(dir string) error {
 					continue
 				}
 				providerIDs[providerID] = struct{}{}
+				providerIDList = append(providerIDList, providerID)
 				models := toSlice(pm["models"])
 				if len(models) == 0 {
 					issues = append(issues, "llm.providers has provider without models: "+providerID)
@@ -1204,6 +1547,7 @@ func ValidateExtracted(dir string) error {
 						continue
 					}
 					modelIDs[modelID] = struct{}{}
+					providerModelKeys = append(providerModelKeys, providerID+"/"+modelID)
 					if alt := strings.TrimSpace(str(mm["modelId"])); alt != "" {
 						modelIDs[alt] = struct{}{}
 					}
@@ -1217,6 +1561,8 @@ func ValidateExtracted(dir string) error {
 					}
 				}
 			}
+			issues = append(issues, util.DuplicateIDIssues("llm.providers", providerIDList)...)
+			issues = append(issues, util.DuplicateIDIssues("llm.providers.models", providerModelKeys)...)
 			for _, key := range []string{"defaultModel", "quickModel", "translateModel", "topicNamingModel"} {
 				m := asMap(llm[key])
 				if len(m) == 0 {
@@ -1236,8 +1582,10 @@ func ValidateExtracted(dir string) error {
 			}
 
 			assistantsSlice := asMap(decoded["assistants"])
+			assistantIDs := []string{}
 			for _, aItem := range toSlice(assistantsSlice["assistants"]) {
 				assistant := asMap(aItem)
+				assistantIDs = append(assistantIDs, str(assistant["id"]))
 				model := asMap(assistant["model"])
 				modelID := firstNonEmpty(str(model["id"]), str(model["modelId"]))
 				if modelID == "" {
@@ -1250,6 +1598,7 @@ func ValidateExtracted(dir string) error {
 					issues = append(issues, "assistant model not found in llm.providers: "+modelID)
 				}
 			}
+			issues = append(issues, util.DuplicateIDIssues("assistants", assistantIDs)...)
 		}
 	}
 	if len(issues) > 0 {
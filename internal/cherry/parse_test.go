@@ -1,9 +1,16 @@
 package cherry
 
 import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"cherrikka/internal/backup"
 	"cherrikka/internal/ir"
+	"cherrikka/internal/util"
 )
 
 func TestBuildAssistantsSlice_DefaultAssistantDoesNotMutateAssistants(t *testing.T) {
@@ -17,7 +24,7 @@ func TestBuildAssistantsSlice_DefaultAssistantDoesNotMutateAssistants(t *testing
 				Title:      "T1",
 			},
 		},
-	})
+	}, nil)
 
 	defaultAssistant, _ := slice["defaultAssistant"].(map[string]any)
 	assistants, _ := slice["assistants"].([]any)
@@ -32,3 +39,431 @@ func TestBuildAssistantsSlice_DefaultAssistantDoesNotMutateAssistants(t *testing
 		t.Fatalf("assistants[0].id should keep original id, got default")
 	}
 }
+
+func TestApplyConversationAssistantFallbacks_PrefersPersistOwnerForUnknownTopicAssistant(t *testing.T) {
+	res := &ir.BackupIR{
+		Assistants: []ir.IRAssistant{{ID: "assistant-a"}, {ID: "assistant-b"}},
+		Conversations: []ir.IRConversation{
+			{ID: "topic-1", AssistantID: "stale-assistant"},
+			{ID: "topic-2", AssistantID: "assistant-a"},
+		},
+		Config: map[string]any{
+			"cherry.persistSlices": map[string]any{
+				"assistants": map[string]any{
+					"assistants": []any{
+						map[string]any{"id": "assistant-b", "topics": []any{
+							map[string]any{"id": "topic-1"},
+							map[string]any{"id": "topic-2"},
+						}},
+					},
+				},
+			},
+		},
+	}
+	applyConversationAssistantFallbacks(res, map[string]bool{"topic-1": true, "topic-2": true}, map[string]string{})
+
+	if got := res.Conversations[0].AssistantID; got != "assistant-b" {
+		t.Fatalf("unknown topic assistant should fall back to persisted owner, got=%s", got)
+	}
+	if got := res.Conversations[1].AssistantID; got != "assistant-a" {
+		t.Fatalf("known explicit topic assistant should be kept, got=%s", got)
+	}
+}
+
+func TestErrorBlockRoundTrip(t *testing.T) {
+	block := map[string]any{
+		"id":     "block-err",
+		"type":   "error",
+		"status": "error",
+		"error":  map[string]any{"name": "APIError", "message": "rate limit exceeded"},
+	}
+	part := mapBlockToPart(block, map[string]ir.IRFile{})
+	if part.Type != "text" || part.Content != "rate limit exceeded" {
+		t.Fatalf("expected error text part, got=%+v", part)
+	}
+	if flag, _ := part.Metadata["error"].(bool); !flag {
+		t.Fatalf("expected error flag in metadata, got=%v", part.Metadata)
+	}
+
+	out := partToCherryBlock("block-new", "msg-1", "2024-01-01T00:00:00Z", part, nil, map[string]string{}, nil)
+	if out["type"] != "error" || out["status"] != "error" {
+		t.Fatalf("expected cherry error block, got=%v", out)
+	}
+	if got := str(asMap(out["error"])["name"]); got != "APIError" {
+		t.Fatalf("expected original error payload restored, got=%v", out["error"])
+	}
+}
+
+func TestBuildAssistantsSlice_PreservesSourceDefaultAssistant(t *testing.T) {
+	in := &ir.BackupIR{
+		Config: map[string]any{
+			"cherry.persistSlices": map[string]any{
+				"assistants": map[string]any{
+					"defaultAssistant": map[string]any{
+						"id":       "default",
+						"name":     "My Default",
+						"prompt":   "custom default prompt",
+						"settings": map[string]any{"temperature": 0.2},
+					},
+				},
+			},
+		},
+	}
+	slice := buildAssistantsSlice([]ir.IRAssistant{
+		{ID: "assistant-1", Name: "First", Prompt: "first prompt"},
+	}, map[string][]ir.IRConversation{}, sourceAssistantsSlice(in))
+
+	defaultAssistant, _ := slice["defaultAssistant"].(map[string]any)
+	if defaultAssistant["prompt"] != "custom default prompt" {
+		t.Fatalf("defaultAssistant prompt = %v, want custom default prompt", defaultAssistant["prompt"])
+	}
+	if defaultAssistant["name"] != "My Default" {
+		t.Fatalf("defaultAssistant name = %v, want My Default", defaultAssistant["name"])
+	}
+}
+
+func TestBuildAssistantsSlice_RestoresPhrasesAndPresets(t *testing.T) {
+	source := map[string]any{
+		"tagsOrder":     []any{"work"},
+		"collapsedTags": map[string]any{"work": true},
+		"presets":       []any{map[string]any{"id": "preset-1", "name": "Translator"}},
+	}
+	slice := buildAssistantsSlice([]ir.IRAssistant{{
+		ID:     "assistant-1",
+		Name:   "First",
+		Opaque: map[string]any{"cherry.regularPhrases": []any{map[string]any{"id": "p1", "content": "hello"}}},
+	}}, map[string][]ir.IRConversation{}, source)
+
+	if got := slice["presets"].([]any); len(got) != 1 {
+		t.Fatalf("presets = %v, want 1 entry", got)
+	}
+	if got := slice["tagsOrder"].([]any); len(got) != 1 || got[0] != "work" {
+		t.Fatalf("tagsOrder = %v, want [work]", got)
+	}
+	if got := slice["collapsedTags"].(map[string]any); got["work"] != true {
+		t.Fatalf("collapsedTags = %v, want work collapsed", got)
+	}
+	first := slice["assistants"].([]any)[0].(map[string]any)
+	if got := first["regularPhrases"].([]any); len(got) != 1 {
+		t.Fatalf("regularPhrases = %v, want 1 entry", got)
+	}
+}
+
+func TestValidateExtracted_ReportsDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "Data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	llm, _ := json.Marshal(map[string]any{"providers": []any{
+		map[string]any{"id": "openai", "models": []any{
+			map[string]any{"id": "gpt-4o", "provider": "openai"},
+			map[string]any{"id": "gpt-4o", "provider": "openai"},
+		}},
+		map[string]any{"id": "proxy", "models": []any{map[string]any{"id": "gpt-4o", "provider": "proxy"}}},
+	}})
+	assistants, _ := json.Marshal(map[string]any{"assistants": []any{
+		map[string]any{"id": "a1"}, map[string]any{"id": "a1"},
+	}})
+	persist, _ := json.Marshal(map[string]any{"llm": string(llm), "assistants": string(assistants)})
+	data, _ := json.Marshal(map[string]any{
+		"indexedDB": map[string]any{"topics": []any{
+			map[string]any{"id": "t1"}, map[string]any{"id": "t1"},
+		}},
+		"localStorage": map[string]any{"persist:cherry-studio": string(persist)},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ValidateExtracted(dir)
+	if err == nil {
+		t.Fatalf("expected duplicate ids to be reported")
+	}
+	for _, want := range []string{
+		"indexedDB.topics duplicate id: t1",
+		"llm.providers.models duplicate id: openai/gpt-4o",
+		"assistants duplicate id: a1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "proxy/gpt-4o") {
+		t.Fatalf("same model id under another provider is not a duplicate: %v", err)
+	}
+}
+
+func TestValidateExtracted_ReportsInvalidToolArguments(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "Data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]any{
+		"indexedDB": map[string]any{"message_blocks": []any{
+			map[string]any{"id": "b1", "type": "tool", "toolName": "search", "arguments": `{"q":`},
+			map[string]any{"id": "b2", "type": "tool", "toolName": "fetch", "arguments": map[string]any{"url": "x"}},
+			map[string]any{"id": "b3", "type": "tool", "toolName": "lookup", "arguments": `{"id":1}`},
+		}},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ValidateExtracted(dir)
+	if err == nil || !strings.Contains(err.Error(), "tool input not valid json: search") {
+		t.Fatalf("expected malformed tool arguments to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "fetch") || strings.Contains(err.Error(), "lookup") {
+		t.Fatalf("valid tool arguments should not be reported: %v", err)
+	}
+}
+
+func TestBlockCreatedAtSurvivesRoundTrip(t *testing.T) {
+	part := mapBlockToPart(map[string]any{
+		"type":      "main_text",
+		"content":   "hi",
+		"createdAt": "2024-03-01T10:00:00.000Z",
+	}, nil)
+	block := partToCherryBlock("b1", "m1", "2024-03-01T09:59:00.000Z", part, nil, map[string]string{}, nil)
+	if block["createdAt"] != "2024-03-01T10:00:00.000Z" {
+		t.Fatalf("block createdAt = %v, want original block time", block["createdAt"])
+	}
+
+	plain := partToCherryBlock("b2", "m1", "2024-03-01T09:59:00.000Z", ir.IRPart{Type: "text", Content: "x"}, nil, map[string]string{}, nil)
+	if plain["createdAt"] != "2024-03-01T09:59:00.000Z" {
+		t.Fatalf("block without own time should use message time, got %v", plain["createdAt"])
+	}
+}
+
+func TestParseToIR_NonObjectDataRoot(t *testing.T) {
+	dir := t.TempDir()
+	wrapped := `[{"indexedDB":{"topics":[{"id":"t1","messages":[]}]}}]`
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(wrapped), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseToIR(dir)
+	if err != nil {
+		t.Fatalf("expected wrapped data.json to parse, got=%v", err)
+	}
+	if len(parsed.Warnings) == 0 || !strings.Contains(strings.Join(parsed.Warnings, ","), "data.json root is an array") {
+		t.Fatalf("expected unwrap warning, got=%v", parsed.Warnings)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(`"oops"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err = ParseToIR(dir)
+	if err != nil {
+		t.Fatalf("expected scalar data.json to be tolerated, got=%v", err)
+	}
+	if !strings.Contains(strings.Join(parsed.Warnings, ","), "data.json root is not a JSON object") {
+		t.Fatalf("expected empty-backup warning, got=%v", parsed.Warnings)
+	}
+}
+
+func TestMessageExtraFieldsSurviveCherryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"indexedDB":{"topics":[{"id":"t1","messages":[{"id":"m1","role":"assistant","blocks":["b1"],"askId":"m0","useful":true,"foldSelected":true,"mentions":[{"id":"gpt-4o","provider":"openai"}]}]}],"message_blocks":[{"id":"b1","messageId":"m1","type":"main_text","content":"hi"}]},"localStorage":{}}`
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseToIR(dir)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	extra := asMap(parsed.Conversations[0].Messages[0].Opaque["cherry.messageExtra"])
+	if extra["useful"] != true || extra["foldSelected"] != true || len(toSlice(extra["mentions"])) != 1 {
+		t.Fatalf("expected unrecognized fields in opaque, got=%v", extra)
+	}
+	if _, ok := extra["blocks"]; ok {
+		t.Fatalf("known fields should not be duplicated into opaque: %v", extra)
+	}
+
+	out := t.TempDir()
+	if _, err := BuildFromIR(context.Background(), parsed, out, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(out, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root struct {
+		IndexedDB struct {
+			Topics []struct {
+				Messages []map[string]any `json:"messages"`
+			} `json:"topics"`
+		} `json:"indexedDB"`
+	}
+	if err := json.Unmarshal(b, &root); err != nil {
+		t.Fatal(err)
+	}
+	msg := root.IndexedDB.Topics[0].Messages[0]
+	if msg["useful"] != true || msg["foldSelected"] != true || msg["askId"] != "m0" || len(toSlice(msg["mentions"])) != 1 {
+		t.Fatalf("expected extra fields re-emitted, got=%v", msg)
+	}
+}
+
+func TestMessageStatusSurvivesCherryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"indexedDB":{"topics":[{"id":"t1","messages":[{"id":"m1","role":"assistant","status":"error","blocks":["b1"]},{"id":"m2","role":"assistant","status":"paused","blocks":["b2"]},{"id":"m3","role":"assistant","status":"success","blocks":["b3"]}]}],"message_blocks":[{"id":"b1","messageId":"m1","type":"main_text","content":"partial"},{"id":"b2","messageId":"m2","type":"main_text","content":"stopped"},{"id":"b3","messageId":"m3","type":"main_text","content":"done"}]},"localStorage":{}}`
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseToIR(dir)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, ok := parsed.Conversations[0].Messages[2].Opaque["cherry.status"]; ok {
+		t.Fatalf("success should not be carried in opaque")
+	}
+
+	out := t.TempDir()
+	if _, err := BuildFromIR(context.Background(), parsed, out, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(out, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root struct {
+		IndexedDB struct {
+			Topics []struct {
+				Messages []map[string]any `json:"messages"`
+			} `json:"topics"`
+		} `json:"indexedDB"`
+	}
+	if err := json.Unmarshal(b, &root); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, msg := range root.IndexedDB.Topics[0].Messages {
+		got = append(got, str(msg["status"]))
+	}
+	if strings.Join(got, ",") != "error,paused,success" {
+		t.Fatalf("expected message states preserved, got=%v", got)
+	}
+}
+
+func TestBuildAssistantsSlice_KeepsUnsetSettingsUnset(t *testing.T) {
+	slice := buildAssistantsSlice([]ir.IRAssistant{
+		{ID: "a1", Name: "No temperature"},
+		{ID: "a2", Name: "Tuned", Settings: map[string]any{"temperature": 0.2, "topP": 0.9}},
+	}, map[string][]ir.IRConversation{}, nil)
+	assistants, _ := slice["assistants"].([]any)
+	if len(assistants) != 2 {
+		t.Fatalf("expected 2 assistants, got=%v", assistants)
+	}
+	unset, _ := assistants[0].(map[string]any)["settings"].(map[string]any)
+	if unset == nil || len(unset) != 0 {
+		t.Fatalf("expected empty settings for an assistant without any, got=%v", unset)
+	}
+	tuned, _ := assistants[1].(map[string]any)["settings"].(map[string]any)
+	if tuned["temperature"] != 0.2 || tuned["topP"] != 0.9 || len(tuned) != 2 {
+		t.Fatalf("expected only the source settings, got=%v", tuned)
+	}
+}
+
+func TestBuildSanitizesFileOriginNames(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(src, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in := &ir.BackupIR{Files: []ir.IRFile{
+		{ID: "f1", Name: "../../etc/passwd", SourcePath: src},
+		{ID: "f2", Name: `..\..\evil.exe`, Ext: "/../x", SourcePath: src},
+		{ID: "f3", Name: "re\x00port\n.pdf", SourcePath: src},
+	}}
+	out := t.TempDir()
+	if _, err := BuildFromIR(context.Background(), in, out, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(out, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root struct {
+		IndexedDB struct {
+			Files []map[string]any `json:"files"`
+		} `json:"indexedDB"`
+	}
+	if err := json.Unmarshal(b, &root); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"passwd", "evil.exe", "report.pdf"}
+	if len(root.IndexedDB.Files) != len(want) {
+		t.Fatalf("expected %d files, got=%v", len(want), root.IndexedDB.Files)
+	}
+	for i, f := range root.IndexedDB.Files {
+		if f["origin_name"] != want[i] {
+			t.Fatalf("origin_name = %v, want %s", f["origin_name"], want[i])
+		}
+		p := str(f["path"])
+		if !strings.HasPrefix(p, "Data/Files/") || strings.ContainsAny(strings.TrimPrefix(p, "Data/Files/"), `/\`) || strings.Contains(p, "..") {
+			t.Fatalf("unexpected file path %q", p)
+		}
+	}
+	if root.IndexedDB.Files[1]["ext"] != ".exe" || root.IndexedDB.Files[2]["ext"] != ".pdf" {
+		t.Fatalf("expected extensions preserved, got=%v", root.IndexedDB.Files)
+	}
+	entries, err := os.ReadDir(filepath.Join(out, "Data", "Files"))
+	if err != nil || len(entries) != len(want) {
+		t.Fatalf("expected every payload inside Data/Files, got=%v err=%v", entries, err)
+	}
+}
+
+func TestBuildContentAddressedFilesShareOnePayload(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, p := range []string{a, b} {
+		if err := os.WriteFile(p, []byte("same bytes"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := util.SHA256Hex([]byte("same bytes"))
+	in := &ir.BackupIR{Files: []ir.IRFile{
+		{ID: "f1", Name: "one.txt", Ext: ".txt", SourcePath: a, HashSHA256: hash},
+		{ID: "f2", Name: "two.txt", Ext: ".txt", SourcePath: b, HashSHA256: hash},
+	}}
+	out := t.TempDir()
+	idMap := map[string]string{}
+	if _, err := BuildFromIRTo(context.Background(), in, out, backup.DirSink{Root: out}, "", nil, idMap, BuildOptions{ContentAddressedFiles: true}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(out, "Data", "Files"))
+	if err != nil || len(entries) != 1 || entries[0].Name() != hash+".txt" {
+		t.Fatalf("expected one shared payload, got=%v err=%v", entries, err)
+	}
+	// cherry opens Data/Files/<id><ext>, so the shared entry's id must be the hash
+	if idMap["file:f1"] != hash || idMap["file:f2"] != hash {
+		t.Fatalf("expected both files mapped to the shared cherry id, got=%v", idMap)
+	}
+	dataJSON, err := os.ReadFile(filepath.Join(out, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root struct {
+		IndexedDB struct {
+			Files []map[string]any `json:"files"`
+		} `json:"indexedDB"`
+	}
+	if err := json.Unmarshal(dataJSON, &root); err != nil {
+		t.Fatal(err)
+	}
+	if len(root.IndexedDB.Files) != 1 || root.IndexedDB.Files[0]["id"] != hash || root.IndexedDB.Files[0]["count"] != float64(2) {
+		t.Fatalf("expected one file-table entry with count 2, got=%v", root.IndexedDB.Files)
+	}
+	if _, err := os.Stat(filepath.Join(out, "Data", "Files", hash+".txt")); err != nil {
+		t.Fatalf("expected the payload at Data/Files/<id><ext>: %v", err)
+	}
+
+	parsed, err := ParseToIR(out)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(parsed.Files) != 1 || parsed.Files[0].Missing || parsed.Files[0].HashSHA256 != hash {
+		t.Fatalf("expected the shared entry to resolve to its payload, got=%+v", parsed.Files)
+	}
+	if err := ValidateExtracted(out); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+}
@@ -88,14 +88,76 @@ type Manifest struct {
 	Redaction     bool              `json:"redaction"`
 	CreatedAt     string            `json:"createdAt"`
 	Sources       []ManifestSource  `json:"sources,omitempty"`
-	Warnings      []string          `json:"warnings,omitempty"`
+	FileHashes    map[string]string `json:"fileHashes,omitempty"`
+	// ModelSelection keeps rikka selection slots that have no cherry counterpart.
+	ModelSelection map[string]string `json:"modelSelection,omitempty"`
+	// UnmappedKeys lists source config leaf paths missing from the target (--report-unmapped).
+	UnmappedKeys []string `json:"unmappedKeys,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+	// DryRun counts what a --dry-run conversion would have written; nil for real runs.
+	DryRun *DryRunSummary `json:"dryRun,omitempty"`
+}
+
+type DryRunSummary struct {
+	Conversations int `json:"conversations"`
+	Messages      int `json:"messages"`
+	Assistants    int `json:"assistants"`
+	Files         int `json:"files"`
 }
 
 type ManifestSource struct {
 	Index        int      `json:"index"`
+	Tag          string   `json:"tag,omitempty"`
 	Name         string   `json:"name"`
 	SourceApp    string   `json:"sourceApp"`
 	SourceFormat string   `json:"sourceFormat"`
 	SourceSHA256 string   `json:"sourceSha256"`
 	Hints        []string `json:"hints,omitempty"`
 }
+
+// ConversionReport is written to cherrikka/report.json beside the manifest. It holds
+// as counts what the manifest only carries as free-text warnings.
+type ConversionReport struct {
+	SchemaVersion     int            `json:"schemaVersion"`
+	Sources           []ReportSource `json:"sources"`
+	Merge             ReportMerge    `json:"merge"`
+	RenamedAssistants []ReportRename `json:"renamedAssistants"`
+	Dropped           []ReportDrop   `json:"dropped"`
+	Output            ReportCounts   `json:"output"`
+}
+
+type ReportCounts struct {
+	Assistants    int `json:"assistants"`
+	Conversations int `json:"conversations"`
+	Messages      int `json:"messages"`
+	Files         int `json:"files"`
+}
+
+// ReportSource counts one input as parsed, before any filter or merge.
+type ReportSource struct {
+	Index  int    `json:"index"`
+	Tag    string `json:"tag,omitempty"`
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	ReportCounts
+}
+
+type ReportMerge struct {
+	PrimarySourceIndex int    `json:"primarySourceIndex"`
+	ConfigPrecedence   string `json:"configPrecedence"`
+	DedupedFiles       int    `json:"dedupedFiles"`
+}
+
+type ReportRename struct {
+	SourceIndex int    `json:"sourceIndex"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+}
+
+// ReportDrop is what one pass (time-filter, archived, selection, ...) removed.
+type ReportDrop struct {
+	Stage         string `json:"stage"`
+	Conversations int    `json:"conversations"`
+	Messages      int    `json:"messages"`
+	Files         int    `json:"files"`
+}
@@ -0,0 +1,132 @@
+package mapping
+
+import "strings"
+
+// Assistant tags are normalized by name: "core.assistantTags" holds the ordered
+// groups as {name, collapsed, id} and every core.assistants entry lists the names
+// of its groups under "tags". Cherry stores the names directly; rikka needs a UUID
+// per tag, kept in "id" when the source had one.
+
+func normalizeCherryAssistantTags(assistantsSlice map[string]any, coreAssistants []any) []any {
+	collapsed := asMap(assistantsSlice["collapsedTags"])
+	order := []string{}
+	for _, name := range asSlice(assistantsSlice["tagsOrder"]) {
+		order = appendUnique(order, pickFirstString(name))
+	}
+	for _, item := range coreAssistants {
+		am := asMap(item)
+		names := []string{}
+		for _, tag := range asSlice(asMap(am["raw"])["tags"]) {
+			names = appendUnique(names, pickFirstString(tag))
+		}
+		if len(names) == 0 {
+			continue
+		}
+		am["tags"] = stringsToAny(names)
+		order = appendUnique(order, names...)
+	}
+	tags := make([]any, 0, len(order))
+	for _, name := range order {
+		isCollapsed, _ := collapsed[name].(bool)
+		tags = append(tags, map[string]any{"name": name, "collapsed": isCollapsed})
+	}
+	return tags
+}
+
+func normalizeRikkaAssistantTags(settings map[string]any, coreAssistants []any) []any {
+	tags := []any{}
+	nameByID := map[string]string{}
+	for _, item := range asSlice(settings["assistantTags"]) {
+		tm := asMap(item)
+		id, name := pickFirstString(tm["id"]), pickFirstString(tm["name"])
+		if name == "" {
+			continue
+		}
+		if id != "" {
+			nameByID[id] = name
+		}
+		tags = append(tags, map[string]any{"id": id, "name": name, "collapsed": false})
+	}
+	for _, item := range coreAssistants {
+		am := asMap(item)
+		names := []string{}
+		for _, id := range asSlice(asMap(am["raw"])["tags"]) {
+			if name := nameByID[pickFirstString(id)]; name != "" {
+				names = appendUnique(names, name)
+			}
+		}
+		if len(names) > 0 {
+			am["tags"] = stringsToAny(names)
+		}
+	}
+	return tags
+}
+
+// buildRikkaAssistantTags returns rikka's assistantTags list and a name -> UUID alias.
+func buildRikkaAssistantTags(coreTags []any) ([]any, map[string]string) {
+	out := make([]any, 0, len(coreTags))
+	alias := map[string]string{}
+	for _, item := range coreTags {
+		tm := asMap(item)
+		name := pickFirstString(tm["name"])
+		if name == "" {
+			continue
+		}
+		if _, exists := alias[name]; exists {
+			continue
+		}
+		id := ensureUUID(pickFirstString(tm["id"]), "assistant-tag:"+name)
+		alias[name] = id
+		out = append(out, map[string]any{"id": id, "name": name})
+	}
+	return out, alias
+}
+
+// attachCherryAssistantTags writes tag names onto the cherry assistants and rebuilds
+// tagsOrder/collapsedTags from the canonical groups.
+func attachCherryAssistantTags(assistantsSlice map[string]any, coreAssistants, coreTags []any) {
+	if len(assistantsSlice) == 0 || len(coreTags) == 0 {
+		return
+	}
+	tagsByAssistant := map[string][]any{}
+	for _, item := range coreAssistants {
+		am := asMap(item)
+		if id, tags := pickFirstString(am["id"]), asSlice(am["tags"]); id != "" && len(tags) > 0 {
+			tagsByAssistant[id] = tags
+		}
+	}
+	for _, item := range asSlice(assistantsSlice["assistants"]) {
+		assistant := asMap(item)
+		if tags := tagsByAssistant[pickFirstString(assistant["id"])]; len(tags) > 0 {
+			assistant["tags"] = cloneAny(tags)
+		}
+	}
+	order := []string{}
+	collapsed := asMap(assistantsSlice["collapsedTags"])
+	if len(collapsed) == 0 {
+		collapsed = map[string]any{}
+	}
+	for _, item := range coreTags {
+		tm := asMap(item)
+		name := pickFirstString(tm["name"])
+		if name == "" {
+			continue
+		}
+		order = appendUnique(order, name)
+		if isCollapsed, _ := tm["collapsed"].(bool); isCollapsed {
+			collapsed[name] = true
+		}
+	}
+	assistantsSlice["tagsOrder"] = stringsToAny(order)
+	assistantsSlice["collapsedTags"] = collapsed
+}
+
+func stringsToAny(items []string) []any {
+	out := make([]any, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
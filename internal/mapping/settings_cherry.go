@@ -49,6 +49,7 @@ func NormalizeFromCherryConfig(config map[string]any) (map[string]any, []string)
 		coreAssistants = append(coreAssistants, entry)
 	}
 	out["core.assistants"] = coreAssistants
+	out["core.assistantTags"] = normalizeCherryAssistantTags(assistantsSlice, coreAssistants)
 
 	llmProviders := asSlice(llm["providers"])
 	coreProviders := make([]any, 0, len(llmProviders))
@@ -69,13 +70,22 @@ func NormalizeFromCherryConfig(config map[string]any) (map[string]any, []string)
 			"mappedType": mapped,
 			"raw":        cloneMap(pm),
 		}
+		annotateCanonicalModelTypes(asMap(entry["raw"]))
 		ensureID(entry)
 		coreProviders = append(coreProviders, entry)
+		if fields := azureProviderFields(pm); isAzureProviderType(pType) && len(fields) > 0 {
+			out["raw.unsupported"] = append(asSlice(out["raw.unsupported"]), map[string]any{
+				"kind":       "provider.azure",
+				"providerId": entry["id"],
+				"name":       entry["name"],
+				"fields":     fields,
+			})
+		}
 	}
 	out["core.providers"] = coreProviders
 
 	coreModels := map[string]any{}
-	for _, key := range []string{"defaultModel", "quickModel", "translateModel", "topicNamingModel"} {
+	for _, key := range []string{"defaultModel", "quickModel", "translateModel", "topicNamingModel", "paintingModel"} {
 		if m := asMap(llm[key]); len(m) > 0 {
 			coreModels[key] = cloneMap(m)
 		}
@@ -88,7 +98,7 @@ func NormalizeFromCherryConfig(config map[string]any) (map[string]any, []string)
 		if len(src) == 0 {
 			return
 		}
-		if id := pickFirstString(src["id"], src["modelId"], src["name"]); id != "" {
+		if id := pickFirstString(src["rikkaId"], src["id"], src["modelId"], src["name"]); id != "" {
 			coreModels[selectionKey] = id
 		}
 	}
@@ -96,6 +106,12 @@ func NormalizeFromCherryConfig(config map[string]any) (map[string]any, []string)
 	setModelSelection("suggestionModelId", "quickModel")
 	setModelSelection("translateModeId", "translateModel")
 	setModelSelection("titleModelId", "topicNamingModel")
+	setModelSelection("imageGenerationModelId", "paintingModel")
+	for key, v := range asMap(config["rehydrate.rikka.modelSelection"]) {
+		if _, exists := coreModels[key]; !exists {
+			setIfPresent(coreModels, key, v)
+		}
+	}
 	out["core.models"] = coreModels
 
 	selection := map[string]any{}
@@ -104,6 +120,14 @@ func NormalizeFromCherryConfig(config map[string]any) (map[string]any, []string)
 	}
 	setIfPresent(selection, "assistantId", settings["assistantId"])
 	out["core.selection"] = selection
+	defaultSettings := asMap(asMap(assistantsSlice["defaultAssistant"])["settings"])
+	chatDefaults := pickChatDefaults(defaultSettings)
+	if _, ok := chatDefaults["maxTokens"]; ok {
+		// cherry ignores maxTokens unless enableMaxTokens is set.
+		enabled, _ := coerceBool(defaultSettings["enableMaxTokens"])
+		chatDefaults["enableMaxTokens"] = enabled
+	}
+	out["core.chatDefaults"] = chatDefaults
 
 	webdav := map[string]any{}
 	for _, key := range []string{"webdavHost", "webdavUser", "webdavPass", "webdavPath", "webdavAutoSync", "webdavSyncInterval", "webdavMaxBackups", "webdavSkipBackupFile", "webdavDisableStream"} {
@@ -154,5 +178,14 @@ func NormalizeFromCherryConfig(config map[string]any) (map[string]any, []string)
 	}
 	out["tts"] = tts
 
+	naming := map[string]any{}
+	if prompt := pickFirstString(settings["topicNamingPrompt"]); prompt != "" {
+		naming["titlePrompt"] = cherryToRikkaTitlePrompt(prompt)
+	}
+	if v, ok := settings["enableTopicNaming"]; ok {
+		naming["enableTopicNaming"] = cloneAny(v)
+	}
+	out["naming"] = naming
+
 	return out, warnings
 }
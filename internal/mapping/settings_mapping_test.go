@@ -1,6 +1,7 @@
 package mapping
 
 import (
+	"strings"
 	"testing"
 
 	"cherrikka/internal/ir"
@@ -517,6 +518,209 @@ func TestBuildCherryPersistSlicesFromIR_RikkaModelToCherryModelShape(t *testing.
 	}
 }
 
+func TestRikkaCherryRikkaRoundTripKeepsAssistantModelID(t *testing.T) {
+	const (
+		assistantID = "0b6a3a53-8f0e-4d8e-9a53-3f4d3c0f6a11"
+		miniID      = "7fd8fb8e-b469-4dbc-8daa-40b2ac73b8e8"
+		fullID      = "2c1f5e0a-6d4b-4f3e-9f8a-5b7c2d1e0f93"
+	)
+	rikkaCfg := map[string]any{
+		"rikka.settings": map[string]any{
+			"providers": []any{
+				map[string]any{
+					"id":   "rp-openai",
+					"type": "openai",
+					"models": []any{
+						map[string]any{"id": miniID, "modelId": "gpt-4o-mini", "type": "CHAT"},
+						map[string]any{"id": fullID, "modelId": "gpt-4o", "type": "CHAT"},
+					},
+				},
+			},
+			"assistants": []any{
+				map[string]any{"id": assistantID, "name": "R1", "chatModelId": fullID},
+			},
+			"assistantId": assistantID,
+			"chatModelId": miniID,
+		},
+	}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{
+		SourceFormat: "rikka",
+		Settings:     rikkaNorm,
+		Config:       rikkaCfg,
+	}, map[string]any{}, map[string]any{
+		"assistants": []any{map[string]any{"id": assistantID, "name": "R1"}},
+	})
+
+	cherryAssistant := asMap(asSlice(asMap(persist["assistants"])["assistants"])[0])
+	if got := pickFirstString(asMap(cherryAssistant["model"])["id"]); got != "gpt-4o" {
+		t.Fatalf("expected cherry assistant model gpt-4o, got=%s", got)
+	}
+
+	cherryCfg := map[string]any{"cherry.persistSlices": persist}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{
+		SourceFormat: "cherry",
+		Settings:     cherryNorm,
+		Config:       cherryCfg,
+	}, nil)
+
+	assistants := asSlice(settings["assistants"])
+	if len(assistants) != 1 {
+		t.Fatalf("expected 1 assistant, got=%d", len(assistants))
+	}
+	if got := str(asMap(assistants[0])["chatModelId"]); got != fullID {
+		t.Fatalf("expected assistant chatModelId=%s, got=%s", fullID, got)
+	}
+	if got := str(settings["chatModelId"]); got != miniID {
+		t.Fatalf("expected chatModelId=%s, got=%s", miniID, got)
+	}
+}
+
+func TestEmbeddingModelTypeSurvivesRikkaCherryRikka(t *testing.T) {
+	const embeddingID = "5d3c1b2a-9e8f-4a7b-8c6d-1e2f3a4b5c6d"
+	rikkaCfg := map[string]any{
+		"rikka.settings": map[string]any{
+			"providers": []any{
+				map[string]any{
+					"id":   "rp-openai",
+					"type": "openai",
+					"models": []any{
+						map[string]any{"id": "7fd8fb8e-b469-4dbc-8daa-40b2ac73b8e8", "modelId": "gpt-4o-mini", "type": "CHAT"},
+						map[string]any{"id": embeddingID, "modelId": "text-embedding-3-small", "type": "EMBEDDING"},
+					},
+				},
+			},
+		},
+	}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{
+		SourceFormat: "rikka",
+		Settings:     rikkaNorm,
+		Config:       rikkaCfg,
+	}, map[string]any{}, map[string]any{})
+
+	cherryModels := asSlice(asMap(asSlice(asMap(persist["llm"])["providers"])[0])["models"])
+	if len(cherryModels) != 2 {
+		t.Fatalf("expected 2 cherry models, got=%d", len(cherryModels))
+	}
+	types := asSlice(asMap(cherryModels[1])["type"])
+	if len(types) != 1 || str(types[0]) != "embedding" {
+		t.Fatalf("expected cherry embedding type list, got=%v", asMap(cherryModels[1])["type"])
+	}
+
+	// drop the preserved canonical hint to make sure the cherry type list alone is enough
+	delete(asMap(cherryModels[1]), "canonicalType")
+	cherryCfg := map[string]any{"cherry.persistSlices": persist}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{
+		SourceFormat: "cherry",
+		Settings:     cherryNorm,
+		Config:       cherryCfg,
+	}, nil)
+
+	models := asSlice(asMap(asSlice(settings["providers"])[0])["models"])
+	found := false
+	for _, item := range models {
+		mm := asMap(item)
+		if str(mm["id"]) == embeddingID {
+			found = true
+			if got := str(mm["type"]); got != "EMBEDDING" {
+				t.Fatalf("expected EMBEDDING after round trip, got=%s", got)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("embedding model missing after round trip: %v", models)
+	}
+}
+
+func TestEmbeddingModelTypeSurvivesCherryRikkaCherry(t *testing.T) {
+	cherryCfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{"assistants": []any{}},
+			"llm": map[string]any{
+				"providers": []any{map[string]any{"id": "openai", "type": "openai", "models": []any{
+					map[string]any{"id": "gpt-4o", "provider": "openai", "name": "GPT-4o", "group": "gpt"},
+					map[string]any{"id": "text-embedding-3-small", "provider": "openai", "name": "Embedding", "group": "embedding", "type": []any{"embedding"}},
+				}}},
+			},
+		},
+	}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	rikkaSettings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: cherryNorm, Config: cherryCfg}, nil)
+	rikkaTypes := map[string]string{}
+	for _, item := range asSlice(asMap(asSlice(rikkaSettings["providers"])[0])["models"]) {
+		rikkaTypes[str(asMap(item)["modelId"])] = str(asMap(item)["type"])
+	}
+	if rikkaTypes["text-embedding-3-small"] != "EMBEDDING" || rikkaTypes["gpt-4o"] != "CHAT" {
+		t.Fatalf("expected rikka EMBEDDING and CHAT types, got=%v", rikkaTypes)
+	}
+
+	// a fresh rikka source, as if the user restored the backup and exported it again
+	rikkaCfg := map[string]any{"rikka.settings": rikkaSettings}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: rikkaNorm, Config: rikkaCfg}, map[string]any{}, map[string]any{"assistants": []any{}})
+	var embedding, chat map[string]any
+	for _, item := range asSlice(asMap(asSlice(asMap(persist["llm"])["providers"])[0])["models"]) {
+		switch mm := asMap(item); str(mm["id"]) {
+		case "text-embedding-3-small":
+			embedding = mm
+		case "gpt-4o":
+			chat = mm
+		}
+	}
+	if types := asSlice(embedding["type"]); len(types) != 1 || str(types[0]) != "embedding" {
+		t.Fatalf("expected cherry embedding type list after round trip, got=%v", embedding)
+	}
+	if _, ok := chat["type"]; ok {
+		t.Fatalf("chat model should not gain a type list, got=%v", chat)
+	}
+}
+
+func TestImageGenerationModelSelectionSurvivesRikkaCherryRikka(t *testing.T) {
+	const imageID = "c9a0d3a4-1111-4bbb-8ccc-000000000001"
+	rikkaCfg := map[string]any{
+		"rikka.settings": map[string]any{
+			"chatModelId":            "7fd8fb8e-b469-4dbc-8daa-40b2ac73b8e8",
+			"imageGenerationModelId": imageID,
+			"providers": []any{map[string]any{"id": "rp-openai", "type": "openai", "models": []any{
+				map[string]any{"id": "7fd8fb8e-b469-4dbc-8daa-40b2ac73b8e8", "modelId": "gpt-4o-mini", "type": "CHAT"},
+				map[string]any{"id": imageID, "modelId": "gpt-image-1", "type": "IMAGE"},
+			}}},
+		},
+	}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: rikkaNorm, Config: rikkaCfg}, map[string]any{}, map[string]any{"assistants": []any{}})
+	if got := str(asMap(asMap(persist["llm"])["paintingModel"])["id"]); got != "gpt-image-1" {
+		t.Fatalf("expected cherry paintingModel gpt-image-1, got=%v", asMap(persist["llm"])["paintingModel"])
+	}
+	for _, item := range asSlice(asMap(asSlice(asMap(persist["llm"])["providers"])[0])["models"]) {
+		mm := asMap(item)
+		if _, ok := mm["canonicalType"]; ok {
+			t.Fatalf("canonicalType must not leak into cherry models, got=%v", mm)
+		}
+		if str(mm["id"]) == "gpt-image-1" {
+			if types := asSlice(mm["type"]); len(types) != 1 || str(types[0]) != "image_generation" {
+				t.Fatalf("expected cherry image_generation type list, got=%v", mm)
+			}
+		}
+	}
+
+	// no sidecar: the cherry paintingModel alone must restore the selection
+	cherryCfg := map[string]any{"cherry.persistSlices": persist}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: cherryNorm, Config: cherryCfg}, nil)
+	if got := str(settings["imageGenerationModelId"]); got != imageID {
+		t.Fatalf("expected imageGenerationModelId=%s after round trip, got=%s", imageID, got)
+	}
+	for _, item := range asSlice(asMap(asSlice(settings["providers"])[0])["models"]) {
+		if mm := asMap(item); str(mm["id"]) == imageID && str(mm["type"]) != "IMAGE" {
+			t.Fatalf("expected IMAGE after round trip, got=%v", mm)
+		}
+	}
+}
+
 func TestBuildRikkaSettingsFromIR_SidecarRehydrateOverlay(t *testing.T) {
 	in := &ir.BackupIR{
 		SourceFormat: "cherry",
@@ -618,3 +822,616 @@ func TestBuildRikkaSettingsFromIR_OpenAIBaseURLV1Normalization(t *testing.T) {
 		t.Fatalf("expected chatCompletionsPath=/chat/completions, got=%s", got)
 	}
 }
+
+func TestBuildRikkaSettingsFromIR_RikkaSourceKeepsUnmappedAssistantFields(t *testing.T) {
+	cfg := map[string]any{
+		"rikka.settings": map[string]any{
+			"assistants": []any{
+				map[string]any{
+					"id":         "3a8e0c52-6f1f-4a3e-9d68-1c2b3d4e5f60",
+					"name":       "Second",
+					"avatar":     map[string]any{"type": "emoji", "content": "🐱"},
+					"background": "file:///bg.png",
+				},
+				map[string]any{"id": "0f1e2d3c-4b5a-4968-8776-a5b4c3d2e1f0", "name": "First"},
+			},
+		},
+	}
+	norm, _ := NormalizeFromRikkaConfig(cfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: norm, Config: cfg}, nil)
+
+	assistants := asSlice(settings["assistants"])
+	if len(assistants) != 2 {
+		t.Fatalf("expected 2 assistants, got=%d", len(assistants))
+	}
+	first := asMap(assistants[0])
+	if first["name"] != "Second" || asMap(assistants[1])["name"] != "First" {
+		t.Fatalf("assistant order not preserved: %v", assistants)
+	}
+	if first["background"] != "file:///bg.png" || asMap(first["avatar"])["content"] != "🐱" {
+		t.Fatalf("expected unmapped assistant fields to survive, got=%v", first)
+	}
+}
+
+func TestBuildRikkaSettingsFromIR_RemapsCherryMCPServerReferences(t *testing.T) {
+	cfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{
+				"assistants": []any{
+					map[string]any{
+						"id":         "a1",
+						"name":       "A1",
+						"mcpServers": []any{map[string]any{"id": "mcp-fetch", "name": "fetch"}},
+					},
+				},
+			},
+			"settings": map[string]any{},
+			"llm":      map[string]any{"providers": []any{}},
+		},
+		"cherry.settings": map[string]any{
+			"mcpServers": []any{map[string]any{"id": "mcp-fetch", "name": "fetch"}},
+		},
+	}
+	norm, _ := NormalizeFromCherryConfig(cfg)
+	settings, warnings := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cfg}, nil)
+
+	servers := asSlice(settings["mcpServers"])
+	if len(servers) != 1 {
+		t.Fatalf("expected 1 mcp server, got=%v", settings["mcpServers"])
+	}
+	serverID := pickFirstString(asMap(servers[0])["id"])
+	if !isValidUUID(serverID) {
+		t.Fatalf("expected uuid mcp server id, got=%q", serverID)
+	}
+	refs := asSlice(asMap(asSlice(settings["assistants"])[0])["mcpServers"])
+	if len(refs) != 1 || refs[0] != serverID {
+		t.Fatalf("expected assistant mcp reference remapped to %s, got=%v warnings=%v", serverID, refs, warnings)
+	}
+}
+
+func TestModelSelectionSlotsSurviveRikkaCherryRikka(t *testing.T) {
+	ids := map[string]string{
+		"chatModelId":            "7fd8fb8e-b469-4dbc-8daa-40b2ac73b8e8",
+		"titleModelId":           "2c1f5e0a-6d4b-4f3e-9f8a-5b7c2d1e0f93",
+		"translateModeId":        "5d3c1b2a-9e8f-4a7b-8c6d-1e2f3a4b5c6d",
+		"suggestionModelId":      "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+		"imageGenerationModelId": "3e2d1c0b-4a5f-4e6d-9c7b-8a9f0e1d2c3b",
+	}
+	modelNames := map[string]string{
+		"chatModelId":            "gpt-4o",
+		"titleModelId":           "gpt-4o-mini",
+		"translateModeId":        "gpt-4.1",
+		"suggestionModelId":      "gpt-4.1-mini",
+		"imageGenerationModelId": "gpt-image-1",
+	}
+	models := []any{}
+	settings := map[string]any{}
+	for key, id := range ids {
+		models = append(models, map[string]any{"id": id, "modelId": modelNames[key], "type": "CHAT"})
+		settings[key] = id
+	}
+	settings["providers"] = []any{map[string]any{"id": "rp-openai", "type": "openai", "models": models}}
+	rikkaCfg := map[string]any{"rikka.settings": settings}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{
+		SourceFormat: "rikka",
+		Settings:     rikkaNorm,
+		Config:       rikkaCfg,
+	}, map[string]any{}, map[string]any{})
+
+	llm := asMap(persist["llm"])
+	for cherryKey, rikkaKey := range map[string]string{
+		"defaultModel":     "chatModelId",
+		"topicNamingModel": "titleModelId",
+		"translateModel":   "translateModeId",
+		"quickModel":       "suggestionModelId",
+	} {
+		if got := str(asMap(llm[cherryKey])["id"]); got != modelNames[rikkaKey] {
+			t.Fatalf("expected cherry %s=%s, got=%s", cherryKey, modelNames[rikkaKey], got)
+		}
+	}
+
+	sidecarSelection := CherryUnmappedModelSelection(rikkaNorm)
+	if sidecarSelection["imageGenerationModelId"] != ids["imageGenerationModelId"] {
+		t.Fatalf("expected image generation model in sidecar selection, got=%v", sidecarSelection)
+	}
+	rehydrated := map[string]any{}
+	for key, id := range sidecarSelection {
+		rehydrated[key] = id
+	}
+	cherryCfg := map[string]any{"cherry.persistSlices": persist, "rehydrate.rikka.modelSelection": rehydrated}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	out, warnings := BuildRikkaSettingsFromIR(&ir.BackupIR{
+		SourceFormat: "cherry",
+		Settings:     cherryNorm,
+		Config:       cherryCfg,
+	}, nil)
+	for key, id := range ids {
+		if got := str(out[key]); got != id {
+			t.Fatalf("expected %s=%s after round trip, got=%s (warnings=%v)", key, id, got, warnings)
+		}
+	}
+}
+
+func TestModelSelectionSlotsSurviveCherryRikkaCherry(t *testing.T) {
+	cherryCfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"llm": map[string]any{
+				"providers": []any{
+					map[string]any{
+						"id":   "openai",
+						"type": "openai",
+						"models": []any{
+							map[string]any{"id": "gpt-4o", "provider": "openai", "name": "GPT-4o"},
+							map[string]any{"id": "gpt-4o-mini", "provider": "openai", "name": "GPT-4o mini"},
+							map[string]any{"id": "gpt-4.1", "provider": "openai", "name": "GPT-4.1"},
+							map[string]any{"id": "gpt-4.1-mini", "provider": "openai", "name": "GPT-4.1 mini"},
+						},
+					},
+				},
+				"defaultModel":     map[string]any{"id": "gpt-4o", "provider": "openai"},
+				"quickModel":       map[string]any{"id": "gpt-4o-mini", "provider": "openai"},
+				"translateModel":   map[string]any{"id": "gpt-4.1", "provider": "openai"},
+				"topicNamingModel": map[string]any{"id": "gpt-4.1-mini", "provider": "openai"},
+			},
+		},
+	}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{
+		SourceFormat: "cherry",
+		Settings:     cherryNorm,
+		Config:       cherryCfg,
+	}, nil)
+
+	rikkaCfg := map[string]any{"rikka.settings": settings}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{
+		SourceFormat: "rikka",
+		Settings:     rikkaNorm,
+		Config:       rikkaCfg,
+	}, map[string]any{}, map[string]any{})
+
+	llm := asMap(persist["llm"])
+	for key, want := range map[string]string{
+		"defaultModel":     "gpt-4o",
+		"quickModel":       "gpt-4o-mini",
+		"translateModel":   "gpt-4.1",
+		"topicNamingModel": "gpt-4.1-mini",
+	} {
+		if got := str(asMap(llm[key])["id"]); got != want {
+			t.Fatalf("expected cherry %s=%s after round trip, got=%s", key, want, got)
+		}
+	}
+}
+
+func TestCherryProviderCapabilityFlagsSurviveRikkaRoundTrip(t *testing.T) {
+	persist := map[string]any{
+		"llm": map[string]any{
+			"providers": []any{
+				map[string]any{
+					"id":                        "my-proxy",
+					"name":                      "My Proxy",
+					"type":                      "openai",
+					"apiHost":                   "https://proxy.example.com",
+					"isNotSupportArrayContent":  true,
+					"isNotSupportStreamOptions": true,
+					"models":                    []any{map[string]any{"id": "gpt-4o", "provider": "my-proxy"}},
+				},
+			},
+		},
+	}
+	cherryCfg := map[string]any{"cherry.persistSlices": persist}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{
+		SourceFormat: "cherry",
+		Settings:     cherryNorm,
+		Config:       cherryCfg,
+	}, nil)
+	if _, leaked := asMap(asSlice(settings["providers"])[0])["isNotSupportArrayContent"]; leaked {
+		t.Fatalf("cherry-only flag should not be written into rikka provider")
+	}
+
+	rikkaCfg := map[string]any{
+		"rikka.settings":                 settings,
+		"rehydrate.cherry.persistSlices": persist,
+	}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	out, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{
+		SourceFormat: "rikka",
+		Settings:     rikkaNorm,
+		Config:       rikkaCfg,
+	}, map[string]any{}, map[string]any{})
+	provider := asMap(asSlice(asMap(out["llm"])["providers"])[0])
+	if provider["isNotSupportArrayContent"] != true || provider["isNotSupportStreamOptions"] != true {
+		t.Fatalf("expected capability flags restored from sidecar, got=%v", provider)
+	}
+}
+
+func TestCherryModelFieldsSurviveRikkaRoundTrip(t *testing.T) {
+	persist := map[string]any{
+		"llm": map[string]any{
+			"providers": []any{
+				map[string]any{
+					"id":      "openai",
+					"name":    "OpenAI",
+					"type":    "openai",
+					"apiHost": "https://api.openai.com",
+					"models": []any{map[string]any{
+						"id":            "gpt-4o",
+						"provider":      "openai",
+						"name":          "GPT-4o",
+						"group":         "gpt-4o",
+						"contextLength": float64(128000),
+						"pricing":       map[string]any{"input_per_million_tokens": 2.5, "output_per_million_tokens": float64(10)},
+					}},
+				},
+			},
+		},
+	}
+	cherryCfg := map[string]any{"cherry.persistSlices": persist}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{
+		SourceFormat: "cherry",
+		Settings:     cherryNorm,
+		Config:       cherryCfg,
+	}, nil)
+
+	rikkaCfg := map[string]any{
+		"rikka.settings":                 settings,
+		"rehydrate.cherry.persistSlices": persist,
+	}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	out, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{
+		SourceFormat: "rikka",
+		Settings:     rikkaNorm,
+		Config:       rikkaCfg,
+	}, map[string]any{}, map[string]any{})
+	model := asMap(asSlice(asMap(asSlice(asMap(out["llm"])["providers"])[0])["models"])[0])
+	if model["contextLength"] != float64(128000) {
+		t.Fatalf("expected contextLength restored from sidecar, got=%v", model)
+	}
+	if asMap(model["pricing"])["input_per_million_tokens"] != 2.5 {
+		t.Fatalf("expected pricing restored from sidecar, got=%v", model["pricing"])
+	}
+	if str(model["group"]) != "gpt-4o" {
+		t.Fatalf("expected source group restored, got=%v", model["group"])
+	}
+	if defaultModel := asMap(asMap(out["llm"])["defaultModel"]); defaultModel["contextLength"] != float64(128000) {
+		t.Fatalf("expected selected model to carry restored fields, got=%v", defaultModel)
+	}
+}
+
+func TestAssistantTagGroupsSurviveCherryRoundTrips(t *testing.T) {
+	cherryCfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{
+				"assistants": []any{
+					map[string]any{"id": "a1", "name": "Writer", "tags": []any{"Work"}},
+					map[string]any{"id": "a2", "name": "Chef", "tags": []any{"Personal", "Work"}},
+				},
+				"tagsOrder":     []any{"Personal", "Work"},
+				"collapsedTags": map[string]any{"Personal": true},
+			},
+		},
+	}
+	cherryNorm, _ := NormalizeFromCherryConfig(cherryCfg)
+	// the builder's assistants slice is rebuilt from the IR and carries no tags
+	freshSlice := func(ids ...string) map[string]any {
+		items := []any{}
+		for _, id := range ids {
+			items = append(items, map[string]any{"id": id})
+		}
+		return map[string]any{"assistants": items}
+	}
+
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: cherryNorm, Config: cherryCfg}, map[string]any{}, freshSlice("a1", "a2"))
+	slice := asMap(persist["assistants"])
+	if got := asSlice(slice["tagsOrder"]); len(got) != 2 || got[0] != "Personal" || got[1] != "Work" {
+		t.Fatalf("expected cherry tagsOrder kept, got=%v", got)
+	}
+	if asMap(slice["collapsedTags"])["Personal"] != true {
+		t.Fatalf("expected collapsed group kept, got=%v", slice["collapsedTags"])
+	}
+	if got := asSlice(asMap(asSlice(slice["assistants"])[1])["tags"]); len(got) != 2 || got[0] != "Personal" {
+		t.Fatalf("expected assistant tag names kept, got=%v", got)
+	}
+
+	settings, warnings := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: cherryNorm, Config: cherryCfg}, nil)
+	rikkaTags := asSlice(settings["assistantTags"])
+	if len(rikkaTags) != 2 || !isValidUUID(str(asMap(rikkaTags[0])["id"])) || asMap(rikkaTags[0])["name"] != "Personal" {
+		t.Fatalf("expected rikka assistantTags with uuids, got=%v", rikkaTags)
+	}
+	for _, w := range warnings {
+		if w == "dropped non-uuid assistant field: tags" {
+			t.Fatalf("cherry tag names should map to rikka tag uuids")
+		}
+	}
+	writer := asMap(asSlice(settings["assistants"])[0])
+	if got := asSlice(writer["tags"]); len(got) != 1 || got[0] != asMap(rikkaTags[1])["id"] {
+		t.Fatalf("expected assistant tag to reference the Work uuid, got=%v", got)
+	}
+
+	rikkaCfg := map[string]any{"rikka.settings": settings}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	back, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: rikkaNorm, Config: rikkaCfg}, map[string]any{}, freshSlice(str(writer["id"])))
+	backSlice := asMap(back["assistants"])
+	if got := asSlice(asMap(asSlice(backSlice["assistants"])[0])["tags"]); len(got) != 1 || got[0] != "Work" {
+		t.Fatalf("expected Work group after cherry->rikka->cherry, got=%v", got)
+	}
+	if got := asSlice(backSlice["tagsOrder"]); len(got) != 2 {
+		t.Fatalf("expected tagsOrder rebuilt from rikka tags, got=%v", got)
+	}
+}
+
+func TestBuildRikkaSettingsFromIR_AssistantBindsModelByDisplayName(t *testing.T) {
+	cfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{
+				"assistants": []any{
+					map[string]any{"id": "a1", "name": "A1", "prompt": "p", "model": map[string]any{"name": "Friendly Name", "provider": "p1"}},
+					map[string]any{"id": "a2", "name": "A2", "prompt": "p", "model": map[string]any{"id": "3f1c2b9e-0000-4000-8000-000000000000", "name": "Friendly Name", "provider": "p1"}},
+				},
+			},
+			"llm": map[string]any{
+				"defaultModel": map[string]any{"id": "other"},
+				"providers": []any{
+					map[string]any{"id": "p1", "type": "openai", "models": []any{
+						map[string]any{"id": "other", "name": "Other"},
+						map[string]any{"id": "m-abc", "name": "Friendly Name"},
+					}},
+				},
+			},
+		},
+	}
+
+	norm, _ := NormalizeFromCherryConfig(cfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cfg}, nil)
+
+	wantID := ""
+	for _, provider := range asSlice(settings["providers"]) {
+		for _, model := range asSlice(asMap(provider)["models"]) {
+			if mm := asMap(model); str(mm["displayName"]) == "Friendly Name" {
+				wantID = str(mm["id"])
+			}
+		}
+	}
+	if wantID == "" {
+		t.Fatalf("expected Friendly Name model in providers")
+	}
+	assistants := asSlice(settings["assistants"])
+	if len(assistants) != 2 {
+		t.Fatalf("expected 2 assistants, got=%d", len(assistants))
+	}
+	for _, item := range assistants {
+		am := asMap(item)
+		if got := str(am["chatModelId"]); got != wantID {
+			t.Fatalf("assistant %s chatModelId=%s, want Friendly Name model %s", str(am["name"]), got, wantID)
+		}
+	}
+}
+
+func TestDefaultModelFallbackPrefersWellKnownChatModel(t *testing.T) {
+	cfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{"assistants": []any{}},
+			"llm": map[string]any{
+				"providers": []any{
+					map[string]any{"id": "off", "type": "openai", "enabled": false, "models": []any{
+						map[string]any{"id": "claude-sonnet-4"},
+					}},
+					map[string]any{"id": "p1", "type": "openai", "enabled": true, "models": []any{
+						map[string]any{"id": "text-embedding-3-small", "type": []any{"embedding"}},
+						map[string]any{"id": "llama-3-8b"},
+						map[string]any{"id": "gpt-4o"},
+					}},
+				},
+			},
+		},
+	}
+	norm, _ := NormalizeFromCherryConfig(cfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cfg}, nil)
+	var chosen map[string]any
+	for _, p := range asSlice(settings["providers"]) {
+		for _, m := range asSlice(asMap(p)["models"]) {
+			if str(asMap(m)["id"]) == str(settings["chatModelId"]) {
+				chosen = asMap(m)
+			}
+		}
+	}
+	if str(chosen["modelId"]) != "gpt-4o" {
+		t.Fatalf("expected gpt-4o from the enabled provider as default, got=%v", chosen)
+	}
+
+	rikkaCfg := map[string]any{
+		"rikka.settings": map[string]any{
+			"providers": []any{
+				map[string]any{"id": "rp1", "type": "openai", "models": []any{
+					map[string]any{"id": "e1", "modelId": "text-embedding-3-small", "type": "EMBEDDING"},
+					map[string]any{"id": "i1", "modelId": "gpt-image-1", "type": "IMAGE"},
+					map[string]any{"id": "c1", "modelId": "my-local-chat", "type": "CHAT"},
+				}},
+			},
+		},
+	}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: rikkaNorm, Config: rikkaCfg}, map[string]any{}, map[string]any{"assistants": []any{}})
+	if got := str(asMap(asMap(persist["llm"])["defaultModel"])["id"]); got != "my-local-chat" {
+		t.Fatalf("expected the first chat model as cherry default, got=%s", got)
+	}
+}
+
+func TestTopicNamingPromptSurvivesCherryRikkaCherry(t *testing.T) {
+	cfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{"assistants": []any{}},
+			"settings": map[string]any{
+				"topicNamingPrompt": "Summarize the chat in five words.",
+				"enableTopicNaming": false,
+			},
+			"llm": map[string]any{
+				"providers": []any{map[string]any{"id": "p1", "type": "openai", "models": []any{map[string]any{"id": "gpt-4o"}}}},
+			},
+		},
+	}
+	norm, _ := NormalizeFromCherryConfig(cfg)
+	rikkaSettings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cfg}, nil)
+	titlePrompt := str(rikkaSettings["titlePrompt"])
+	if !strings.HasPrefix(titlePrompt, "Summarize the chat in five words.") || !strings.Contains(titlePrompt, "{content}") {
+		t.Fatalf("expected cherry prompt mapped to rikka titlePrompt with a content placeholder, got=%q", titlePrompt)
+	}
+	if _, ok := rikkaSettings["enableTopicNaming"]; ok {
+		t.Fatalf("enableTopicNaming has no rikka setting and should not be written")
+	}
+
+	rikkaCfg := map[string]any{"rikka.settings": rikkaSettings}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: rikkaNorm, Config: rikkaCfg}, map[string]any{}, map[string]any{"assistants": []any{}})
+	if got := str(asMap(persist["settings"])["topicNamingPrompt"]); got != "Summarize the chat in five words." {
+		t.Fatalf("expected topic naming prompt restored on the way back, got=%q", got)
+	}
+
+	defaultCfg := map[string]any{"rikka.settings": map[string]any{
+		"titlePrompt": "I will give you some dialogue content in the `<content>` block.\nSummarize it into a short title.\n\n<content>\n{content}\n</content>",
+	}}
+	defaultNorm, _ := NormalizeFromRikkaConfig(defaultCfg)
+	persist, _ = BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: defaultNorm, Config: defaultCfg}, map[string]any{}, map[string]any{"assistants": []any{}})
+	if got, ok := asMap(persist["settings"])["topicNamingPrompt"]; ok {
+		t.Fatalf("rikka's default title prompt should not replace cherry's, got=%q", got)
+	}
+
+	customCfg := map[string]any{"rikka.settings": map[string]any{"titlePrompt": "Title this: {content}"}}
+	customNorm, _ := NormalizeFromRikkaConfig(customCfg)
+	persist, _ = BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: customNorm, Config: customCfg}, map[string]any{}, map[string]any{"assistants": []any{}})
+	if got := str(asMap(persist["settings"])["topicNamingPrompt"]); got != "Title this: {content}" {
+		t.Fatalf("expected a customized rikka title prompt carried over, got=%q", got)
+	}
+}
+
+func TestChatDefaultsSurviveCherryRikkaCherry(t *testing.T) {
+	cfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{
+				"defaultAssistant": map[string]any{"id": "default", "settings": map[string]any{"temperature": 0.3, "topP": 0.8, "maxTokens": 2048, "enableMaxTokens": true, "contextCount": 10}},
+				"assistants":       []any{map[string]any{"id": "a1", "name": "Writer", "settings": map[string]any{"contextCount": 10}}},
+			},
+			"settings": map[string]any{"assistantId": "a1"},
+			"llm": map[string]any{
+				"providers": []any{map[string]any{"id": "p1", "type": "openai", "models": []any{map[string]any{"id": "gpt-4o"}}}},
+			},
+		},
+	}
+	norm, _ := NormalizeFromCherryConfig(cfg)
+	if got := asMap(norm["core.chatDefaults"]); got["temperature"] != 0.3 || got["maxTokens"] != 2048 {
+		t.Fatalf("expected cherry default assistant params normalized, got=%v", got)
+	}
+	rikkaSettings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cfg}, nil)
+	var selected map[string]any
+	for _, item := range asSlice(rikkaSettings["assistants"]) {
+		if am := asMap(item); str(am["id"]) == str(rikkaSettings["assistantId"]) {
+			selected = am
+		}
+	}
+	if selected["temperature"] != 0.3 || selected["topP"] != 0.8 || selected["maxTokens"] != int64(2048) {
+		t.Fatalf("expected chat defaults on the selected rikka assistant, got=%v", selected)
+	}
+
+	rikkaCfg := map[string]any{"rikka.settings": rikkaSettings}
+	rikkaNorm, _ := NormalizeFromRikkaConfig(rikkaCfg)
+	assistantsSlice := map[string]any{"defaultAssistant": map[string]any{"id": "default", "settings": map[string]any{"temperature": 0.7}}, "assistants": []any{}}
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "rikka", Settings: rikkaNorm, Config: rikkaCfg}, map[string]any{}, assistantsSlice)
+	defaults := asMap(asMap(asMap(persist["assistants"])["defaultAssistant"])["settings"])
+	if defaults["temperature"] != 0.3 || defaults["topP"] != 0.8 || defaults["enableMaxTokens"] != true {
+		t.Fatalf("expected chat defaults restored on the cherry default assistant, got=%v", defaults)
+	}
+}
+
+func TestDisabledCherryMaxTokensStayUnlimitedInRikka(t *testing.T) {
+	cfg := map[string]any{
+		"cherry.persistSlices": map[string]any{
+			"assistants": map[string]any{
+				"defaultAssistant": map[string]any{"id": "default", "settings": map[string]any{"temperature": 0.3, "maxTokens": 4096, "enableMaxTokens": false}},
+				"assistants":       []any{map[string]any{"id": "a1", "name": "Writer"}},
+			},
+			"settings": map[string]any{"assistantId": "a1"},
+			"llm": map[string]any{
+				"providers": []any{map[string]any{"id": "p1", "type": "openai", "models": []any{map[string]any{"id": "gpt-4o"}}}},
+			},
+		},
+	}
+	norm, _ := NormalizeFromCherryConfig(cfg)
+	rikkaSettings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cfg}, nil)
+	var selected map[string]any
+	for _, item := range asSlice(rikkaSettings["assistants"]) {
+		if am := asMap(item); str(am["id"]) == str(rikkaSettings["assistantId"]) {
+			selected = am
+		}
+	}
+	if selected["temperature"] != 0.3 {
+		t.Fatalf("expected temperature on the selected rikka assistant, got=%v", selected)
+	}
+	if v, ok := selected["maxTokens"]; ok && v != nil {
+		t.Fatalf("maxTokens was disabled in cherry and should not become a rikka limit, got=%v", v)
+	}
+
+	persist, _ := BuildCherryPersistSlicesFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cfg}, map[string]any{}, map[string]any{"defaultAssistant": map[string]any{"id": "default"}, "assistants": []any{}})
+	defaults := asMap(asMap(asMap(persist["assistants"])["defaultAssistant"])["settings"])
+	if defaults["enableMaxTokens"] != false {
+		t.Fatalf("expected enableMaxTokens=false kept on the cherry default assistant, got=%v", defaults)
+	}
+}
+
+func TestAzureProviderKeepsEndpointAndReportsAzureFields(t *testing.T) {
+	cherryCfg := map[string]any{"cherry.persistSlices": map[string]any{"llm": map[string]any{"providers": []any{
+		map[string]any{"id": "azure", "name": "Azure", "type": "azure-openai", "apiKey": "k", "apiHost": "https://contoso.openai.azure.com/", "apiVersion": "2024-10-21", "deploymentName": "gpt4o-prod",
+			"models": []any{map[string]any{"id": "gpt4o-prod", "provider": "azure", "name": "gpt4o-prod"}}},
+	}}}}
+	norm, _ := NormalizeFromCherryConfig(cherryCfg)
+	unsupported := asSlice(norm["raw.unsupported"])
+	if len(unsupported) != 1 || asMap(asMap(unsupported[0])["fields"])["apiVersion"] != "2024-10-21" {
+		t.Fatalf("expected azure fields kept in raw.unsupported, got=%v", unsupported)
+	}
+
+	settings, warnings := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cherryCfg}, nil)
+	provider := asMap(asSlice(settings["providers"])[0])
+	if got := str(provider["baseUrl"]); got != "https://contoso.openai.azure.com/openai/v1" {
+		t.Fatalf("expected azure v1 base url, got=%s", got)
+	}
+	found := false
+	for _, w := range warnings {
+		if w == "provider-azure-fields-dropped:Azure:apiVersion,deploymentName" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("expected azure fields warning, got=%v", warnings)
+	}
+}
+
+func TestLocalProviderBaseURLsAreNotRewrittenToOpenAI(t *testing.T) {
+	model := func(provider string) []any {
+		return []any{map[string]any{"id": "llama3", "provider": provider, "name": "llama3"}}
+	}
+	cherryCfg := map[string]any{"cherry.persistSlices": map[string]any{"llm": map[string]any{"providers": []any{
+		map[string]any{"id": "ollama", "name": "Ollama", "type": "ollama", "apiHost": "http://localhost:11434", "models": model("ollama")},
+		map[string]any{"id": "lan", "name": "LAN Ollama", "type": "ollama", "host": "192.168.1.20:11434/", "models": model("lan")},
+		map[string]any{"id": "bare", "name": "Bare Ollama", "type": "ollama", "apiHost": "localhost:11434", "models": model("bare")},
+		map[string]any{"id": "lmstudio", "name": "LM Studio", "type": "lmstudio", "models": model("lmstudio")},
+		map[string]any{"id": "remote", "name": "Remote", "type": "openai", "apiHost": "https://api.example.com", "models": model("remote")},
+	}}}}
+	norm, _ := NormalizeFromCherryConfig(cherryCfg)
+	settings, _ := BuildRikkaSettingsFromIR(&ir.BackupIR{SourceFormat: "cherry", Settings: norm, Config: cherryCfg}, nil)
+	want := map[string]string{
+		"Ollama":      "http://localhost:11434",
+		"LAN Ollama":  "http://192.168.1.20:11434",
+		"Bare Ollama": "http://localhost:11434",
+		"LM Studio":   "http://localhost:1234/v1",
+		"Remote":      "https://api.example.com/v1",
+	}
+	for _, item := range asSlice(settings["providers"]) {
+		p := asMap(item)
+		if got := str(p["baseUrl"]); got != want[str(p["name"])] {
+			t.Fatalf("provider %s baseUrl=%s, want %s", str(p["name"]), got, want[str(p["name"])])
+		}
+	}
+}
@@ -39,22 +39,25 @@ func normalizeFromSource(in *ir.BackupIR) (map[string]any, []string) {
 
 func defaultNormalizedSettings() map[string]any {
 	return map[string]any{
-		"core.providers":    []any{},
-		"core.models":       map[string]any{},
-		"core.assistants":   []any{},
-		"core.selection":    map[string]any{},
-		"sync.webdav":       map[string]any{},
-		"sync.s3":           map[string]any{},
-		"sync.local":        map[string]any{},
-		"ui.profile":        map[string]any{},
-		"search":            map[string]any{},
-		"mcp":               map[string]any{},
-		"tts":               map[string]any{},
-		"raw.cherry":        map[string]any{},
-		"raw.rikka":         map[string]any{},
-		"raw.unsupported":   []any{},
-		"normalizer.ver":    1,
-		"normalizer.source": "",
+		"core.providers":     []any{},
+		"core.models":        map[string]any{},
+		"core.assistants":    []any{},
+		"core.assistantTags": []any{},
+		"core.selection":     map[string]any{},
+		"sync.webdav":        map[string]any{},
+		"sync.s3":            map[string]any{},
+		"sync.local":         map[string]any{},
+		"ui.profile":         map[string]any{},
+		"search":             map[string]any{},
+		"mcp":                map[string]any{},
+		"tts":                map[string]any{},
+		"naming":             map[string]any{},
+		"core.chatDefaults":  map[string]any{},
+		"raw.cherry":         map[string]any{},
+		"raw.rikka":          map[string]any{},
+		"raw.unsupported":    []any{},
+		"normalizer.ver":     1,
+		"normalizer.source":  "",
 	}
 }
 
@@ -202,6 +205,21 @@ func cherryProviderToCanonical(providerType string) (string, bool) {
 	}
 }
 
+func isAzureProviderType(providerType string) bool {
+	return strings.EqualFold(strings.TrimSpace(providerType), "azure-openai")
+}
+
+// azureProviderFields are the Azure OpenAI settings with no canonical (or rikka) field.
+func azureProviderFields(raw map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, key := range []string{"apiVersion", "resourceName", "deploymentName"} {
+		if v := pickFirstString(raw[key]); v != "" {
+			out[key] = v
+		}
+	}
+	return out
+}
+
 func rikkaProviderToCanonical(providerType string) (string, bool) {
 	switch strings.ToLower(strings.TrimSpace(providerType)) {
 	case "openai":
@@ -256,3 +274,211 @@ func ensureID(m map[string]any) string {
 	}
 	return id
 }
+
+// canonicalModelType maps a rikka model type string or a cherry model type list
+// to CHAT, IMAGE or EMBEDDING; it returns "" when no type is recognized.
+func canonicalModelType(v any) string {
+	if list, ok := v.([]any); ok {
+		out := ""
+		for _, item := range list {
+			switch strings.ToLower(strings.TrimSpace(str(item))) {
+			case "embedding":
+				return "EMBEDDING"
+			case "image", "image_generation":
+				out = "IMAGE"
+			case "text", "vision", "reasoning", "function_calling", "web_search":
+				if out == "" {
+					out = "CHAT"
+				}
+			}
+		}
+		return out
+	}
+	switch strings.ToUpper(strings.TrimSpace(pickFirstString(v))) {
+	case "CHAT":
+		return "CHAT"
+	case "IMAGE":
+		return "IMAGE"
+	case "EMBEDDING":
+		return "EMBEDDING"
+	default:
+		return ""
+	}
+}
+
+// rikkaTitleContentSuffix is appended to cherry topic-naming prompts, which carry no
+// placeholder, because rikka substitutes the conversation into {content}.
+const rikkaTitleContentSuffix = "\n\n<content>\n{content}\n</content>"
+
+func cherryToRikkaTitlePrompt(prompt string) string {
+	if strings.TrimSpace(prompt) == "" || strings.Contains(prompt, "{content}") {
+		return prompt
+	}
+	return prompt + rikkaTitleContentSuffix
+}
+
+// rikkaDefaultTitlePromptHead opens rikka's built-in title prompt; a prompt starting
+// with it was never customized and has no business replacing cherry's default.
+const rikkaDefaultTitlePromptHead = "I will give you some dialogue content in the `<content>` block."
+
+// rikkaToCherryTitlePrompt returns "" when the prompt is rikka's untouched default.
+func rikkaToCherryTitlePrompt(prompt string) string {
+	if strings.HasPrefix(strings.TrimSpace(prompt), rikkaDefaultTitlePromptHead) {
+		return ""
+	}
+	return strings.TrimSuffix(prompt, rikkaTitleContentSuffix)
+}
+
+// chatDefaultKeys are the sampling params new chats start with: cherry keeps them on
+// assistants.defaultAssistant, rikka on the selected assistant.
+var chatDefaultKeys = []string{"temperature", "topP", "maxTokens", "frequencyPenalty", "presencePenalty"}
+
+func pickChatDefaults(src map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, key := range chatDefaultKeys {
+		if v, ok := src[key]; ok && v != nil {
+			out[key] = cloneAny(v)
+		}
+	}
+	return out
+}
+
+// IsKnownProviderType reports whether a cherry or rikka provider type maps to a
+// canonical provider.
+func IsKnownProviderType(providerType string) bool {
+	if _, ok := cherryProviderToCanonical(providerType); ok {
+		return true
+	}
+	_, ok := rikkaProviderToCanonical(providerType)
+	return ok
+}
+
+// AddDefaultProvider appends a normalized provider of providerType holding a single
+// chat model, and selects that model, when no provider in settings has a model. It
+// reports whether the provider was added.
+func AddDefaultProvider(settings map[string]any, providerType, model string) bool {
+	for _, item := range asSlice(settings["core.providers"]) {
+		if len(asSlice(asMap(asMap(item)["raw"])["models"])) > 0 {
+			return false
+		}
+	}
+	mapped, ok := cherryProviderToCanonical(providerType)
+	if !ok {
+		if mapped, ok = rikkaProviderToCanonical(providerType); !ok {
+			return false
+		}
+	}
+	raw := map[string]any{
+		"id":      providerType,
+		"name":    providerType,
+		"type":    providerType,
+		"enabled": true,
+		"models": []any{map[string]any{
+			"id":            model,
+			"modelId":       model,
+			"name":          model,
+			"displayName":   model,
+			"type":          "CHAT",
+			"canonicalType": "CHAT",
+		}},
+	}
+	settings["core.providers"] = append(asSlice(settings["core.providers"]), map[string]any{
+		"id":         providerType,
+		"name":       providerType,
+		"sourceType": providerType,
+		"mappedType": mapped,
+		"raw":        raw,
+	})
+	coreModels := cloneMap(asMap(settings["core.models"]))
+	for _, key := range []string{"chatModelId", "titleModelId", "translateModeId", "suggestionModelId"} {
+		if pickFirstString(coreModels[key]) == "" {
+			coreModels[key] = model
+		}
+	}
+	settings["core.models"] = coreModels
+	return true
+}
+
+// wellKnownChatModels are preferred, in order, when a backup selects no default model.
+var wellKnownChatModels = []string{
+	"gpt-4o", "gpt-4.1", "claude-sonnet", "claude-3-5-sonnet", "claude-3-7-sonnet",
+	"gemini-2.5-pro", "gemini-1.5-pro", "deepseek-chat", "qwen-max",
+}
+
+// nonChatModelHints identify untyped models that cannot hold a conversation.
+var nonChatModelHints = []string{"embed", "rerank", "dall-e", "tts", "whisper", "moderation"}
+
+// pickDefaultChatModel chooses the fallback default model: a chat model from the
+// enabled providers, a well-known one when present, otherwise the first one in
+// provider order. Disabled providers are only used when no enabled one has a chat
+// model. It returns nil when there is no chat model at all.
+func pickDefaultChatModel(providers []any) map[string]any {
+	for _, enabledOnly := range []bool{true, false} {
+		var first, best map[string]any
+		bestRank := len(wellKnownChatModels)
+		for _, item := range providers {
+			pm := asMap(item)
+			if enabled, ok := coerceBool(pm["enabled"]); enabledOnly && ok && !enabled {
+				continue
+			}
+			for _, mv := range asSlice(pm["models"]) {
+				mm := asMap(mv)
+				if !isChatModel(mm) {
+					continue
+				}
+				if first == nil {
+					first = mm
+				}
+				if rank := wellKnownChatModelRank(mm); rank < bestRank {
+					best, bestRank = mm, rank
+				}
+			}
+		}
+		if best != nil {
+			return best
+		}
+		if first != nil {
+			return first
+		}
+	}
+	return nil
+}
+
+func isChatModel(model map[string]any) bool {
+	if len(model) == 0 {
+		return false
+	}
+	if t := pickFirstString(canonicalModelType(model["type"]), model["canonicalType"]); t != "" {
+		return t == "CHAT"
+	}
+	name := strings.ToLower(pickFirstString(model["modelId"], model["id"], model["name"]))
+	for _, hint := range nonChatModelHints {
+		if strings.Contains(name, hint) {
+			return false
+		}
+	}
+	return true
+}
+
+func wellKnownChatModelRank(model map[string]any) int {
+	name := strings.ToLower(pickFirstString(model["modelId"], model["id"], model["name"]))
+	for i, hint := range wellKnownChatModels {
+		if strings.Contains(name, hint) {
+			return i
+		}
+	}
+	return len(wellKnownChatModels)
+}
+
+// annotateCanonicalModelTypes records canonicalType on every model of a raw provider.
+func annotateCanonicalModelTypes(provider map[string]any) {
+	for _, item := range asSlice(provider["models"]) {
+		mm := asMap(item)
+		if len(mm) == 0 || pickFirstString(mm["canonicalType"]) != "" {
+			continue
+		}
+		if t := canonicalModelType(mm["type"]); t != "" {
+			mm["canonicalType"] = t
+		}
+	}
+}
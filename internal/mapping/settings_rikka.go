@@ -29,6 +29,7 @@ func NormalizeFromRikkaConfig(config map[string]any) (map[string]any, []string)
 			"mappedType": mapped,
 			"raw":        cloneMap(pm),
 		}
+		annotateCanonicalModelTypes(asMap(entry["raw"]))
 		ensureID(entry)
 		coreProviders = append(coreProviders, entry)
 	}
@@ -57,6 +58,7 @@ func NormalizeFromRikkaConfig(config map[string]any) (map[string]any, []string)
 		coreAssistants = append(coreAssistants, entry)
 	}
 	out["core.assistants"] = coreAssistants
+	out["core.assistantTags"] = normalizeRikkaAssistantTags(settings, coreAssistants)
 
 	coreModels := map[string]any{}
 	for _, key := range []string{"chatModelId", "titleModelId", "translateModeId", "suggestionModelId", "imageGenerationModelId"} {
@@ -68,6 +70,16 @@ func NormalizeFromRikkaConfig(config map[string]any) (map[string]any, []string)
 	setIfPresent(selection, "assistantId", settings["assistantId"])
 	out["core.selection"] = selection
 
+	chatDefaults := map[string]any{}
+	selectedID := pickFirstString(settings["assistantId"], defaultAssistantID)
+	for _, item := range assistantsRaw {
+		if am := asMap(item); pickFirstString(am["id"]) == selectedID {
+			chatDefaults = pickChatDefaults(am)
+			break
+		}
+	}
+	out["core.chatDefaults"] = chatDefaults
+
 	out["sync.webdav"] = cloneMap(asMap(settings["webDavConfig"]))
 	out["sync.s3"] = cloneMap(asMap(settings["s3Config"]))
 
@@ -99,5 +111,9 @@ func NormalizeFromRikkaConfig(config map[string]any) (map[string]any, []string)
 	}
 	out["tts"] = tts
 
+	naming := map[string]any{}
+	setIfPresent(naming, "titlePrompt", settings["titlePrompt"])
+	out["naming"] = naming
+
 	return out, warnings
 }
@@ -48,7 +48,13 @@ func BuildCherryPersistSlicesFromIR(in *ir.BackupIR, base map[string]any, assist
 	}
 
 	coreModels := asMap(norm["core.models"])
-	cherryProviders, modelLookup, firstModel := buildCherryProviders(asSlice(norm["core.providers"]), &warnings)
+	sourceProviders := asSlice(asMap(asMap(in.Config["rehydrate.cherry.persistSlices"])["llm"])["providers"])
+	if len(sourceProviders) == 0 {
+		sourceProviders = asSlice(asMap(in.Config["rehydrate.cherry.llm"])["providers"])
+	}
+	matchSource := cherrySourceProviderMatcher(sourceProviders)
+	cherryProviders, modelLookup, firstModel := buildCherryProviders(asSlice(norm["core.providers"]), matchSource, &warnings)
+	restoreCherryProviderFlags(cherryProviders, matchSource)
 	if len(cherryProviders) > 0 {
 		llm["providers"] = cherryProviders
 	}
@@ -56,6 +62,17 @@ func BuildCherryPersistSlicesFromIR(in *ir.BackupIR, base map[string]any, assist
 	applyCherrySelection(llm, "quickModel", modelLookup, firstModel, &warnings, coreModels["quickModel"], coreModels["suggestionModelId"])
 	applyCherrySelection(llm, "translateModel", modelLookup, firstModel, &warnings, coreModels["translateModel"], coreModels["translateModeId"])
 	applyCherrySelection(llm, "topicNamingModel", modelLookup, firstModel, &warnings, coreModels["topicNamingModel"], coreModels["titleModelId"])
+	// no first-model fallback: a chat model is not a usable image model
+	for _, candidate := range []any{coreModels["paintingModel"], coreModels["imageGenerationModelId"]} {
+		if model := resolveCherryModel(candidate, modelLookup); len(model) > 0 {
+			llm["paintingModel"] = model
+			break
+		}
+	}
+	attachCherryAssistantModels(asMap(dst["assistants"]), asSlice(norm["core.assistants"]), modelLookup)
+	attachCherryAssistantTags(asMap(dst["assistants"]), asSlice(norm["core.assistants"]), asSlice(norm["core.assistantTags"]))
+
+	applyCherryChatDefaults(asMap(dst["assistants"]), asMap(norm["core.chatDefaults"]))
 
 	ui := asMap(norm["ui.profile"])
 	for _, key := range []string{"userId", "userName", "language", "targetLanguage"} {
@@ -110,6 +127,13 @@ func BuildCherryPersistSlicesFromIR(in *ir.BackupIR, base map[string]any, assist
 	if tts := asMap(norm["tts"]); len(tts) > 0 {
 		mergeMissing(settings, tts)
 	}
+	naming := asMap(norm["naming"])
+	if prompt := rikkaToCherryTitlePrompt(pickFirstString(naming["titlePrompt"])); prompt != "" {
+		settings["topicNamingPrompt"] = prompt
+	}
+	if v, ok := naming["enableTopicNaming"]; ok {
+		settings["enableTopicNaming"] = cloneAny(v)
+	}
 
 	if strings.EqualFold(in.SourceFormat, "cherry") {
 		mergeMissing(settings, asMap(in.Config["cherry.settings"]))
@@ -128,7 +152,26 @@ func BuildCherryPersistSlicesFromIR(in *ir.BackupIR, base map[string]any, assist
 	return dst, warnings
 }
 
-func buildCherryProviders(coreProviders []any, warnings *[]string) ([]any, map[string]map[string]any, map[string]any) {
+// applyCherryChatDefaults writes the normalized new-chat sampling params onto the
+// default assistant; maxTokens is only honoured by cherry with enableMaxTokens.
+func applyCherryChatDefaults(assistantsSlice, chatDefaults map[string]any) {
+	defaultAssistant := asMap(assistantsSlice["defaultAssistant"])
+	if len(chatDefaults) == 0 || len(defaultAssistant) == 0 {
+		return
+	}
+	settings := cloneMap(asMap(defaultAssistant["settings"]))
+	for key, v := range chatDefaults {
+		settings[key] = cloneAny(v)
+	}
+	if maxTokens, ok := coerceInt(chatDefaults["maxTokens"]); ok && maxTokens > 0 {
+		if _, exists := settings["enableMaxTokens"]; !exists {
+			settings["enableMaxTokens"] = true
+		}
+	}
+	defaultAssistant["settings"] = settings
+}
+
+func buildCherryProviders(coreProviders []any, matchSource func(map[string]any) map[string]any, warnings *[]string) ([]any, map[string]map[string]any, map[string]any) {
 	out := make([]any, 0, len(coreProviders))
 	modelLookup := map[string]map[string]any{}
 	firstModel := map[string]any{}
@@ -157,6 +200,7 @@ func buildCherryProviders(coreProviders []any, warnings *[]string) ([]any, map[s
 			raw["name"] = pickFirstString(pm["name"], strings.ToUpper(mapped))
 		}
 		raw["type"] = cherryType
+		sourceModels := asSlice(matchSource(raw)["models"])
 		rawModels := asSlice(raw["models"])
 		normModels := make([]any, 0, len(rawModels))
 		for _, mv := range rawModels {
@@ -171,7 +215,22 @@ func buildCherryProviders(coreProviders []any, warnings *[]string) ([]any, map[s
 			}
 			model := cloneMap(mm)
 			model["id"] = modelID
+			// Keep the rikka model UUID so the reverse trip can restore stable model IDs.
+			if isValidUUID(sourceID) && sourceID != modelID {
+				model["rikkaId"] = sourceID
+			}
 			model["provider"] = providerID
+			if _, isList := mm["type"].([]any); !isList {
+				// rikka stores a single type string; cherry expects a list of capabilities.
+				delete(model, "type")
+				switch pickFirstString(canonicalModelType(mm["type"]), mm["canonicalType"]) {
+				case "EMBEDDING":
+					model["type"] = []any{"embedding"}
+				case "IMAGE":
+					model["type"] = []any{"image_generation"}
+				}
+			}
+			delete(model, "canonicalType")
 			model["name"] = pickFirstString(mm["name"], mm["displayName"], mm["modelId"], modelID)
 			if pickFirstString(model["group"]) == "" {
 				model["group"] = "default"
@@ -179,6 +238,7 @@ func buildCherryProviders(coreProviders []any, warnings *[]string) ([]any, map[s
 			if pickFirstString(model["modelId"]) == "" {
 				model["modelId"] = modelID
 			}
+			restoreCherryModelFields(model, sourceModels)
 			registerCherryModelAlias(modelLookup, sourceID, model)
 			registerCherryModelAlias(modelLookup, pickFirstString(model["id"]), model)
 			registerCherryModelAlias(modelLookup, pickFirstString(model["modelId"]), model)
@@ -203,9 +263,104 @@ func buildCherryProviders(coreProviders []any, warnings *[]string) ([]any, map[s
 		}
 		out = append(out, raw)
 	}
+	if model := pickDefaultChatModel(out); len(model) > 0 {
+		firstModel = cloneMap(model)
+	}
 	return out, modelLookup, firstModel
 }
 
+// cherrySourceProviderMatcher indexes the sidecar's original cherry providers. A built
+// provider matches by id, by the UUID the rikka build derived from that id, or by name
+// and type; the matcher returns nil when nothing matches.
+func cherrySourceProviderMatcher(source []any) func(map[string]any) map[string]any {
+	byID := map[string]map[string]any{}
+	byName := map[string]map[string]any{}
+	for _, item := range source {
+		sp := asMap(item)
+		if id := pickFirstString(sp["id"]); id != "" {
+			byID[id] = sp
+			byID[ensureUUID(id, "provider:"+id)] = sp
+		}
+		if name := strings.ToLower(pickFirstString(sp["name"])); name != "" {
+			byName[name+"|"+pickFirstString(sp["type"])] = sp
+		}
+	}
+	return func(pm map[string]any) map[string]any {
+		if sp := byID[pickFirstString(pm["id"])]; len(sp) > 0 {
+			return sp
+		}
+		return byName[strings.ToLower(pickFirstString(pm["name"]))+"|"+pickFirstString(pm["type"])]
+	}
+}
+
+// restoreCherryProviderFlags copies boolean provider flags (isNotSupportArrayContent,
+// isNotSupportStreamOptions, ...) that rikka cannot hold back from the sidecar's
+// original cherry providers.
+func restoreCherryProviderFlags(providers []any, matchSource func(map[string]any) map[string]any) {
+	for _, item := range providers {
+		pm := asMap(item)
+		sp := matchSource(pm)
+		for k, v := range sp {
+			if _, isBool := v.(bool); !isBool {
+				continue
+			}
+			if _, exists := pm[k]; !exists {
+				pm[k] = v
+			}
+		}
+	}
+}
+
+// restoreCherryModelFields fills in the cherry model attributes rikka has no slot for
+// (pricing, context limits, endpoint type, ...) from the sidecar's original model with
+// the same id or name. Fields the build already set win, except the "default" group.
+func restoreCherryModelFields(model map[string]any, sourceModels []any) {
+	var source map[string]any
+	for _, key := range []string{"id", "name"} {
+		want := pickFirstString(model[key])
+		for _, item := range sourceModels {
+			if sm := asMap(item); want != "" && pickFirstString(sm[key]) == want {
+				source = sm
+				break
+			}
+		}
+		if len(source) > 0 {
+			break
+		}
+	}
+	for k, v := range source {
+		if _, exists := model[k]; !exists || (k == "group" && pickFirstString(model[k]) == "default") {
+			model[k] = cloneAny(v)
+		}
+	}
+}
+
+func attachCherryAssistantModels(assistantsSlice map[string]any, coreAssistants []any, lookup map[string]map[string]any) {
+	if len(assistantsSlice) == 0 || len(lookup) == 0 {
+		return
+	}
+	modelByAssistant := map[string]string{}
+	for _, item := range coreAssistants {
+		am := asMap(item)
+		if id, modelID := pickFirstString(am["id"]), pickFirstString(am["chatModelId"]); id != "" && modelID != "" {
+			modelByAssistant[id] = modelID
+		}
+	}
+	for _, item := range asSlice(assistantsSlice["assistants"]) {
+		assistant := asMap(item)
+		if len(assistant) == 0 {
+			continue
+		}
+		candidate := any(modelByAssistant[pickFirstString(assistant["id"])])
+		if pickFirstString(candidate) == "" {
+			candidate = assistant["model"]
+		}
+		if model := resolveCherryModel(candidate, lookup); len(model) > 0 {
+			assistant["model"] = model
+		}
+	}
+}
+
 func registerCherryModelAlias(lookup map[string]map[string]any, key string, model map[string]any) {
 	key = strings.TrimSpace(key)
 	if key == "" {
@@ -276,3 +431,15 @@ func applyCherrySelection(llm map[string]any, key string, lookup map[string]map[
 		*warnings = appendUnique(*warnings, "provider-invalid-disabled:model-selection-fallback:"+key)
 	}
 }
+
+// CherryUnmappedModelSelection returns the rikka selection slots that cherry
+// may not keep, so they can be carried in the sidecar manifest. The image model is
+// written to llm.paintingModel only when it resolves to a cherry model.
+func CherryUnmappedModelSelection(settings map[string]any) map[string]string {
+	coreModels := asMap(settings["core.models"])
+	out := map[string]string{}
+	if id := pickFirstString(coreModels["imageGenerationModelId"]); id != "" {
+		out["imageGenerationModelId"] = id
+	}
+	return out
+}
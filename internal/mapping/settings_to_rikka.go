@@ -2,7 +2,9 @@ package mapping
 
 import (
 	"fmt"
+	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -41,7 +43,14 @@ func BuildRikkaSettingsFromIR(in *ir.BackupIR, base map[string]any) (map[string]
 		dst["providers"] = []any{}
 	}
 
-	if dstAssistants := buildRikkaAssistants(in, asSlice(norm["core.assistants"]), modelAlias, &warnings); len(dstAssistants) > 0 {
+	mcp := asMap(norm["mcp"])
+	mcpServers, mcpAlias := buildRikkaMCPServers(mcp["servers"])
+
+	assistantTags, tagAlias := buildRikkaAssistantTags(asSlice(norm["core.assistantTags"]))
+	if len(assistantTags) > 0 {
+		dst["assistantTags"] = assistantTags
+	}
+	if dstAssistants := buildRikkaAssistants(in, asSlice(norm["core.assistants"]), modelAlias, mcpAlias, tagAlias, &warnings); len(dstAssistants) > 0 {
 		dst["assistants"] = dstAssistants
 	} else if _, ok := dst["assistants"]; !ok {
 		dst["assistants"] = []any{}
@@ -79,6 +88,11 @@ func BuildRikkaSettingsFromIR(in *ir.BackupIR, base map[string]any) (map[string]
 		if display, ok := ui["displaySetting"]; ok {
 			dst["displaySetting"] = cloneAny(display)
 		}
+		if userName := pickFirstString(ui["userName"]); userName != "" {
+			display := cloneMap(asMap(dst["displaySetting"]))
+			display["userNickname"] = userName
+			dst["displaySetting"] = display
+		}
 	}
 
 	if search := asMap(norm["search"]); len(search) > 0 {
@@ -88,10 +102,8 @@ func BuildRikkaSettingsFromIR(in *ir.BackupIR, base map[string]any) (map[string]
 			}
 		}
 	}
-	if mcp := asMap(norm["mcp"]); len(mcp) > 0 {
-		if v, ok := mcp["servers"]; ok {
-			dst["mcpServers"] = cloneAny(v)
-		}
+	if _, ok := mcp["servers"]; ok {
+		dst["mcpServers"] = mcpServers
 	}
 	if tts := asMap(norm["tts"]); len(tts) > 0 {
 		if v, ok := tts["ttsProviders"]; ok {
@@ -102,15 +114,105 @@ func BuildRikkaSettingsFromIR(in *ir.BackupIR, base map[string]any) (map[string]
 		}
 	}
 
+	// enableTopicNaming has no rikka setting; the cherry sidecar keeps it
+	if prompt := pickFirstString(asMap(norm["naming"])["titlePrompt"]); prompt != "" {
+		dst["titlePrompt"] = prompt
+	}
+
 	if strings.EqualFold(in.SourceFormat, "rikka") {
 		raw := asMap(asMap(in.Config["rikka.settings"]))
 		mergeMissing(dst, raw)
 	}
+	reattachRikkaAssistantFields(dst, in, &warnings)
 
 	warnings = appendUnique(warnings, enforceRikkaConsistency(dst)...)
+	applyRikkaChatDefaults(dst, asMap(norm["core.chatDefaults"]))
 	return dst, warnings
 }
 
+// reattachRikkaAssistantFields restores the isolated per-assistant fields (lorebooks,
+// mode injections, memory) onto the rebuilt assistant with the same id. The top-level
+// rehydrate overlay is replaced by the mapped assistants, so without this a
+// rikka->cherry->rikka round trip loses them.
+func reattachRikkaAssistantFields(dst map[string]any, in *ir.BackupIR, warnings *[]string) {
+	unsupported := asMap(in.Opaque["interop.rikka.unsupported"])
+	for _, key := range []string{"modeInjections", "lorebooks", "memoryEntities", "memories"} {
+		if v, ok := unsupported[key]; ok {
+			if _, exists := dst[key]; !exists {
+				dst[key] = cloneAny(v)
+			}
+		}
+	}
+	byID := map[string]map[string]any{}
+	sources := append(asSlice(asMap(in.Config["rehydrate.rikka.settings"])["assistants"]), asSlice(unsupported["assistants"])...)
+	for _, item := range sources {
+		src := asMap(item)
+		id := pickFirstString(src["id"])
+		if id == "" {
+			continue
+		}
+		if byID[id] == nil {
+			byID[id] = map[string]any{}
+		}
+		for _, key := range rikkaUnsupportedAssistantKeys {
+			if v, ok := src[key]; ok && isMeaningfulUnsupported(v) {
+				if _, seen := byID[id][key]; !seen {
+					byID[id][key] = v
+				}
+			}
+		}
+	}
+	if len(byID) == 0 {
+		return
+	}
+	for _, item := range asSlice(dst["assistants"]) {
+		assistant := asMap(item)
+		fields := byID[pickFirstString(assistant["id"])]
+		if len(fields) == 0 {
+			continue
+		}
+		for key, v := range fields {
+			if _, ok := assistant[key]; !ok {
+				assistant[key] = cloneAny(v)
+			}
+		}
+		sanitizeAssistantUUIDListField(assistant, "modeInjectionIds", warnings)
+		sanitizeAssistantUUIDListField(assistant, "lorebookIds", warnings)
+	}
+}
+
+// applyRikkaChatDefaults fills the selected assistant's sampling params that it does not
+// set itself. Rikka has no penalty settings, so those stay in the cherry sidecar.
+func applyRikkaChatDefaults(settings, chatDefaults map[string]any) {
+	if len(chatDefaults) == 0 {
+		return
+	}
+	selectedID := pickFirstString(settings["assistantId"])
+	for _, item := range asSlice(settings["assistants"]) {
+		am := asMap(item)
+		if pickFirstString(am["id"]) != selectedID {
+			continue
+		}
+		for _, key := range []string{"temperature", "topP"} {
+			if _, exists := am[key]; exists && am[key] != nil {
+				continue
+			}
+			if v, ok := coerceFloat(chatDefaults[key]); ok {
+				am[key] = v
+			}
+		}
+		if enabled, ok := coerceBool(chatDefaults["enableMaxTokens"]); ok && !enabled {
+			return
+		}
+		if _, exists := am["maxTokens"]; !exists || am["maxTokens"] == nil {
+			if v, ok := coerceInt(chatDefaults["maxTokens"]); ok && v > 0 {
+				am["maxTokens"] = v
+			}
+		}
+		return
+	}
+}
+
 func applyRikkaModelSelection(dst, coreModels map[string]any, modelAlias map[string]string) {
 	if len(coreModels) == 0 {
 		return
@@ -129,7 +231,7 @@ func applyRikkaModelSelection(dst, coreModels map[string]any, modelAlias map[str
 	setSelection("titleModelId", coreModels["titleModelId"], coreModels["topicNamingModel"])
 	setSelection("translateModeId", coreModels["translateModeId"], coreModels["translateModel"])
 	setSelection("suggestionModelId", coreModels["suggestionModelId"], coreModels["quickModel"])
-	setSelection("imageGenerationModelId", coreModels["imageGenerationModelId"])
+	setSelection("imageGenerationModelId", coreModels["imageGenerationModelId"], coreModels["paintingModel"])
 }
 
 func buildRikkaProviders(coreProviders []any, warnings *[]string) ([]any, map[string]string) {
@@ -163,7 +265,18 @@ func buildRikkaProviders(coreProviders []any, warnings *[]string) ([]any, map[st
 		switch pType {
 		case "openai":
 			setIfPresent(provider, "apiKey", pickFirstString(raw["apiKey"]))
-			baseURL := normalizeOpenAIBaseURLV1(pickFirstString(raw["baseUrl"], raw["apiHost"], "https://api.openai.com/v1"))
+			sourceType := pickFirstString(pm["sourceType"])
+			baseURL := openAIProviderBaseURL(sourceType, raw)
+			if isAzureProviderType(sourceType) {
+				if fields := azureProviderFields(raw); len(fields) > 0 {
+					keys := make([]string, 0, len(fields))
+					for k := range fields {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					*warnings = appendUnique(*warnings, "provider-azure-fields-dropped:"+pickFirstString(provider["name"], providerID)+":"+strings.Join(keys, ","))
+				}
+			}
 			setIfPresent(provider, "baseUrl", baseURL)
 			chatPath := normalizeOpenAIChatPath(pickFirstString(raw["chatCompletionsPath"], raw["apiPath"]), baseURL)
 			setIfPresent(provider, "chatCompletionsPath", chatPath)
@@ -197,8 +310,11 @@ func buildRikkaProviders(coreProviders []any, warnings *[]string) ([]any, map[st
 			if modelRef == "" {
 				modelRef = util.NewUUID()
 			}
-			modelID := ensureUUID(pickFirstString(mm["id"]), "model:"+providerID+":"+modelRef)
+			modelID := ensureUUID(pickFirstString(mm["rikkaId"], mm["id"]), "model:"+providerID+":"+modelRef)
 			modelType := normalizeRikkaModelType(mm["type"])
+			if t := pickFirstString(mm["canonicalType"]); t != "" {
+				modelType = normalizeRikkaModelType(t)
+			}
 			if pickFirstString(mm["type"]) != "" && modelType != strings.ToUpper(strings.TrimSpace(pickFirstString(mm["type"]))) {
 				*warnings = appendUnique(*warnings, "normalized unsupported model type to CHAT: "+pickFirstString(mm["type"]))
 			}
@@ -239,19 +355,54 @@ func buildRikkaProviders(coreProviders []any, warnings *[]string) ([]any, map[st
 	return out, modelAlias
 }
 
-func buildRikkaAssistants(in *ir.BackupIR, coreAssistants []any, modelAlias map[string]string, warnings *[]string) []any {
+// buildRikkaMCPServers gives every MCP server a UUID id and returns the old->new id alias.
+func buildRikkaMCPServers(v any) (any, map[string]string) {
+	alias := map[string]string{}
+	items, ok := v.([]any)
+	if !ok {
+		return cloneAny(v), alias
+	}
+	out := make([]any, 0, len(items))
+	for i, item := range items {
+		server := asMap(item)
+		if len(server) == 0 {
+			out = append(out, cloneAny(item))
+			continue
+		}
+		server = cloneMap(server)
+		sourceID := pickFirstString(server["id"])
+		seed := pickFirstString(sourceID, server["name"], strconv.Itoa(i))
+		server["id"] = ensureUUID(sourceID, "mcp:"+seed)
+		if sourceID != "" {
+			alias[sourceID] = server["id"].(string)
+		}
+		if name := pickFirstString(server["name"]); name != "" {
+			if _, exists := alias[name]; !exists {
+				alias[name] = server["id"].(string)
+			}
+		}
+		out = append(out, server)
+	}
+	return out, alias
+}
+
+func buildRikkaAssistants(in *ir.BackupIR, coreAssistants []any, modelAlias, mcpAlias, tagAlias map[string]string, warnings *[]string) []any {
 	out := make([]any, 0, len(coreAssistants)+len(in.Assistants))
 	usedNames := map[string]struct{}{}
-	appendAssistant := func(raw map[string]any) {
+	// rikka sources keep their original assistant objects; only mapped fields are rewritten.
+	passthrough := strings.EqualFold(in.SourceFormat, "rikka")
+	appendAssistant := func(raw, original map[string]any) {
 		if len(raw) == 0 {
 			return
 		}
-		assistant := map[string]any{
-			"id":           pickFirstString(raw["id"]),
-			"name":         pickFirstString(raw["name"]),
-			"systemPrompt": pickFirstString(raw["systemPrompt"]),
-			"chatModelId":  pickFirstString(raw["chatModelId"]),
+		assistant := map[string]any{}
+		if passthrough && len(original) > 0 {
+			assistant = cloneMap(original)
 		}
+		assistant["id"] = pickFirstString(raw["id"])
+		assistant["name"] = pickFirstString(raw["name"])
+		assistant["systemPrompt"] = pickFirstString(raw["systemPrompt"])
+		assistant["chatModelId"] = pickFirstString(raw["chatModelId"])
 		if temperature, ok := coerceFloat(raw["temperature"]); ok {
 			assistant["temperature"] = temperature
 		}
@@ -267,6 +418,8 @@ func buildRikkaAssistants(in *ir.BackupIR, coreAssistants []any, modelAlias map[
 		if maxTokens, ok := coerceInt(raw["maxTokens"]); ok {
 			if maxTokens > 0 {
 				assistant["maxTokens"] = maxTokens
+			} else {
+				delete(assistant, "maxTokens")
 			}
 		}
 		if enableMemory, ok := coerceBool(raw["enableMemory"]); ok {
@@ -287,12 +440,22 @@ func buildRikkaAssistants(in *ir.BackupIR, coreAssistants []any, modelAlias map[
 		assistantSeed := pickFirstString(assistant["id"], assistant["name"], util.NewUUID())
 		assistant["id"] = ensureUUID(pickFirstString(assistant["id"]), "assistant:"+assistantSeed)
 		assignUniqueAssistantName(assistant, usedNames, warnings)
+		remapAssistantReferenceList(assistant, "mcpServers", mcpAlias)
 		sanitizeAssistantUUIDListField(assistant, "mcpServers", warnings)
 		sanitizeAssistantUUIDListField(assistant, "tags", warnings)
 		sanitizeAssistantUUIDListField(assistant, "modeInjectionIds", warnings)
 		sanitizeAssistantUUIDListField(assistant, "lorebookIds", warnings)
-		if chatModel := pickFirstString(assistant["chatModelId"]); chatModel != "" {
-			if resolved := resolveModelID(chatModel, modelAlias); resolved != "" {
+		if chatModel := pickFirstString(assistant["chatModelId"]); chatModel != "" || len(asMap(raw["model"])) > 0 {
+			// cherry assistants may only carry the model by name in their raw model object
+			rawModel := cloneMap(asMap(raw["model"]))
+			if chatModel != "" {
+				rawModel["id"] = chatModel
+			}
+			resolved := resolveModelID(rawModel, modelAlias)
+			if chatModel == "" {
+				chatModel = pickFirstString(asMap(raw["model"])["name"])
+			}
+			if resolved != "" {
 				assistant["chatModelId"] = resolved
 			} else {
 				delete(assistant, "chatModelId")
@@ -315,7 +478,8 @@ func buildRikkaAssistants(in *ir.BackupIR, coreAssistants []any, modelAlias map[
 		if len(am) == 0 {
 			continue
 		}
-		raw := cloneMap(asMap(am["raw"]))
+		original := asMap(am["raw"])
+		raw := cloneMap(original)
 		raw["id"] = pickFirstString(raw["id"], am["id"])
 		raw["name"] = pickFirstString(raw["name"], am["name"])
 		raw["systemPrompt"] = pickFirstString(raw["systemPrompt"], am["systemPrompt"])
@@ -335,7 +499,16 @@ func buildRikkaAssistants(in *ir.BackupIR, coreAssistants []any, modelAlias map[
 		if _, ok := raw["maxTokens"]; !ok {
 			raw["maxTokens"] = am["maxTokens"]
 		}
-		appendAssistant(raw)
+		if names := asSlice(am["tags"]); len(names) > 0 {
+			tagIDs := make([]any, 0, len(names))
+			for _, name := range names {
+				if id := tagAlias[pickFirstString(name)]; id != "" {
+					tagIDs = append(tagIDs, id)
+				}
+			}
+			raw["tags"] = tagIDs
+		}
+		appendAssistant(raw, original)
 	}
 
 	if len(out) > 0 || len(in.Assistants) == 0 {
@@ -364,7 +537,7 @@ func buildRikkaAssistants(in *ir.BackupIR, coreAssistants []any, modelAlias map[
 		if v, ok := a.Settings["maxTokens"]; ok {
 			raw["maxTokens"] = v
 		}
-		appendAssistant(raw)
+		appendAssistant(raw, nil)
 	}
 	return out
 }
@@ -377,6 +550,9 @@ func enforceRikkaConsistency(settings map[string]any) []string {
 	enabledModelIDs := map[string]struct{}{}
 	firstModelID := ""
 	firstEnabledModelID := ""
+	// finalAlias maps every pre-consistency id/modelId/displayName to the final model id,
+	// so references are rebound after ids are regenerated here.
+	finalAlias := map[string]string{}
 	for pi, pItem := range providers {
 		pm := asMap(pItem)
 		providerSeed := pickFirstString(pm["id"], pm["name"], util.NewUUID())
@@ -389,8 +565,9 @@ func enforceRikkaConsistency(settings map[string]any) []string {
 		models := asSlice(pm["models"])
 		for mi, mItem := range models {
 			mm := asMap(mItem)
+			sourceID := pickFirstString(mm["id"])
 			modelRef := pickFirstString(mm["modelId"], mm["id"], mm["name"], mm["displayName"], util.NewUUID())
-			mm["id"] = ensureUUID(pickFirstString(mm["id"]), "model:consistency:"+pickFirstString(pm["id"])+":"+modelRef)
+			mm["id"] = ensureUUID(sourceID, "model:consistency:"+pickFirstString(pm["id"])+":"+modelRef)
 			if pickFirstString(mm["modelId"]) == "" {
 				mm["modelId"] = modelRef
 			}
@@ -400,6 +577,9 @@ func enforceRikkaConsistency(settings map[string]any) []string {
 			mm["type"] = normalizeRikkaModelType(mm["type"])
 
 			id := pickFirstString(mm["id"])
+			for _, key := range []string{id, sourceID, pickFirstString(mm["modelId"]), pickFirstString(mm["displayName"]), pickFirstString(mm["name"])} {
+				registerModelAlias(finalAlias, key, id)
+			}
 			if id != "" {
 				modelIDs[id] = struct{}{}
 				if firstModelID == "" {
@@ -430,6 +610,20 @@ func enforceRikkaConsistency(settings map[string]any) []string {
 		activeModelIDs = enabledModelIDs
 		activeFirstModelID = firstEnabledModelID
 	}
+	if id := pickFirstString(pickDefaultChatModel(providers)["id"]); id != "" {
+		if _, ok := activeModelIDs[id]; ok {
+			activeFirstModelID = id
+		}
+	}
+
+	activeModel := func(ref string) (string, bool) {
+		if _, ok := activeModelIDs[ref]; ok {
+			return ref, true
+		}
+		id := pickFirstString(finalAlias[ref], finalAlias[strings.ToLower(ref)])
+		_, ok := activeModelIDs[id]
+		return id, ok
+	}
 
 	assistants := asSlice(settings["assistants"])
 	assistantIDs := map[string]struct{}{}
@@ -443,7 +637,9 @@ func enforceRikkaConsistency(settings map[string]any) []string {
 			am["name"] = "Imported Assistant"
 		}
 		if chatModel := pickFirstString(am["chatModelId"]); chatModel != "" {
-			if _, ok := activeModelIDs[chatModel]; !ok {
+			if id, ok := activeModel(chatModel); ok {
+				am["chatModelId"] = id
+			} else {
 				if activeFirstModelID != "" {
 					am["chatModelId"] = activeFirstModelID
 				} else {
@@ -484,7 +680,9 @@ func enforceRikkaConsistency(settings map[string]any) []string {
 			}
 			continue
 		}
-		if _, ok := activeModelIDs[id]; !ok {
+		if rebound, ok := activeModel(id); ok {
+			settings[key] = rebound
+		} else {
 			if activeFirstModelID != "" {
 				settings[key] = activeFirstModelID
 			}
@@ -496,35 +694,27 @@ func enforceRikkaConsistency(settings map[string]any) []string {
 }
 
 func resolveModelID(value any, alias map[string]string) string {
-	resolveByString := func(s string) string {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return ""
-		}
-		if isValidUUID(s) {
-			return s
+	// aliases win over passing a UUID through: a source UUID may have been
+	// regenerated for the target, or be stale while the name still matches
+	candidates := []string{pickFirstString(value)}
+	m := asMap(value)
+	for _, key := range []string{"id", "modelId", "name", "displayName"} {
+		candidates = append(candidates, pickFirstString(m[key]))
+	}
+	for _, s := range candidates {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
 		}
-		if v, ok := alias[s]; ok && v != "" {
+		if v := alias[s]; v != "" {
 			return v
 		}
-		low := strings.ToLower(s)
-		if v, ok := alias[low]; ok && v != "" {
+		if v := alias[strings.ToLower(s)]; v != "" {
 			return v
 		}
-		return ""
-	}
-
-	if id := resolveByString(pickFirstString(value)); id != "" {
-		return id
-	}
-
-	m := asMap(value)
-	if len(m) == 0 {
-		return ""
 	}
-	for _, key := range []string{"id", "modelId", "name", "displayName"} {
-		if id := resolveByString(pickFirstString(m[key])); id != "" {
-			return id
+	for _, s := range candidates {
+		if s = strings.TrimSpace(s); isValidUUID(s) {
+			return s
 		}
 	}
 	return ""
@@ -563,6 +753,28 @@ func registerModelAlias(alias map[string]string, key, value string) {
 	}
 }
 
+// remapAssistantReferenceList rewrites id references (plain ids or objects with an id) through alias.
+func remapAssistantReferenceList(raw map[string]any, key string, alias map[string]string) {
+	items, ok := raw[key].([]any)
+	if !ok || len(alias) == 0 {
+		return
+	}
+	out := make([]any, 0, len(items))
+	for _, item := range items {
+		id := pickFirstString(item)
+		if id == "" {
+			m := asMap(item)
+			id = pickFirstString(m["id"], m["uuid"], m["name"])
+		}
+		if mapped, ok := alias[id]; ok {
+			out = append(out, mapped)
+			continue
+		}
+		out = append(out, item)
+	}
+	raw[key] = out
+}
+
 func sanitizeAssistantUUIDListField(raw map[string]any, key string, warnings *[]string) {
 	if _, ok := raw[key]; !ok {
 		return
@@ -677,14 +889,78 @@ func coerceFloat(v any) (float64, bool) {
 }
 
 func normalizeRikkaModelType(v any) string {
-	switch strings.ToUpper(strings.TrimSpace(pickFirstString(v))) {
-	case "IMAGE":
-		return "IMAGE"
-	case "EMBEDDING":
-		return "EMBEDDING"
-	default:
-		return "CHAT"
+	if t := canonicalModelType(v); t != "" {
+		return t
+	}
+	return "CHAT"
+}
+
+// localProviderDefaults is where local OpenAI-compatible servers listen when the
+// source provider has no host of its own.
+var localProviderDefaults = map[string]string{
+	"ollama":   "http://localhost:11434/v1",
+	"lmstudio": "http://localhost:1234/v1",
+}
+
+func openAIProviderBaseURL(sourceType string, raw map[string]any) string {
+	if isAzureProviderType(sourceType) {
+		return normalizeOpenAIBaseURLV1(azureOpenAIBaseURL(raw))
+	}
+	host := pickFirstString(raw["baseUrl"], raw["apiHost"], raw["host"], raw["endpoint"])
+	localDefault, localServer := localProviderDefaults[strings.ToLower(strings.TrimSpace(sourceType))]
+	if host == "" {
+		if localServer {
+			return localDefault
+		}
+		return "https://api.openai.com/v1"
+	}
+	// local servers are kept as configured, since their OpenAI path is not always /v1;
+	// only a missing scheme is filled in so rikka gets a usable url
+	if localServer && isLocalHost(host) {
+		host = strings.TrimRight(strings.TrimSpace(host), "/")
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return host
+	}
+	return normalizeOpenAIBaseURLV1(host)
+}
+
+func isLocalHost(rawURL string) bool {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified())
+}
+
+// azureOpenAIBaseURL points rikka's OpenAI-compatible client at the Azure v1 API,
+// https://<resource>.openai.azure.com/openai/v1, since a bare resource host would get /v1.
+func azureOpenAIBaseURL(raw map[string]any) string {
+	host := pickFirstString(raw["baseUrl"], raw["apiHost"])
+	if host == "" {
+		if resource := pickFirstString(raw["resourceName"]); resource != "" {
+			host = "https://" + resource + ".openai.azure.com"
+		}
+	}
+	if host == "" {
+		return ""
+	}
+	u, err := url.Parse(host)
+	if err != nil || strings.Trim(u.Path, "/") != "" {
+		return host
 	}
+	u.Path = "/openai"
+	return u.String()
 }
 
 func normalizeOpenAIBaseURLV1(baseURL string) string {
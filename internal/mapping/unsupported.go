@@ -2,6 +2,16 @@ package mapping
 
 import "strings"
 
+// rikkaUnsupportedAssistantKeys are the per-assistant fields kept for rehydration.
+var rikkaUnsupportedAssistantKeys = []string{
+	"modeInjectionIds",
+	"lorebookIds",
+	"enableMemory",
+	"useGlobalMemory",
+	"regexes",
+	"localTools",
+}
+
 // ExtractRikkaUnsupportedSettings isolates Rikka-specific fields that are not
 // mapped cross-app in V1.1 but should be preserved for sidecar rehydration.
 func ExtractRikkaUnsupportedSettings(settings map[string]any) map[string]any {
@@ -22,14 +32,6 @@ func ExtractRikkaUnsupportedSettings(settings map[string]any) map[string]any {
 		}
 	}
 
-	assistantKeys := []string{
-		"modeInjectionIds",
-		"lorebookIds",
-		"enableMemory",
-		"useGlobalMemory",
-		"regexes",
-		"localTools",
-	}
 	assistantsOut := []any{}
 	for _, item := range asSlice(settings["assistants"]) {
 		assistant := asMap(item)
@@ -43,7 +45,7 @@ func ExtractRikkaUnsupportedSettings(settings map[string]any) map[string]any {
 		if name := pickFirstString(assistant["name"]); name != "" {
 			entry["name"] = name
 		}
-		for _, key := range assistantKeys {
+		for _, key := range rikkaUnsupportedAssistantKeys {
 			if v, ok := assistant[key]; ok && isMeaningfulUnsupported(v) {
 				entry[key] = cloneAny(v)
 			}
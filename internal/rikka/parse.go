@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -30,14 +31,18 @@ func ValidateExtracted(dir string) error {
 		return errors.New(strings.Join(issues, "; "))
 	}
 
-	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	db, closeDB, err := openHubDB(dir, "")
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer closeDB()
+	if err := checkDBIntegrity(db); err != nil {
+		return err
+	}
 
 	validAssistantIDs := map[string]struct{}{}
 	modelIDs := map[string]struct{}{}
+	providerIDList, modelIDList, assistantIDList := []string{}, []string{}, []string{}
 	if b, err := os.ReadFile(filepath.Join(dir, "settings.json")); err == nil {
 		settings := map[string]any{}
 		if err := json.Unmarshal(b, &settings); err != nil {
@@ -46,6 +51,7 @@ func ValidateExtracted(dir string) error {
 			for _, item := range asSlice(settings["providers"]) {
 				provider := asMap(item)
 				providerID := strings.TrimSpace(str(provider["id"]))
+				providerIDList = append(providerIDList, providerID)
 				enabled := true
 				if v, ok := provider["enabled"].(bool); ok {
 					enabled = v
@@ -61,6 +67,8 @@ func ValidateExtracted(dir string) error {
 						continue
 					}
 					modelCount++
+					// only explicit ids are global references; modelId may repeat across providers
+					modelIDList = append(modelIDList, str(model["id"]))
 					if enabled {
 						modelIDs[modelID] = struct{}{}
 					}
@@ -87,12 +95,20 @@ func ValidateExtracted(dir string) error {
 				assistant := asMap(item)
 				if id := str(assistant["id"]); id != "" {
 					validAssistantIDs[id] = struct{}{}
+					assistantIDList = append(assistantIDList, id)
 				}
 				checkModelRef("assistant.chatModelId", str(assistant["chatModelId"]))
 			}
 		}
 	}
 
+	issues = append(issues, util.DuplicateIDIssues("settings.providers", providerIDList)...)
+	issues = append(issues, util.DuplicateIDIssues("settings.providers.models", modelIDList)...)
+	issues = append(issues, util.DuplicateIDIssues("settings.assistants", assistantIDList)...)
+	if convIDs, err := queryStrings(db, `SELECT id FROM ConversationEntity`); err == nil {
+		issues = append(issues, util.DuplicateIDIssues("ConversationEntity", convIDs)...)
+	}
+
 	managed := map[string]struct{}{}
 	hasManagedIndex := false
 	rows, err := db.Query(`SELECT relative_path FROM managed_files`)
@@ -130,15 +146,26 @@ func ValidateExtracted(dir string) error {
 				parts := asSlice(m["parts"])
 				for _, partItem := range parts {
 					part := asMap(partItem)
+					if has(part, "toolName") {
+						if input := strings.TrimSpace(str(part["input"])); input != "" && !json.Valid([]byte(input)) {
+							issues = append(issues, "tool input not valid json: "+str(part["toolName"]))
+						}
+					}
 					url := str(part["url"])
 					if !strings.HasPrefix(url, "file://") {
 						continue
 					}
-					fileName := filepath.Base(strings.TrimPrefix(url, "file://"))
-					if fileName == "" || fileName == "." || fileName == "/" {
+					rel := matchFileURLToRel(url, func(rel string) bool {
+						if hasManagedIndex {
+							_, ok := managed[rel]
+							return ok
+						}
+						_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+						return err == nil
+					})
+					if rel == "" {
 						continue
 					}
-					rel := filepath.ToSlash(filepath.Join("upload", fileName))
 					if hasManagedIndex {
 						if _, ok := managed[rel]; !ok {
 							issues = append(issues, "message_node file url has no managed_files entry: "+rel)
@@ -172,15 +199,30 @@ func ValidateExtracted(dir string) error {
 	return nil
 }
 
+// ParseOptions tunes ParseToIRWith. TempDir is where a pending WAL is checkpointed
+// on a copy of the database; "" uses the system temp dir.
+type ParseOptions struct {
+	TempDir string
+}
+
 func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
+	return ParseToIRWith(extractedDir, ParseOptions{})
+}
+
+func ParseToIRWith(extractedDir string, opts ParseOptions) (*ir.BackupIR, error) {
 	settingsBytes, err := os.ReadFile(filepath.Join(extractedDir, "settings.json"))
 	if err != nil {
 		return nil, err
 	}
-	var settings map[string]any
-	if err := json.Unmarshal(settingsBytes, &settings); err != nil {
+	var settingsRoot any
+	if err := json.Unmarshal(settingsBytes, &settingsRoot); err != nil {
 		return nil, fmt.Errorf("parse settings.json: %w", err)
 	}
+	settings, settingsIsObject := settingsRoot.(map[string]any)
+	if !settingsIsObject {
+		// keep going so the conversations in rikka_hub.db can still be recovered
+		settings = map[string]any{}
+	}
 
 	res := &ir.BackupIR{
 		SourceApp:    "rikkahub",
@@ -191,6 +233,9 @@ func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 		Opaque:       map[string]any{},
 		Secrets:      map[string]string{},
 	}
+	if !settingsIsObject {
+		res.Warnings = append(res.Warnings, "settings.json is not a JSON object, treating it as empty settings")
+	}
 	if sidecarExists(extractedDir) {
 		res.Opaque["interop.sidecar.available"] = true
 	}
@@ -199,11 +244,17 @@ func ParseToIR(extractedDir string) (*ir.BackupIR, error) {
 		res.Warnings = append(res.Warnings, "unsupported-isolated:rikka.settings")
 	}
 
-	db, err := sql.Open("sqlite", filepath.Join(extractedDir, "rikka_hub.db"))
+	if hasPendingWAL(extractedDir) {
+		res.Warnings = append(res.Warnings, "rikka-wal-present:may-contain-uncommitted-data")
+	}
+	db, closeDB, err := openHubDB(extractedDir, opts.TempDir)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
+	defer closeDB()
+	if err := checkDBIntegrity(db); err != nil {
+		return nil, err
+	}
 
 	fileByRelPath := map[string]ir.IRFile{}
 	fileWarnings, err := parseManagedFiles(db, extractedDir, fileByRelPath)
@@ -295,8 +346,8 @@ func parseManagedFiles(db *sql.DB, extractedDir string, out map[string]ir.IRFile
 			Size:        size,
 			MimeType:    mime,
 			Ext:         filepath.Ext(displayName),
-			CreatedAt:   time.UnixMilli(createdAt).UTC().Format(time.RFC3339),
-			UpdatedAt:   time.UnixMilli(updatedAt).UTC().Format(time.RFC3339),
+			CreatedAt:   time.UnixMilli(createdAt).UTC().Format(util.TimestampLayout),
+			UpdatedAt:   time.UnixMilli(updatedAt).UTC().Format(util.TimestampLayout),
 			HashSHA256:  hash,
 			LogicalType: inferLogicalTypeFromMime(mime, filepath.Ext(displayName)),
 			Missing:     sourcePath == "",
@@ -318,9 +369,41 @@ func parseManagedFiles(db *sql.DB, extractedDir string, out map[string]ir.IRFile
 	return dedupeWarnings(warnings), nil
 }
 
+// uploadDirs lists the directories holding managed files: upload/ plus every folder
+// referenced by managed_files relative paths, since some RikkaHub versions use another name.
+func uploadDirs(out map[string]ir.IRFile) []string {
+	seen := map[string]struct{}{"upload": {}}
+	dirs := []string{"upload"}
+	rels := make([]string, 0, len(out))
+	for rel := range out {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		dir := path.Dir(filepath.ToSlash(rel))
+		if dir == "." || dir == "/" || strings.HasPrefix(dir, "..") {
+			continue
+		}
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
 func mergeUploadFiles(extractedDir string, out map[string]ir.IRFile) []string {
 	warnings := []string{}
-	uploadDir := filepath.Join(extractedDir, "upload")
+	for _, dir := range uploadDirs(out) {
+		warnings = append(warnings, mergeUploadDir(extractedDir, dir, out)...)
+	}
+	return dedupeWarnings(warnings)
+}
+
+func mergeUploadDir(extractedDir, dir string, out map[string]ir.IRFile) []string {
+	warnings := []string{}
+	uploadDir := filepath.Join(extractedDir, filepath.FromSlash(dir))
 	entries, err := os.ReadDir(uploadDir)
 	if err != nil {
 		return nil
@@ -329,7 +412,7 @@ func mergeUploadFiles(extractedDir string, out map[string]ir.IRFile) []string {
 		if entry.IsDir() {
 			continue
 		}
-		rel := filepath.ToSlash(filepath.Join("upload", entry.Name()))
+		rel := path.Join(dir, entry.Name())
 		if _, exists := out[rel]; exists {
 			continue
 		}
@@ -340,15 +423,19 @@ func mergeUploadFiles(extractedDir string, out map[string]ir.IRFile) []string {
 		}
 		hash, _ := util.SHA256File(full)
 		ext := filepath.Ext(entry.Name())
+		id := "upload:" + entry.Name()
+		if dir != "upload" {
+			id = "upload:" + rel
+		}
 		out[rel] = ir.IRFile{
-			ID:          "upload:" + entry.Name(),
+			ID:          id,
 			Name:        entry.Name(),
 			RelativeSrc: rel,
 			SourcePath:  full,
 			Size:        st.Size(),
 			Ext:         ext,
-			CreatedAt:   st.ModTime().UTC().Format(time.RFC3339),
-			UpdatedAt:   st.ModTime().UTC().Format(time.RFC3339),
+			CreatedAt:   st.ModTime().UTC().Format(util.TimestampLayout),
+			UpdatedAt:   st.ModTime().UTC().Format(util.TimestampLayout),
 			HashSHA256:  hash,
 			LogicalType: inferLogicalTypeFromMime("", ext),
 			Orphan:      true,
@@ -387,8 +474,21 @@ func inferLogicalTypeFromMime(mime, ext string) string {
 	}
 }
 
+// Message opaque keys holding a message_node's alternatives (regenerated responses):
+// the raw rikka messages and which of them is selected.
+const (
+	OpaqueBranches    = "rikka.branches"
+	OpaqueSelectIndex = "rikka.selectIndex"
+)
+
 func parseConversations(db *sql.DB, out *ir.BackupIR, fileByRelPath map[string]ir.IRFile) error {
-	rows, err := db.Query(`SELECT id, assistant_id, title, create_at, update_at, truncate_index, suggestions, is_pinned FROM ConversationEntity ORDER BY update_at DESC`)
+	archivedExpr := "0"
+	if col, err := archivedColumn(db); err != nil {
+		return err
+	} else if col != "" {
+		archivedExpr = "`" + col + "`"
+	}
+	rows, err := db.Query(`SELECT id, assistant_id, title, create_at, update_at, truncate_index, suggestions, is_pinned, ` + archivedExpr + ` FROM ConversationEntity ORDER BY update_at DESC`)
 	if err != nil {
 		return err
 	}
@@ -403,16 +503,17 @@ func parseConversations(db *sql.DB, out *ir.BackupIR, fileByRelPath map[string]i
 			truncateIdx int
 			suggestions string
 			isPinned    int
+			isArchived  int
 		)
-		if err := rows.Scan(&id, &assistantID, &title, &createAtMS, &updateAtMS, &truncateIdx, &suggestions, &isPinned); err != nil {
+		if err := rows.Scan(&id, &assistantID, &title, &createAtMS, &updateAtMS, &truncateIdx, &suggestions, &isPinned, &isArchived); err != nil {
 			return err
 		}
 		conv := ir.IRConversation{
 			ID:          id,
 			AssistantID: assistantID,
 			Title:       title,
-			CreatedAt:   time.UnixMilli(createAtMS).UTC().Format(time.RFC3339),
-			UpdatedAt:   time.UnixMilli(updateAtMS).UTC().Format(time.RFC3339),
+			CreatedAt:   time.UnixMilli(createAtMS).UTC().Format(util.TimestampLayout),
+			UpdatedAt:   time.UnixMilli(updateAtMS).UTC().Format(util.TimestampLayout),
 			Messages:    []ir.IRMessage{},
 			Opaque: map[string]any{
 				"truncateIndex": truncateIdx,
@@ -420,6 +521,9 @@ func parseConversations(db *sql.DB, out *ir.BackupIR, fileByRelPath map[string]i
 				"isPinned":      isPinned,
 			},
 		}
+		if isArchived != 0 {
+			conv.Opaque["archived"] = true
+		}
 
 		nodes, err := db.Query(`SELECT id, node_index, messages, select_index FROM message_node WHERE conversation_id = ? ORDER BY node_index ASC`, id)
 		if err != nil {
@@ -453,10 +557,12 @@ func parseConversations(db *sql.DB, out *ir.BackupIR, fileByRelPath map[string]i
 			if msg.Role == "" {
 				msg.Role = "assistant"
 			}
-			conv.Messages = append(conv.Messages, msg)
 			if len(messages) > 1 {
-				conv.Opaque[fmt.Sprintf("node:%s:branches", nodeID)] = messages
+				// every alternative of the node, raw, so a rikka target can write it back
+				msg.Opaque[OpaqueBranches] = messages
+				msg.Opaque[OpaqueSelectIndex] = selectIndex
 			}
+			conv.Messages = append(conv.Messages, msg)
 		}
 		nodes.Close()
 		out.Conversations = append(out.Conversations, conv)
@@ -498,11 +604,24 @@ func parseRikkaPart(pm map[string]any, filesByRel map[string]ir.IRFile) ir.IRPar
 	case has(pm, "reasoning"):
 		p.Type = "reasoning"
 		p.Content = str(pm["reasoning"])
-	case has(pm, "toolCallId") && has(pm, "toolName") && has(pm, "input"):
+	case has(pm, "toolName"):
+		// tool calls saved mid-stream may lack toolCallId/input; writers fill the defaults
 		p.Type = "tool"
 		p.ToolCallID = str(pm["toolCallId"])
 		p.Name = str(pm["toolName"])
 		p.Input = str(pm["input"])
+		missing := []any{}
+		if strings.TrimSpace(p.ToolCallID) == "" {
+			missing = append(missing, "toolCallId")
+		}
+		if strings.TrimSpace(p.Input) == "" {
+			p.Input = "{}"
+			missing = append(missing, "input")
+		}
+		if len(missing) > 0 {
+			p.Metadata["partial"] = true
+			p.Metadata["partialMissing"] = missing
+		}
 		if outParts, ok := pm["output"].([]any); ok {
 			for _, o := range outParts {
 				om, _ := o.(map[string]any)
@@ -540,11 +659,10 @@ func mapPartURLFile(p *ir.IRPart, url string, filesByRel map[string]ir.IRFile) {
 	if !strings.HasPrefix(url, "file://") {
 		return
 	}
-	fileName := filepath.Base(strings.TrimPrefix(url, "file://"))
-	if fileName == "." || fileName == "/" || fileName == "" {
-		return
-	}
-	relPath := "upload/" + fileName
+	relPath := matchFileURLToRel(url, func(rel string) bool {
+		_, ok := filesByRel[rel]
+		return ok
+	})
 	if f, ok := filesByRel[relPath]; ok {
 		p.FileID = f.ID
 		if p.Name == "" {
@@ -556,6 +674,23 @@ func mapPartURLFile(p *ir.IRPart, url string, filesByRel map[string]ir.IRFile) {
 	}
 }
 
+// matchFileURLToRel maps an absolute file:// URL to a backup-relative path by trying the
+// longest path suffix that known accepts, falling back to upload/<name>.
+func matchFileURLToRel(url string, known func(rel string) bool) string {
+	p := strings.Trim(filepath.ToSlash(strings.TrimPrefix(url, "file://")), "/")
+	segments := strings.Split(p, "/")
+	fileName := segments[len(segments)-1]
+	if fileName == "" || fileName == "." {
+		return ""
+	}
+	for i := 0; i < len(segments)-1; i++ {
+		if rel := strings.Join(segments[i:], "/"); known(rel) {
+			return rel
+		}
+	}
+	return "upload/" + fileName
+}
+
 func inferMediaType(url, typeField string) string {
 	lowType := strings.ToLower(typeField)
 	if strings.Contains(lowType, ".video") {
@@ -631,6 +766,117 @@ func str(v any) string {
 	return s
 }
 
+// CountTableRows returns the row count of a table in the extracted rikka DB, or 0 when the table is absent.
+func CountTableRows(extractedDir, tableName string) (int, error) {
+	db, closeDB, err := openHubDB(extractedDir, "")
+	if err != nil {
+		return 0, err
+	}
+	defer closeDB()
+	exists, err := tableExists(db, tableName)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var count int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(1) FROM `%s`", tableName)).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// archivedColumn returns the ConversationEntity archive flag column, if this schema version has one.
+func archivedColumn(db *sql.DB) (string, error) {
+	rows, err := db.Query("PRAGMA table_info(`ConversationEntity`)")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	found := ""
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return "", err
+		}
+		switch strings.ToLower(name) {
+		case "is_archived", "archived", "is_hidden":
+			if found == "" {
+				found = name
+			}
+		}
+	}
+	return found, rows.Err()
+}
+
+// hasPendingWAL reports a non-empty rikka_hub-wal, the write-ahead log RikkaHub backs
+// up next to the database. SQLite only looks for rikka_hub.db-wal, so it would
+// otherwise be ignored along with any changes not yet checkpointed into the db.
+func hasPendingWAL(dir string) bool {
+	st, err := os.Stat(filepath.Join(dir, "rikka_hub-wal"))
+	return err == nil && st.Mode().IsRegular() && st.Size() > 0
+}
+
+// openHubDB opens rikka_hub.db in dir. With a pending WAL the database and log are
+// copied to a temp dir under tempDir and checkpointed there, so dir itself is never
+// written.
+func openHubDB(dir, tempDir string) (*sql.DB, func(), error) {
+	dbPath := filepath.Join(dir, "rikka_hub.db")
+	if !hasPendingWAL(dir) {
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func() { db.Close() }, nil
+	}
+	tmp, err := os.MkdirTemp(tempDir, "cherrikka-rikka-wal-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	tmpDB := filepath.Join(tmp, "rikka_hub.db")
+	if err := util.CopyFile(dbPath, tmpDB); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := util.CopyFile(filepath.Join(dir, "rikka_hub-wal"), tmpDB+"-wal"); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	db, err := sql.Open("sqlite", tmpDB)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		db.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("apply rikka_hub-wal: %w", err)
+	}
+	return db, func() {
+		db.Close()
+		cleanup()
+	}, nil
+}
+
+// checkDBIntegrity runs PRAGMA quick_check up front, since sql.Open is lazy and a
+// truncated database would otherwise fail somewhere mid-parse.
+func checkDBIntegrity(db *sql.DB) error {
+	results, err := queryStrings(db, `PRAGMA quick_check`)
+	if err != nil {
+		return fmt.Errorf("rikka_hub.db is corrupt: %w", err)
+	}
+	if len(results) == 1 && results[0] == "ok" {
+		return nil
+	}
+	return fmt.Errorf("rikka_hub.db is corrupt: %s", strings.Join(results, "; "))
+}
+
 func tableExists(db *sql.DB, tableName string) (bool, error) {
 	var count int
 	if err := db.QueryRow(`SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = ?`, tableName).Scan(&count); err != nil {
@@ -638,3 +884,20 @@ func tableExists(db *sql.DB, tableName string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+func queryStrings(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
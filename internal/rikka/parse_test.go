@@ -0,0 +1,312 @@
+package rikka
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cherrikka/internal/ir"
+)
+
+func TestParseRikkaPart_PartialToolCall(t *testing.T) {
+	p := parseRikkaPart(map[string]any{
+		"type":     "me.rerere.ai.ui.UIMessagePart.Tool",
+		"toolName": "search_web",
+	}, nil)
+	if p.Type != "tool" || p.Name != "search_web" {
+		t.Fatalf("expected partial tool part, got=%+v", p)
+	}
+	if p.Input != "{}" {
+		t.Fatalf("expected default input, got=%q", p.Input)
+	}
+	if p.Metadata["partial"] != true {
+		t.Fatalf("expected partial metadata, got=%v", p.Metadata)
+	}
+	missing, _ := p.Metadata["partialMissing"].([]any)
+	if len(missing) != 2 {
+		t.Fatalf("expected toolCallId and input reported missing, got=%v", missing)
+	}
+}
+
+func TestParseToIR_NonUploadManagedFolder(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(`{"assistants":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := createRikkaDB(filepath.Join(dir, "rikka_hub.db"), defaultIdentityHash); err != nil {
+		t.Fatal(err)
+	}
+	mediaDir := filepath.Join(dir, "files", "media")
+	if err := os.MkdirAll(mediaDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"photo.png", "stray.txt"} {
+		if err := os.WriteFile(filepath.Join(mediaDir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO managed_files (folder, relative_path, display_name, mime_type, size_bytes, created_at, updated_at) VALUES ('files/media', 'files/media/photo.png', 'photo.png', 'image/png', 9, 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO ConversationEntity (id, title, nodes, create_at, update_at) VALUES ('c1', 'Media', '[]', 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	msg := `[{"id":"m1","role":"USER","parts":[{"type":"me.rerere.ai.ui.UIMessagePart.Image","url":"file:///data/user/0/me.rerere.rikkahub/files/files/media/photo.png"}]}]`
+	if _, err := db.Exec(`INSERT INTO message_node (id, conversation_id, node_index, messages, select_index) VALUES ('n1', 'c1', 0, ?, 0)`, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseToIR(dir)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(parsed.Files) != 2 {
+		t.Fatalf("expected managed and stray files from files/media, got=%d", len(parsed.Files))
+	}
+	part := parsed.Conversations[0].Messages[0].Parts[0]
+	if part.FileID != "managed:1" {
+		t.Fatalf("expected image part linked to managed file, got=%+v", part)
+	}
+	if err := ValidateExtracted(dir); err != nil {
+		t.Fatalf("expected non-upload folder to validate, got=%v", err)
+	}
+}
+
+func TestValidateExtracted_ReportsDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	settings := `{
+		"providers": [
+			{"id": "p1", "models": [{"id": "m1"}]},
+			{"id": "p1", "models": [{"id": "m1"}]}
+		],
+		"assistants": [{"id": "a1"}, {"id": "a1"}]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := createRikkaDB(filepath.Join(dir, "rikka_hub.db"), defaultIdentityHash); err != nil {
+		t.Fatal(err)
+	}
+	err := ValidateExtracted(dir)
+	if err == nil {
+		t.Fatalf("expected duplicate ids to be reported")
+	}
+	for _, want := range []string{
+		"settings.providers duplicate id: p1",
+		"settings.providers.models duplicate id: m1",
+		"settings.assistants duplicate id: a1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestValidateExtracted_ReportsInvalidToolInput(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := createRikkaDB(filepath.Join(dir, "rikka_hub.db"), defaultIdentityHash); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO ConversationEntity (id, title, nodes, create_at, update_at) VALUES ('c1', 'Tools', '[]', 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	msg := `[{"id":"m1","role":"ASSISTANT","parts":[` +
+		`{"type":"me.rerere.ai.ui.UIMessagePart.Tool","toolCallId":"call-1","toolName":"search","input":"{\"q\":"},` +
+		`{"type":"me.rerere.ai.ui.UIMessagePart.Tool","toolCallId":"call-2","toolName":"fetch","input":"{\"url\":\"x\"}"}]}]`
+	if _, err := db.Exec(`INSERT INTO message_node (id, conversation_id, node_index, messages, select_index) VALUES ('n1', 'c1', 0, ?, 0)`, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	err = ValidateExtracted(dir)
+	if err == nil || !strings.Contains(err.Error(), "tool input not valid json: search") {
+		t.Fatalf("expected malformed tool input to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "fetch") {
+		t.Fatalf("valid tool input should not be reported: %v", err)
+	}
+}
+
+func TestParseToIR_TruncatedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "rikka_hub.db")
+	if err := createRikkaDB(dbPath, defaultIdentityHash); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(dbPath, st.Size()/2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseToIR(dir); err == nil || !strings.Contains(err.Error(), "rikka_hub.db is corrupt") {
+		t.Fatalf("expected corrupt database error from parse, got %v", err)
+	}
+	if err := ValidateExtracted(dir); err == nil || !strings.Contains(err.Error(), "rikka_hub.db is corrupt") {
+		t.Fatalf("expected corrupt database error from validate, got %v", err)
+	}
+}
+
+func TestParseToIR_NonObjectSettingsKeepsConversations(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(`[1,2,3]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := createRikkaDB(filepath.Join(dir, "rikka_hub.db"), defaultIdentityHash); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(dir, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO ConversationEntity (id, title, nodes, create_at, update_at) VALUES ('c1', 'Kept', '[]', 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseToIR(dir)
+	if err != nil {
+		t.Fatalf("expected non-object settings.json to be tolerated, got=%v", err)
+	}
+	if len(parsed.Conversations) != 1 || parsed.Conversations[0].Title != "Kept" {
+		t.Fatalf("expected conversation to be recovered, got=%+v", parsed.Conversations)
+	}
+	found := false
+	for _, w := range parsed.Warnings {
+		if strings.Contains(w, "settings.json is not a JSON object") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected non-object settings warning, got=%v", parsed.Warnings)
+	}
+}
+
+func TestBuildSanitizesManagedFileNames(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(src, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in := &ir.BackupIR{Files: []ir.IRFile{
+		{ID: "f1", Name: "../../etc/passwd", RelativeSrc: "upload/../../etc/passwd", SourcePath: src},
+		{ID: "f2", Name: `C:\Users\me\notes.txt`, SourcePath: src},
+	}}
+	out := t.TempDir()
+	if _, err := BuildFromIR(context.Background(), in, out, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(out, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT relative_path, display_name FROM managed_files ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var rel, name string
+		if err := rows.Scan(&rel, &name); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(rel, "upload/") || strings.Contains(rel, "..") || strings.ContainsAny(strings.TrimPrefix(rel, "upload/"), `/\`) {
+			t.Fatalf("unexpected relative_path %q", rel)
+		}
+		names = append(names, name)
+	}
+	if strings.Join(names, ",") != "passwd,notes.txt" {
+		t.Fatalf("unexpected display names: %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(out, "upload", "passwd")); err != nil {
+		t.Fatalf("expected payload under upload/: %v", err)
+	}
+}
+
+func TestParseAppliesPendingWAL(t *testing.T) {
+	in := &ir.BackupIR{Conversations: []ir.IRConversation{{
+		ID:       "c1",
+		Title:    "before wal",
+		Messages: []ir.IRMessage{{ID: "m1", Role: "user", Parts: []ir.IRPart{{Type: "text", Content: "hi"}}}},
+	}}}
+	out := t.TempDir()
+	if _, err := BuildFromIR(context.Background(), in, out, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(out, "rikka_hub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{`PRAGMA journal_mode=WAL`, `PRAGMA wal_autocheckpoint=0`, `UPDATE ConversationEntity SET title = 'after wal'`} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	// snapshot while the connection is open, before the log is checkpointed on close
+	snap := t.TempDir()
+	for src, dst := range map[string]string{"settings.json": "settings.json", "rikka_hub.db": "rikka_hub.db", "rikka_hub.db-wal": "rikka_hub-wal"} {
+		b, err := os.ReadFile(filepath.Join(out, src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(snap, dst), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parsed, err := ParseToIR(snap)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(parsed.Conversations) != 1 || parsed.Conversations[0].Title != "after wal" {
+		t.Fatalf("expected the WAL update to be applied, got=%+v", parsed.Conversations)
+	}
+	found := false
+	for _, w := range parsed.Warnings {
+		if w == "rikka-wal-present:may-contain-uncommitted-data" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected wal warning, got=%v", parsed.Warnings)
+	}
+	if _, err := os.Stat(filepath.Join(snap, "rikka_hub.db-wal")); !os.IsNotExist(err) {
+		t.Fatalf("expected the extracted dir to be left untouched, stat err=%v", err)
+	}
+
+	// the WAL copy goes under the configured temp dir and is removed afterwards
+	scratch := t.TempDir()
+	if _, err := ParseToIRWith(snap, ParseOptions{TempDir: scratch}); err != nil {
+		t.Fatalf("parse with temp dir: %v", err)
+	}
+	if entries, err := os.ReadDir(scratch); err != nil || len(entries) != 0 {
+		t.Fatalf("expected the WAL copy cleaned up from the temp dir, entries=%v err=%v", entries, err)
+	}
+	if _, err := ParseToIRWith(snap, ParseOptions{TempDir: filepath.Join(scratch, "missing")}); err == nil {
+		t.Fatalf("expected the missing temp dir to be used and fail")
+	}
+}
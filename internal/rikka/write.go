@@ -1,6 +1,7 @@
 package rikka
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,12 +14,24 @@ import (
 	guuid "github.com/google/uuid"
 	_ "modernc.org/sqlite"
 
+	"cherrikka/internal/backup"
 	"cherrikka/internal/ir"
 	"cherrikka/internal/mapping"
 	"cherrikka/internal/util"
 )
 
-func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets bool, idMap map[string]string) ([]string, error) {
+func BuildFromIR(ctx context.Context, in *ir.BackupIR, outputDir, templateDir string, redactSecrets bool, idMap map[string]string) ([]string, error) {
+	var redact *util.RedactConfig
+	if redactSecrets {
+		redact = &util.RedactConfig{}
+	}
+	return BuildFromIRTo(ctx, in, outputDir, backup.DirSink{Root: outputDir}, templateDir, redact, idMap)
+}
+
+// BuildFromIRTo is BuildFromIR with attachment payloads sent to sink instead of
+// outputDir; settings.json and the database are still written to outputDir. A nil
+// redact keeps secrets.
+func BuildFromIRTo(ctx context.Context, in *ir.BackupIR, outputDir string, sink backup.FileSink, templateDir string, redact *util.RedactConfig, idMap map[string]string) ([]string, error) {
 	warnings := []string{}
 	if err := util.EnsureDir(filepath.Join(outputDir, "upload")); err != nil {
 		return nil, err
@@ -29,8 +42,8 @@ func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets b
 	settingsBase := loadBaseSettings(in, templateDir)
 	settings, mappingWarnings := mapping.BuildRikkaSettingsFromIR(in, settingsBase)
 	warnings = append(warnings, mappingWarnings...)
-	if redactSecrets {
-		redacted, _ := util.RedactAny(settings).(map[string]any)
+	if redact != nil {
+		redacted, _ := util.RedactAnyWithConfig(settings, *redact).(map[string]any)
 		settings = redacted
 	}
 	settingsJSON, err := json.Marshal(settings)
@@ -61,14 +74,15 @@ func BuildFromIR(in *ir.BackupIR, outputDir, templateDir string, redactSecrets b
 	defer db.Close()
 
 	filePathByID := map[string]string{}
-	fileWarnings, err := materializeFiles(db, outputDir, in.Files, filePathByID, idMap)
+	writtenRel := map[string]struct{}{}
+	fileWarnings, err := materializeFiles(ctx, db, sink, in.Files, filePathByID, writtenRel, idMap)
 	if err != nil {
 		return nil, err
 	}
 	warnings = append(warnings, fileWarnings...)
 	resolveAssistantID := newAssistantResolver(settings)
 	flattenToolCalls := strings.EqualFold(strings.TrimSpace(in.SourceFormat), "cherry")
-	convWarnings, err := writeConversations(db, in.Conversations, filePathByID, idMap, resolveAssistantID, flattenToolCalls)
+	convWarnings, err := writeConversations(ctx, db, in.Conversations, filePathByID, writtenRel, idMap, resolveAssistantID, flattenToolCalls)
 	if err != nil {
 		return nil, err
 	}
@@ -142,18 +156,22 @@ func createRikkaDB(dbPath, identityHash string) error {
 	return nil
 }
 
-func materializeFiles(db *sql.DB, outputDir string, files []ir.IRFile, pathByID map[string]string, idMap map[string]string) ([]string, error) {
+func materializeFiles(ctx context.Context, db *sql.DB, sink backup.FileSink, files []ir.IRFile, pathByID map[string]string, writtenRel map[string]struct{}, idMap map[string]string) ([]string, error) {
 	warnings := []string{}
 	usedRelPath := map[string]struct{}{}
 
 	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		fileID := f.ID
 		if fileID == "" {
 			fileID = util.NewUUID()
 		}
-		ext := f.Ext
+		displayName := util.SanitizeFileName(f.Name, "")
+		ext := util.SanitizeFileExt(f.Ext)
 		if ext == "" {
-			ext = filepath.Ext(f.Name)
+			ext = util.SanitizeFileExt(filepath.Ext(displayName))
 		}
 		relPath := preferredRikkaRelPath(f, ext)
 		if _, exists := usedRelPath[relPath]; exists {
@@ -161,60 +179,69 @@ func materializeFiles(db *sql.DB, outputDir string, files []ir.IRFile, pathByID
 		}
 		usedRelPath[relPath] = struct{}{}
 		fileName := filepath.Base(relPath)
-		fullPath := filepath.Join(outputDir, filepath.FromSlash(relPath))
+		size := int64(0)
 		if f.SourcePath != "" {
-			if err := util.CopyFile(f.SourcePath, fullPath); err != nil {
+			n, err := sink.CopyFile(relPath, f.SourcePath)
+			if err != nil {
 				return nil, err
 			}
+			size = n
 		} else {
-			if err := os.WriteFile(fullPath, nil, 0o644); err != nil {
+			if err := sink.WriteFile(relPath, nil); err != nil {
 				return nil, err
 			}
 			warnings = append(warnings, fmt.Sprintf("file %s missing source payload; created empty placeholder", fileID))
 		}
-		st, _ := os.Stat(fullPath)
-		size := int64(0)
-		if st != nil {
-			size = st.Size()
-		}
 		createdAt := parseMillisOrNow(f.CreatedAt)
 		updatedAt := parseMillisOrNow(f.UpdatedAt)
-		if _, err := db.Exec(`INSERT INTO managed_files (folder, relative_path, display_name, mime_type, size_bytes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-			"upload", relPath, fallbackName(f.Name, fileName), fallbackString(f.MimeType, "application/octet-stream"), size, createdAt, updatedAt,
+		if _, err := db.ExecContext(ctx, `INSERT INTO managed_files (folder, relative_path, display_name, mime_type, size_bytes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			"upload", relPath, fallbackName(displayName, fileName), fallbackString(f.MimeType, "application/octet-stream"), size, createdAt, updatedAt,
 		); err != nil {
 			return nil, err
 		}
 		pathByID[fileID] = absRikkaUploadPath(fileName)
+		writtenRel[relPath] = struct{}{}
 		idMap["file:"+f.ID] = relPath
 	}
 	return dedupeWarnings(warnings), nil
 }
 
 func writeConversations(
+	ctx context.Context,
 	db *sql.DB,
 	convs []ir.IRConversation,
 	filePathByID map[string]string,
+	writtenRel map[string]struct{},
 	idMap map[string]string,
 	resolveAssistantID func(string) string,
 	flattenToolCalls bool,
 ) ([]string, error) {
 	warnings := []string{}
+	archived := 0
+	danglingAlternateParts := 0
 	for _, conv := range convs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if b, _ := conv.Opaque["archived"].(bool); b {
+			archived++
+		}
 		convID := normalizeUUIDOrDeterministic(conv.ID, "conversation:"+conv.ID+":"+conv.Title)
 		idMap["topic:"+conv.ID] = convID
 		created := parseTimeMillis(conv.CreatedAt)
 		updated := parseTimeMillis(conv.UpdatedAt)
 		assistantID := resolveAssistantID(conv.AssistantID)
-		if _, err := db.Exec(`INSERT INTO ConversationEntity (id, assistant_id, title, nodes, create_at, update_at, truncate_index, suggestions, is_pinned) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		truncateIndex, suggestions, isPinned := rikkaConversationState(conv)
+		if _, err := db.ExecContext(ctx, `INSERT INTO ConversationEntity (id, assistant_id, title, nodes, create_at, update_at, truncate_index, suggestions, is_pinned) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 			convID,
 			assistantID,
 			deriveRikkaConversationTitle(conv),
 			"[]",
 			created,
 			updated,
-			-1,
-			"[]",
-			0,
+			truncateIndex,
+			suggestions,
+			isPinned,
 		); err != nil {
 			return nil, err
 		}
@@ -228,13 +255,14 @@ func writeConversations(
 			}
 			nodeID := util.NewUUID()
 			msg := rikkaMessageFromIR(m, filePathByID, flattenToolCalls)
-			msgJSON := util.MustJSON([]any{msg})
-			if _, err := db.Exec(`INSERT INTO message_node (id, conversation_id, node_index, messages, select_index) VALUES (?, ?, ?, ?, ?)`,
+			nodeMessages, selectIndex, dangling := rikkaNodeMessages(m, msg, writtenRel)
+			danglingAlternateParts += dangling
+			if _, err := db.ExecContext(ctx, `INSERT INTO message_node (id, conversation_id, node_index, messages, select_index) VALUES (?, ?, ?, ?, ?)`,
 				nodeID,
 				convID,
 				idx,
-				msgJSON,
-				0,
+				util.MustJSON(nodeMessages),
+				selectIndex,
 			); err != nil {
 				return nil, err
 			}
@@ -243,25 +271,160 @@ func writeConversations(
 			}
 		}
 	}
+	if archived > 0 {
+		warnings = append(warnings, fmt.Sprintf("rikka schema has no archived flag; %d archived conversation(s) written as regular conversations", archived))
+	}
+	if danglingAlternateParts > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d alternate message part(s) referenced files not in the output and were dropped", danglingAlternateParts))
+	}
 	return dedupeWarnings(warnings), nil
 }
 
-func deriveRikkaConversationTitle(conv ir.IRConversation) string {
-	if title := normalizeConversationTitleText(conv.Title); title != "" {
-		return title
+// rikkaNodeMessages restores the alternatives a rikka source kept for this node, with
+// the selected one replaced by the freshly built message so IR edits still apply.
+// Alternate file parts whose upload is not in writtenRel (omitted or dropped since the
+// source was parsed) are removed; the count of removed parts is returned.
+func rikkaNodeMessages(m ir.IRMessage, msg map[string]any, writtenRel map[string]struct{}) ([]any, int, int) {
+	var branches []any
+	switch raw := m.Opaque[OpaqueBranches].(type) {
+	case []map[string]any:
+		for _, b := range raw {
+			branches = append(branches, b)
+		}
+	case []any:
+		branches = raw
+	}
+	selectIndex := opaqueInt(m.Opaque[OpaqueSelectIndex], -1)
+	if len(branches) < 2 || selectIndex < 0 || selectIndex >= len(branches) {
+		return []any{msg}, 0, 0
+	}
+	out := append([]any{}, branches...)
+	dangling := 0
+	for i, b := range out {
+		if i == selectIndex {
+			continue
+		}
+		var n int
+		out[i], n = dropDanglingFileParts(b, writtenRel)
+		dangling += n
 	}
-	if title := deriveTitleFromMessages(conv.Messages, true); title != "" {
-		return title
+	out[selectIndex] = msg
+	return out, selectIndex, dangling
+}
+
+func dropDanglingFileParts(branch any, writtenRel map[string]struct{}) (any, int) {
+	bm, ok := branch.(map[string]any)
+	if !ok {
+		return branch, 0
+	}
+	parts, _ := bm["parts"].([]any)
+	kept := make([]any, 0, len(parts))
+	for _, item := range parts {
+		pm, _ := item.(map[string]any)
+		if url := str(pm["url"]); strings.HasPrefix(url, "file://") {
+			rel := matchFileURLToRel(url, func(rel string) bool {
+				_, ok := writtenRel[rel]
+				return ok
+			})
+			if _, ok := writtenRel[rel]; !ok {
+				continue
+			}
+		}
+		kept = append(kept, item)
+	}
+	if len(kept) == len(parts) {
+		return branch, 0
+	}
+	dropped := len(parts) - len(kept)
+	if len(kept) == 0 {
+		kept = append(kept, map[string]any{"type": "me.rerere.ai.ui.UIMessagePart.Text", "text": ""})
 	}
-	if title := deriveTitleFromMessages(conv.Messages, false); title != "" {
+	out := make(map[string]any, len(bm))
+	for k, v := range bm {
+		out[k] = v
+	}
+	out["parts"] = kept
+	return out, dropped
+}
+
+// opaqueInt reads an integer kept in Opaque, which may have been decoded from JSON.
+func opaqueInt(v any, fallback int) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return fallback
+}
+
+// rikkaConversationState returns truncate_index, suggestions and is_pinned as parsed
+// from a rikka source, or the schema defaults for conversations from elsewhere.
+func rikkaConversationState(conv ir.IRConversation) (int, string, int) {
+	suggestions := "[]"
+	if s, ok := conv.Opaque["suggestions"].(string); ok && json.Valid([]byte(s)) {
+		suggestions = s
+	}
+	isPinned := 0
+	if opaqueInt(conv.Opaque["isPinned"], 0) != 0 {
+		isPinned = 1
+	}
+	truncateIndex := opaqueInt(conv.Opaque["truncateIndex"], -1)
+	if truncateIndex >= len(conv.Messages) {
+		// messages were dropped or sliced away since the source was parsed
+		truncateIndex = -1
+	}
+	return truncateIndex, suggestions, isPinned
+}
+
+const (
+	TitleStrategyKeep           = "keep"
+	TitleStrategyFirstUser      = "first-user"
+	TitleStrategyFirstMessage   = "first-message"
+	TitleStrategyFirstAssistant = "first-assistant"
+)
+
+func deriveRikkaConversationTitle(conv ir.IRConversation) string {
+	if title := DeriveConversationTitle(conv, TitleStrategyKeep); title != "" {
 		return title
 	}
 	return "Imported Conversation"
 }
 
-func deriveTitleFromMessages(messages []ir.IRMessage, preferUser bool) string {
+// DeriveConversationTitle picks a conversation title according to strategy
+// (keep|first-user|first-message|first-assistant; empty means keep). The other
+// sources are used as fallbacks, and "" is returned when none yields text.
+func DeriveConversationTitle(conv ir.IRConversation, strategy string) string {
+	existing := func() string { return normalizeConversationTitleText(conv.Title) }
+	fromRole := func(role string) func() string {
+		return func() string { return deriveTitleFromMessages(conv.Messages, role) }
+	}
+	var order []func() string
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case TitleStrategyFirstUser:
+		order = []func() string{fromRole("user"), existing, fromRole("")}
+	case TitleStrategyFirstMessage:
+		order = []func() string{fromRole(""), existing}
+	case TitleStrategyFirstAssistant:
+		order = []func() string{fromRole("assistant"), existing, fromRole("")}
+	default:
+		order = []func() string{existing, fromRole("user"), fromRole("")}
+	}
+	for _, next := range order {
+		if title := next(); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// deriveTitleFromMessages returns the first usable text from messages with the
+// given role, or from any message when role is empty.
+func deriveTitleFromMessages(messages []ir.IRMessage, role string) string {
 	for _, m := range messages {
-		if preferUser && !strings.EqualFold(strings.TrimSpace(m.Role), "user") {
+		if role != "" && !strings.EqualFold(strings.TrimSpace(m.Role), role) {
 			continue
 		}
 		for _, p := range m.Parts {
@@ -466,14 +629,12 @@ func pickRelPath(v any) string {
 	if s == "" {
 		return ""
 	}
-	if strings.HasPrefix(s, "upload/") {
-		return s
-	}
-	base := filepath.Base(s)
-	if base == "." || base == "/" || base == "" {
+	// keep only the last element so names like "upload/../../x" cannot leave upload/
+	base := util.SanitizeFileName(strings.TrimPrefix(s, "upload/"), "")
+	if base == "" {
 		return ""
 	}
-	return filepath.ToSlash(filepath.Join("upload", base))
+	return "upload/" + base
 }
 
 func asMetaMap(v any) map[string]any {
@@ -485,10 +646,7 @@ func asMetaMap(v any) map[string]any {
 }
 
 func parseMillisOrNow(v string) int64 {
-	if strings.TrimSpace(v) == "" {
-		return time.Now().UnixMilli()
-	}
-	if t, err := time.Parse(time.RFC3339, v); err == nil {
+	if t, ok := util.ParseTimestamp(v); ok {
 		return t.UnixMilli()
 	}
 	return time.Now().UnixMilli()
@@ -594,14 +752,10 @@ func normalizeRikkaRole(role string) string {
 }
 
 func parseTimeMillis(v string) int64 {
-	if v == "" {
-		return time.Now().UnixMilli()
-	}
-	t, err := time.Parse(time.RFC3339, v)
-	if err != nil {
-		return time.Now().UnixMilli()
+	if t, ok := util.ParseTimestamp(v); ok {
+		return t.UnixMilli()
 	}
-	return t.UnixMilli()
+	return time.Now().UnixMilli()
 }
 
 func fallbackName(v, d string) string {
@@ -46,6 +46,34 @@ func TestDeriveRikkaConversationTitle_FallbackImported(t *testing.T) {
 	}
 }
 
+func TestDeriveConversationTitle_Strategies(t *testing.T) {
+	conv := ir.IRConversation{
+		Title: "New Topic",
+		Messages: []ir.IRMessage{
+			{Role: "system", Parts: []ir.IRPart{{Type: "text", Content: "system prompt"}}},
+			{Role: "user", Parts: []ir.IRPart{{Type: "text", Content: "how do I install ollama"}}},
+			{Role: "assistant", Parts: []ir.IRPart{{Type: "text", Content: "Installing Ollama on Linux"}}},
+		},
+	}
+	cases := map[string]string{
+		"":                          "New Topic",
+		TitleStrategyKeep:           "New Topic",
+		TitleStrategyFirstUser:      "how do I install ollama",
+		TitleStrategyFirstMessage:   "system prompt",
+		TitleStrategyFirstAssistant: "Installing Ollama on Linux",
+	}
+	for strategy, want := range cases {
+		if got := DeriveConversationTitle(conv, strategy); got != want {
+			t.Fatalf("strategy %q: expected %q, got=%q", strategy, want, got)
+		}
+	}
+
+	noAssistant := ir.IRConversation{Title: "Kept", Messages: conv.Messages[:2]}
+	if got := DeriveConversationTitle(noAssistant, TitleStrategyFirstAssistant); got != "Kept" {
+		t.Fatalf("expected fallback to existing title, got=%q", got)
+	}
+}
+
 func TestNormalizeConversationTitleText_TruncatesLongText(t *testing.T) {
 	long := strings.Repeat("a", 120)
 	got := normalizeConversationTitleText(long)
@@ -6,8 +6,12 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 func EnsureDir(path string) error {
@@ -90,3 +94,45 @@ func ListFiles(root string) ([]string, error) {
 	sort.Strings(files)
 	return files, nil
 }
+
+const maxFileNameBytes = 255
+
+// SanitizeFileName reduces a display name to a single safe path element: anything up
+// to the last / or \ is dropped along with control characters, overlong names are cut
+// while keeping their extension, and dot-only or empty results become fallback.
+func SanitizeFileName(name, fallback string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if strings.Trim(name, ".") == "" {
+		return fallback
+	}
+	if len(name) > maxFileNameBytes {
+		ext := SanitizeFileExt(path.Ext(name))
+		stem := strings.TrimSuffix(name, ext)
+		stem = strings.ToValidUTF8(stem[:maxFileNameBytes-len(ext)], "")
+		name = stem + ext
+	}
+	return name
+}
+
+// SanitizeFileExt returns ext when it is a plain ".xyz" extension and "" otherwise.
+func SanitizeFileExt(ext string) string {
+	ext = strings.TrimSpace(ext)
+	if len(ext) < 2 || len(ext) > 16 || ext[0] != '.' {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return ""
+		}
+	}
+	return ext
+}
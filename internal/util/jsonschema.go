@@ -0,0 +1,105 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// JSONSchemaFor builds a draft 2020-12 JSON Schema from the json tags of v's type.
+func JSONSchemaFor(v any, title string) map[string]any {
+	out := schemaForType(reflect.TypeOf(v), map[reflect.Type]bool{})
+	out["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	if title != "" {
+		out["title"] = title
+	}
+	return out
+}
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]any{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		properties := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitEmpty, skip := parseJSONTag(field)
+			if skip {
+				continue
+			}
+			prop := schemaForType(field.Type, visiting)
+			if !omitEmpty {
+				required = append(required, name)
+				// encoding/json emits null for nil slices, maps and pointers.
+				switch field.Type.Kind() {
+				case reflect.Slice, reflect.Map, reflect.Pointer:
+					if typ, ok := prop["type"].(string); ok {
+						prop["type"] = []string{typ, "null"}
+					}
+				}
+			}
+			properties[name] = prop
+		}
+		out := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			out["required"] = required
+		}
+		return out
+	default:
+		return map[string]any{}
+	}
+}
+
+func parseJSONTag(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
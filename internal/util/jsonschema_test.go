@@ -0,0 +1,37 @@
+package util
+
+import "testing"
+
+type schemaSample struct {
+	Name    string            `json:"name"`
+	Count   int               `json:"count,omitempty"`
+	Tags    []string          `json:"tags"`
+	Extra   map[string]any    `json:"extra,omitempty"`
+	Child   *schemaSample     `json:"child,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Ignored string            `json:"-"`
+}
+
+func TestJSONSchemaFor(t *testing.T) {
+	schema := JSONSchemaFor(schemaSample{}, "sample")
+	if schema["type"] != "object" || schema["title"] != "sample" {
+		t.Fatalf("unexpected root schema: %#v", schema)
+	}
+	props := schema["properties"].(map[string]any)
+	if _, ok := props["Ignored"]; ok {
+		t.Fatalf("json:\"-\" fields should be skipped")
+	}
+	if got := props["count"].(map[string]any)["type"]; got != "integer" {
+		t.Fatalf("expected integer count, got=%v", got)
+	}
+	if got := props["tags"].(map[string]any)["items"].(map[string]any)["type"]; got != "string" {
+		t.Fatalf("expected string tags items, got=%v", got)
+	}
+	if got := props["child"].(map[string]any)["type"]; got != "object" {
+		t.Fatalf("expected recursive child to collapse to object, got=%v", got)
+	}
+	required := schema["required"].([]string)
+	if len(required) != 2 || required[0] != "name" || required[1] != "tags" {
+		t.Fatalf("unexpected required fields: %v", required)
+	}
+}
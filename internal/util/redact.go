@@ -12,13 +12,35 @@ var secretFieldTokens = []string{
 	"secretaccesskey",
 }
 
+// RedactConfig adjusts which keys are redacted. ExtraTokens are matched like the
+// built-in tokens (case-insensitive substring); AllowKeys are exact key names
+// (case-insensitive) that are never redacted, even when a token matches.
+type RedactConfig struct {
+	ExtraTokens []string
+	AllowKeys   []string
+}
+
 func ShouldRedactKey(k string) bool {
+	return RedactConfig{}.ShouldRedactKey(k)
+}
+
+func (c RedactConfig) ShouldRedactKey(k string) bool {
 	k = strings.ToLower(strings.TrimSpace(k))
+	for _, allowed := range c.AllowKeys {
+		if k == strings.ToLower(strings.TrimSpace(allowed)) {
+			return false
+		}
+	}
 	for _, token := range secretFieldTokens {
 		if strings.Contains(k, token) {
 			return true
 		}
 	}
+	for _, token := range c.ExtraTokens {
+		if token = strings.ToLower(strings.TrimSpace(token)); token != "" && strings.Contains(k, token) {
+			return true
+		}
+	}
 	return false
 }
 
@@ -30,11 +52,15 @@ func RedactString(v string) string {
 }
 
 func RedactAny(v any) any {
+	return RedactAnyWithConfig(v, RedactConfig{})
+}
+
+func RedactAnyWithConfig(v any, cfg RedactConfig) any {
 	switch t := v.(type) {
 	case map[string]any:
 		out := make(map[string]any, len(t))
 		for k, val := range t {
-			if ShouldRedactKey(k) {
+			if cfg.ShouldRedactKey(k) {
 				s, ok := val.(string)
 				if ok {
 					out[k] = RedactString(s)
@@ -43,13 +69,13 @@ func RedactAny(v any) any {
 				}
 				continue
 			}
-			out[k] = RedactAny(val)
+			out[k] = RedactAnyWithConfig(val, cfg)
 		}
 		return out
 	case []any:
 		out := make([]any, len(t))
 		for i, val := range t {
-			out[i] = RedactAny(val)
+			out[i] = RedactAnyWithConfig(val, cfg)
 		}
 		return out
 	default:
@@ -23,3 +23,59 @@ func TestRedactAny(t *testing.T) {
 		t.Fatalf("safe field should be unchanged")
 	}
 }
+
+func TestRedactAnyWithConfig(t *testing.T) {
+	in := map[string]any{
+		"bearer":    "b1",
+		"x_auth":    "a1",
+		"apiKey":    "abc",
+		"maxTokens": 4096,
+		"name":      "ok",
+	}
+	out := RedactAnyWithConfig(in, RedactConfig{ExtraTokens: []string{"Bearer", "x_auth"}, AllowKeys: []string{"maxtokens"}}).(map[string]any)
+	if out["bearer"] != "***REDACTED***" || out["x_auth"] != "***REDACTED***" {
+		t.Fatalf("extra tokens should be redacted, got=%v", out)
+	}
+	if out["apiKey"] != "***REDACTED***" {
+		t.Fatalf("built-in tokens should still be redacted")
+	}
+	if out["maxTokens"] != 4096 || out["name"] != "ok" {
+		t.Fatalf("allowlisted and safe keys should be unchanged, got=%v", out)
+	}
+	if def := RedactAny(in).(map[string]any); def["bearer"] != "b1" {
+		t.Fatalf("default config should not redact extra tokens")
+	}
+}
+
+func TestExternalizeSecretsRoundTrip(t *testing.T) {
+	in := map[string]any{
+		"providers": []any{
+			map[string]any{"name": "a", "apiKey": "sk-1"},
+			map[string]any{"name": "b", "apiKey": "sk-1"},
+		},
+		"webdav": map[string]any{"password": "pw"},
+	}
+	secrets := map[string]string{}
+	out := ExternalizeSecrets(in, "config", secrets).(map[string]any)
+	if len(secrets) != 2 {
+		t.Fatalf("expected 2 distinct secrets, got=%v", secrets)
+	}
+	first := out["providers"].([]any)[0].(map[string]any)["apiKey"]
+	if first != SecretPlaceholder("config.providers.0.apiKey") {
+		t.Fatalf("unexpected placeholder: %v", first)
+	}
+	if second := out["providers"].([]any)[1].(map[string]any)["apiKey"]; second != first {
+		t.Fatalf("repeated secret should share a placeholder, got=%v", second)
+	}
+
+	resolved, missing := ResolveSecretPlaceholders(out, secrets)
+	if len(missing) != 0 {
+		t.Fatalf("unexpected missing secrets: %v", missing)
+	}
+	if resolved.(map[string]any)["webdav"].(map[string]any)["password"] != "pw" {
+		t.Fatalf("password placeholder should resolve")
+	}
+	if _, missing := ResolveSecretPlaceholders(out, map[string]string{}); len(missing) != 2 {
+		t.Fatalf("expected unresolved keys to be reported, got=%v", missing)
+	}
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Externalized secrets are replaced by "${cherrikka-secret:<key>}", where key is the
+// dotted path of the first field that held the value.
+const (
+	secretPlaceholderPrefix = "${cherrikka-secret:"
+	secretPlaceholderSuffix = "}"
+)
+
+func SecretPlaceholder(key string) string {
+	return secretPlaceholderPrefix + key + secretPlaceholderSuffix
+}
+
+// ParseSecretPlaceholder returns the key of a placeholder produced by SecretPlaceholder.
+func ParseSecretPlaceholder(v string) (string, bool) {
+	if !strings.HasPrefix(v, secretPlaceholderPrefix) || !strings.HasSuffix(v, secretPlaceholderSuffix) {
+		return "", false
+	}
+	key := strings.TrimSuffix(strings.TrimPrefix(v, secretPlaceholderPrefix), secretPlaceholderSuffix)
+	return key, key != ""
+}
+
+// ExternalizeSecrets moves secret string values into secrets and returns a copy of v
+// holding placeholders instead. Repeated values share the key of their first occurrence.
+func ExternalizeSecrets(v any, path string, secrets map[string]string) any {
+	byValue := make(map[string]string, len(secrets))
+	for key, value := range secrets {
+		byValue[value] = key
+	}
+	return externalizeSecrets(v, path, secrets, byValue)
+}
+
+func externalizeSecrets(v any, path string, secrets, byValue map[string]string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make(map[string]any, len(t))
+		for _, k := range keys {
+			child := joinSecretPath(path, k)
+			if s, ok := t[k].(string); ok && ShouldRedactKey(k) && s != "" {
+				if _, isPlaceholder := ParseSecretPlaceholder(s); !isPlaceholder {
+					key, seen := byValue[s]
+					if !seen {
+						key = child
+						secrets[key] = s
+						byValue[s] = key
+					}
+					out[k] = SecretPlaceholder(key)
+					continue
+				}
+			}
+			out[k] = externalizeSecrets(t[k], child, secrets, byValue)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = externalizeSecrets(val, joinSecretPath(path, strconv.Itoa(i)), secrets, byValue)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// ResolveSecretPlaceholders substitutes placeholders from secrets and reports keys it could not resolve.
+func ResolveSecretPlaceholders(v any, secrets map[string]string) (any, []string) {
+	missing := map[string]struct{}{}
+	out := resolveSecretPlaceholders(v, secrets, missing)
+	keys := make([]string, 0, len(missing))
+	for key := range missing {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return out, keys
+}
+
+func resolveSecretPlaceholders(v any, secrets map[string]string, missing map[string]struct{}) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[k] = resolveSecretPlaceholders(val, secrets, missing)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = resolveSecretPlaceholders(val, secrets, missing)
+		}
+		return out
+	case string:
+		key, ok := ParseSecretPlaceholder(t)
+		if !ok {
+			return t
+		}
+		if secret, found := secrets[key]; found {
+			return secret
+		}
+		missing[key] = struct{}{}
+		return t
+	default:
+		return v
+	}
+}
+
+func joinSecretPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BuildTime returns the timestamp stamped into generated backups. It honors
+// SOURCE_DATE_EPOCH (unix seconds) so repeated conversions can be byte-identical.
+func BuildTime() time.Time {
+	if raw := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH")); raw != "" {
+		if sec, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+	return time.Now().UTC()
+}
+
+// TimestampLayout is the canonical IR timestamp: UTC RFC3339 with fixed milliseconds,
+// matching JavaScript's toISOString, so IR timestamps also sort as strings.
+const TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// zonelessLayouts are accepted without an offset and read as UTC; rikka stores
+// LocalDateTime strings this way.
+var zonelessLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// ParseTimestamp reads the timestamp shapes found in backups: RFC3339 with any
+// precision, zoneless date-times, and epoch seconds or milliseconds (integer or
+// fractional strings; values above 1e11 are millis).
+func ParseTimestamp(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t.UTC(), true
+	}
+	for _, layout := range zonelessLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC(), true
+		}
+	}
+	if n, err := strconv.ParseFloat(raw, 64); err == nil && n > 0 {
+		if n > 1e11 {
+			return time.UnixMilli(int64(n)).UTC(), true
+		}
+		return time.UnixMilli(int64(n * 1000)).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// NormalizeTimestamp rewrites raw in TimestampLayout. Unparseable values are returned
+// unchanged so nothing is lost.
+func NormalizeTimestamp(raw string) string {
+	if t, ok := ParseTimestamp(raw); ok {
+		return t.Format(TimestampLayout)
+	}
+	return raw
+}
+
+// LoadTimeZone resolves an IANA zone name, "Local", "UTC" or a fixed "+08:00" offset.
+func LoadTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+	if len(name) == 6 && (name[0] == '+' || name[0] == '-') && name[3] == ':' {
+		if t, err := time.Parse("-07:00", name); err == nil {
+			_, offset := t.Zone()
+			return time.FixedZone("UTC"+name, offset), nil
+		}
+	}
+	return time.LoadLocation(name)
+}
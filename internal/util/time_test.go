@@ -0,0 +1,36 @@
+package util
+
+import "testing"
+
+func TestNormalizeTimestampMixedFormats(t *testing.T) {
+	cases := map[string]string{
+		"2024-03-01T10:00:00Z":        "2024-03-01T10:00:00.000Z",
+		"2024-03-01T18:00:00.5+08:00": "2024-03-01T10:00:00.500Z",
+		"2024-03-01T10:00:00.123456":  "2024-03-01T10:00:00.123Z",
+		"2024-03-01 10:00:00":         "2024-03-01T10:00:00.000Z",
+		"1709287200000":               "2024-03-01T10:00:00.000Z",
+		"1709287200":                  "2024-03-01T10:00:00.000Z",
+		"1709287200.25":               "2024-03-01T10:00:00.250Z",
+		"not a time":                  "not a time",
+		"":                            "",
+	}
+	for in, want := range cases {
+		if got := NormalizeTimestamp(in); got != want {
+			t.Errorf("NormalizeTimestamp(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoadTimeZoneFixedOffset(t *testing.T) {
+	loc, err := LoadTimeZone("+08:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, _ := ParseTimestamp("2024-03-01T10:00:00Z")
+	if got := ts.In(loc).Format("15:04 -07:00"); got != "18:00 +08:00" {
+		t.Fatalf("unexpected offset rendering: %s", got)
+	}
+	if _, err := LoadTimeZone("Nowhere/City"); err == nil {
+		t.Fatalf("expected unknown zone to fail")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 // NewUUID returns an RFC 4122 v4 UUID string.
@@ -17,3 +18,26 @@ func NewUUID() string {
 	h := hex.EncodeToString(b)
 	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
 }
+
+// DuplicateIDIssues reports every id that occurs more than once, in first-seen order.
+func DuplicateIDIssues(scope string, ids []string) []string {
+	counts := map[string]int{}
+	order := []string{}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if counts[id] == 0 {
+			order = append(order, id)
+		}
+		counts[id]++
+	}
+	issues := []string{}
+	for _, id := range order {
+		if counts[id] > 1 {
+			issues = append(issues, fmt.Sprintf("%s duplicate id: %s (%d occurrences)", scope, id, counts[id]))
+		}
+	}
+	return issues
+}
@@ -1,8 +1,11 @@
 package web
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -10,27 +13,64 @@ import (
 	"strings"
 
 	"cherrikka/internal/app"
+	"cherrikka/internal/backup"
 	"cherrikka/internal/util"
 )
 
-func Serve(listen string) error {
+// Uploads are untrusted, so extraction is always capped; these apply when
+// ServeOptions leaves a limit at 0.
+const (
+	defaultMaxExtractBytes = int64(4 << 30)
+	defaultMaxExtractFiles = int64(200000)
+)
+
+type ServeOptions struct {
+	Listen          string
+	AllowedOrigins  []string // defaults to "*"
+	MaxExtractBytes int64    // decompressed bytes per uploaded zip; 0 uses 4 GiB
+	MaxExtractFiles int64    // file entries per uploaded zip; 0 uses 200000
+}
+
+func Serve(opts ServeOptions) error {
+	limits := app.ExtractLimits{MaxBytes: opts.MaxExtractBytes, MaxFiles: opts.MaxExtractFiles}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = defaultMaxExtractBytes
+	}
+	if limits.MaxFiles <= 0 {
+		limits.MaxFiles = defaultMaxExtractFiles
+	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", serveIndex)
-	mux.HandleFunc("/api/health", func(w http.ResponseWriter, _ *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
-	})
-	mux.HandleFunc("/api/inspect", handleInspect)
-	mux.HandleFunc("/api/validate", handleValidate)
-	mux.HandleFunc("/api/convert", handleConvert)
+	mux.HandleFunc("/api/health", handleHealth)
+	mux.HandleFunc("/api/inspect", func(w http.ResponseWriter, r *http.Request) { handleInspect(w, r, limits) })
+	mux.HandleFunc("/api/validate", func(w http.ResponseWriter, r *http.Request) { handleValidate(w, r, limits) })
+	mux.HandleFunc("/api/convert", func(w http.ResponseWriter, r *http.Request) { handleConvert(w, r, limits) })
+	mux.HandleFunc("/api/convert/stream", func(w http.ResponseWriter, r *http.Request) { handleConvertStream(w, r, limits) })
 
 	s := &http.Server{
-		Addr:    listen,
-		Handler: withCORS(mux),
+		Addr:    opts.Listen,
+		Handler: withCORS(mux, opts.AllowedOrigins),
 	}
 	return s.ListenAndServe()
 }
 
-func handleInspect(w http.ResponseWriter, r *http.Request) {
+// webCapabilities lists the optional endpoints and form fields this server accepts, so
+// a front-end can hide options an older server would ignore.
+var webCapabilities = []string{"convert-stream", "multi-file-merge"}
+
+func handleHealth(w http.ResponseWriter, _ *http.Request) {
+	info := app.CurrentBuildInfo()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":            true,
+		"version":       info.Version,
+		"schemaVersion": info.SchemaVersion,
+		"sourceFormats": info.SourceFormats,
+		"targetFormats": info.TargetFormats,
+		"capabilities":  webCapabilities,
+	})
+}
+
+func handleInspect(w http.ResponseWriter, r *http.Request, limits app.ExtractLimits) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -42,15 +82,15 @@ func handleInspect(w http.ResponseWriter, r *http.Request) {
 	}
 	defer cleanup()
 
-	res, err := app.Inspect(inputPath)
+	res, err := app.InspectContext(r.Context(), inputPath, app.InspectOptions{ExtractLimits: limits})
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeJSON(w, http.StatusBadRequest, errorBody(err))
 		return
 	}
 	writeJSON(w, http.StatusOK, res)
 }
 
-func handleValidate(w http.ResponseWriter, r *http.Request) {
+func handleValidate(w http.ResponseWriter, r *http.Request, limits app.ExtractLimits) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -62,75 +102,185 @@ func handleValidate(w http.ResponseWriter, r *http.Request) {
 	}
 	defer cleanup()
 
-	res, err := app.Validate(inputPath)
+	res, err := app.ValidateContext(r.Context(), inputPath, app.ValidateOptions{
+		VerifyHashes:  r.FormValue("verifyHashes") == "true",
+		ExtractLimits: limits,
+	})
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeJSON(w, http.StatusBadRequest, errorBody(err))
 		return
 	}
 	writeJSON(w, http.StatusOK, res)
 }
 
-func handleConvert(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+func handleConvert(w http.ResponseWriter, r *http.Request, limits app.ExtractLimits) {
+	opts, cleanup, ok := prepareConvert(w, r, limits)
+	if !ok {
 		return
 	}
-	if err := r.ParseMultipartForm(200 << 20); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+	defer cleanup()
+
+	manifest, err := app.ConvertContext(r.Context(), opts)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody(err))
 		return
 	}
 
-	inputPath, cleanup, err := saveUploadField(r, "file")
+	b, err := os.ReadFile(opts.OutputPath)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	mb, _ := json.Marshal(manifest)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=converted.zip")
+	w.Header().Set("X-Cherrikka-Manifest", string(mb))
+	_, _ = w.Write(b)
+}
+
+// handleConvertStream takes the same form as handleConvert but answers with
+// Server-Sent Events: "progress" events while converting, then one "done" event
+// carrying the manifest and the base64 zip, or an "error" event.
+func handleConvertStream(w http.ResponseWriter, r *http.Request, limits app.ExtractLimits) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "streaming is not supported"})
+		return
+	}
+	opts, cleanup, ok := prepareConvert(w, r, limits)
+	if !ok {
 		return
 	}
 	defer cleanup()
 
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	opts.Progress = func(stage string, done, total int) {
+		writeEvent(w, flusher, "progress", map[string]any{"stage": stage, "done": done, "total": total})
+	}
+
+	manifest, err := app.ConvertContext(r.Context(), opts)
+	if err != nil {
+		writeEvent(w, flusher, "error", errorBody(err))
+		return
+	}
+	b, err := os.ReadFile(opts.OutputPath)
+	if err != nil {
+		writeEvent(w, flusher, "error", map[string]any{"error": err.Error()})
+		return
+	}
+	writeEvent(w, flusher, "done", map[string]any{"manifest": manifest, "zip": base64.StdEncoding.EncodeToString(b)})
+}
+
+// prepareConvert saves the uploads and reads the convert form. On failure it has
+// already written the error response and returns ok=false.
+func prepareConvert(w http.ResponseWriter, r *http.Request, limits app.ExtractLimits) (app.ConvertOptions, func(), bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return app.ConvertOptions{}, nil, false
+	}
+	if err := r.ParseMultipartForm(200 << 20); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return app.ConvertOptions{}, nil, false
+	}
+
+	cleanups := []func(){}
+	cleanup := func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+	fail := func(status int, err error) (app.ConvertOptions, func(), bool) {
+		cleanup()
+		writeJSON(w, status, map[string]any{"error": err.Error()})
+		return app.ConvertOptions{}, nil, false
+	}
+
+	uploads := r.MultipartForm.File["file"]
+	if len(uploads) == 0 {
+		return fail(http.StatusBadRequest, http.ErrMissingFile)
+	}
+	inputPaths := make([]string, 0, len(uploads))
+	sourceNames := make([]string, 0, len(uploads))
+	for _, hdr := range uploads {
+		path, inputCleanup, err := saveUpload(hdr)
+		if err != nil {
+			return fail(http.StatusBadRequest, err)
+		}
+		cleanups = append(cleanups, inputCleanup)
+		inputPaths = append(inputPaths, path)
+		// uploads are saved as input.zip, so merge tags come from the browser's file name
+		base := filepath.Base(hdr.Filename)
+		sourceNames = append(sourceNames, strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+
 	templatePath := ""
-	tmplCleanup := func() {}
 	if hasFile(r, "template") {
+		var tmplCleanup func()
+		var err error
 		templatePath, tmplCleanup, err = saveUploadField(r, "template")
 		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
-			return
+			return fail(http.StatusBadRequest, err)
 		}
-		defer tmplCleanup()
+		cleanups = append(cleanups, tmplCleanup)
 	}
 
 	outputTmpDir, err := os.MkdirTemp("", "cherrikka-web-out-*")
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
-		return
+		return fail(http.StatusInternalServerError, err)
 	}
-	defer os.RemoveAll(outputTmpDir)
+	cleanups = append(cleanups, func() { os.RemoveAll(outputTmpDir) })
 
 	outputZip := filepath.Join(outputTmpDir, "converted.zip")
 	redact, _ := strconv.ParseBool(r.FormValue("redact"))
+	allowSameFormat, _ := strconv.ParseBool(r.FormValue("allowSameFormat"))
+	dropEmpty := true
+	if v, err := strconv.ParseBool(r.FormValue("dropEmptyMessages")); err == nil {
+		dropEmpty = v
+	}
+	verifyOutput := true
+	if v, err := strconv.ParseBool(r.FormValue("verifyOutput")); err == nil {
+		verifyOutput = v
+	}
+	includeIDMap := true
+	if v, err := strconv.ParseBool(r.FormValue("includeIdMap")); err == nil {
+		includeIDMap = v
+	}
+	embedRaw := true
+	if v, err := strconv.ParseBool(r.FormValue("embedRawSources")); err == nil {
+		embedRaw = v
+	}
+	configSourceIndex := 0
+	if v := strings.TrimSpace(r.FormValue("configSourceIndex")); v != "" {
+		if configSourceIndex, err = strconv.Atoi(v); err != nil {
+			return fail(http.StatusBadRequest, errors.New("configSourceIndex must be a number"))
+		}
+	}
 	opts := app.ConvertOptions{
-		InputPath:     inputPath,
-		OutputPath:    outputZip,
-		From:          fallback(r.FormValue("from"), "auto"),
-		To:            fallback(r.FormValue("to"), "cherry"),
-		TemplatePath:  templatePath,
-		RedactSecrets: redact,
-	}
-	manifest, err := app.Convert(opts)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
-		return
+		InputPaths:        inputPaths,
+		OutputPath:        outputZip,
+		From:              fallback(r.FormValue("from"), "auto"),
+		To:                fallback(r.FormValue("to"), "cherry"),
+		TemplatePath:      templatePath,
+		RedactSecrets:     redact,
+		KeepEmptyMessages: !dropEmpty,
+		OmitIDMap:         !includeIDMap,
+		OmitRawSources:    !embedRaw,
+		SkipVerifyOutput:  !verifyOutput,
+		AllowSameFormat:   allowSameFormat,
+		ConfigPrecedence:  r.FormValue("configPrecedence"),
+		ConfigSourceIndex: configSourceIndex,
+		ExtractLimits:     limits,
 	}
-
-	b, err := os.ReadFile(outputZip)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
-		return
+	if len(inputPaths) > 1 {
+		opts.SourceNames = sourceNames
 	}
-	mb, _ := json.Marshal(manifest)
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", "attachment; filename=converted.zip")
-	w.Header().Set("X-Cherrikka-Manifest", string(mb))
-	_, _ = w.Write(b)
+	if strings.EqualFold(opts.To, app.TargetIR) {
+		return fail(http.StatusBadRequest, errors.New("to=ir is only available from the CLI"))
+	}
+	return opts, cleanup, true
 }
 
 func saveUploadToTemp(r *http.Request, field string) (string, func(), error) {
@@ -145,6 +295,15 @@ func saveUploadField(r *http.Request, field string) (string, func(), error) {
 	if err != nil {
 		return "", nil, err
 	}
+	f.Close()
+	return saveUpload(hdr)
+}
+
+func saveUpload(hdr *multipart.FileHeader) (string, func(), error) {
+	f, err := hdr.Open()
+	if err != nil {
+		return "", nil, err
+	}
 	defer f.Close()
 
 	tmpDir, err := os.MkdirTemp("", "cherrikka-upload-*")
@@ -181,15 +340,55 @@ func hasFile(r *http.Request, field string) bool {
 	return false
 }
 
+// errorBody tags unreadable uploads so the UI can ask for a re-upload instead of
+// showing a generic conversion failure.
+func errorBody(err error) map[string]any {
+	body := map[string]any{"error": err.Error()}
+	if errors.Is(err, backup.ErrCorruptArchive) {
+		body["code"] = "corrupt-archive"
+		body["hint"] = "the upload looks incomplete; re-download the backup and try again"
+	}
+	if errors.Is(err, backup.ErrExtractLimit) {
+		body["code"] = "extract-limit"
+		body["hint"] = "the backup decompresses to more than the server allows; convert it with the CLI instead"
+	}
+	return body
+}
+
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event string, data any) {
+	b, _ := json.Marshal(data)
+	_, _ = io.WriteString(w, "event: "+event+"\ndata: "+string(b)+"\n\n")
+	flusher.Flush()
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_, _ = w.Write([]byte(util.PrettyJSON(data)))
 }
 
-func withCORS(next http.Handler) http.Handler {
+func withCORS(next http.Handler, allowedOrigins []string) http.Handler {
+	allowAny := len(allowedOrigins) == 0
+	allowed := map[string]struct{}{}
+	for _, origin := range allowedOrigins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			allowAny = true
+		} else if origin != "" {
+			allowed[origin] = struct{}{}
+		}
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if allowAny {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if _, ok := allowed[origin]; ok {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		if r.Method == http.MethodOptions {
@@ -233,6 +432,7 @@ var indexHTML = `<!doctype html>
     pre { background:#0f1720; color:#e5f2f0; padding:12px; border-radius:10px; overflow:auto; min-height:120px; }
     .row { display:flex; gap:8px; align-items:center; }
     .row input[type=checkbox] { width:auto; }
+    progress { height: 10px; accent-color: var(--accent); }
   </style>
 </head>
 <body>
@@ -253,17 +453,24 @@ var indexHTML = `<!doctype html>
 
       <div class="card">
         <h3>Convert</h3>
-        <label>Source Zip</label>
-        <input id="srcFile" type="file" accept=".zip" />
+        <label>Source Zip (pick several to merge)</label>
+        <input id="srcFile" type="file" accept=".zip" multiple />
         <label>Template Zip (optional)</label>
         <input id="tmplFile" type="file" accept=".zip" />
         <label>From</label>
         <select id="from"><option value="auto">auto</option><option value="cherry">cherry</option><option value="rikka">rikka</option></select>
         <label>To</label>
         <select id="to"><option value="cherry">cherry</option><option value="rikka">rikka</option></select>
+        <label>Config Precedence (merge)</label>
+        <select id="configPrecedence"><option value="latest">latest</option><option value="first">first</option><option value="target">target</option><option value="source">source</option></select>
+        <label>Config Source Index (for source)</label>
+        <input id="configSourceIndex" type="number" min="1" value="1" />
         <div class="row"><input id="redact" type="checkbox" /><span>redact secrets</span></div>
         <div style="height:8px"></div>
         <button onclick="convert()">Convert & Download</button>
+        <div style="height:8px"></div>
+        <progress id="progress" max="1" value="0" style="width:100%"></progress>
+        <div id="stage" style="font-size:.85rem; opacity:.8"></div>
       </div>
     </div>
 
@@ -292,31 +499,64 @@ async function validate(){
 }
 
 async function convert(){
-  const src = document.getElementById('srcFile').files[0];
-  if(!src) return print('请选择 source zip');
+  const srcs = Array.from(document.getElementById('srcFile').files);
+  if(!srcs.length) return print('请选择 source zip');
   const tmpl = document.getElementById('tmplFile').files[0];
   const fd = new FormData();
-  fd.append('file', src);
+  srcs.forEach(f => fd.append('file', f));
   if(tmpl) fd.append('template', tmpl);
-  fd.append('from', document.getElementById('from').value);
+  // merging only detects formats automatically
+  fd.append('from', srcs.length > 1 ? 'auto' : document.getElementById('from').value);
   fd.append('to', document.getElementById('to').value);
+  if(srcs.length > 1){
+    fd.append('configPrecedence', document.getElementById('configPrecedence').value);
+    fd.append('configSourceIndex', document.getElementById('configSourceIndex').value);
+  }
   fd.append('redact', document.getElementById('redact').checked ? 'true' : 'false');
 
-  const r = await fetch('/api/convert',{method:'POST',body:fd});
+  const r = await fetch('/api/convert/stream',{method:'POST',body:fd});
   if(!r.ok){
     const e = await r.json();
     return print(e);
   }
-  const manifest = r.headers.get('X-Cherrikka-Manifest');
-  if(manifest){
-    try { print(JSON.parse(manifest)); } catch { print(manifest); }
+  const reader = r.body.getReader();
+  const decoder = new TextDecoder();
+  let buf = '';
+  for(;;){
+    const {value, done} = await reader.read();
+    if(done) break;
+    buf += decoder.decode(value, {stream:true});
+    let i;
+    while((i = buf.indexOf('\n\n')) >= 0){
+      const frame = buf.slice(0, i); buf = buf.slice(i + 2);
+      const event = (frame.match(/^event: (.*)$/m) || [])[1];
+      const data = JSON.parse((frame.match(/^data: (.*)$/m) || [])[1] || 'null');
+      handleEvent(event, data);
+    }
+  }
+}
+
+// stages: parse, merge, files, write, verify
+function handleEvent(event, data){
+  const bar = document.getElementById('progress');
+  const stage = document.getElementById('stage');
+  if(event === 'progress'){
+    bar.max = data.total || 1; bar.value = data.done;
+    stage.textContent = data.stage + ' ' + data.done + '/' + data.total;
+  } else if(event === 'error'){
+    stage.textContent = 'failed';
+    print(data);
+  } else if(event === 'done'){
+    bar.max = 1; bar.value = 1;
+    stage.textContent = 'done';
+    print(data.manifest);
+    const bytes = Uint8Array.from(atob(data.zip), c => c.charCodeAt(0));
+    const a = document.createElement('a');
+    a.href = URL.createObjectURL(new Blob([bytes], {type:'application/zip'}));
+    a.download = 'converted.zip';
+    a.click();
+    URL.revokeObjectURL(a.href);
   }
-  const blob = await r.blob();
-  const a = document.createElement('a');
-  a.href = URL.createObjectURL(blob);
-  a.download = 'converted.zip';
-  a.click();
-  URL.revokeObjectURL(a.href);
 }
 </script>
 </body>
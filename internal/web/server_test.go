@@ -0,0 +1,226 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cherrikka/internal/app"
+	"cherrikka/internal/backup"
+	"cherrikka/internal/ir"
+	"cherrikka/internal/rikka"
+	"cherrikka/internal/util"
+)
+
+func writeRikkaUpload(t *testing.T, assistantName string) []byte {
+	t.Helper()
+	in := &ir.BackupIR{
+		Assistants: []ir.IRAssistant{{ID: "assistant-1", Name: assistantName, Settings: map[string]any{}}},
+		Conversations: []ir.IRConversation{{
+			ID:          "conv-1",
+			AssistantID: "assistant-1",
+			Title:       "Hello",
+			Messages:    []ir.IRMessage{{ID: "msg-1", Role: "user", Parts: []ir.IRPart{{Type: "text", Content: "hi"}}}},
+		}},
+		Config: map[string]any{"rikka.settings": map[string]any{
+			"assistants": []any{map[string]any{"id": "assistant-1", "name": assistantName}},
+		}},
+	}
+	dir := t.TempDir()
+	if _, err := rikka.BuildFromIR(context.Background(), in, dir, "", false, map[string]string{}); err != nil {
+		t.Fatalf("build rikka: %v", err)
+	}
+	paths, err := util.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make([]backup.ZipEntry, 0, len(paths))
+	for _, rel := range paths {
+		entries = append(entries, backup.ZipEntry{Path: rel, SourcePath: filepath.Join(dir, filepath.FromSlash(rel))})
+	}
+	out := filepath.Join(t.TempDir(), "backup.zip")
+	if err := backup.WriteZip(context.Background(), out, entries); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHandleConvertMergesMultipleUploads(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, name := range []string{"phone", "tablet"} {
+		fw, err := mw.CreateFormFile("file", name+".zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(writeRikkaUpload(t, "Assistant "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = mw.WriteField("to", "cherry")
+	_ = mw.WriteField("configPrecedence", "source")
+	_ = mw.WriteField("configSourceIndex", "2")
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/convert", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handleConvert(rec, req, app.ExtractLimits{})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("convert status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var manifest ir.Manifest
+	if err := json.Unmarshal([]byte(rec.Header().Get("X-Cherrikka-Manifest")), &manifest); err != nil {
+		t.Fatalf("decode manifest header: %v", err)
+	}
+	if len(manifest.Sources) != 2 || manifest.Sources[0].Tag != "phone" || manifest.Sources[1].Tag != "tablet" {
+		t.Fatalf("expected both uploads merged, got=%+v", manifest.Sources)
+	}
+}
+
+func TestWithCORSAllowedOrigins(t *testing.T) {
+	var served int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := withCORS(next, []string{"https://app.example.com/"})
+	request := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/health", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodGet, "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" || rec.Header().Get("Vary") != "Origin" {
+		t.Fatalf("expected the allowed origin echoed with Vary, got origin=%q vary=%q", got, rec.Header().Get("Vary"))
+	}
+	rec = request(http.MethodGet, "https://evil.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no allow-origin for a disallowed origin, got %q", got)
+	}
+	if served != 2 {
+		t.Fatalf("expected both GET requests served, got %d", served)
+	}
+
+	rec = request(http.MethodOptions, "https://app.example.com")
+	if rec.Code != http.StatusNoContent || served != 2 {
+		t.Fatalf("expected preflight answered without calling the handler, got status=%d served=%d", rec.Code, served)
+	}
+	if rec.Header().Get("Access-Control-Allow-Methods") == "" || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("unexpected preflight headers: %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	withCORS(next, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("expected any origin allowed without a list, got %q", got)
+	}
+}
+
+type sseEvent struct {
+	name string
+	data map[string]any
+}
+
+// parseSSE splits a text/event-stream body into its "event:"/"data:" frames.
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	if !strings.HasSuffix(body, "\n\n") {
+		t.Fatalf("stream does not end with a complete frame: %q", body)
+	}
+	events := []sseEvent{}
+	for _, frame := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		lines := strings.Split(frame, "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("malformed frame: %q", frame)
+		}
+		ev := sseEvent{name: strings.TrimPrefix(lines[0], "event: ")}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &ev.data); err != nil {
+			t.Fatalf("frame data is not json: %q", frame)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func postConvertStream(t *testing.T, upload []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "phone.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(upload); err != nil {
+		t.Fatal(err)
+	}
+	_ = mw.WriteField("to", "cherry")
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/convert-stream", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handleConvertStream(rec, req, app.ExtractLimits{})
+	return rec
+}
+
+func TestHandleConvertStreamSendsProgressThenResult(t *testing.T) {
+	rec := postConvertStream(t, writeRikkaUpload(t, "Assistant"))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected stream response: status=%d content-type=%q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	events := parseSSE(t, rec.Body.String())
+	if len(events) < 2 {
+		t.Fatalf("expected progress events before the result, got %+v", events)
+	}
+	for _, ev := range events[:len(events)-1] {
+		if ev.name != "progress" || ev.data["stage"] == "" {
+			t.Fatalf("expected only progress events before the result, got %+v", ev)
+		}
+	}
+	last := events[len(events)-1]
+	if last.name != "done" {
+		t.Fatalf("expected a final done event, got %+v", last)
+	}
+	if manifest, _ := last.data["manifest"].(map[string]any); manifest["targetFormat"] != "cherry" {
+		t.Fatalf("expected the manifest in the result, got %v", last.data["manifest"])
+	}
+	zipBytes, err := base64.StdEncoding.DecodeString(last.data["zip"].(string))
+	if err != nil || !bytes.HasPrefix(zipBytes, []byte("PK")) {
+		t.Fatalf("expected a base64 zip in the result, err=%v", err)
+	}
+}
+
+func TestHandleConvertStreamReportsErrorEvent(t *testing.T) {
+	rec := postConvertStream(t, []byte("not a backup"))
+	events := parseSSE(t, rec.Body.String())
+	last := events[len(events)-1]
+	if last.name != "error" || last.data["error"] == "" {
+		t.Fatalf("expected a final error event, got %+v", events)
+	}
+	for _, ev := range events {
+		if ev.name == "done" {
+			t.Fatalf("no result expected after a failed conversion, got %+v", events)
+		}
+	}
+}